@@ -0,0 +1,54 @@
+//nolint:lll // we accept long lines for struct field tags used by kong
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dgsb/configlite"
+)
+
+// tagAliasPrefix prefixes the configuration names holding tag aliases.
+// The configuration tag_alias.wip holds the canonical tag wip is replaced by.
+const tagAliasPrefix = "tag_alias."
+
+// tagAliases returns the tag aliases stored in the configuration repository.
+func tagAliases(repo *configlite.Repository) (map[string]string, error) {
+	configs, err := repo.GetConfigs(appName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read tag aliases configuration: %w", err)
+	}
+
+	aliases := map[string]string{}
+	for name, value := range configs {
+		if alias := strings.TrimPrefix(name, tagAliasPrefix); alias != name && value != "" {
+			aliases[alias] = value
+		}
+	}
+	return aliases, nil
+}
+
+// setTagAlias stores in the configuration repository that alias must be
+// replaced by tag. An empty tag removes the alias.
+func setTagAlias(repo *configlite.Repository, alias, tag string) error {
+	if alias == tag {
+		return fmt.Errorf("%w: tag %s aliased to itself", errInvalidParameter, alias)
+	}
+
+	if err := repo.RegisterApplication(appName); err != nil {
+		return fmt.Errorf("cannot register application in configuration repository: %w", err)
+	}
+	if err := repo.UpsertConfig(appName, tagAliasPrefix+alias, tag); err != nil {
+		return fmt.Errorf("cannot store tag alias %s: %w", alias, err)
+	}
+	return nil
+}
+
+type AliasCmd struct {
+	From string `arg:"" help:"the tag to replace when starting, tagging or continuing an interval"`
+	To   string `arg:"" optional:"" help:"the canonical tag stored instead, remove the alias when not set"`
+}
+
+func (cmd *AliasCmd) Run(repo *configlite.Repository) error {
+	return setTagAlias(repo, cmd.From, cmd.To)
+}
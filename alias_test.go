@@ -0,0 +1,47 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgsb/configlite"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dgsb/tt/internal/db"
+)
+
+func TestTagAliases(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+
+	repo, err := configlite.New(filepath.Join(t.TempDir(), "config.db"))
+	require.NoError(t, err)
+	t.Cleanup(repo.Close)
+
+	require.NoError(t, setTagAlias(repo, "wip", "work-in-progress"))
+	require.NoError(t, setTagAlias(repo, "tmp", "temporary"))
+	require.NoError(t, setTagAlias(repo, "tmp", ""))
+	require.ErrorIs(t, setTagAlias(repo, "a", "a"), errInvalidParameter)
+
+	aliases, err := tagAliases(repo)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"wip": "work-in-progress"}, aliases)
+
+	tt, err := db.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, tt.Close()) })
+	require.NoError(t, configure(tt, repo))
+
+	require.NoError(t, tt.Start(at(10), []string{"wip"}))
+	require.NoError(t, tt.StopAt(at(11)))
+	require.NoError(t, tt.Start(at(11), []string{"work-in-progress"}))
+	require.NoError(t, tt.StopAt(at(13)))
+
+	intervals, err := tt.List(at(0), at(23))
+	require.NoError(t, err)
+	summaries, total := summarize(intervals, workHours{})
+	require.Equal(t, 3*time.Hour, total)
+	require.Equal(t, []tagSummary{{Tag: "work-in-progress", Count: 2, Duration: 3 * time.Hour}}, summaries)
+}
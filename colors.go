@@ -0,0 +1,117 @@
+//nolint:lll // we accept long lines for struct field tags used by kong
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dgsb/configlite"
+)
+
+// tagColorPrefix prefixes the configuration names holding tag colors.
+// The configuration tag_color.wip holds the color name of the wip tag.
+const tagColorPrefix = "tag_color."
+
+// ansiColors maps the supported color names to their ANSI foreground code.
+var ansiColors = map[string]int{
+	"black":   30,
+	"red":     31,
+	"green":   32,
+	"yellow":  33,
+	"blue":    34,
+	"magenta": 35,
+	"cyan":    36,
+	"white":   37,
+}
+
+// hashedColors are the ANSI foreground codes a tag without configured color
+// is displayed with. Black and white are left out as they may not be
+// readable on the terminal background.
+var hashedColors = []int{31, 32, 33, 34, 35, 36}
+
+// tagColors returns the tag colors stored in the configuration repository.
+func tagColors(repo *configlite.Repository) (map[string]string, error) {
+	configs, err := repo.GetConfigs(appName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read tag colors configuration: %w", err)
+	}
+
+	colors := map[string]string{}
+	for name, value := range configs {
+		if tag := strings.TrimPrefix(name, tagColorPrefix); tag != name && value != "" {
+			colors[tag] = value
+		}
+	}
+	return colors, nil
+}
+
+// setTagColor stores in the configuration repository the color tag is
+// displayed with. An empty color removes the configured color.
+func setTagColor(repo *configlite.Repository, tag, color string) error {
+	if _, ok := ansiColors[color]; !ok && color != "" {
+		return fmt.Errorf("%w: unknown color %s", errInvalidParameter, color)
+	}
+
+	if err := repo.RegisterApplication(appName); err != nil {
+		return fmt.Errorf("cannot register application in configuration repository: %w", err)
+	}
+	if err := repo.UpsertConfig(appName, tagColorPrefix+tag, color); err != nil {
+		return fmt.Errorf("cannot store tag color %s: %w", tag, err)
+	}
+	return nil
+}
+
+// colorizeTag wraps tag in the ANSI escape sequences of its configured color,
+// or of a color derived from its name when none is configured.
+func colorizeTag(tag string, colors map[string]string) string {
+	code, ok := ansiColors[colors[tag]]
+	if !ok {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(tag))
+		code = hashedColors[h.Sum32()%uint32(len(hashedColors))]
+	}
+	return fmt.Sprintf("\x1b[%dm%s\x1b[0m", code, tag)
+}
+
+// useColor tells if the reports written to out are colorized
+// for the given --color mode.
+func useColor(mode string, out *os.File) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	info, err := out.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+type TagColorCmd struct {
+	Tag   string `arg:"" optional:"" help:"the tag to configure, list the configured colors when not set"`
+	Color string `arg:"" optional:"" help:"the color the tag is displayed with, one of black, red, green, yellow, blue, magenta, cyan or white, remove the color when not set"`
+}
+
+func (cmd *TagColorCmd) Run(repo *configlite.Repository) error {
+	if cmd.Tag != "" {
+		return setTagColor(repo, cmd.Tag, cmd.Color)
+	}
+
+	colors, err := tagColors(repo)
+	if err != nil {
+		return err
+	}
+	tags := make([]string, 0, len(colors))
+	for tag := range colors {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		if _, err := fmt.Fprintf(os.Stdout, "%s %s\n", tag, colors[tag]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgsb/configlite"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dgsb/tt/internal/db"
+)
+
+func TestTagColors(t *testing.T) {
+	repo, err := configlite.New(filepath.Join(t.TempDir(), "config.db"))
+	require.NoError(t, err)
+	t.Cleanup(repo.Close)
+
+	require.NoError(t, setTagColor(repo, "work", "red"))
+	require.NoError(t, setTagColor(repo, "tmp", "blue"))
+	require.NoError(t, setTagColor(repo, "tmp", ""))
+	require.ErrorIs(t, setTagColor(repo, "a", "purple"), errInvalidParameter)
+
+	colors, err := tagColors(repo)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"work": "red"}, colors)
+
+	require.Equal(t, "\x1b[31mwork\x1b[0m", colorizeTag("work", colors))
+	require.Regexp(t, "^\x1b\\[3[1-6]mother\x1b\\[0m$", colorizeTag("other", colors))
+	require.Equal(t, colorizeTag("other", nil), colorizeTag("other", colors))
+
+	tas := []db.TaggedInterval{{
+		Interval: db.Interval{
+			ID:             "1",
+			StartTimestamp: time.Date(2022, 2, 25, 9, 0, 0, 0, time.UTC),
+			StopTimestamp:  time.Date(2022, 2, 25, 10, 0, 0, 0, time.UTC),
+		},
+		Tags: []string{"work", "other"},
+	}}
+
+	var out bytes.Buffer
+	require.NoError(t, FlatReport(tas, &out, FlatReportOptions{Color: true, TagColors: colors}))
+	require.Contains(t, out.String(), "\x1b[31mwork\x1b[0m,"+colorizeTag("other", colors))
+
+	out.Reset()
+	require.NoError(t, FlatReport(tas, &out, FlatReportOptions{
+		Color:     useColor("never", os.Stdout),
+		TagColors: colors,
+	}))
+	require.Contains(t, out.String(), "work,other")
+	require.NotContains(t, out.String(), "\x1b[")
+}
+
+func TestUseColor(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, f.Close()) })
+
+	require.True(t, useColor("always", f))
+	require.False(t, useColor("never", f))
+	require.False(t, useColor("auto", f))
+}
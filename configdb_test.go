@@ -0,0 +1,47 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dgsb/tt/internal/db"
+)
+
+func TestOpenConfigRepository(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.db")
+
+	repo, err := openConfigRepository(path)
+	require.NoError(t, err)
+	apps, err := repo.GetApps()
+	require.NoError(t, err)
+	require.Equal(t, []string{appName}, apps)
+	require.NoError(t, setDefaultTags(repo, []string{"me"}))
+	require.NoError(t, setTagAlias(repo, "wip", "work-in-progress"))
+	repo.Close()
+
+	// Another repository doesn't see the configuration
+	other, err := openConfigRepository(filepath.Join(t.TempDir(), "config.db"))
+	require.NoError(t, err)
+	t.Cleanup(other.Close)
+	tags, err := defaultTags(other)
+	require.NoError(t, err)
+	require.Empty(t, tags)
+
+	repo, err = openConfigRepository(path)
+	require.NoError(t, err)
+	t.Cleanup(repo.Close)
+
+	tt, err := db.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, tt.Close()) })
+	require.NoError(t, configure(tt, repo))
+
+	at := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, tt.Start(at, []string{"wip"}))
+	current, err := tt.Current()
+	require.NoError(t, err)
+	require.Equal(t, []string{"work-in-progress", "me"}, current.Tags)
+}
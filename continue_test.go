@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dgsb/tt/internal/db"
+)
+
+func TestContinueList(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.Local)
+	}
+
+	tt, err := db.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, tt.Close()) })
+	for idx, tags := range [][]string{{"a"}, {"b", "c"}, nil} {
+		require.NoError(t, tt.Start(at(10+idx), tags))
+		require.NoError(t, tt.StopAt(at(11+idx)))
+	}
+
+	tas, err := tt.RecentClosed(10)
+	require.NoError(t, err)
+
+	t.Run("listing", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, printRecentIntervals(tas, &out))
+		require.Equal(t, strings.Join([]string{
+			"1) id:3 2022-03-01 12:00 - 13:00",
+			"2) id:2 2022-03-01 11:00 - 12:00 b,c",
+			"3) id:1 2022-03-01 10:00 - 11:00 a",
+		}, "\n")+"\n", out.String())
+	})
+
+	t.Run("selection", func(t *testing.T) {
+		for answer, id := range map[string]string{"1\n": "3", " 2 \n": "2", "3": "1", "\n": ""} {
+			var out bytes.Buffer
+			got, err := selectInterval(tas, strings.NewReader(answer), &out)
+			require.NoError(t, err)
+			require.Equal(t, id, got, answer)
+			require.Equal(t, "Interval to continue [1-3]: ", out.String())
+		}
+
+		for _, answer := range []string{"0\n", "4\n", "a\n"} {
+			_, err := selectInterval(tas, strings.NewReader(answer), &bytes.Buffer{})
+			require.ErrorIs(t, err, errInvalidParameter, answer)
+		}
+	})
+
+	t.Run("continue selected interval", func(t *testing.T) {
+		id, err := selectInterval(tas, strings.NewReader("2\n"), &bytes.Buffer{})
+		require.NoError(t, err)
+		require.NoError(t, tt.Continue(at(14), id, nil))
+
+		current, err := tt.Current()
+		require.NoError(t, err)
+		require.Equal(t, []string{"b", "c"}, current.Tags)
+	})
+}
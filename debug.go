@@ -0,0 +1,72 @@
+//nolint:lll // we accept long lines for struct field tags used by kong
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/dgsb/tt/internal/db"
+)
+
+// DebugCmd groups the developer commands, they never touch the application database.
+type DebugCmd struct {
+	Replay DebugReplayCmd `cmd:"" help:"replay a logged sequence of start, stop and sync operations against fresh databases"`
+}
+
+type DebugReplayCmd struct {
+	File string `arg:"" type:"existingfile" help:"the JSON operation log, as reported by the synchronisation property test, - reads the standard input"`
+}
+
+func (cmd *DebugReplayCmd) Run() error {
+	var in io.Reader = os.Stdin
+	if cmd.File != "-" {
+		f, err := os.Open(cmd.File)
+		if err != nil {
+			return fmt.Errorf("cannot open replay log: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	steps, err := db.ReadReplayLog(in)
+	if err != nil {
+		return err
+	}
+
+	return replay(steps, os.Stdout)
+}
+
+// replay runs steps and writes the intervals of each resulting replica to out.
+func replay(steps []db.ReplayStep, out io.Writer) (ret error) {
+	replicas, err := db.Replay(steps)
+	if err != nil {
+		return fmt.Errorf("cannot replay operation log: %w", err)
+	}
+	defer func() {
+		for _, tt := range replicas {
+			if err := tt.Close(); err != nil {
+				ret = multierror.Append(ret, fmt.Errorf("cannot close replica: %w", err))
+			}
+		}
+	}()
+
+	until := steps[len(steps)-1].Timestamp.Add(time.Hour)
+	for idx, tt := range replicas {
+		intervals, err := tt.List(time.Time{}, until)
+		if err != nil {
+			return fmt.Errorf("cannot list intervals of replica %d: %w", idx, err)
+		}
+		if _, err := fmt.Fprintf(out, "replica %d\n", idx); err != nil {
+			return err
+		}
+		if err := CSVReport(intervals, out); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dgsb/tt/internal/db"
+)
+
+func TestReplay(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, replay([]db.ReplayStep{
+		{Operation: "start", DBIndex: 1, Timestamp: at(9)},
+		{Operation: "sync", DBIndex: 0, Timestamp: at(10)},
+	}, &out))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Equal(t, []string{
+		"replica 0",
+		"id,start,stop,duration_seconds,tags",
+		"1,2022-03-01T09:00:00Z,2022-03-01T10:00:00Z,3600,",
+		"replica 1",
+		"id,start,stop,duration_seconds,tags",
+		"1,2022-03-01T09:00:00Z,2022-03-01T10:00:00Z,3600,",
+	}, lines)
+
+	require.ErrorIs(t, replay([]db.ReplayStep{{Operation: "delete", Timestamp: at(9)}}, &out),
+		db.ErrInvalidParam)
+}
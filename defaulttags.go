@@ -0,0 +1,66 @@
+//nolint:lll // we accept long lines for struct field tags used by kong
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dgsb/configlite"
+)
+
+// defaultTagsConfig is the configuration name holding the comma separated
+// list of tags added to every started interval.
+const defaultTagsConfig = "default_tags"
+
+// defaultTags returns the default tags stored in the configuration repository.
+func defaultTags(repo *configlite.Repository) ([]string, error) {
+	value, err := repo.GetConfig(appName, defaultTagsConfig)
+	if err != nil && !errors.Is(err, configlite.ErrConfigNotFound) {
+		return nil, fmt.Errorf("cannot read default tags configuration: %w", err)
+	}
+	if value == "" {
+		return nil, nil
+	}
+	return strings.Split(value, ","), nil
+}
+
+// setDefaultTags stores the default tags in the configuration repository.
+// No tag removes the default tags.
+func setDefaultTags(repo *configlite.Repository, tags []string) error {
+	for _, tag := range tags {
+		if tag == "" || strings.Contains(tag, ",") {
+			return fmt.Errorf("%w: invalid default tag %q", errInvalidParameter, tag)
+		}
+	}
+
+	if err := repo.RegisterApplication(appName); err != nil {
+		return fmt.Errorf("cannot register application in configuration repository: %w", err)
+	}
+	if err := repo.UpsertConfig(appName, defaultTagsConfig, strings.Join(tags, ",")); err != nil {
+		return fmt.Errorf("cannot store default tags: %w", err)
+	}
+	return nil
+}
+
+type DefaultTagsCmd struct {
+	Clear bool     `help:"remove the default tags"`
+	Tags  []string `arg:"" optional:"" help:"the tags added to every started interval, print the current ones when not set"`
+}
+
+func (cmd *DefaultTagsCmd) Run(repo *configlite.Repository) error {
+	if cmd.Clear || len(cmd.Tags) > 0 {
+		return setDefaultTags(repo, cmd.Tags)
+	}
+
+	tags, err := defaultTags(repo)
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	_, err = fmt.Fprintln(os.Stdout, strings.Join(tags, " "))
+	return err
+}
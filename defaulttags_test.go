@@ -0,0 +1,57 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgsb/configlite"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dgsb/tt/internal/db"
+	itime "github.com/dgsb/tt/internal/time"
+)
+
+func TestDefaultTags(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+
+	repo, err := configlite.New(filepath.Join(t.TempDir(), "config.db"))
+	require.NoError(t, err)
+	t.Cleanup(repo.Close)
+
+	tags, err := defaultTags(repo)
+	require.NoError(t, err)
+	require.Empty(t, tags)
+
+	require.ErrorIs(t, setDefaultTags(repo, []string{"a,b"}), errInvalidParameter)
+	require.NoError(t, setDefaultTags(repo, []string{"me", "team"}))
+	tags, err = defaultTags(repo)
+	require.NoError(t, err)
+	require.Equal(t, []string{"me", "team"}, tags)
+
+	tt, err := db.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, tt.Close()) })
+	require.NoError(t, configure(tt, repo))
+
+	start := StartCmd{At: itime.Time(at(10)), Tags: []string{"a", "me"}}
+	require.NoError(t, start.Run(tt))
+	require.NoError(t, tt.StopAt(at(11)))
+
+	start = StartCmd{At: itime.Time(at(11)), Tags: []string{"b"}, NoDefaultTags: true}
+	require.NoError(t, start.Run(tt))
+	require.NoError(t, tt.StopAt(at(12)))
+
+	intervals, err := tt.List(at(0), at(23))
+	require.NoError(t, err)
+	require.Len(t, intervals, 2)
+	require.Equal(t, []string{"a", "me", "team"}, intervals[0].Tags)
+	require.Equal(t, []string{"b"}, intervals[1].Tags)
+
+	require.NoError(t, setDefaultTags(repo, nil))
+	tags, err = defaultTags(repo)
+	require.NoError(t, err)
+	require.Empty(t, tags)
+}
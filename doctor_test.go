@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dgsb/tt/internal/db"
+)
+
+func TestRepairMigrations(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tt.db")
+	tt, err := db.New(file)
+	require.NoError(t, err)
+	require.NoError(t, tt.Close())
+
+	raw, err := sql.Open("sqlite3", file)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, raw.Close()) })
+	_, err = raw.Exec(`UPDATE darwin_migrations SET checksum = 'tampered' WHERE version = 1`)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	cmd := DoctorCmd{RepairMigrations: true}
+	require.ErrorIs(t, cmd.repairMigrations(file, strings.NewReader("y\n"), &out), errInvalidParameter)
+
+	cmd.IUnderstand = true
+	require.NoError(t, cmd.repairMigrations(file, strings.NewReader("n\n"), &out))
+	_, err = db.New(file)
+	require.ErrorIs(t, err, db.ErrMigrationChecksum)
+
+	out.Reset()
+	require.NoError(t, cmd.repairMigrations(file, strings.NewReader("y\n"), &out))
+	require.Contains(t, out.String(), "repaired migration version 1\n")
+
+	tt, err = db.New(file)
+	require.NoError(t, err)
+	require.NoError(t, tt.Close())
+}
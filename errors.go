@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/dgsb/tt/internal/db"
+)
+
+// errorCodes maps the errors tooling may want to react on to a stable code
+// and a process exit status. The first matching entry wins.
+var errorCodes = []struct {
+	err    error
+	code   string
+	status int
+}{
+	{errInvalidParameter, "ErrInvalidParam", 2},
+	{db.ErrInvalidParam, "ErrInvalidParam", 2},
+	{db.ErrNotFound, "ErrNotFound", 3},
+	{db.ErrExistingOpenInterval, "ErrExistingOpenInterval", 4},
+	{db.ErrNoOpenInterval, "ErrNoOpenInterval", 5},
+	{db.ErrMultipleOpenInterval, "ErrMultipleOpenInterval", 6},
+	{db.ErrIntervalLocked, "ErrIntervalLocked", 7},
+	{db.ErrOverlappingInterval, "ErrOverlappingInterval", 8},
+	{db.ErrInvalidStartTimestamp, "ErrInvalidStartTimestamp", 9},
+	{db.ErrInvalidStopTimestamp, "ErrInvalidStopTimestamp", 10},
+	{db.ErrInvalidInterval, "ErrInvalidInterval", 11},
+	{db.ErrInvalidTag, "ErrInvalidTag", 12},
+	{db.ErrDuplicatedIntervalTag, "ErrDuplicatedIntervalTag", 13},
+	{db.ErrClockSkew, "ErrClockSkew", 14},
+	{db.ErrNotImplemented, "ErrNotImplemented", 15},
+	{errSanityCheck, "ErrSanityCheck", 16},
+	{db.ErrTooManyTags, "ErrTooManyTags", 17},
+}
+
+// errorCode returns the code and exit status mapped to err.
+// Unknown errors are reported as ErrUnknown with the exit status 1.
+func errorCode(err error) (code string, status int) {
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			return c.code, c.status
+		}
+	}
+	return "ErrUnknown", 1
+}
+
+// writeJSONError writes err as a JSON object to out and returns
+// the exit status mapped to err.
+func writeJSONError(out io.Writer, err error) int {
+	code, status := errorCode(err)
+	// There is nothing much to do if the error cannot be reported.
+	_ = json.NewEncoder(out).Encode(struct {
+		Error string `json:"error"`
+		Code  string `json:"code"`
+	}{err.Error(), code})
+	return status
+}
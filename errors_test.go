@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dgsb/tt/internal/db"
+)
+
+func TestWriteJSONError(t *testing.T) {
+	tt, err := db.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, tt.Close()) })
+
+	for _, tc := range []struct {
+		name   string
+		err    error
+		code   string
+		status int
+	}{
+		{
+			name:   "not found",
+			err:    fmt.Errorf("cannot tag interval: %w", tt.Tag("42", []string{"a"})),
+			code:   "ErrNotFound",
+			status: 3,
+		},
+		{
+			name:   "no opened interval",
+			err:    fmt.Errorf("cannot stop: %w", tt.StopAt(time.Now())),
+			code:   "ErrNoOpenInterval",
+			status: 5,
+		},
+		{
+			name:   "unknown",
+			err:    fmt.Errorf("something went wrong"),
+			code:   "ErrUnknown",
+			status: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			require.Equal(t, tc.status, writeJSONError(&out, tc.err))
+
+			var got struct {
+				Error string `json:"error"`
+				Code  string `json:"code"`
+			}
+			require.NoError(t, json.Unmarshal(out.Bytes(), &got))
+			require.Equal(t, tc.code, got.Code)
+			require.Equal(t, tc.err.Error(), got.Error)
+		})
+	}
+}
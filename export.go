@@ -0,0 +1,148 @@
+//nolint:lll // we accept long lines for struct field tags used by kong
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgsb/tt/internal/db"
+	itime "github.com/dgsb/tt/internal/time"
+)
+
+// exportedInterval is the JSON representation of an exported interval.
+// It can be imported back, the id being ignored.
+type exportedInterval struct {
+	ID    string    `json:"id"`
+	Start time.Time `json:"start"`
+	Stop  time.Time `json:"stop"`
+	Tags  []string  `json:"tags"`
+}
+
+type ExportCmd struct {
+	Format    string     `help:"the output format" default:"json" enum:"json,csv"`
+	Since     itime.Time `help:"export the intervals started or stopped after this timestamp"`
+	Until     itime.Time `help:"export the intervals started or stopped before this timestamp"`
+	Anonymize bool       `help:"replace each tag with a stable pseudonym like tag1, tag2..."`
+	Tag       []string   `help:"export only the intervals carrying all these tags"`
+}
+
+func (cmd *ExportCmd) Run(tt *db.TimeTracker) error {
+	until := cmd.Until.Time()
+	if until.IsZero() {
+		until = time.Now().Add(time.Hour)
+	}
+
+	tas, err := tt.ListFiltered(cmd.Since.Time(), until, db.ListFilter{Tags: cmd.Tag})
+	if err != nil {
+		return fmt.Errorf("cannot list recorded interval: %w", err)
+	}
+	if cmd.Anonymize {
+		tas = anonymizeTags(tas)
+	}
+
+	if cmd.Format == "csv" {
+		return exportCSV(tas, os.Stdout)
+	}
+	return exportJSON(tas, os.Stdout)
+}
+
+// anonymizeTags returns a copy of tas in which each distinct tag is replaced
+// with a pseudonym. Pseudonyms are numbered after the sorted list of the
+// distinct tags so that the same intervals are always anonymized the same way.
+// Only tags are replaced, timestamps and thus durations are kept as is.
+func anonymizeTags(tas []db.TaggedInterval) []db.TaggedInterval {
+	seen := map[string]bool{}
+	var names []string
+	for _, ta := range tas {
+		for _, tag := range ta.Tags {
+			if !seen[tag] {
+				seen[tag] = true
+				names = append(names, tag)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	pseudonyms := make(map[string]string, len(names))
+	for idx, name := range names {
+		pseudonyms[name] = fmt.Sprintf("tag%d", idx+1)
+	}
+
+	anonymized := make([]db.TaggedInterval, 0, len(tas))
+	for _, ta := range tas {
+		var tags []string
+		for _, tag := range ta.Tags {
+			tags = append(tags, pseudonyms[tag])
+		}
+		ta.Tags = tags
+		anonymized = append(anonymized, ta)
+	}
+	return anonymized
+}
+
+// exportJSON writes the intervals as a JSON array. Tags are written as is.
+// The opened interval is skipped so that the export can be imported back.
+func exportJSON(tas []db.TaggedInterval, out io.Writer) error {
+	intervals := make([]exportedInterval, 0, len(tas))
+	for _, ta := range tas {
+		if ta.Interval.StopTimestamp.IsZero() {
+			continue
+		}
+		itv := exportedInterval{
+			ID:    ta.Interval.ID,
+			Start: ta.Interval.StartTimestamp,
+			Stop:  ta.Interval.StopTimestamp,
+			Tags:  ta.Tags,
+		}
+		if itv.Tags == nil {
+			itv.Tags = []string{}
+		}
+		intervals = append(intervals, itv)
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(intervals); err != nil {
+		return fmt.Errorf("cannot encode exported intervals: %w", err)
+	}
+	return nil
+}
+
+// exportCSV writes the intervals as CSV records with a header line.
+// Tags are joined with commas in a single field, which is unambiguous
+// as tags cannot hold commas. The stop field is empty for the opened interval.
+func exportCSV(tas []db.TaggedInterval, out io.Writer) error {
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"id", "start", "stop", "duration_seconds", "tags"}); err != nil {
+		return fmt.Errorf("cannot write csv header: %w", err)
+	}
+
+	for _, ta := range tas {
+		var stop string
+		if !ta.Interval.StopTimestamp.IsZero() {
+			stop = ta.Interval.StopTimestamp.Format(time.RFC3339)
+		}
+		if err := w.Write([]string{
+			ta.Interval.ID,
+			ta.Interval.StartTimestamp.Format(time.RFC3339),
+			stop,
+			strconv.FormatInt(int64(intervalDuration(ta).Seconds()), 10),
+			strings.Join(ta.Tags, ","),
+		}); err != nil {
+			return fmt.Errorf("cannot write csv record: %w", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("cannot flush csv records: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dgsb/tt/internal/db"
+)
+
+func TestExport(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+	tags := [][]string{
+		{"client meeting", "billable"},
+		{"réunion équipe", "日本語", `say "hi"`},
+		nil,
+	}
+
+	source, err := db.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, source.Close()) })
+	for idx, tt := range tags {
+		require.NoError(t, source.Start(at(10+idx), tt))
+		require.NoError(t, source.StopAt(at(11+idx)))
+	}
+	require.ErrorIs(t, source.Start(at(15), []string{"a,b"}), db.ErrInvalidTag)
+
+	tas, err := source.List(at(0), at(23))
+	require.NoError(t, err)
+
+	t.Run("json round trip", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, exportJSON(tas, &out))
+
+		target, err := db.New(":memory:")
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, target.Close()) })
+		require.NoError(t, importJSON(target, &out, nil))
+
+		imported, err := target.List(at(0), at(23))
+		require.NoError(t, err)
+		require.Len(t, imported, len(tags))
+		for idx := range tags {
+			require.Equal(t, tags[idx], imported[idx].Tags)
+			require.True(t, imported[idx].StartTimestamp.Equal(at(10+idx)))
+			require.True(t, imported[idx].StopTimestamp.Equal(at(11+idx)))
+		}
+	})
+
+	t.Run("json round trip with the running interval", func(t *testing.T) {
+		running := append(tas[:len(tas):len(tas)], db.TaggedInterval{
+			Interval: db.Interval{ID: "4", StartTimestamp: at(16)},
+			Tags:     []string{"running"},
+		})
+		var out bytes.Buffer
+		require.NoError(t, exportJSON(running, &out))
+
+		target, err := db.New(":memory:")
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, target.Close()) })
+		require.NoError(t, importJSON(target, &out, nil))
+
+		imported, err := target.List(at(0), at(23))
+		require.NoError(t, err)
+		require.Len(t, imported, len(tags))
+		current, err := target.Current()
+		require.NoError(t, err)
+		require.Nil(t, current)
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, exportCSV(tas, &out))
+
+		records, err := csv.NewReader(&out).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, len(tags)+1)
+		require.Equal(t, []string{"id", "start", "stop", "duration_seconds", "tags"}, records[0])
+		for idx := range tags {
+			require.Equal(t, "3600", records[idx+1][3])
+			var got []string
+			if records[idx+1][4] != "" {
+				got = strings.Split(records[idx+1][4], ",")
+			}
+			require.Equal(t, tags[idx], got)
+		}
+	})
+
+	t.Run("anonymize", func(t *testing.T) {
+		anonymized := anonymizeTags(append(tas[:len(tas):len(tas)], tas[0]))
+		require.Len(t, anonymized, len(tas)+1)
+		require.Equal(t, []string{"tag2", "tag1"}, anonymized[0].Tags)
+		require.Equal(t, []string{"tag3", "tag5", "tag4"}, anonymized[1].Tags)
+		require.Nil(t, anonymized[2].Tags)
+		require.Equal(t, anonymized[0].Tags, anonymized[3].Tags)
+		for idx, ta := range tas {
+			require.Equal(t, intervalDuration(ta), intervalDuration(anonymized[idx]))
+			require.Equal(t, ta.Interval, anonymized[idx].Interval)
+		}
+		require.Equal(t, []string{"client meeting", "billable"}, tas[0].Tags)
+	})
+
+	t.Run("tag filter", func(t *testing.T) {
+		filter := db.ListFilter{Tags: []string{"billable"}}
+		filtered, err := source.ListFiltered(at(0), at(23), filter)
+		require.NoError(t, err)
+
+		var out bytes.Buffer
+		require.NoError(t, exportJSON(filtered, &out))
+		var exported []exportedInterval
+		require.NoError(t, json.Unmarshal(out.Bytes(), &exported))
+		require.Len(t, exported, 1)
+		require.Equal(t, tags[0], exported[0].Tags)
+		require.True(t, exported[0].Start.Equal(at(10)))
+
+		out.Reset()
+		require.NoError(t, exportCSV(filtered, &out))
+		records, err := csv.NewReader(&out).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		require.Equal(t, strings.Join(tags[0], ","), records[1][4])
+	})
+}
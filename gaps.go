@@ -0,0 +1,161 @@
+//nolint:lll // we accept long lines for struct field tags used by kong
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/dgsb/tt/internal/db"
+	itime "github.com/dgsb/tt/internal/time"
+)
+
+// gap is a period of time no interval has been recorded for.
+type gap struct {
+	Start time.Time
+	Stop  time.Time
+}
+
+// findGaps returns the gaps between from and until not covered by any of
+// the intervals, in chronological order. The opened interval is considered
+// as stopping at now and nothing after now is reported. Gaps shorter than
+// minDuration are left out. Intervals are expected to be sorted by start timestamp.
+func findGaps(tas []db.TaggedInterval, from, until, now time.Time, minDuration time.Duration) []gap {
+	if now.Before(until) {
+		until = now
+	}
+
+	var gaps []gap
+	cursor := from
+	add := func(stop time.Time) {
+		if stop.After(until) {
+			stop = until
+		}
+		if stop.Sub(cursor) > 0 && stop.Sub(cursor) >= minDuration {
+			gaps = append(gaps, gap{Start: cursor, Stop: stop})
+		}
+	}
+
+	for _, ta := range tas {
+		stop := ta.StopTimestamp
+		if stop.IsZero() {
+			stop = now
+		}
+		if ta.StartTimestamp.After(cursor) {
+			add(ta.StartTimestamp)
+		}
+		if stop.After(cursor) {
+			cursor = stop
+		}
+	}
+	add(until)
+
+	return gaps
+}
+
+// gapsBetween returns the gaps between consecutive intervals, in chronological
+// order, leaving out the ones shorter than minDuration. The opened interval is not
+// followed by any interval hence it ends the gaps. Intervals are expected
+// to be sorted by start timestamp.
+func gapsBetween(tas []db.TaggedInterval, minDuration time.Duration) []gap {
+	var (
+		gaps   []gap
+		cursor time.Time
+	)
+	for _, ta := range tas {
+		if !cursor.IsZero() && ta.StartTimestamp.After(cursor) &&
+			ta.StartTimestamp.Sub(cursor) >= minDuration {
+			gaps = append(gaps, gap{Start: cursor, Stop: ta.StartTimestamp})
+		}
+		if ta.StopTimestamp.IsZero() {
+			break
+		}
+		if ta.StopTimestamp.After(cursor) {
+			cursor = ta.StopTimestamp
+		}
+	}
+	return gaps
+}
+
+// GapsReport prints the gaps between consecutive intervals of tas
+// not shorter than minDuration, one per line.
+func GapsReport(tas []db.TaggedInterval, out io.Writer, minDuration time.Duration) error {
+	return writeGaps(gapsBetween(tas, minDuration), out)
+}
+
+// writeGaps prints the start, stop and duration of each gap, one per line.
+func writeGaps(gaps []gap, out io.Writer) error {
+	for _, g := range gaps {
+		start, stop := g.Start.Format(time.RFC3339), g.Stop.Format(time.RFC3339)
+		if _, err := fmt.Fprintf(out, "%s %s %s\n", start, stop, g.Stop.Sub(g.Start)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type GapsCmd struct {
+	At      periodAnchor   `help:"another starting point for the required time period instead of now, or the day, month or year itself like 2023-02"`
+	Min     itime.Duration `help:"ignore the gaps shorter than this duration, when reporting as well as filling" default:"1m"`
+	Between bool           `help:"only report the gaps between two intervals, not the ones at the bounds of the period"`
+	Fill    bool           `help:"record a closed interval covering each gap"`
+	Tag     string         `help:"the tag of the intervals filling the gaps" default:"break"`
+	Yes     bool           `short:"y" help:"do not ask for confirmation before filling the gaps"`
+	Period  string         `arg:"" help:"a logical description of the time period to look at" default:":day" enum:":week,:day,:month,:year"`
+}
+
+func (cmd *GapsCmd) Run(tt *db.TimeTracker, offset dayStart) error {
+	startTime, stopTime, err := cmd.At.bounds(cmd.Period, offset)
+	if err != nil {
+		return err
+	}
+
+	taggedIntervals, err := tt.List(startTime, stopTime)
+	if err != nil {
+		return fmt.Errorf("cannot list recorded interval: %w", err)
+	}
+
+	var gaps []gap
+	if cmd.Between {
+		gaps = gapsBetween(taggedIntervals, cmd.Min.Duration())
+	} else {
+		// Timestamps are recorded with a second precision
+		now := time.Now().Truncate(time.Second)
+		gaps = findGaps(taggedIntervals, startTime, stopTime, now, cmd.Min.Duration())
+	}
+	if err := writeGaps(gaps, os.Stdout); err != nil {
+		return err
+	}
+	if !cmd.Fill || len(gaps) == 0 {
+		return nil
+	}
+
+	if !cmd.Yes {
+		ok, err := confirm(os.Stdin, os.Stdout,
+			fmt.Sprintf("%d intervals tagged %s will be recorded.", len(gaps), cmd.Tag))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+	}
+
+	if err := tt.Import(gapIntervals(gaps, cmd.Tag), nil); err != nil {
+		return fmt.Errorf("cannot fill gaps: %w", err)
+	}
+	return nil
+}
+
+// gapIntervals returns the closed intervals tagged with tag covering gaps.
+func gapIntervals(gaps []gap, tag string) []db.TaggedInterval {
+	intervals := make([]db.TaggedInterval, 0, len(gaps))
+	for _, g := range gaps {
+		intervals = append(intervals, db.TaggedInterval{
+			Interval: db.Interval{StartTimestamp: g.Start, StopTimestamp: g.Stop},
+			Tags:     []string{tag},
+		})
+	}
+	return intervals
+}
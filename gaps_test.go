@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dgsb/tt/internal/db"
+)
+
+func TestGaps(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2022, 3, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	tt, err := db.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, tt.Close()) })
+
+	for _, itv := range [][2]time.Time{
+		{at(9, 0), at(10, 0)},
+		{at(10, 5), at(12, 0)},
+		{at(13, 0), at(15, 0)},
+	} {
+		require.NoError(t, tt.Start(itv[0], []string{"work"}))
+		require.NoError(t, tt.StopAt(itv[1]))
+	}
+	// Overlapping interval in another context
+	tt.SetContext("support")
+	require.NoError(t, tt.Start(at(14, 0), []string{"ticket"}))
+	require.NoError(t, tt.StopAt(at(16, 0)))
+	tt.SetContext("")
+	require.NoError(t, tt.Start(at(17, 0), []string{"work"}))
+
+	// Timestamps are compared in UTC as listed intervals are in the local time zone
+	utc := func(gaps []gap) []gap {
+		for idx := range gaps {
+			gaps[idx] = gap{gaps[idx].Start.UTC(), gaps[idx].Stop.UTC()}
+		}
+		return gaps
+	}
+
+	from, until, now := at(8, 0), at(20, 0), at(18, 0)
+	tas, err := tt.List(from, until)
+	require.NoError(t, err)
+
+	require.Equal(t, []gap{
+		{at(8, 0), at(9, 0)},
+		{at(10, 0), at(10, 5)},
+		{at(12, 0), at(13, 0)},
+		{at(16, 0), at(17, 0)},
+	}, utc(findGaps(tas, from, until, now, 0)))
+
+	gaps := utc(findGaps(tas, from, until, now, 10*time.Minute))
+	require.Equal(t, []gap{
+		{at(8, 0), at(9, 0)},
+		{at(12, 0), at(13, 0)},
+		{at(16, 0), at(17, 0)},
+	}, gaps)
+
+	require.NoError(t, tt.StopAt(now))
+	require.NoError(t, tt.Import(gapIntervals(gaps, "break"), nil))
+	tas, err = tt.List(from, until)
+	require.NoError(t, err)
+	require.Len(t, tas, 8)
+	require.Equal(t, []gap{{at(10, 0), at(10, 5)}}, utc(findGaps(tas, from, until, now, 0)))
+
+	var breaks []gap
+	for _, ta := range tas {
+		if len(ta.Tags) == 1 && ta.Tags[0] == "break" {
+			breaks = append(breaks, gap{ta.StartTimestamp, ta.StopTimestamp})
+		}
+	}
+	require.Equal(t, gaps, utc(breaks))
+}
+
+func TestGapsReport(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2022, 3, 1, hour, minute, 0, 0, time.UTC)
+	}
+	interval := func(start, stop time.Time) db.TaggedInterval {
+		return db.TaggedInterval{Interval: db.Interval{StartTimestamp: start, StopTimestamp: stop}}
+	}
+
+	tas := []db.TaggedInterval{
+		interval(at(9, 0), at(10, 0)),
+		// adjacent interval, no gap
+		interval(at(10, 0), at(11, 0)),
+		// sub-threshold gap
+		interval(at(11, 0).Add(30*time.Second), at(12, 0)),
+		// large gap
+		interval(at(14, 0), at(15, 0)),
+		// opened interval, nothing follows it
+		interval(at(16, 0), time.Time{}),
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, GapsReport(tas, &out, time.Minute))
+	require.Equal(t,
+		"2022-03-01T12:00:00Z 2022-03-01T14:00:00Z 2h0m0s\n"+
+			"2022-03-01T15:00:00Z 2022-03-01T16:00:00Z 1h0m0s\n",
+		out.String())
+
+	out.Reset()
+	require.NoError(t, GapsReport(tas, &out, 0))
+	require.Equal(t,
+		"2022-03-01T11:00:00Z 2022-03-01T11:00:30Z 30s\n"+
+			"2022-03-01T12:00:00Z 2022-03-01T14:00:00Z 2h0m0s\n"+
+			"2022-03-01T15:00:00Z 2022-03-01T16:00:00Z 1h0m0s\n",
+		out.String())
+
+	out.Reset()
+	require.NoError(t, GapsReport(tas[4:], &out, 0))
+	require.Empty(t, out.String())
+}
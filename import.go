@@ -0,0 +1,191 @@
+//nolint:lll // we accept long lines for struct field tags used by kong
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dgsb/tt/internal/db"
+	itime "github.com/dgsb/tt/internal/time"
+)
+
+// importedInterval is the JSON representation of an interval to import.
+type importedInterval struct {
+	Start time.Time `json:"start"`
+	Stop  time.Time `json:"stop"`
+	Tags  []string  `json:"tags"`
+}
+
+type ImportCmd struct {
+	Format          string `enum:"json,csv" default:"json" help:"the format of the imported file: json or csv"`
+	File            string `arg:"" type:"existingfile" help:"the file holding the intervals to import, - reads the standard input"`
+	ConfirmOverlaps bool   `help:"interactively ask how to resolve each overlap instead of failing" xor:"overlap"`
+	MergeTags       bool   `name:"merge-tags" help:"add the tags of an imported interval to the recorded one with the same start and stop instead of failing" xor:"overlap"`
+}
+
+func (cmd *ImportCmd) Run(tt *db.TimeTracker) error {
+	var in io.Reader = os.Stdin
+	if cmd.File != "-" {
+		f, err := os.Open(cmd.File)
+		if err != nil {
+			return fmt.Errorf("cannot open import file: %w", err)
+		}
+		defer f.Close()
+		in = f
+	} else if cmd.ConfirmOverlaps {
+		return fmt.Errorf(
+			"%w: overlaps cannot be confirmed while reading intervals from the standard input", errInvalidParameter)
+	}
+
+	var resolve db.OverlapResolver
+	if cmd.ConfirmOverlaps {
+		resolve = promptResolver(os.Stdin, os.Stdout)
+	} else if cmd.MergeTags {
+		resolve = func(db.TaggedInterval, db.Interval) (db.OverlapResolution, error) {
+			return db.OverlapMergeTags, nil
+		}
+	}
+
+	if cmd.Format == "csv" {
+		return importCSV(tt, in, resolve)
+	}
+	return importJSON(tt, in, resolve)
+}
+
+// importJSON decodes a JSON array of intervals from r and imports them.
+func importJSON(tt *db.TimeTracker, r io.Reader, resolve db.OverlapResolver) error {
+	var payload []importedInterval
+	if err := json.NewDecoder(r).Decode(&payload); err != nil {
+		return fmt.Errorf("cannot decode imported intervals: %w", err)
+	}
+
+	intervals := make([]db.TaggedInterval, 0, len(payload))
+	for _, itv := range payload {
+		intervals = append(intervals, db.TaggedInterval{
+			Interval: db.Interval{StartTimestamp: itv.Start, StopTimestamp: itv.Stop},
+			Tags:     itv.Tags,
+		})
+	}
+
+	if err := tt.Import(intervals, resolve); err != nil {
+		return fmt.Errorf("cannot import intervals: %w", err)
+	}
+
+	return nil
+}
+
+// importCSV reads start,stop,tags rows from r and imports them as closed intervals.
+// Tags are separated by semicolons. A first row starting with a "start" field is
+// considered as a header and ignored. Failures report the offending line number.
+func importCSV(tt *db.TimeTracker, r io.Reader, resolve db.OverlapResolver) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var (
+		intervals []db.TaggedInterval
+		lines     []int
+	)
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read imported intervals: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		if first && strings.EqualFold(strings.TrimSpace(record[0]), "start") {
+			continue
+		}
+		itv, err := parseCSVInterval(record)
+		if err != nil {
+			return fmt.Errorf("cannot parse imported interval on line %d: %w", line, err)
+		}
+		intervals = append(intervals, itv)
+		lines = append(lines, line)
+	}
+
+	if err := tt.Import(intervals, resolve); err != nil {
+		var importErr *db.ImportError
+		if errors.As(err, &importErr) {
+			return fmt.Errorf("cannot import interval on line %d: %w", lines[importErr.Index], importErr.Err)
+		}
+		return fmt.Errorf("cannot import intervals: %w", err)
+	}
+
+	return nil
+}
+
+// parseCSVInterval builds an interval from a start,stop,tags record.
+func parseCSVInterval(record []string) (db.TaggedInterval, error) {
+	if len(record) < 2 || len(record) > 3 {
+		return db.TaggedInterval{}, fmt.Errorf("%w: %d fields found, expected start,stop,tags",
+			errInvalidParameter, len(record))
+	}
+
+	var start, stop itime.Time
+	if err := start.UnmarshalText([]byte(strings.TrimSpace(record[0]))); err != nil {
+		return db.TaggedInterval{}, fmt.Errorf("cannot parse start timestamp: %w", err)
+	}
+	if err := stop.UnmarshalText([]byte(strings.TrimSpace(record[1]))); err != nil {
+		return db.TaggedInterval{}, fmt.Errorf("cannot parse stop timestamp: %w", err)
+	}
+
+	var tags []string
+	if len(record) == 3 {
+		for _, tag := range strings.Split(record[2], ";") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	return db.TaggedInterval{
+		Interval: db.Interval{StartTimestamp: time.Time(start), StopTimestamp: time.Time(stop)},
+		Tags:     tags,
+	}, nil
+}
+
+// promptResolver returns an overlap resolver asking the user on out
+// and reading the decision from in.
+func promptResolver(in io.Reader, out io.Writer) db.OverlapResolver {
+	scanner := bufio.NewScanner(in)
+	return func(imported db.TaggedInterval, existing db.Interval) (db.OverlapResolution, error) {
+		stop := "now"
+		if !existing.StopTimestamp.IsZero() {
+			stop = existing.StopTimestamp.Format(time.RFC3339)
+		}
+		fmt.Fprintf(out, "%s - %s %v overlaps interval %s (%s - %s)\n",
+			imported.StartTimestamp.Format(time.RFC3339),
+			imported.StopTimestamp.Format(time.RFC3339),
+			imported.Tags, existing.ID,
+			existing.StartTimestamp.Format(time.RFC3339), stop)
+
+		for {
+			fmt.Fprint(out, "[s]kip, s[n]ap or [a]bort? ")
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return db.OverlapAbort, fmt.Errorf("cannot read answer: %w", err)
+				}
+				return db.OverlapAbort, nil
+			}
+			switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+			case "s", "skip":
+				return db.OverlapSkip, nil
+			case "n", "snap":
+				return db.OverlapSnap, nil
+			case "a", "abort":
+				return db.OverlapAbort, nil
+			}
+		}
+	}
+}
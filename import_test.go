@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dgsb/tt/internal/db"
+)
+
+func TestImportJSON(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+
+	tt, err := db.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, tt.Close()) })
+
+	require.NoError(t, tt.Start(at(10), []string{"existing"}))
+	require.NoError(t, tt.StopAt(at(11)))
+	require.NoError(t, tt.Start(at(14), []string{"existing"}))
+	require.NoError(t, tt.StopAt(at(15)))
+
+	payload := `[
+		{"start": "2022-03-01T10:30:00Z", "stop": "2022-03-01T11:30:00Z", "tags": ["a"]},
+		{"start": "2022-03-01T13:00:00Z", "stop": "2022-03-01T14:30:00Z", "tags": ["b"]}
+	]`
+
+	t.Run("fail fast", func(t *testing.T) {
+		require.ErrorIs(t, importJSON(tt, strings.NewReader(payload), nil), db.ErrOverlappingInterval)
+	})
+
+	t.Run("prompt", func(t *testing.T) {
+		var out bytes.Buffer
+		resolve := promptResolver(strings.NewReader("what\nskip\nn\n"), &out)
+		require.NoError(t, importJSON(tt, strings.NewReader(payload), resolve))
+		require.Equal(t, 3, strings.Count(out.String(), "[s]kip, s[n]ap or [a]bort? "))
+
+		itvs, err := tt.List(at(0), at(23))
+		require.NoError(t, err)
+		require.Len(t, itvs, 3)
+		require.Equal(t, []string{"b"}, itvs[1].Tags)
+		require.True(t, itvs[1].StopTimestamp.Equal(at(14)))
+	})
+	t.Run("atomic", func(t *testing.T) {
+		var in bytes.Buffer
+		in.WriteString(`[
+			{"start": "2022-03-02T10:00:00Z", "stop": "2022-03-02T11:00:00Z", "tags": ["c"]},
+			{"start": "2022-03-02T13:00:00Z", "stop": "2022-03-02T12:00:00Z", "tags": ["d"]}
+		]`)
+		require.ErrorIs(t, importJSON(tt, &in, nil), db.ErrInvalidInterval)
+
+		itvs, err := tt.List(at(0).AddDate(0, 0, 1), at(23).AddDate(0, 0, 1))
+		require.NoError(t, err)
+		require.Empty(t, itvs)
+	})
+}
+
+func TestImportCSV(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2022, 3, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	tt, err := db.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, tt.Close()) })
+
+	t.Run("valid", func(t *testing.T) {
+		payload := "2022-03-01T10:00:00Z,2022-03-01T11:00:00Z,a;b\n" +
+			"2022-03-01T11:00:00Z,2022-03-01T12:00:00Z,\n" +
+			"2022-03-01T13:00:00Z,2022-03-01T14:00:00Z,\"c, with comma;d\"\n"
+		require.ErrorIs(t, importCSV(tt, strings.NewReader(payload), nil), db.ErrInvalidTag)
+
+		payload = strings.Replace(payload, "c, with comma", "c", 1)
+		require.NoError(t, importCSV(tt, strings.NewReader(payload), nil))
+
+		itvs, err := tt.List(at(1, 0), at(1, 23))
+		require.NoError(t, err)
+		require.Len(t, itvs, 3)
+		require.Equal(t, []string{"a", "b"}, itvs[0].Tags)
+		require.Nil(t, itvs[1].Tags)
+		require.Equal(t, []string{"c", "d"}, itvs[2].Tags)
+		require.True(t, itvs[2].StopTimestamp.Equal(at(1, 14)))
+	})
+
+	t.Run("header", func(t *testing.T) {
+		payload := "start,stop,tags\n" +
+			"\"2022-03-02T10:00:00Z\",\"2022-03-02T11:00:00Z\",\"a\"\n"
+		require.NoError(t, importCSV(tt, strings.NewReader(payload), nil))
+
+		itvs, err := tt.List(at(2, 0), at(2, 23))
+		require.NoError(t, err)
+		require.Len(t, itvs, 1)
+		require.Equal(t, []string{"a"}, itvs[0].Tags)
+	})
+
+	t.Run("malformed row", func(t *testing.T) {
+		for _, tc := range []struct {
+			name    string
+			payload string
+			line    string
+		}{
+			{"unparsable timestamp", "start,stop,tags\n2022-03-03T10:00:00Z,2022-03-03T11:00:00Z,a\nyesterday,2022-03-03T12:00:00Z,b\n", "line 3"},
+			{"missing stop", "2022-03-03T10:00:00Z\n", "line 1"},
+			{"stop before start", "2022-03-03T10:00:00Z,2022-03-03T11:00:00Z,a\n2022-03-03T13:00:00Z,2022-03-03T12:00:00Z,b\n", "line 2"},
+			{"overlap", "start,stop\n\n2022-03-01T10:30:00Z,2022-03-01T10:45:00Z\n", "line 3"},
+			{"unterminated quote", "2022-03-03T10:00:00Z,\"2022-03-03T11:00:00Z,a\n", "line 1"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				err := importCSV(tt, strings.NewReader(tc.payload), nil)
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.line)
+			})
+		}
+
+		itvs, err := tt.List(at(3, 0), at(3, 23))
+		require.NoError(t, err)
+		require.Empty(t, itvs)
+	})
+}
@@ -0,0 +1,194 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Amend changes the start and stop timestamps of the interval identified by id.
+// A zero timestamp leaves the matching one unchanged. The stop timestamp of
+// an opened interval cannot be amended, it has to be stopped instead.
+// As intervals are never updated, the interval is deleted and replaced by a new
+// one, with a new id, holding the same tags, estimate, billable flag, context,
+// time zone and place.
+// The amended interval must not overlap another interval of its context:
+// ErrInvalidStartTimestamp or ErrInvalidStopTimestamp is returned otherwise.
+// It returns ErrIntervalLocked if either the current or the new start timestamp
+// is before the lock date.
+func (tt *TimeTracker) Amend(id string, newStart, newStop time.Time) (ret error) {
+	tx, err := tt.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("cannot start a transaction: %w", err)
+	}
+	defer tt.completeTransaction(tx, &ret)
+
+	if err := tt.checkLock(tx, id); err != nil {
+		return err
+	}
+
+	var current struct {
+		UUID           string        `db:"uuid"`
+		StartTimestamp int64         `db:"start_timestamp"`
+		StopTimestamp  sql.NullInt64 `db:"stop_timestamp"`
+		Context        string        `db:"context"`
+	}
+	if err := tx.Get(&current, `
+		SELECT interval_start.uuid, start_timestamp, stop_timestamp, context
+		FROM interval_start
+			LEFT JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+		WHERE interval_tombstone.uuid IS NULL
+			AND interval_start.id = ?`, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: id %s", ErrNotFound, id)
+		}
+		return fmt.Errorf("cannot retrieve interval %s: %w", id, err)
+	}
+
+	start := current.StartTimestamp
+	if !newStart.IsZero() {
+		start = newStart.Unix()
+	}
+	if !tt.lockDate.IsZero() && start < tt.lockDate.Unix() {
+		return fmt.Errorf("%w: id %s would start before %s", ErrIntervalLocked, id, tt.lockDate)
+	}
+
+	stop := current.StopTimestamp
+	if !newStop.IsZero() {
+		if !stop.Valid {
+			return fmt.Errorf("%w: the stop of the opened interval %s cannot be amended",
+				ErrInvalidParam, id)
+		}
+		stop.Int64 = newStop.Unix()
+	}
+	if stop.Valid && stop.Int64 <= start {
+		return ErrInvalidStopTimestamp
+	}
+
+	if err := checkAmendOverlap(tx, current.UUID, current.Context, start, stop); err != nil {
+		return err
+	}
+
+	// Preconditions ok. Replace the interval.
+	newUUID, err := tt.replaceInterval(tx, id, current.UUID, start)
+	if err != nil {
+		return err
+	}
+
+	if !stop.Valid {
+		return nil
+	}
+	return tt.insertIntervalStop(tx, newUUID, time.Unix(stop.Int64, 0))
+}
+
+// replaceInterval deletes the interval identified by intervalUUID and
+// inserts a new one starting at start, left opened, holding the same tags,
+// estimate, billable flag, context, time zone and place.
+// It returns the uuid of the new interval.
+func (tt *TimeTracker) replaceInterval(
+	tx *sqlx.Tx, id, intervalUUID string, start int64,
+) (string, error) {
+	now := tt.now().Unix()
+	if _, err := tx.Exec(`
+		INSERT INTO interval_tombstone (uuid, start_uuid, created_at)
+		VALUES (uuid(), ?, ?)`, intervalUUID, now); err != nil {
+		return "", fmt.Errorf("cannot delete interval %s: %w", id, err)
+	}
+
+	var newUUID string
+	if err := tx.Get(&newUUID, `
+		INSERT INTO interval_start (uuid, start_timestamp, created_at, tz, context, location)
+		SELECT uuid(), ?, ?, tz, context, location
+		FROM interval_start
+		WHERE uuid = ?
+		RETURNING uuid`, start, now, intervalUUID); err != nil {
+		return "", fmt.Errorf("cannot insert interval replacing %s: %w", id, err)
+	}
+
+	tags, err := getIntervalTags(tx, intervalUUID)
+	if err != nil {
+		return "", err
+	}
+	for _, tag := range tags {
+		if err := insertIntervalTag(tx, newUUID, tag, now, now); err != nil {
+			return "", fmt.Errorf("cannot link interval replacing %s with tag %s: %w", id, tag, err)
+		}
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO interval_estimate (uuid, start_uuid, estimate, created_at)
+		SELECT uuid(), ?1, estimate, ?2
+		FROM interval_estimate
+			LEFT JOIN interval_estimate_tombstone
+				ON interval_estimate.uuid = interval_estimate_tombstone.estimate_uuid
+		WHERE interval_estimate.start_uuid = ?3
+			AND interval_estimate_tombstone.uuid IS NULL`,
+		newUUID, now, intervalUUID); err != nil {
+		return "", fmt.Errorf("cannot copy estimate of interval %s: %w", id, err)
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO interval_billable (uuid, start_uuid, created_at)
+		SELECT uuid(), ?1, ?2
+		FROM interval_billable
+			LEFT JOIN interval_billable_tombstone
+				ON interval_billable.uuid = interval_billable_tombstone.billable_uuid
+		WHERE interval_billable.start_uuid = ?3
+			AND interval_billable_tombstone.uuid IS NULL`,
+		newUUID, now, intervalUUID); err != nil {
+		return "", fmt.Errorf("cannot copy billable flag of interval %s: %w", id, err)
+	}
+
+	return newUUID, nil
+}
+
+// checkAmendOverlap applies the checks of Start and stop to the interval
+// identified by intervalUUID once amended to [start, stop), ignoring the
+// interval itself. An opened interval, with an invalid stop, must start
+// after every other interval of its context.
+func checkAmendOverlap(
+	tx rowQueryer, intervalUUID, context string, start int64, stop sql.NullInt64,
+) error {
+	// The start must not fall in another interval, the opened one included
+	var count int
+	row := tx.QueryRow(`
+		SELECT count(1)
+		FROM interval_start
+			LEFT JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+		WHERE start_timestamp <= ?1 AND (stop_timestamp > ?1 OR interval_stop.uuid IS NULL)
+			AND interval_tombstone.uuid IS NULL
+			AND interval_start.uuid != ?2
+			AND context = ?3`, start, intervalUUID, context)
+	if err := row.Scan(&count); err != nil {
+		return fmt.Errorf("cannot count overlapping interval: %w", err)
+	}
+	if count >= 1 {
+		return ErrInvalidStartTimestamp
+	}
+
+	// No other interval must start within the amended one
+	row = tx.QueryRow(`
+		SELECT count(1)
+		FROM interval_start
+			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+		WHERE start_timestamp >= ?1
+			AND (?2 IS NULL OR start_timestamp < ?2)
+			AND interval_tombstone.uuid IS NULL
+			AND interval_start.uuid != ?3
+			AND context = ?4`, start, stop, intervalUUID, context)
+	if err := row.Scan(&count); err != nil {
+		return fmt.Errorf("cannot count enclosed interval: %w", err)
+	}
+	if count >= 1 {
+		if !stop.Valid {
+			return ErrInvalidStartTimestamp
+		}
+		return ErrInvalidStopTimestamp
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAmend(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2022, 3, 1, hour, minute, 0, 0, time.Local)
+	}
+
+	// setup records two closed intervals and an opened one
+	setup := func(t *testing.T) *TimeTracker {
+		tt := setupTT(t)
+		require.NoError(t, tt.Start(at(9, 0), []string{"a", "b"}))
+		require.NoError(t, tt.StopAt(at(10, 0)))
+		require.NoError(t, tt.Start(at(11, 0), []string{"c"}))
+		require.NoError(t, tt.StopAt(at(12, 0)))
+		require.NoError(t, tt.Start(at(13, 0), []string{"d"}))
+		return tt
+	}
+	list := func(t *testing.T, tt *TimeTracker) []TaggedInterval {
+		intervals, err := tt.List(at(0, 0), at(23, 0))
+		require.NoError(t, err)
+		for idx := range intervals {
+			intervals[idx].Interval.UUID = ""
+			intervals[idx].Interval.Location = nil
+		}
+		return intervals
+	}
+
+	t.Run("closed interval", func(t *testing.T) {
+		tt := setup(t)
+		require.NoError(t, tt.SetEstimate("1", time.Hour))
+		require.NoError(t, tt.SetBillable("1", true))
+
+		require.NoError(t, tt.Amend("1", at(8, 30), at(10, 30)))
+		intervals := list(t, tt)
+		require.Len(t, intervals, 3)
+		require.Equal(t, TaggedInterval{
+			Interval: Interval{ID: "4", StartTimestamp: at(8, 30), StopTimestamp: at(10, 30)},
+			Tags:     []string{"a", "b"},
+			Estimate: time.Hour,
+			Billable: true,
+		}, intervals[0])
+
+		// A zero timestamp is left unchanged
+		require.NoError(t, tt.Amend("2", time.Time{}, at(12, 30)))
+		intervals = list(t, tt)
+		require.Equal(t, at(11, 0), intervals[1].StartTimestamp)
+		require.Equal(t, at(12, 30), intervals[1].StopTimestamp)
+		require.Equal(t, []string{"c"}, intervals[1].Tags)
+
+		// The cached daily totals follow the amended timestamps
+		total, err := tt.TotalDuration()
+		require.NoError(t, err)
+		require.NoError(t, tt.RebuildDailyTotals())
+		rebuilt, err := tt.TotalDuration()
+		require.NoError(t, err)
+		require.InDelta(t, total.Seconds(), rebuilt.Seconds(), 1)
+	})
+
+	t.Run("opened interval", func(t *testing.T) {
+		tt := setup(t)
+		require.NoError(t, tt.Amend("3", at(12, 30), time.Time{}))
+		current, err := tt.Current()
+		require.NoError(t, err)
+		require.Equal(t, "4", current.ID)
+		require.Equal(t, at(12, 30), current.StartTimestamp)
+		require.Equal(t, []string{"d"}, current.Tags)
+
+		err = tt.Amend("4", time.Time{}, at(14, 0))
+		require.ErrorIs(t, err, ErrInvalidParam)
+	})
+
+	t.Run("overlap", func(t *testing.T) {
+		tt := setup(t)
+		before := list(t, tt)
+
+		require.ErrorIs(t, tt.Amend("1", at(11, 30), at(12, 30)), ErrInvalidStartTimestamp)
+		require.ErrorIs(t, tt.Amend("1", time.Time{}, at(11, 30)), ErrInvalidStopTimestamp)
+		require.ErrorIs(t, tt.Amend("2", at(13, 30), at(14, 0)), ErrInvalidStartTimestamp)
+		require.ErrorIs(t, tt.Amend("3", at(10, 30), time.Time{}), ErrInvalidStartTimestamp)
+		require.ErrorIs(t, tt.Amend("1", time.Time{}, at(8, 0)), ErrInvalidStopTimestamp)
+		require.Equal(t, before, list(t, tt))
+
+		// Intervals are half open
+		require.NoError(t, tt.Amend("2", at(10, 0), at(13, 0)))
+	})
+
+	t.Run("unknown or locked interval", func(t *testing.T) {
+		tt := setup(t)
+		require.ErrorIs(t, tt.Amend("42", at(8, 0), time.Time{}), ErrNotFound)
+
+		tt.SetLockDate(at(10, 30))
+		require.ErrorIs(t, tt.Amend("1", at(9, 30), time.Time{}), ErrIntervalLocked)
+		require.ErrorIs(t, tt.Amend("2", at(10, 0), time.Time{}), ErrIntervalLocked)
+		require.NoError(t, tt.Amend("2", at(10, 45), time.Time{}))
+	})
+}
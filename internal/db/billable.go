@@ -0,0 +1,86 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// SetBillable flags the interval identified by id as billable or clears the flag.
+// Like tags, the flag is never updated: it is recorded as a row which is
+// tombstoned when the flag is cleared. Setting the current value is a no-op.
+func (tt *TimeTracker) SetBillable(id string, billable bool) (ret error) {
+	tx, err := tt.db.Begin()
+	if err != nil {
+		return fmt.Errorf("cannot start a transaction: %w", err)
+	}
+	defer tt.completeTransaction(tx, &ret)
+
+	if err := tt.checkLock(tx, id); err != nil {
+		return err
+	}
+
+	row := tx.QueryRow(`
+		SELECT interval_start.uuid
+		FROM interval_start
+			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+		WHERE interval_tombstone.uuid IS NULL
+			AND interval_start.id = ?`, id)
+	var intervalUUID string
+	if err := row.Scan(&intervalUUID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: id %s", ErrNotFound, id)
+		}
+		return fmt.Errorf("cannot retrieve uuid from database scan: %w", err)
+	}
+
+	current, err := getIntervalBillable(tx, intervalUUID)
+	if err != nil {
+		return err
+	}
+	if current == billable {
+		return nil
+	}
+
+	if !billable {
+		if _, err := tx.Exec(`
+			INSERT INTO interval_billable_tombstone (uuid, billable_uuid, created_at)
+			SELECT uuid(), interval_billable.uuid, ?
+			FROM interval_billable
+				LEFT JOIN interval_billable_tombstone
+					ON interval_billable.uuid = interval_billable_tombstone.billable_uuid
+			WHERE interval_billable.start_uuid = ?
+				AND interval_billable_tombstone.uuid IS NULL`,
+			tt.now().Unix(), intervalUUID); err != nil {
+			return fmt.Errorf("cannot clear billable flag of interval %s: %w", id, err)
+		}
+		return nil
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO interval_billable (uuid, start_uuid, created_at)
+		VALUES (uuid(), ?, ?)`,
+		intervalUUID, tt.now().Unix()); err != nil {
+		return fmt.Errorf("cannot set billable flag of interval %s: %w", id, err)
+	}
+
+	return nil
+}
+
+// getIntervalBillable reports whether an interval is flagged as billable.
+func getIntervalBillable(tx rowQueryer, intervalUUID string) (bool, error) {
+	var billable bool
+	row := tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1
+			FROM interval_billable
+				LEFT JOIN interval_billable_tombstone
+					ON interval_billable.uuid = interval_billable_tombstone.billable_uuid
+			WHERE start_uuid = ?
+				AND interval_billable_tombstone.uuid IS NULL
+		)`, intervalUUID)
+	if err := row.Scan(&billable); err != nil {
+		return false, fmt.Errorf("cannot retrieve billable flag: %w", err)
+	}
+	return billable, nil
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dgsb/tt/internal/funk"
+)
+
+func TestSetBillable(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.Local)
+	}
+
+	tt := setupTT(t)
+	require.NoError(t, tt.Start(at(10), []string{"a"}))
+	require.NoError(t, tt.StopAt(at(11)))
+	require.NoError(t, tt.Start(at(11), []string{"b"}))
+
+	billables := func(t *testing.T) []bool {
+		intervals, err := tt.List(at(0), at(23))
+		require.NoError(t, err)
+		return funk.Map(intervals, func(_ int, itv TaggedInterval) bool {
+			return itv.Billable
+		})
+	}
+
+	require.Equal(t, []bool{false, false}, billables(t))
+
+	require.NoError(t, tt.SetBillable("1", true))
+	require.NoError(t, tt.SetBillable("2", true))
+	require.Equal(t, []bool{true, true}, billables(t))
+
+	current, err := tt.Current()
+	require.NoError(t, err)
+	require.True(t, current.Billable)
+
+	require.NoError(t, tt.SetBillable("2", false))
+	require.Equal(t, []bool{true, false}, billables(t))
+	closed, err := tt.At(at(10))
+	require.NoError(t, err)
+	require.True(t, closed.Billable)
+
+	// Setting the current value doesn't record anything
+	require.NoError(t, tt.SetBillable("1", true))
+	require.NoError(t, tt.SetBillable("2", false))
+	var flags, tombstones int
+	require.NoError(t, tt.db.Get(&flags, `SELECT count(1) FROM interval_billable`))
+	require.NoError(t, tt.db.Get(&tombstones, `SELECT count(1) FROM interval_billable_tombstone`))
+	require.Equal(t, 2, flags)
+	require.Equal(t, 1, tombstones)
+
+	require.NoError(t, tt.SetBillable("2", true))
+	require.Equal(t, []bool{true, true}, billables(t))
+
+	require.ErrorIs(t, tt.SetBillable("3", true), ErrNotFound)
+
+	tt.SetLockDate(at(11))
+	require.ErrorIs(t, tt.SetBillable("1", false), ErrIntervalLocked)
+}
+
+func TestSyncBillable(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+
+	tt1 := setupTT(t)
+	tt2 := setupTT(t)
+
+	require.NoError(t, tt1.Start(at(10), []string{"a"}))
+	require.NoError(t, tt1.StopAt(at(11)))
+	require.NoError(t, tt1.Start(at(11), []string{"b"}))
+	require.NoError(t, tt1.StopAt(at(12)))
+	require.NoError(t, tt1.SetBillable("1", true))
+	require.NoError(t, tt1.SetBillable("2", true))
+	require.NoError(t, tt1.SetBillable("2", false))
+
+	synchronise := func(syncTime time.Time) {
+		tx1, err := tt1.db.Beginx()
+		require.NoError(t, err)
+		defer commit(t, tx1)
+		tx2, err := tt2.db.Beginx()
+		require.NoError(t, err)
+		defer commit(t, tx2)
+
+		require.NoError(t, funk.CallAbortOnError(
+			func() error { return synchroniseTags(tx1, tx2, syncTime, MergeUnion) },
+			func() error { return synchroniseIntervalStart(tx1, tx2, syncTime, MergeUnion) },
+			func() error { return synchroniseIntervalStop(tx1, tx2, syncTime, MergeUnion) },
+			func() error { return synchroniseIntervalTags(tx1, tx2, syncTime, MergeUnion) },
+			func() error { return synchroniseIntervalBillable(tx1, tx2, syncTime, MergeUnion) },
+			func() error { return synchroniseIntervalBillableTombstone(tx1, tx2, syncTime, MergeUnion) },
+		))
+	}
+
+	synchronise(time.Now())
+
+	intervals, err := tt2.List(at(0), at(23))
+	require.NoError(t, err)
+	require.Len(t, intervals, 2)
+	require.True(t, intervals[0].Billable)
+	require.False(t, intervals[1].Billable)
+
+	for _, table := range []string{"interval_billable", "interval_billable_tombstone"} {
+		var count1, count2 int
+		require.NoError(t, tt1.db.Get(&count1, `SELECT count(1) FROM `+table))
+		require.NoError(t, tt2.db.Get(&count2, `SELECT count(1) FROM `+table))
+		require.Equal(t, count1, count2, table)
+	}
+}
@@ -1,20 +1,32 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	_ "embed"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/GuiaBolso/darwin"
 	"github.com/google/uuid"
 	"github.com/hashicorp/go-multierror"
 	"github.com/jmoiron/sqlx"
 	"github.com/mattn/go-sqlite3"
+	"github.com/sirupsen/logrus"
 )
 
 const customSqliteDriverName = "sqlite3_tt"
 
+// maxReadOnlyConns is the number of connections of a read-only database
+// handle which concurrent reads are spread on.
+const maxReadOnlyConns = 4
+
 func init() {
 	sql.Register(customSqliteDriverName, &sqlite3.SQLiteDriver{
 		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
@@ -29,6 +41,18 @@ func init() {
 	})
 }
 
+type rowQueryer interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+type rowsQueryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
 type transactioner interface {
 	Commit() error
 	Rollback() error
@@ -45,8 +69,62 @@ func completeTransaction(
 	}
 }
 
-func setupDB(databaseName string) (*sqlx.DB, error) {
-	db, err := sql.Open(customSqliteDriverName, databaseName)
+// completeTransaction completes a transaction of the time tracker database.
+// When the foreign key check is enabled, the database foreign keys are
+// verified once the transaction has been committed.
+func (tt *TimeTracker) completeTransaction(tx transactioner, retErr *error) { //nolint:gocritic
+	completeTransaction(tx, retErr)
+	if *retErr != nil || !tt.fkCheck {
+		return
+	}
+	*retErr = tt.checkForeignKeys()
+}
+
+// checkForeignKeys returns ErrForeignKeyViolation if any foreign key
+// constraint of the database is not satisfied.
+func (tt *TimeTracker) checkForeignKeys() error {
+	type violation struct {
+		Table  string        `db:"table"`
+		RowID  sql.NullInt64 `db:"rowid"`
+		Parent string        `db:"parent"`
+		FKID   int64         `db:"fkid"`
+	}
+	var violations []violation
+	if err := tt.db.Select(&violations, `PRAGMA foreign_key_check`); err != nil {
+		return fmt.Errorf("cannot check foreign keys: %w", err)
+	}
+
+	var merr *multierror.Error
+	for _, v := range violations {
+		merr = multierror.Append(merr, fmt.Errorf(
+			"%w: row %d of %s references a missing %s row", ErrForeignKeyViolation, v.RowID.Int64, v.Table, v.Parent))
+	}
+	return merr.ErrorOrNil()
+}
+
+// sqliteURI returns the file URI of the database at path with the query parameters.
+// The path is made absolute so that it is never taken for the URI authority.
+func sqliteURI(path string, query url.Values) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve database path %s: %w", path, err)
+	}
+	return (&url.URL{Scheme: "file", Path: abs, RawQuery: query.Encode()}).String(), nil
+}
+
+// setupDB opens the database and brings its schema up to date.
+// A read-only database is opened with the sqlite mode=ro option: neither the
+// migrations nor the connection pragmas are run but the schema is expected
+// to be up to date.
+func setupDB(databaseName string, readOnly bool) (*sqlx.DB, error) {
+	dsn := databaseName
+	if readOnly {
+		var err error
+		if dsn, err = sqliteURI(databaseName, url.Values{"mode": {"ro"}}); err != nil {
+			return nil, err
+		}
+	}
+	db, err := sql.Open(customSqliteDriverName, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("cannot open database %s: %w", databaseName, err)
 	}
@@ -54,9 +132,46 @@ func setupDB(databaseName string) (*sqlx.DB, error) {
 		return nil, fmt.Errorf("cannot validate database connection %s: %w", databaseName, err)
 	}
 
+	if readOnly {
+		if err := checkMigrated(db); err != nil {
+			return nil, fmt.Errorf("cannot open database %s read-only: %w", databaseName, err)
+		}
+		if err := checkRequiredTables(db); err != nil {
+			return nil, fmt.Errorf("cannot open database %s: %w", databaseName, err)
+		}
+		// Readers don't block each other nor the writer of another process
+		// under WAL, each query can get its own connection.
+		db.SetMaxOpenConns(maxReadOnlyConns)
+		db.SetMaxIdleConns(maxReadOnlyConns)
+		return sqlx.NewDb(db, "sqlite3"), nil
+	}
+
+	// sqlite allows a single writer at a time so a second connection would
+	// only wait for the database lock. A single connection also ensures the
+	// per connection pragmas below apply to every query and that an in memory
+	// database, which is private to its connection, is never lost.
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
+
 	if err := runSqliteMigrations(db); err != nil {
+		var checksumErr darwin.InvalidChecksumError
+		if errors.As(err, &checksumErr) {
+			return nil, fmt.Errorf("%w: migration version %g of database %s has been modified since it was applied",
+				ErrMigrationChecksum, checksumErr.Version, databaseName)
+		}
 		return nil, fmt.Errorf("cannot run schema migration on database %s: %w", databaseName, err)
 	}
+	if err := checkRequiredTables(db); err != nil {
+		return nil, fmt.Errorf("cannot open database %s: %w", databaseName, err)
+	}
+
+	// Under WAL readers keep reading from a consistent snapshot
+	// while a writer commits instead of failing with database is locked.
+	// The journal mode is recorded in the database file.
+	if _, err := db.Exec(`PRAGMA journal_mode = WAL`); err != nil {
+		return nil, fmt.Errorf("cannot enable write ahead logging: %w", err)
+	}
 
 	if _, err := db.Exec(`PRAGMA foreign_keys = ON`); err != nil {
 		return nil, fmt.Errorf("cannot enforce foreign keys consistency mode: %w", err)
@@ -75,20 +190,137 @@ type Interval struct {
 	UUID           string
 	StartTimestamp time.Time
 	StopTimestamp  time.Time
+	// Location is the time zone in which the interval has been started.
+	// It is nil when it has not been recorded or is unknown on this host.
+	Location *time.Location
+	// Context is the name of the context the interval belongs to.
+	// The default context is the empty string.
+	Context string
+	// Place is the free form location the interval has been started at,
+	// like a city for travel time. It is empty when none has been recorded.
+	Place string
+}
+
+// OriginalStart returns the start timestamp in the time zone it has been recorded in.
+func (i Interval) OriginalStart() time.Time {
+	if i.Location == nil {
+		return i.StartTimestamp
+	}
+	return i.StartTimestamp.In(i.Location)
+}
+
+// OriginalStop returns the stop timestamp in the time zone the interval has been started in.
+func (i Interval) OriginalStop() time.Time {
+	if i.Location == nil || i.StopTimestamp.IsZero() {
+		return i.StopTimestamp
+	}
+	return i.StopTimestamp.In(i.Location)
+}
+
+// zoneName returns the IANA name of loc suitable for storage.
+// The local zone name is looked up from the TZ environment variable
+// or the /etc/localtime link. An empty string is returned when it cannot be found.
+func zoneName(loc *time.Location) string {
+	if loc != time.Local {
+		if loc == time.UTC {
+			return "UTC"
+		}
+		if _, err := time.LoadLocation(loc.String()); err != nil {
+			return ""
+		}
+		return loc.String()
+	}
+
+	if tz, ok := os.LookupEnv("TZ"); ok {
+		tz = strings.TrimPrefix(tz, ":")
+		if tz == "" {
+			return "UTC"
+		}
+		if _, err := time.LoadLocation(tz); err == nil {
+			return tz
+		}
+		return ""
+	}
+
+	target, err := os.Readlink("/etc/localtime")
+	if err != nil {
+		return ""
+	}
+	if _, name, found := strings.Cut(target, "zoneinfo/"); found {
+		if _, err := time.LoadLocation(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// loadZone returns the location stored as name or nil if unknown.
+func loadZone(name sql.NullString) *time.Location {
+	if !name.Valid || name.String == "" {
+		return nil
+	}
+	loc, err := time.LoadLocation(name.String)
+	if err != nil {
+		return nil
+	}
+	return loc
 }
 
 type TaggedInterval struct {
 	Interval
+	// Tags is nil, never an empty slice, for an interval without any tag
+	// whichever method has returned it.
 	Tags []string
+	// Estimate is the planned duration of the interval, zero when not set.
+	Estimate time.Duration
+	// Billable reports whether the interval has been flagged as billable.
+	Billable bool
 }
 
 type TimeTracker struct {
-	db  *sqlx.DB
-	now func() time.Time
+	db             *sqlx.DB
+	now            func() time.Time
+	lockDate       time.Time
+	clockGuard     ClockGuard
+	clockTolerance time.Duration
+
+	preserveTagTimestamps bool
+	fkCheck               bool
+	context               string
+	place                 string
+	tagAliases            map[string]string
+	defaultTags           []string
+	autoStopStale         time.Duration
+	maxTags               int
 }
 
+// ClockGuard is the behaviour adopted when the system clock appears
+// to have jumped backward when starting an interval.
+type ClockGuard int
+
+const (
+	// ClockGuardOff disables the clock check.
+	ClockGuardOff ClockGuard = iota
+	// ClockGuardWarn logs a warning and proceeds.
+	ClockGuardWarn
+	// ClockGuardError refuses the operation with ErrClockSkew.
+	ClockGuardError
+)
+
 func New(databaseName string) (*TimeTracker, error) {
-	db, err := setupDB(databaseName)
+	db, err := setupDB(databaseName, false)
+	if err != nil {
+		return nil, fmt.Errorf("cannot setup time tracker database: %w", err)
+	}
+
+	return &TimeTracker{db: db, now: time.Now}, nil
+}
+
+// NewReadOnly opens an existing database without ever writing to it,
+// which allows reporting from a read-only medium or while another process
+// writes. The database schema must be up to date. Any modification fails.
+func NewReadOnly(databaseName string) (*TimeTracker, error) {
+	db, err := setupDB(databaseName, true)
 	if err != nil {
 		return nil, fmt.Errorf("cannot setup time tracker database: %w", err)
 	}
@@ -96,15 +328,239 @@ func New(databaseName string) (*TimeTracker, error) {
 	return &TimeTracker{db: db, now: time.Now}, nil
 }
 
+// SetLockDate prevents any modification of the intervals started before t.
+// No interval can be started, recorded or imported before t either, and the
+// opened interval can only be stopped at or after t, so that the time accounted
+// before t never changes. The only exception is StopStale which stops a forgotten
+// interval at its last known activity, possibly before t.
+// A zero t disables the lock.
+func (tt *TimeTracker) SetLockDate(t time.Time) {
+	tt.lockDate = t
+}
+
+// SetClockGuard configures the check performed on Start against the most recent
+// record of the database. The guard triggers when the current time is earlier
+// than this record by more than tolerance.
+func (tt *TimeTracker) SetClockGuard(guard ClockGuard, tolerance time.Duration) {
+	tt.clockGuard = guard
+	tt.clockTolerance = tolerance
+}
+
+// SetForeignKeyCheck enables the verification of the database foreign keys
+// after each committed transaction. It is meant to catch regressions during development.
+func (tt *TimeTracker) SetForeignKeyCheck(enable bool) {
+	tt.fkCheck = enable
+}
+
+// SetContext selects the context in which intervals are started, stopped
+// and looked up by Start, Stop and Current. At most one interval can be opened
+// in each context. The default context is the empty string.
+func (tt *TimeTracker) SetContext(name string) {
+	tt.context = name
+}
+
+// SetPlace sets the location recorded with the intervals opened by Start
+// and StartMerging. No location is recorded when place is empty.
+func (tt *TimeTracker) SetPlace(place string) {
+	tt.place = place
+}
+
+// SetTagAliases configures the tags replaced by their canonical form
+// by Start, Tag and Continue before being stored or looked up.
+// Tags which are not aliased are used unchanged.
+func (tt *TimeTracker) SetTagAliases(aliases map[string]string) {
+	tt.tagAliases = aliases
+}
+
+// SetDefaultTags configures the tags added to every interval opened by Start,
+// StartMerging and Continue. They are validated like any other tag.
+func (tt *TimeTracker) SetDefaultTags(tags []string) error {
+	if err := validateTags(tags); err != nil {
+		return fmt.Errorf("invalid default tags: %w", err)
+	}
+	tt.defaultTags = tags
+	return nil
+}
+
+// startTags returns the tags of a new interval: the given ones followed by
+// the default ones, aliases resolved and duplicates removed.
+func (tt *TimeTracker) startTags(tags []string) []string {
+	if len(tt.defaultTags) == 0 {
+		return tt.resolveTags(tags)
+	}
+
+	var merged []string
+	for _, tag := range tt.resolveTags(append(append([]string{}, tags...), tt.defaultTags...)) {
+		if !containsTag(merged, tag) {
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
+
+// normalizeTags trims the whitespace surrounding tags, which are then
+// kept once when given several times.
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); !containsTag(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// resolveTags normalizes tags and replaces aliased tags by their canonical form.
+// A tag given several times, directly or through aliases, is kept once.
+func (tt *TimeTracker) resolveTags(tags []string) []string {
+	tags = normalizeTags(tags)
+	if len(tt.tagAliases) == 0 {
+		return tags
+	}
+
+	var resolved []string
+	for _, tag := range tags {
+		if canonical, ok := tt.tagAliases[tag]; ok {
+			tag = canonical
+		}
+		if !containsTag(resolved, tag) {
+			resolved = append(resolved, tag)
+		}
+	}
+	return resolved
+}
+
+// SetAutoStopStale enables StopStale for the opened intervals started more than
+// threshold ago. A zero threshold disables it.
+func (tt *TimeTracker) SetAutoStopStale(threshold time.Duration) {
+	tt.autoStopStale = threshold
+}
+
+// SetMaxTags limits the number of tags an interval can hold. Starting or
+// tagging an interval beyond it fails with ErrTooManyTags. A zero maxTags
+// leaves the number of tags unlimited.
+func (tt *TimeTracker) SetMaxTags(maxTags int) {
+	tt.maxTags = maxTags
+}
+
+// checkTagCount returns ErrTooManyTags if an interval cannot hold count tags.
+func (tt *TimeTracker) checkTagCount(count int) error {
+	if tt.maxTags > 0 && count > tt.maxTags {
+		return fmt.Errorf("%w: %d tags, at most %d allowed", ErrTooManyTags, count, tt.maxTags)
+	}
+	return nil
+}
+
+// SetPreserveTagTimestamps makes Tag reuse the creation timestamp of the first
+// time a tag has been set on an interval when the tag is added back after having been removed.
+//
+// Beware that synchronisation only propagates rows created after the last
+// synchronisation. A tag added back with a preserved timestamp older than the
+// last synchronisation won't be propagated until a full synchronisation,
+// see SetLastSync.
+func (tt *TimeTracker) SetPreserveTagTimestamps(preserve bool) {
+	tt.preserveTagTimestamps = preserve
+}
+
+// lastRecordTime returns the most recent created_at value of the database.
+// It returns a zero time when the database is empty.
+func lastRecordTime(tx rowQueryer) (time.Time, error) {
+	var lastCreatedAt sql.NullInt64
+	row := tx.QueryRow(`
+		SELECT max(created_at) FROM (
+			SELECT max(created_at) created_at FROM interval_start
+			UNION ALL SELECT max(created_at) FROM interval_stop
+			UNION ALL SELECT max(created_at) FROM interval_tombstone
+			UNION ALL SELECT max(created_at) FROM interval_tags
+			UNION ALL SELECT max(created_at) FROM interval_tags_tombstone
+			UNION ALL SELECT max(created_at) FROM interval_estimate
+			UNION ALL SELECT max(created_at) FROM interval_estimate_tombstone
+			UNION ALL SELECT max(created_at) FROM interval_billable
+			UNION ALL SELECT max(created_at) FROM interval_billable_tombstone
+		)`)
+	if err := row.Scan(&lastCreatedAt); err != nil {
+		return time.Time{}, fmt.Errorf("cannot retrieve most recent record timestamp: %w", err)
+	}
+	if !lastCreatedAt.Valid {
+		return time.Time{}, nil
+	}
+	return time.Unix(lastCreatedAt.Int64, 0), nil
+}
+
+// checkClock compares the current time with the most recent created_at
+// value of the database according to the configured clock guard.
+func (tt *TimeTracker) checkClock(tx rowQueryer) error {
+	if tt.clockGuard == ClockGuardOff {
+		return nil
+	}
+
+	last, err := lastRecordTime(tx)
+	if err != nil {
+		return err
+	}
+	if last.IsZero() {
+		return nil
+	}
+
+	now := tt.now()
+	if last.Sub(now) <= tt.clockTolerance {
+		return nil
+	}
+
+	if tt.clockGuard == ClockGuardWarn {
+		logrus.WithFields(logrus.Fields{
+			"now":         now,
+			"last_record": last,
+		}).Warn("system clock appears to have jumped backward")
+		return nil
+	}
+
+	return fmt.Errorf("%w: current time %s is before last record %s", ErrClockSkew, now, last)
+}
+
+// checkLock returns ErrIntervalLocked if the interval identified by id
+// started before the lock date. An unknown id is not reported here and
+// is left to the caller.
+func (tt *TimeTracker) checkLock(tx rowQueryer, id string) error {
+	if tt.lockDate.IsZero() {
+		return nil
+	}
+
+	var startTimestamp int64
+	row := tx.QueryRow(`SELECT start_timestamp FROM interval_start WHERE id = ?`, id)
+	if err := row.Scan(&startTimestamp); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("cannot retrieve interval %s start timestamp: %w", id, err)
+	}
+
+	if startTimestamp < tt.lockDate.Unix() {
+		return fmt.Errorf("%w: id %s started before %s", ErrIntervalLocked, id, tt.lockDate)
+	}
+
+	return nil
+}
+
+// checkLockedTime returns ErrIntervalLocked if an interval can't start or stop at t
+// because t is before the lock date.
+func (tt *TimeTracker) checkLockedTime(t time.Time) error {
+	if !tt.lockDate.IsZero() && t.Unix() < tt.lockDate.Unix() {
+		return fmt.Errorf("%w: %s is before %s", ErrIntervalLocked, t, tt.lockDate)
+	}
+	return nil
+}
+
 // Close releases resources associated with the TimeTracker object.
 func (tt *TimeTracker) Close() error {
 	return tt.db.Close()
 }
 
 // countOpenedInterval counts the number of currently started
-// and not stopped time interval.
-// We should have at most one.
-func (tt *TimeTracker) countOpenedInterval(tx *sqlx.Tx) (int, error) {
+// and not stopped time interval in the current context, or in
+// all contexts when allContexts is set.
+// We should have at most one per context.
+func (tt *TimeTracker) countOpenedInterval(tx *sqlx.Tx, allContexts bool) (int, error) {
 	var count int
 	row := tx.QueryRow(`
 		SELECT count(1)
@@ -112,7 +568,8 @@ func (tt *TimeTracker) countOpenedInterval(tx *sqlx.Tx) (int, error) {
 			LEFT JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
 			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
 		WHERE interval_stop.uuid IS NULL
-			AND interval_tombstone.uuid IS NULL`)
+			AND interval_tombstone.uuid IS NULL
+			AND (?1 OR context = ?2)`, allContexts, tt.context)
 	if err := row.Scan(&count); err != nil {
 		return 0, err
 	}
@@ -120,22 +577,131 @@ func (tt *TimeTracker) countOpenedInterval(tx *sqlx.Tx) (int, error) {
 }
 
 // Start registers a new opened interval with a set of tags. This method ensures
-// that no other opened is currently registered in the database and that
-// the wanted start time doesn't already belong to a closed interval.
+// that no other opened is currently registered in the database for the current
+// context and that the wanted start time doesn't already belong to a closed
+// interval of this context.
+// Intervals are half open: [start, stop). Hence starting exactly at the stop
+// timestamp of a closed interval is allowed.
+// The system clock is checked according to the configured clock guard.
+// The configured default tags are added to tags.
 func (tt *TimeTracker) Start(t time.Time, tags []string) (ret error) {
 	tx, err := tt.db.Beginx()
 	if err != nil {
 		return fmt.Errorf("cannot start transaction: %w", err)
 	}
-	defer completeTransaction(tx, &ret)
+	defer tt.completeTransaction(tx, &ret)
+
+	if err := tt.checkStartPreconditions(tx); err != nil {
+		return err
+	}
+
+	return tt.start(tx, t, tt.startTags(tags))
+}
+
+// StartMerging behaves like Start except when the last closed interval stopped at most
+// gap before t and holds exactly the same tags. This interval is then reopened instead:
+// it is deleted and replaced by an opened interval with the same start timestamp, tags,
+// estimate, billable flag, context, time zone and place, see Amend.
+// An interval started before the lock date is never reopened.
+// It reports whether the last closed interval has been reopened.
+func (tt *TimeTracker) StartMerging(t time.Time, tags []string, gap time.Duration) (merged bool, ret error) {
+	tx, err := tt.db.Beginx()
+	if err != nil {
+		return false, fmt.Errorf("cannot start transaction: %w", err)
+	}
+	defer tt.completeTransaction(tx, &ret)
+
+	if err := tt.checkStartPreconditions(tx); err != nil {
+		return false, err
+	}
+	tags = tt.startTags(tags)
+
+	var last struct {
+		ID             string `db:"id"`
+		UUID           string `db:"uuid"`
+		StartTimestamp int64  `db:"start_timestamp"`
+		StopTimestamp  int64  `db:"stop_timestamp"`
+	}
+	err = tx.Get(&last, `
+		SELECT id, interval_start.uuid, start_timestamp, stop_timestamp
+		FROM interval_start
+			INNER JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+		WHERE interval_tombstone.uuid IS NULL
+			AND stop_timestamp <= ?
+			AND context = ?
+		ORDER BY stop_timestamp DESC, interval_start.uuid DESC
+		LIMIT 1`, t.Unix(), tt.context)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && t.Unix()-last.StopTimestamp > int64(gap.Seconds())) {
+		return false, tt.start(tx, t, tags)
+	} else if err != nil {
+		return false, fmt.Errorf("cannot retrieve last closed interval: %w", err)
+	}
+
+	var lastTags []string
+	if err := tx.Select(&lastTags, `
+		SELECT tags.name
+		FROM interval_tags
+			JOIN tags ON interval_tags.tag_id = tags.id
+			LEFT JOIN interval_tags_tombstone
+				ON interval_tags.uuid = interval_tags_tombstone.interval_tag_uuid
+		WHERE interval_start_uuid = ?
+			AND interval_tags_tombstone.uuid IS NULL`, last.UUID); err != nil {
+		return false, fmt.Errorf("cannot retrieve last closed interval tags: %w", err)
+	}
+	if !sameTags(lastTags, tags) {
+		return false, tt.start(tx, t, tags)
+	}
+
+	if err := tt.checkLock(tx, last.ID); errors.Is(err, ErrIntervalLocked) {
+		return false, tt.start(tx, t, tags)
+	} else if err != nil {
+		return false, err
+	}
+
+	if _, err := tt.replaceInterval(tx, last.ID, last.UUID, last.StartTimestamp); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// sameTags reports whether both tag lists hold the same set of values.
+func sameTags(tags1, tags2 []string) bool {
+	set := make(map[string]bool, len(tags1))
+	for _, tag := range tags1 {
+		set[tag] = true
+	}
+	other := make(map[string]bool, len(tags2))
+	for _, tag := range tags2 {
+		if !set[tag] {
+			return false
+		}
+		other[tag] = true
+	}
+	return len(set) == len(other)
+}
+
+// checkStartPreconditions checks the system clock and that no interval
+// is currently opened in the current context.
+func (tt *TimeTracker) checkStartPreconditions(tx *sqlx.Tx) error {
+	if err := tt.checkClock(tx); err != nil {
+		return err
+	}
 
 	// Check we don't have an already running opened interval
-	if count, err := tt.countOpenedInterval(tx); err != nil {
+	if count, err := tt.countOpenedInterval(tx, false); err != nil {
 		return fmt.Errorf("cannot count opened intervals: %w", err)
 	} else if count >= 1 {
 		return ErrExistingOpenInterval
 	}
 
+	return nil
+}
+
+// start inserts a new opened interval after having checked t doesn't
+// fall in a closed interval of the current context.
+func (tt *TimeTracker) start(tx *sqlx.Tx, t time.Time, tags []string) error {
 	// Check the requested start time doesn't fall in a known closed interval
 	var count int
 	row := tx.QueryRow(`
@@ -144,7 +710,8 @@ func (tt *TimeTracker) Start(t time.Time, tags []string) (ret error) {
 			INNER JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
 			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
 		WHERE start_timestamp <= ?1 AND stop_timestamp > ?1
-			AND interval_tombstone.uuid IS NULL`, t.Unix())
+			AND interval_tombstone.uuid IS NULL
+			AND context = ?2`, t.Unix(), tt.context)
 	if err := row.Scan(&count); err != nil {
 		return fmt.Errorf("cannot count overlapping closed interval: %w", err)
 	}
@@ -153,46 +720,83 @@ func (tt *TimeTracker) Start(t time.Time, tags []string) (ret error) {
 	}
 
 	// Preconditions ok. Start inserting the new opened interval.
+	if _, err := tt.insertIntervalStart(tx, t, tags); err != nil {
+		return err
+	}
+
+	return nil
+}
 
-	// Ensure all requested tags are already known
+// validateTags checks tags can be stored. Tags may hold any character
+// but commas which are used as tag separator in reports and on the command line.
+func validateTags(tags []string) error {
 	for _, tag := range tags {
-		if _, err := tx.Exec(
-			`INSERT INTO tags (name, created_at)
-			VALUES (?, ?)
-			ON CONFLICT DO NOTHING`,
-			tag,
-			tt.now().Unix(),
-		); err != nil {
-			return fmt.Errorf("cannot insert missing tag %s: %w", tag, err)
+		if tag == "" || strings.Contains(tag, ",") {
+			return fmt.Errorf("%w: %q", ErrInvalidTag, tag)
 		}
 	}
+	return nil
+}
+
+// insertIntervalStart inserts a new interval starting at t and links it
+// with its tags in the current context and place. It returns the uuid of the new interval.
+// No validity check is performed.
+func (tt *TimeTracker) insertIntervalStart(tx *sqlx.Tx, t time.Time, tags []string) (string, error) {
+	if err := tt.checkLockedTime(t); err != nil {
+		return "", err
+	}
+	tags = normalizeTags(tags)
+	if err := validateTags(tags); err != nil {
+		return "", err
+	}
+	if err := tt.checkTagCount(len(tags)); err != nil {
+		return "", err
+	}
 
 	// Insert the new interval
 	var newUUID string
-	row = tx.QueryRow(`
-		INSERT INTO interval_start (uuid, start_timestamp, created_at)
-		VALUES(uuid(), ?, ?)
+	row := tx.QueryRow(`
+		INSERT INTO interval_start (uuid, start_timestamp, created_at, tz, context, location)
+		VALUES(uuid(), ?, ?, NULLIF(?, ''), ?, NULLIF(?, ''))
 		RETURNING (uuid)
-	`, t.Unix(), tt.now().Unix())
+	`, t.Unix(), tt.now().Unix(), zoneName(t.Location()), tt.context, tt.place)
 	if err := row.Scan(&newUUID); err != nil {
-		return fmt.Errorf("cannot insert new interval: %w", err)
+		return "", fmt.Errorf("cannot insert new interval: %w", err)
 	}
 
 	// Link the new interval with its associated tags
+	now := tt.now().Unix()
 	for _, tag := range tags {
-		_, err := tx.Exec(`
-			INSERT INTO interval_tags (uuid, interval_start_uuid, tag, created_at)
-			VALUES (uuid(), ?1, ?2, ?3)
-		`, newUUID, tag, tt.now().Unix())
-		if err != nil {
-			return fmt.Errorf("cannot link new interval with tag %s: %w", tag, err)
+		if err := insertIntervalTag(tx, newUUID, tag, now, now); err != nil {
+			return "", fmt.Errorf("cannot link new interval with tag %s: %w", tag, err)
 		}
 	}
 
+	return newUUID, nil
+}
+
+// insertIntervalTag links the interval identified by intervalUUID with tag.
+// The tag is registered first with the now creation timestamp if it is not known yet.
+func insertIntervalTag(tx execer, intervalUUID, tag string, createdAt, now int64) error {
+	if _, err := tx.Exec(`
+		INSERT INTO tags (name, created_at)
+		VALUES (?, ?)
+		ON CONFLICT DO NOTHING`, tag, now); err != nil {
+		return fmt.Errorf("cannot insert missing tag %s: %w", tag, err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO interval_tags (uuid, interval_start_uuid, tag_id, created_at)
+		SELECT uuid(), ?, id, ?
+		FROM tags
+		WHERE name = ?`, intervalUUID, createdAt, tag); err != nil {
+		return fmt.Errorf("cannot insert interval tag %s: %w", tag, err)
+	}
 	return nil
 }
 
-// Stop close the current opened interval at the requested timestamp.
+// Stop close the current opened interval of the current context at the requested timestamp.
+// It returns ErrNoOpenInterval if there is no interval to close.
 func (tt *TimeTracker) stop(t time.Time, d time.Duration) (ret error) {
 
 	if (!t.IsZero() && d != 0) || (t.IsZero() && d == 0) {
@@ -203,7 +807,7 @@ func (tt *TimeTracker) stop(t time.Time, d time.Duration) (ret error) {
 	if err != nil {
 		return fmt.Errorf("cannot start transaction: %w", err)
 	}
-	defer completeTransaction(tx, &ret)
+	defer tt.completeTransaction(tx, &ret)
 
 	// Check we have a single running timestamp
 	// and that the required stop timestamp is actually after the start timestamp
@@ -217,8 +821,12 @@ func (tt *TimeTracker) stop(t time.Time, d time.Duration) (ret error) {
 			LEFT JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
 			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
 		WHERE stop_timestamp IS NULL AND interval_tombstone.created_at IS NULL
-		LIMIT 1`)
+			AND context = ?
+		LIMIT 1`, tt.context)
 	if err = row.Scan(&intervalUUID, &startTimestampUnix, &count); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoOpenInterval
+		}
 		return fmt.Errorf("cannot count opened interval: %w", err)
 	}
 	if count > 1 {
@@ -230,16 +838,21 @@ func (tt *TimeTracker) stop(t time.Time, d time.Duration) (ret error) {
 	if startTimestampUnix >= t.Unix() {
 		return ErrInvalidStopTimestamp
 	}
+	if err := tt.checkLockedTime(t); err != nil {
+		return err
+	}
 
 	// Check the requested stop timestamp doesn't include other
-	// closed interval.
+	// closed interval. As intervals are half open, stopping exactly
+	// at the start timestamp of the next interval is allowed.
 	row = tx.QueryRow(`
 		SELECT count(1)
 		FROM interval_start
 			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
 		WHERE start_timestamp > ?
 			AND start_timestamp < ?
-			AND interval_tombstone.uuid IS NULL`, startTimestampUnix, t.Unix())
+			AND interval_tombstone.uuid IS NULL
+			AND context = ?`, startTimestampUnix, t.Unix(), tt.context)
 	if err = row.Scan(&count); err != nil {
 		return fmt.Errorf("cannot count enclosed interval: %w", err)
 	}
@@ -248,14 +861,18 @@ func (tt *TimeTracker) stop(t time.Time, d time.Duration) (ret error) {
 	}
 
 	// preconditions ok. Close the currently opened interval.
-	_, err = tx.Exec(`
+	return tt.insertIntervalStop(tx, intervalUUID, t)
+}
+
+// insertIntervalStop closes the interval identified by startUUID at t.
+// No validity check is performed.
+func (tt *TimeTracker) insertIntervalStop(tx sqlx.Execer, startUUID string, t time.Time) error {
+	if _, err := tx.Exec(`
 		INSERT INTO interval_stop (uuid, start_uuid, stop_timestamp, created_at)
 		VALUES (uuid(), ?, ?, ?)`,
-		intervalUUID, t.Unix(), tt.now().Unix())
-	if err != nil {
-		return fmt.Errorf("cannot insert interval tombstone: %w", err)
+		startUUID, t.Unix(), tt.now().Unix()); err != nil {
+		return fmt.Errorf("cannot insert interval stop: %w", err)
 	}
-
 	return nil
 }
 
@@ -267,15 +884,95 @@ func (tt *TimeTracker) StopFor(d time.Duration) error {
 	return tt.stop(time.Time{}, d)
 }
 
-// XXX add unit test
-func (tt *TimeTracker) getIntervalTags(intervalUUID string) (tags []string, retErr error) {
-	rows, err := tt.db.Query(`
-		SELECT tag
+// StopStale stops the opened intervals of every context started more than the
+// threshold configured with SetAutoStopStale ago. Such an interval is stopped at
+// the most recent record of the database, the last known activity, or threshold
+// after its start when nothing has been recorded since. It is never stopped after
+// the start of a later interval of its context. A warning is logged for each
+// stopped interval. Nothing is done when no threshold is configured.
+func (tt *TimeTracker) StopStale() (ret error) {
+	if tt.autoStopStale == 0 {
+		return nil
+	}
+
+	tx, err := tt.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("cannot start transaction: %w", err)
+	}
+	defer tt.completeTransaction(tx, &ret)
+
+	var opened []struct {
+		ID             string `db:"id"`
+		UUID           string `db:"uuid"`
+		StartTimestamp int64  `db:"start_timestamp"`
+		Context        string `db:"context"`
+	}
+	if err := tx.Select(&opened, `
+		SELECT id, interval_start.uuid, start_timestamp, context
+		FROM interval_start
+			LEFT JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+		WHERE interval_stop.uuid IS NULL
+			AND interval_tombstone.uuid IS NULL`); err != nil {
+		return fmt.Errorf("cannot retrieve opened intervals: %w", err)
+	}
+
+	last, err := lastRecordTime(tx)
+	if err != nil {
+		return err
+	}
+
+	now := tt.now()
+	for _, itv := range opened {
+		start := time.Unix(itv.StartTimestamp, 0)
+		if now.Sub(start) <= tt.autoStopStale {
+			continue
+		}
+
+		stop := last
+		if !stop.After(start) {
+			stop = start.Add(tt.autoStopStale)
+		}
+
+		var next sql.NullInt64
+		if err := tx.Get(&next, `
+			SELECT min(start_timestamp)
+			FROM interval_start
+				LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+			WHERE interval_tombstone.uuid IS NULL
+				AND context = ?
+				AND start_timestamp > ?`, itv.Context, itv.StartTimestamp); err != nil {
+			return fmt.Errorf("cannot retrieve next interval start: %w", err)
+		}
+		if next.Valid && next.Int64 < stop.Unix() {
+			stop = time.Unix(next.Int64, 0)
+		}
+
+		if err := tt.insertIntervalStop(tx, itv.UUID, stop); err != nil {
+			return err
+		}
+		logrus.WithFields(logrus.Fields{
+			"id":    itv.ID,
+			"start": start,
+			"stop":  stop,
+		}).Warn("stale opened interval automatically stopped")
+	}
+
+	return nil
+}
+
+// getIntervalTags returns the tags of an interval in insertion order, as Iterate does.
+// It returns nil when the interval has no tag.
+func getIntervalTags(tx rowsQueryer, intervalUUID string) (tags []string, retErr error) {
+	rows, err := tx.Query(`
+		SELECT tags.name
 		FROM interval_tags
+			JOIN tags ON interval_tags.tag_id = tags.id
 			LEFT JOIN interval_tags_tombstone
 				ON interval_tags.uuid = interval_tags_tombstone.interval_tag_uuid
 		WHERE interval_start_uuid = ?
-			AND interval_tags_tombstone.uuid IS NULL`, intervalUUID)
+			AND interval_tags_tombstone.uuid IS NULL
+		ORDER BY interval_tags.rowid`, intervalUUID)
 	if err != nil {
 		return nil, fmt.Errorf("cannot retrieve associated tags: %w", err)
 	}
@@ -303,79 +1000,76 @@ func (tt *TimeTracker) getIntervalTags(intervalUUID string) (tags []string, retE
 // List returns a list of interval whose start timestamp is equal
 // or after the timestamp given as parameter.
 // XXX add unit test
-func (tt *TimeTracker) List(since, until time.Time) (retTi []TaggedInterval, retErr error) {
-	rows, err := tt.db.Query(`
-		SELECT id, interval_start.uuid, start_timestamp, stop_timestamp
-		FROM interval_start
-			LEFT JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
-			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
-		WHERE
-			(
-				(start_timestamp >= ?1  AND start_timestamp < ?2)
-				OR (stop_timestamp >= ?1 AND stop_timestamp < ?2)
-				OR stop_timestamp IS NULL
-			) AND interval_tombstone.uuid IS NULL
-		ORDER BY start_timestamp`,
-		since.Unix(), until.Unix())
+func (tt *TimeTracker) List(since, until time.Time) ([]TaggedInterval, error) {
+	return tt.ListFiltered(since, until, ListFilter{})
+}
+
+// ListFiltered behaves like List with the intervals restricted by filter.
+func (tt *TimeTracker) ListFiltered(
+	since, until time.Time, filter ListFilter,
+) ([]TaggedInterval, error) {
+	return tt.listFiltered(context.Background(), since, until, filter)
+}
+
+// listFiltered is ListFiltered, the query being cancelled with ctx.
+func (tt *TimeTracker) listFiltered(
+	ctx context.Context, since, until time.Time, filter ListFilter,
+) (retTi []TaggedInterval, retErr error) {
+	it, err := tt.Iterate(ctx, since, until, filter)
 	if err != nil {
-		return nil, fmt.Errorf("cannot query for interval: %w", err)
+		return nil, err
 	}
 	defer func() {
-		if err := rows.Close(); err != nil {
+		if err := it.Close(); err != nil {
 			retTi = nil
 			retErr = fmt.Errorf("closing intervals table rows object: %w", err)
 		}
 	}()
 
 	intervals := make([]TaggedInterval, 0, 126)
-	for rows.Next() {
-		var (
-			unixStartTimestamp int64
-			unixStopTimestamp  sql.NullInt64
-			interval           TaggedInterval
-		)
-
-		if err := rows.Scan(
-			&interval.Interval.ID,
-			&interval.Interval.UUID,
-			&unixStartTimestamp,
-			&unixStopTimestamp); err != nil {
-			return nil, fmt.Errorf("cannot scan value for current row: %w", err)
-		}
-
-		interval.Interval.StartTimestamp = time.Unix(unixStartTimestamp, 0)
-		if unixStopTimestamp.Valid {
-			interval.Interval.StopTimestamp = time.Unix(unixStopTimestamp.Int64, 0)
-		}
-
-		intervals = append(intervals, interval)
+	for it.Next() {
+		intervals = append(intervals, it.Interval())
 	}
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("cannot iterate over query returned rows: %w", err)
-	}
-
-	for idx := range intervals {
-		tags, err := tt.getIntervalTags(intervals[idx].Interval.UUID)
-		if err != nil {
-			return nil, err
-		}
-		intervals[idx].Tags = tags
+	if err := it.Err(); err != nil {
+		return nil, err
 	}
 
 	return intervals, nil
 }
 
+// Delete soft deletes the interval identified by id.
+// It returns ErrNotFound when the interval doesn't exist. An already deleted
+// interval is reported as not found as well since it can't be told apart
+// from a mistyped id.
 func (tt *TimeTracker) Delete(id string) (ret error) {
 
 	tx, err := tt.db.Begin()
 	if err != nil {
 		return fmt.Errorf("cannot start transaction: %w", err)
 	}
-	defer completeTransaction(tx, &ret)
+	defer tt.completeTransaction(tx, &ret)
+
+	if err := tt.checkLock(tx, id); err != nil {
+		return err
+	}
+
+	row := tx.QueryRow(`
+		SELECT interval_start.uuid
+		FROM interval_start
+			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+		WHERE interval_tombstone.uuid IS NULL
+			AND interval_start.id = ?`, id)
+	var intervalUUID string
+	if err := row.Scan(&intervalUUID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: id %s", ErrNotFound, id)
+		}
+		return fmt.Errorf("cannot retrieve uuid from database scan: %w", err)
+	}
 
 	_, err = tx.Exec(`
-		INSERT OR IGNORE INTO interval_tombstone (uuid, start_uuid, created_at)
-		SELECT uuid(), (SELECT uuid FROM interval_start WHERE id = ?), ?`, id, tt.now().Unix())
+		INSERT INTO interval_tombstone (uuid, start_uuid, created_at)
+		VALUES (uuid(), ?, ?)`, intervalUUID, tt.now().Unix())
 	if err != nil {
 		return fmt.Errorf("cannot delete interval %s: %w", id, err)
 	}
@@ -389,7 +1083,38 @@ func (tt *TimeTracker) Tag(id string, tags []string) (ret error) {
 	if err != nil {
 		return fmt.Errorf("cannot start a transaction: %w", err)
 	}
-	defer completeTransaction(tx, &ret)
+	defer tt.completeTransaction(tx, &ret)
+
+	return tt.tag(tx, id, tags)
+}
+
+// TagMany tags all the intervals identified by ids with the same set of tags
+// in a single transaction. Nothing is tagged if any of the ids is invalid.
+func (tt *TimeTracker) TagMany(ids []string, tags []string) (ret error) {
+	tx, err := tt.db.Begin()
+	if err != nil {
+		return fmt.Errorf("cannot start a transaction: %w", err)
+	}
+	defer tt.completeTransaction(tx, &ret)
+
+	for _, id := range ids {
+		if err := tt.tag(tx, id, tags); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (tt *TimeTracker) tag(tx *sql.Tx, id string, tags []string) error {
+	tags = tt.resolveTags(tags)
+	if err := validateTags(tags); err != nil {
+		return err
+	}
+
+	if err := tt.checkLock(tx, id); err != nil {
+		return err
+	}
 
 	row := tx.QueryRow(`
 		SELECT interval_start.uuid
@@ -399,40 +1124,47 @@ func (tt *TimeTracker) Tag(id string, tags []string) (ret error) {
 			AND interval_start.id = ?`, id)
 	var intervalUUID string
 	if err := row.Scan(&intervalUUID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: id %s", ErrNotFound, id)
+		}
 		return fmt.Errorf("cannot retrieve uuid from database scan: %w", err)
 	}
 
+	current, err := getIntervalTags(tx, intervalUUID)
+	if err != nil {
+		return err
+	}
+	// We should try to implement that as a trigger
 	for _, tag := range tags {
-
-		// We should try to implement that as a trigger
-		row := tx.QueryRow(`
-			SELECT count(1)
-			FROM interval_tags
-				LEFT JOIN interval_tags_tombstone
-					ON interval_tags.uuid = interval_tags_tombstone.interval_tag_uuid
-			WHERE interval_start_uuid = ?
-				AND tag = ?
-				AND interval_tags_tombstone.uuid IS NULL`, intervalUUID, tag)
-		var count int
-		if err := row.Scan(&count); err != nil {
-			return fmt.Errorf("cannot scan database: %w", err)
-		}
-		if count >= 1 {
-			return fmt.Errorf("%w: id:%s, tag:%s", ErrDuplicatedIntervalTag, id, tag)
+		for _, currentTag := range current {
+			if tag == currentTag {
+				return fmt.Errorf("%w: id:%s, tag:%s", ErrDuplicatedIntervalTag, id, tag)
+			}
 		}
+	}
+	// Already set tags have been rejected hence all of tags are new ones
+	if err := tt.checkTagCount(len(current) + len(tags)); err != nil {
+		return fmt.Errorf("cannot tag interval %s: %w", id, err)
+	}
 
-		if _, err := tx.Exec(`
-				INSERT INTO tags (name, created_at)
-				VALUES (?, ?)
-				ON CONFLICT DO NOTHING`,
-			tag, tt.now().Unix()); err != nil {
-			return fmt.Errorf("cannot insert new tags %s: %w", tag, err)
+	for _, tag := range tags {
+		createdAt := tt.now().Unix()
+		if tt.preserveTagTimestamps {
+			var firstCreatedAt sql.NullInt64
+			row := tx.QueryRow(`
+				SELECT min(interval_tags.created_at)
+				FROM interval_tags
+					JOIN tags ON interval_tags.tag_id = tags.id
+				WHERE interval_start_uuid = ? AND tags.name = ?`, intervalUUID, tag)
+			if err := row.Scan(&firstCreatedAt); err != nil {
+				return fmt.Errorf("cannot retrieve first tagging timestamp: %w", err)
+			}
+			if firstCreatedAt.Valid {
+				createdAt = firstCreatedAt.Int64
+			}
 		}
 
-		if _, err := tx.Exec(`
-			INSERT INTO interval_tags (uuid, interval_start_uuid, tag, created_at)
-			VALUES (uuid(), ?, ?, ?)
-			ON CONFLICT DO NOTHING`, intervalUUID, tag, tt.now().Unix()); err != nil {
+		if err := insertIntervalTag(tx, intervalUUID, tag, createdAt, tt.now().Unix()); err != nil {
 			return fmt.Errorf("cannot tag interval %s with %s: %w", id, tag, err)
 		}
 	}
@@ -445,7 +1177,11 @@ func (tt *TimeTracker) Untag(id string, tags []string) (ret error) {
 	if err != nil {
 		return fmt.Errorf("cannot start a transaction: %w", err)
 	}
-	defer completeTransaction(tx, &ret)
+	defer tt.completeTransaction(tx, &ret)
+
+	if err := tt.checkLock(tx, id); err != nil {
+		return err
+	}
 
 	row := tx.QueryRow(`
 		SELECT interval_start.uuid
@@ -459,11 +1195,18 @@ func (tt *TimeTracker) Untag(id string, tags []string) (ret error) {
 		return multierror.Append(fmt.Errorf("%w: id %s", ErrNotFound, id), err)
 	}
 
-	for _, tag := range tags {
+	return tt.untag(tx, id, tags)
+}
+
+// untag removes tags from the interval identified by id.
+// No validity check is performed.
+func (tt *TimeTracker) untag(tx *sql.Tx, id string, tags []string) error {
+	for _, tag := range normalizeTags(tags) {
 		if _, err := tx.Exec(`
 			WITH to_delete AS (
 				SELECT interval_tags.uuid
 				FROM interval_tags
+					JOIN tags ON interval_tags.tag_id = tags.id
 					JOIN interval_start
 						ON interval_tags.interval_start_uuid = interval_start.uuid
 					LEFT JOIN interval_tombstone
@@ -473,7 +1216,7 @@ func (tt *TimeTracker) Untag(id string, tags []string) (ret error) {
 				WHERE interval_tags_tombstone.uuid IS NULL
 					AND interval_tombstone.uuid IS NULL
 					AND interval_start.id = ?
-					AND interval_tags.tag = ?
+					AND tags.name = ?
 			)
 			INSERT INTO interval_tags_tombstone (uuid, interval_tag_uuid, created_at)
 			SELECT uuid(), uuid, ? FROM to_delete
@@ -485,22 +1228,25 @@ func (tt *TimeTracker) Untag(id string, tags []string) (ret error) {
 	return nil
 }
 
-// Current returned the currently single opened interval if any.
+// Current returned the currently single opened interval of the current context if any.
 func (tt *TimeTracker) Current() (*TaggedInterval, error) {
 	row := tt.db.QueryRow(`
-		SELECT id, interval_start.uuid, start_timestamp
+		SELECT id, interval_start.uuid, start_timestamp, tz, coalesce(location, '')
 		FROM interval_start
 			LEFT JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
 			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
 		WHERE interval_stop.uuid IS NULL
-			AND interval_tombstone.uuid IS NULL`)
+			AND interval_tombstone.uuid IS NULL
+			AND context = ?`, tt.context)
 
 	var (
 		unixStartTimestamp int64
+		tz                 sql.NullString
 		interval           TaggedInterval
 	)
 	if err := row.Scan(
-		&interval.Interval.ID, &interval.Interval.UUID, &unixStartTimestamp,
+		&interval.Interval.ID, &interval.Interval.UUID, &unixStartTimestamp, &tz,
+		&interval.Interval.Place,
 	); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -509,151 +1255,395 @@ func (tt *TimeTracker) Current() (*TaggedInterval, error) {
 	}
 
 	interval.Interval.StartTimestamp = time.Unix(unixStartTimestamp, 0)
+	interval.Interval.Location = loadZone(tz)
+	interval.Interval.Context = tt.context
 
-	rows, err := tt.db.Query(
-		`SELECT tag FROM interval_tags WHERE interval_start_uuid = ?`,
-		interval.Interval.UUID)
+	tags, err := getIntervalTags(tt.db, interval.Interval.UUID)
 	if err != nil {
-		return nil, fmt.Errorf("cannot fetch tags for interval %s: %w", interval.Interval.ID, err)
+		return nil, err
 	}
+	interval.Tags = tags
 
-	for rows.Next() {
-		var tag string
-		if err := rows.Scan(&tag); err != nil {
-			return nil, fmt.Errorf("cannot scan a tag: %w", err)
-		}
-		interval.Tags = append(interval.Tags, tag)
+	if interval.Estimate, err = getIntervalEstimate(tt.db, interval.Interval.UUID); err != nil {
+		return nil, err
 	}
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("cannot iterate over tags cursor: %w", err)
+
+	if interval.Billable, err = getIntervalBillable(tt.db, interval.Interval.UUID); err != nil {
+		return nil, err
 	}
 
 	return &interval, nil
 }
 
-// Continue opens a new interval with the same tags as the last closed one.
-// It will return an error if there is already an opened interval.
-func (tt *TimeTracker) Continue(t time.Time, id string) (ret error) {
-	tx, err := tt.db.Begin()
-	if err != nil {
-		return fmt.Errorf("cannot start transaction: %w", err)
-	}
-	defer completeTransaction(tx, &ret)
-
-	var count int64
-	row := tx.QueryRow(`
-		SELECT count(1)
+// OpenSince returns the start timestamp of the opened interval of the current
+// context. The returned time is zero when no interval is opened.
+func (tt *TimeTracker) OpenSince() (time.Time, error) {
+	var startTimestamp sql.NullInt64
+	if err := tt.db.Get(&startTimestamp, `
+		SELECT max(start_timestamp)
 		FROM interval_start
 			LEFT JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
 			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
 		WHERE interval_stop.uuid IS NULL
-			AND interval_tombstone.uuid IS NULL`)
-	if err = row.Scan(&count); err != nil {
-		return fmt.Errorf("cannot count opened intervals: %w", err)
+			AND interval_tombstone.uuid IS NULL
+			AND context = ?`, tt.context); err != nil {
+		return time.Time{}, fmt.Errorf("cannot retrieve opened interval start: %w", err)
 	}
-
-	if count >= 1 {
-		return ErrMultipleOpenInterval
+	if !startTimestamp.Valid {
+		return time.Time{}, nil
 	}
+	return time.Unix(startTimestamp.Int64, 0), nil
+}
 
-	row = tx.QueryRow(`
-		SELECT count(1)
+// At returns the interval active at t if any. As intervals are half open,
+// an interval stopped exactly at t is not returned while one started
+// exactly at t is. The opened interval is returned if it started before t.
+func (tt *TimeTracker) At(t time.Time) (*TaggedInterval, error) {
+	row := tt.db.QueryRow(`
+		SELECT id, interval_start.uuid, start_timestamp, stop_timestamp, tz, context,
+			coalesce(location, '')
 		FROM interval_start
 			LEFT JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
 			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
 		WHERE interval_tombstone.uuid IS NULL
 			AND start_timestamp <= ?1
-			AND stop_timestamp > ?1`, t.Unix())
-	if err = row.Scan(&count); err != nil {
-		return fmt.Errorf("cannot count overlapping intervals: %w", err)
+			AND (stop_timestamp > ?1 OR stop_timestamp IS NULL)
+		ORDER BY start_timestamp DESC, interval_start.uuid DESC
+		LIMIT 1`, t.Unix())
+
+	var (
+		unixStartTimestamp int64
+		unixStopTimestamp  sql.NullInt64
+		tz                 sql.NullString
+		interval           TaggedInterval
+	)
+	if err := row.Scan(
+		&interval.Interval.ID, &interval.Interval.UUID, &unixStartTimestamp, &unixStopTimestamp, &tz,
+		&interval.Interval.Context, &interval.Interval.Place,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot scan interval active at %s: %w", t, err)
 	}
+	interval.Interval.Location = loadZone(tz)
 
-	if count >= 1 {
-		return ErrInvalidStartTimestamp
+	interval.Interval.StartTimestamp = time.Unix(unixStartTimestamp, 0)
+	if unixStopTimestamp.Valid {
+		interval.Interval.StopTimestamp = time.Unix(unixStopTimestamp.Int64, 0)
+	}
+
+	tags, err := getIntervalTags(tt.db, interval.Interval.UUID)
+	if err != nil {
+		return nil, err
+	}
+	interval.Tags = tags
+
+	if interval.Estimate, err = getIntervalEstimate(tt.db, interval.Interval.UUID); err != nil {
+		return nil, err
 	}
 
-	var query string
+	if interval.Billable, err = getIntervalBillable(tt.db, interval.Interval.UUID); err != nil {
+		return nil, err
+	}
+
+	return &interval, nil
+}
+
+// IntervalTags returns the tags of the interval identified by id in insertion order.
+// It returns ErrNotFound when the interval doesn't exist or has been deleted.
+func (tt *TimeTracker) IntervalTags(id string) ([]string, error) {
+	var intervalUUID string
+	row := tt.db.QueryRow(`
+		SELECT interval_start.uuid
+		FROM interval_start
+			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+		WHERE interval_tombstone.uuid IS NULL
+			AND interval_start.id = ?`, id)
+	if err := row.Scan(&intervalUUID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: id %s", ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("cannot retrieve uuid from database scan: %w", err)
+	}
+
+	return getIntervalTags(tt.db, intervalUUID)
+}
+
+// LastIntervalTags returns the tags of the most recently started interval of
+// the current context, whether it is opened or not, minus the except tags.
+// It returns ErrNotFound when no interval has been recorded.
+func (tt *TimeTracker) LastIntervalTags(except []string) ([]string, error) {
+	var intervalUUID string
+	row := tt.db.QueryRow(`
+		SELECT interval_start.uuid
+		FROM interval_start
+			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+		WHERE interval_tombstone.uuid IS NULL
+			AND context = ?
+		ORDER BY start_timestamp DESC, interval_start.uuid DESC
+		LIMIT 1`, tt.context)
+	if err := row.Scan(&intervalUUID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: no interval recorded", ErrNotFound)
+		}
+		return nil, fmt.Errorf("cannot retrieve last interval: %w", err)
+	}
+
+	tags, err := getIntervalTags(tt.db, intervalUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	var kept []string
+	for _, tag := range tags {
+		if !containsTag(except, tag) {
+			kept = append(kept, tag)
+		}
+	}
+	return kept, nil
+}
+
+// RecentClosed returns the n most recently started closed intervals of the
+// current context with their tags, the most recent first.
+func (tt *TimeTracker) RecentClosed(n int) ([]TaggedInterval, error) {
+	intervals, err := tt.recentClosed(n)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tags are retrieved once the intervals rows are closed
+	for idx := range intervals {
+		tags, err := getIntervalTags(tt.db, intervals[idx].Interval.UUID)
+		if err != nil {
+			return nil, err
+		}
+		intervals[idx].Tags = tags
+	}
+
+	return intervals, nil
+}
+
+func (tt *TimeTracker) recentClosed(n int) (intervals []TaggedInterval, retErr error) {
+	rows, err := tt.db.Query(`
+		SELECT id, interval_start.uuid, start_timestamp, stop_timestamp, tz, context,
+			coalesce(location, '')
+		FROM interval_start
+			JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+		WHERE interval_tombstone.uuid IS NULL
+			AND context = ?
+		ORDER BY start_timestamp DESC, interval_start.uuid DESC
+		LIMIT ?`, tt.context, n)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query recent intervals: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			intervals = nil
+			retErr = fmt.Errorf("closing recent intervals rows object: %w", err)
+		}
+	}()
+
+	for rows.Next() {
+		var (
+			unixStartTimestamp int64
+			unixStopTimestamp  int64
+			tz                 sql.NullString
+			interval           TaggedInterval
+		)
+		if err := rows.Scan(
+			&interval.Interval.ID, &interval.Interval.UUID, &unixStartTimestamp, &unixStopTimestamp,
+			&tz, &interval.Interval.Context, &interval.Interval.Place,
+		); err != nil {
+			return nil, fmt.Errorf("cannot scan recent interval: %w", err)
+		}
+		interval.Interval.Location = loadZone(tz)
+		interval.Interval.StartTimestamp = time.Unix(unixStartTimestamp, 0)
+		interval.Interval.StopTimestamp = time.Unix(unixStopTimestamp, 0)
+		intervals = append(intervals, interval)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("cannot iterate over recent intervals rows: %w", err)
+	}
+
+	return
+}
+
+// Continue opens a new interval with the same tags as the last closed one.
+// The new interval starts at startTime in the current context and place.
+// It will return an error if there is already an opened interval in the current context.
+// When no id is given, the last interval is the most recent one of the current
+// context carrying all the requiredTags. requiredTags is ignored when an id is given.
+// The new interval is checked and inserted as Start does.
+func (tt *TimeTracker) Continue(startTime time.Time, id string, requiredTags []string) (ret error) {
+	tx, err := tt.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("cannot start transaction: %w", err)
+	}
+	defer tt.completeTransaction(tx, &ret)
+
+	if err := tt.checkStartPreconditions(tx); errors.Is(err, ErrExistingOpenInterval) {
+		return ErrMultipleOpenInterval
+	} else if err != nil {
+		return err
+	}
+
+	requiredTags = tt.resolveTags(requiredTags)
+	if requiredTags == nil {
+		requiredTags = []string{}
+	}
+	jsonRequiredTags, err := json.Marshal(requiredTags)
+	if err != nil {
+		return fmt.Errorf("cannot marshal required tags: %w", err)
+	}
+
+	// The interval is looked up on its own so that a missing interval
+	// is not confused with an interval without any tag
+	var row *sql.Row
 	if id == "" {
-		query = `WITH last_id AS (
-			SELECT id, interval_start.uuid
+		row = tx.QueryRow(`
+			SELECT interval_start.uuid
 			FROM interval_start
 				LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+				LEFT JOIN interval_tags
+					ON interval_tags.interval_start_uuid = interval_start.uuid
+						AND interval_tags.tag_id IN (
+							SELECT id FROM tags WHERE name IN (SELECT value FROM json_each(?)))
+				LEFT JOIN interval_tags_tombstone
+					ON interval_tags_tombstone.interval_tag_uuid = interval_tags.uuid
 			WHERE interval_tombstone.uuid IS NULL
-			ORDER BY start_timestamp DESC
-			LIMIT 1
-		)
-		SELECT last_id.uuid, interval_tags.tag
-		FROM last_id
-			LEFT JOIN interval_tags
-				ON interval_tags.interval_start_uuid = last_id.uuid
-			LEFT JOIN interval_tags_tombstone
-				ON interval_tags_tombstone.interval_tag_uuid = interval_tags.uuid
-		WHERE interval_tags_tombstone.uuid IS NULL`
+				AND interval_start.context = ?
+			GROUP BY interval_start.uuid
+			HAVING count(DISTINCT CASE
+					WHEN interval_tags_tombstone.uuid IS NULL THEN interval_tags.tag_id
+				END) = json_array_length(?)
+			ORDER BY max(interval_start.start_timestamp) DESC, interval_start.uuid DESC
+			LIMIT 1`, string(jsonRequiredTags), tt.context, string(jsonRequiredTags))
 	} else {
-		query = `
-			SELECT interval_start.uuid, interval_tags.tag
+		row = tx.QueryRow(`
+			SELECT interval_start.uuid
 			FROM interval_start
-				LEFT JOIN interval_tags
-					ON interval_start.uuid = interval_tags.interval_start_uuid
 				LEFT JOIN interval_tombstone
 					ON interval_start.uuid = interval_tombstone.start_uuid
-				LEFT JOIN interval_tags_tombstone
-					ON interval_tags.uuid = interval_tags_tombstone.interval_tag_uuid
 			WHERE interval_tombstone.uuid IS NULL
-				AND interval_tags_tombstone.uuid IS NULL
-				AND interval_start.id = ?
-		`
+				AND interval_start.id = ?`, id)
 	}
 
-	rows, err := tx.Query(query, id)
-	if err != nil {
-		return fmt.Errorf("cannot retrieve tags associated with last closed interval: %w", err)
+	var UUID string
+	if err := row.Scan(&UUID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("cannot find interval to continue: %w", ErrNotFound)
+		}
+		return fmt.Errorf("cannot retrieve interval to continue: %w", err)
 	}
 
-	var UUID string
-	var tags []string
+	tags, err := getIntervalTags(tx, UUID)
+	if err != nil {
+		return fmt.Errorf("cannot retrieve tags of interval to continue: %w", err)
+	}
+	return tt.start(tx, startTime, tt.startTags(tags))
+}
 
-	for rows.Next() {
-		var t sql.NullString
-		if err := rows.Scan(&UUID, &t); err != nil {
-			return fmt.Errorf("cannot scan tag: %w", err)
-		}
-		if t.Valid {
-			tags = append(tags, t.String)
-		}
+// Vacuum hard deletes all data which has been soft deleted before the timestamp.
+// It will also remove unused tags. At the end of the clean process, it will
+// perform a database vacuum.
+// Beware that data already pushed to a synchronisation database will be
+// retrieved back on a full synchronisation.
+func (tt *TimeTracker) Vacuum(before time.Time) error {
+	if err := tt.deleteTombstoned(before); err != nil {
+		return err
 	}
-	if err := rows.Err(); err != nil {
-		return fmt.Errorf("cannot iterate over tags cursor: %w", err)
+
+	// VACUUM cannot run within a transaction
+	if _, err := tt.db.Exec(`VACUUM`); err != nil {
+		return fmt.Errorf("cannot vacuum database: %w", err)
 	}
+	return nil
+}
 
-	if UUID == "" {
-		return fmt.Errorf("cannot find interval to continue: %w", ErrNotFound)
+// deleteTombstoned hard deletes in a single transaction the intervals and
+// the interval tags tombstoned before the timestamp, along with their tombstones,
+// and the tags which are not referenced anymore.
+func (tt *TimeTracker) deleteTombstoned(before time.Time) (ret error) {
+	tx, err := tt.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("cannot start transaction: %w", err)
 	}
+	defer tt.completeTransaction(tx, &ret)
 
-	var newUUID string
-	row = tx.QueryRow(`
-		INSERT INTO interval_start (uuid, start_timestamp, created_at)
-		VALUES (uuid(), ?, ?)
-		RETURNING (uuid)`, t.Unix(), tt.now().Unix())
-	if err := row.Scan(&newUUID); err != nil {
-		return fmt.Errorf("cannot insert new interval: %w", err)
+	// The rows are deleted before the tombstones referencing them, which
+	// are needed to select them, hence the foreign keys are checked on commit.
+	// The pragma is switched off at the end of the transaction.
+	if _, err := tx.Exec(`PRAGMA defer_foreign_keys = ON`); err != nil {
+		return fmt.Errorf("cannot defer foreign keys check: %w", err)
 	}
 
-	for _, t := range tags {
-		_, err := tx.Exec(`
-			INSERT INTO interval_tags (uuid, interval_start_uuid, tag, created_at)
-			VALUES (uuid(), ?, ?, ?)`, newUUID, t, tt.now().Unix())
-		if err != nil {
-			return fmt.Errorf("cannot tag interval %s with value %s: %w", newUUID, t, err)
+	const intervals = `SELECT start_uuid FROM interval_tombstone WHERE created_at < ?1`
+
+	// The interval tombstones are deleted after the interval stops
+	// for the daily totals to be left untouched.
+	for _, statement := range []struct{ table, where string }{
+		{"interval_billable_tombstone", `billable_uuid IN (
+			SELECT uuid FROM interval_billable WHERE start_uuid IN (` + intervals + `))`},
+		{"interval_billable", `start_uuid IN (` + intervals + `)`},
+		{"interval_estimate_tombstone", `estimate_uuid IN (
+			SELECT uuid FROM interval_estimate WHERE start_uuid IN (` + intervals + `))`},
+		{"interval_estimate", `start_uuid IN (` + intervals + `)`},
+		{"interval_tags", `interval_start_uuid IN (` + intervals + `)
+			OR uuid IN (
+				SELECT interval_tag_uuid FROM interval_tags_tombstone WHERE created_at < ?1)`},
+		{"interval_tags_tombstone", `interval_tag_uuid NOT IN (SELECT uuid FROM interval_tags)`},
+		{"interval_stop", `start_uuid IN (` + intervals + `)`},
+		{"interval_start", `uuid IN (` + intervals + `)`},
+		{"interval_tombstone", `created_at < ?1`},
+		{"tags", `id NOT IN (SELECT tag_id FROM interval_tags)`},
+	} {
+		if _, err := tx.Exec(
+			`DELETE FROM `+statement.table+` WHERE `+statement.where, before.Unix(),
+		); err != nil {
+			return fmt.Errorf("cannot delete soft deleted rows from table %s: %w",
+				statement.table, err)
 		}
 	}
 
 	return nil
 }
 
-// Vacuum hard deletes all data which has been soft deleted before the timestamp.
-// It will also remove unused tags. At the end of the clean process, it will
-// perform a database vacuum.
-func (tt *TimeTracker) Vacuum(before time.Time) (ret error) {
-	return ErrNotImplemented
+// Purge deletes all recorded intervals and tags in a single transaction.
+// The synchronisation history is deleted as well when syncHistory is set.
+// Unlike Vacuum, it is not restricted to soft deleted data. The schema is left untouched.
+// Beware that data already pushed to a synchronisation database will be
+// retrieved back on the next synchronisation.
+func (tt *TimeTracker) Purge(syncHistory bool) (ret error) {
+	tx, err := tt.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("cannot start transaction: %w", err)
+	}
+	defer tt.completeTransaction(tx, &ret)
+
+	tables := []string{
+		"interval_billable_tombstone",
+		"interval_billable",
+		"interval_estimate_tombstone",
+		"interval_estimate",
+		"interval_tags_tombstone",
+		"interval_tags",
+		"interval_tombstone",
+		"interval_stop",
+		"interval_start",
+		"tags",
+		"daily_totals",
+	}
+	if syncHistory {
+		tables = append(tables, "sync_history")
+	}
+
+	for _, table := range tables {
+		if _, err := tx.Exec(`DELETE FROM ` + table); err != nil {
+			return fmt.Errorf("cannot purge table %s: %w", table, err)
+		}
+	}
+
+	return nil
 }
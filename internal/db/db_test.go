@@ -1,11 +1,18 @@
 package db
 
 import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/GuiaBolso/darwin"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
+
+	"github.com/dgsb/tt/internal/funk"
 )
 
 func setupTT(t *testing.T, file ...string) *TimeTracker {
@@ -64,6 +71,7 @@ func TestTimeTracker(t *testing.T) {
 		_, err = uuid.Parse(ti.UUID)
 		require.NoError(t, err)
 		ti.UUID = ""
+		ti.Location = nil
 		require.Equal(t, &TaggedInterval{
 			Interval: Interval{
 				ID:             "1",
@@ -82,6 +90,7 @@ func TestTimeTracker(t *testing.T) {
 		tia, err := tt.List(now.Add(-1*time.Hour), now.Add(2*time.Hour))
 		require.NoError(t, err)
 		tia[0].UUID = ""
+		tia[0].Location = nil
 
 		require.Equal(t, []TaggedInterval{
 			{
@@ -109,6 +118,7 @@ func TestTimeTracker(t *testing.T) {
 		require.NoError(t, err)
 		for idx := range itv {
 			itv[idx].Interval.UUID = ""
+			itv[idx].Interval.Location = nil
 		}
 		require.Equal(t, []TaggedInterval{
 			{
@@ -139,6 +149,17 @@ func TestTimeTracker(t *testing.T) {
 		require.Error(t, err)
 	})
 
+	t.Run("stop without opened interval - failed", func(t *testing.T) {
+		tt := setupTT(t)
+		require.ErrorIs(t, tt.StopAt(time.Now()), ErrNoOpenInterval)
+		require.ErrorIs(t, tt.StopFor(time.Hour), ErrNoOpenInterval)
+
+		now := time.Now()
+		require.NoError(t, tt.Start(now.Add(-time.Hour), nil))
+		require.NoError(t, tt.StopAt(now))
+		require.ErrorIs(t, tt.StopAt(now.Add(time.Hour)), ErrNoOpenInterval)
+	})
+
 	t.Run("simple check stop timestamp after start timestamp", func(t *testing.T) {
 		tt := setupTT(t)
 
@@ -264,6 +285,7 @@ func TestTimeTracker(t *testing.T) {
 		require.NoError(t, err)
 		require.Len(t, itv, 1)
 		itv[0].UUID = ""
+		itv[0].Location = nil
 		require.Equal(t, []TaggedInterval{
 			{
 				Interval: Interval{
@@ -323,7 +345,7 @@ func TestTimeTracker(t *testing.T) {
 
 		// Implicit continue without previous interval should fail
 		{
-			err := tt.Continue(time.Now(), "")
+			err := tt.Continue(time.Now(), "", nil)
 			require.Error(t, err)
 		}
 
@@ -335,7 +357,7 @@ func TestTimeTracker(t *testing.T) {
 			err = tt.Delete("1")
 			require.NoError(t, err)
 
-			err = tt.Continue(time.Now(), "1")
+			err = tt.Continue(time.Now(), "1", nil)
 			require.Error(t, err)
 		}
 
@@ -351,19 +373,19 @@ func TestTimeTracker(t *testing.T) {
 		err = tt.StopAt(time.Date(2022, 2, 25, 15, 0, 0, 0, time.UTC))
 		require.NoError(t, err)
 
-		err = tt.Continue(time.Date(2022, 2, 25, 15, 0, 0, 0, time.UTC), "")
+		err = tt.Continue(time.Date(2022, 2, 25, 15, 0, 0, 0, time.UTC), "", nil)
 		require.NoError(t, err)
 
 		err = tt.StopAt(time.Date(2022, 2, 25, 16, 0, 0, 0, time.UTC))
 		require.NoError(t, err)
 
-		err = tt.Continue(time.Date(2022, 2, 25, 16, 0, 0, 0, time.UTC), "2")
+		err = tt.Continue(time.Date(2022, 2, 25, 16, 0, 0, 0, time.UTC), "2", nil)
 		require.NoError(t, err)
 
 		err = tt.StopAt(time.Date(2022, 2, 25, 17, 0, 0, 0, time.UTC))
 		require.NoError(t, err)
 
-		err = tt.Continue(time.Date(2022, 2, 25, 18, 0, 0, 0, time.UTC), "3")
+		err = tt.Continue(time.Date(2022, 2, 25, 18, 0, 0, 0, time.UTC), "3", nil)
 		require.NoError(t, err)
 
 		err = tt.StopAt(time.Date(2022, 2, 25, 19, 0, 0, 0, time.UTC))
@@ -399,13 +421,13 @@ func TestTimeTracker(t *testing.T) {
 		err = tt.Untag("1", []string{"tag2"})
 		require.NoError(t, err)
 
-		err = tt.Continue(now.Add(-58*time.Minute), "")
+		err = tt.Continue(now.Add(-58*time.Minute), "", nil)
 		require.NoError(t, err)
 
 		err = tt.StopAt(now.Add(-57 * time.Minute))
 		require.NoError(t, err)
 
-		err = tt.Continue(now.Add(-56*time.Minute), "1")
+		err = tt.Continue(now.Add(-56*time.Minute), "1", nil)
 		require.NoError(t, err)
 
 		err = tt.StopAt(now.Add(-55 * time.Minute))
@@ -415,6 +437,7 @@ func TestTimeTracker(t *testing.T) {
 		require.NoError(t, err)
 		for idx := range itv {
 			itv[idx].UUID = ""
+			itv[idx].Location = nil
 		}
 		require.Equal(t, []TaggedInterval{
 			{
@@ -443,4 +466,1622 @@ func TestTimeTracker(t *testing.T) {
 			},
 		}, itv)
 	})
+
+	t.Run("continue start timestamp", func(t *testing.T) {
+		tt := setupTT(t)
+		loc := time.FixedZone("UTC+2", 2*60*60)
+		start := time.Date(2022, 2, 25, 10, 0, 0, 0, loc)
+		continued := time.Date(2022, 2, 25, 11, 17, 43, 0, loc)
+		tt.now = func() time.Time { return continued.Add(time.Hour) }
+
+		require.NoError(t, tt.Start(start, []string{"tag1", "tag2"}))
+		require.NoError(t, tt.StopAt(start.Add(time.Hour)))
+		require.NoError(t, tt.Continue(continued, "", nil))
+
+		ti, err := tt.Current()
+		require.NoError(t, err)
+		require.Equal(t, []string{"tag1", "tag2"}, ti.Tags)
+		require.True(t, continued.Equal(ti.StartTimestamp), ti.StartTimestamp)
+	})
+
+	t.Run("continue tagless interval", func(t *testing.T) {
+		at := func(hour int) time.Time {
+			return time.Date(2022, 2, 25, hour, 0, 0, 0, time.UTC)
+		}
+		tt := setupTT(t)
+
+		require.NoError(t, tt.Start(at(10), nil))
+		require.NoError(t, tt.StopAt(at(11)))
+		require.NoError(t, tt.Start(at(11), []string{"tag1"}))
+		require.NoError(t, tt.StopAt(at(12)))
+		require.NoError(t, tt.Untag("2", []string{"tag1"}))
+
+		// Both intervals have no tag but they exist
+		require.NoError(t, tt.Continue(at(12), "1", nil))
+		require.NoError(t, tt.StopAt(at(13)))
+		require.NoError(t, tt.Continue(at(13), "2", nil))
+		require.NoError(t, tt.StopAt(at(14)))
+		require.NoError(t, tt.Continue(at(14), "", nil))
+		require.NoError(t, tt.StopAt(at(15)))
+
+		require.ErrorIs(t, tt.Continue(at(15), "42", nil), ErrNotFound)
+		require.NoError(t, tt.Delete("1"))
+		require.ErrorIs(t, tt.Continue(at(15), "1", nil), ErrNotFound)
+
+		itv, err := tt.List(at(0), at(23))
+		require.NoError(t, err)
+		require.Len(t, itv, 4)
+		for _, ta := range itv {
+			require.Empty(t, ta.Tags, ta.ID)
+		}
+	})
+
+	t.Run("continue with required tags", func(t *testing.T) {
+		tt := setupTT(t)
+
+		err := tt.Start(time.Date(2022, 2, 25, 12, 0, 0, 0, time.UTC), []string{"tag1", "tag2"})
+		require.NoError(t, err)
+
+		err = tt.StopAt(time.Date(2022, 2, 25, 13, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+
+		err = tt.Start(time.Date(2022, 2, 25, 13, 0, 0, 0, time.UTC), []string{"tag1", "tag3"})
+		require.NoError(t, err)
+
+		err = tt.StopAt(time.Date(2022, 2, 25, 14, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+
+		err = tt.Start(time.Date(2022, 2, 25, 14, 0, 0, 0, time.UTC), []string{"tag1", "tag2", "tag4"})
+		require.NoError(t, err)
+
+		err = tt.StopAt(time.Date(2022, 2, 25, 15, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+
+		err = tt.Untag("3", []string{"tag2"})
+		require.NoError(t, err)
+
+		// The most recent interval lost tag2, the first one is chosen
+		err = tt.Continue(time.Date(2022, 2, 25, 15, 0, 0, 0, time.UTC), "", []string{"tag1", "tag2"})
+		require.NoError(t, err)
+
+		err = tt.StopAt(time.Date(2022, 2, 25, 16, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+
+		// The most recent interval doesn't hold tag3, the second one is chosen
+		err = tt.Continue(time.Date(2022, 2, 25, 16, 0, 0, 0, time.UTC), "", []string{"tag3"})
+		require.NoError(t, err)
+
+		err = tt.StopAt(time.Date(2022, 2, 25, 17, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+
+		// No interval holds tag5
+		err = tt.Continue(time.Date(2022, 2, 25, 17, 0, 0, 0, time.UTC), "", []string{"tag5"})
+		require.ErrorIs(t, err, ErrNotFound)
+
+		itv, err := tt.List(
+			time.Date(2022, 2, 25, 11, 0, 0, 0, time.UTC),
+			time.Date(2022, 2, 25, 20, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		require.Len(t, itv, 5)
+		require.Equal(t, "4", itv[3].ID)
+		require.Equal(t, []string{"tag1", "tag2"}, itv[3].Tags)
+		require.Equal(t, "5", itv[4].ID)
+		require.Equal(t, []string{"tag1", "tag3"}, itv[4].Tags)
+	})
+}
+
+func TestDumpSchema(t *testing.T) {
+	tt := setupTT(t)
+
+	err := tt.Start(time.Date(2022, 2, 25, 12, 0, 0, 0, time.UTC), []string{"secret-tag"})
+	require.NoError(t, err)
+
+	dump, err := tt.DumpSchema()
+	require.NoError(t, err)
+
+	for _, table := range []string{
+		"interval_start",
+		"interval_stop",
+		"interval_tombstone",
+		"interval_tags",
+		"interval_tags_tombstone",
+		"tags",
+		"sync_history",
+		"darwin_migrations",
+	} {
+		require.Contains(t, dump, "-- table "+table+"\n")
+	}
+	require.Contains(t, dump, "-- version 6: ")
+	require.NotContains(t, dump, "secret-tag")
+}
+
+func TestLockDate(t *testing.T) {
+	setup := func(t *testing.T) *TimeTracker {
+		tt := setupTT(t)
+
+		err := tt.Start(time.Date(2022, 2, 25, 12, 0, 0, 0, time.UTC), []string{"tag1"})
+		require.NoError(t, err)
+		err = tt.StopAt(time.Date(2022, 2, 25, 13, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+
+		err = tt.Start(time.Date(2022, 2, 26, 12, 0, 0, 0, time.UTC), []string{"tag1"})
+		require.NoError(t, err)
+		err = tt.StopAt(time.Date(2022, 2, 26, 13, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+
+		tt.SetLockDate(time.Date(2022, 2, 26, 0, 0, 0, 0, time.UTC))
+		return tt
+	}
+
+	t.Run("delete", func(t *testing.T) {
+		tt := setup(t)
+
+		err := tt.Delete("1")
+		require.ErrorIs(t, err, ErrIntervalLocked)
+
+		err = tt.Delete("2")
+		require.NoError(t, err)
+
+		itv, err := tt.List(
+			time.Date(2022, 2, 25, 0, 0, 0, 0, time.UTC),
+			time.Date(2022, 2, 27, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		require.Len(t, itv, 1)
+		require.Equal(t, "1", itv[0].ID)
+	})
+
+	t.Run("tag and untag", func(t *testing.T) {
+		tt := setup(t)
+
+		err := tt.Tag("1", []string{"tag2"})
+		require.ErrorIs(t, err, ErrIntervalLocked)
+
+		err = tt.Untag("1", []string{"tag1"})
+		require.ErrorIs(t, err, ErrIntervalLocked)
+
+		err = tt.Tag("2", []string{"tag2"})
+		require.NoError(t, err)
+
+		err = tt.Untag("2", []string{"tag1"})
+		require.NoError(t, err)
+	})
+
+	t.Run("record and import", func(t *testing.T) {
+		tt := setup(t)
+
+		err := tt.Start(time.Date(2022, 2, 25, 14, 0, 0, 0, time.UTC), []string{"tag2"})
+		require.ErrorIs(t, err, ErrIntervalLocked)
+
+		err = tt.Import([]TaggedInterval{{
+			Interval: Interval{
+				StartTimestamp: time.Date(2022, 2, 25, 14, 0, 0, 0, time.UTC),
+				StopTimestamp:  time.Date(2022, 2, 25, 15, 0, 0, 0, time.UTC),
+			},
+			Tags: []string{"tag2"},
+		}}, nil)
+		require.ErrorIs(t, err, ErrIntervalLocked)
+
+		err = tt.Import([]TaggedInterval{{
+			Interval: Interval{
+				StartTimestamp: time.Date(2022, 2, 26, 14, 0, 0, 0, time.UTC),
+				StopTimestamp:  time.Date(2022, 2, 26, 15, 0, 0, 0, time.UTC),
+			},
+			Tags: []string{"tag2"},
+		}}, nil)
+		require.NoError(t, err)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		tt := setup(t)
+
+		tt.SetLockDate(time.Time{})
+		require.NoError(t, tt.Start(time.Date(2022, 2, 27, 12, 0, 0, 0, time.UTC), []string{"tag1"}))
+		tt.SetLockDate(time.Date(2022, 2, 28, 0, 0, 0, 0, time.UTC))
+
+		err := tt.StopAt(time.Date(2022, 2, 27, 13, 0, 0, 0, time.UTC))
+		require.ErrorIs(t, err, ErrIntervalLocked)
+
+		err = tt.StopAt(time.Date(2022, 2, 28, 9, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+	})
+
+	t.Run("unlock", func(t *testing.T) {
+		tt := setup(t)
+
+		tt.SetLockDate(time.Time{})
+		err := tt.Delete("1")
+		require.NoError(t, err)
+	})
+}
+
+func TestDelete(t *testing.T) {
+	tt := setupTT(t)
+	require.NoError(t, tt.Start(time.Date(2022, 2, 25, 10, 0, 0, 0, time.UTC), []string{"a"}))
+	require.NoError(t, tt.StopAt(time.Date(2022, 2, 25, 11, 0, 0, 0, time.UTC)))
+
+	require.ErrorIs(t, tt.Delete("2"), ErrNotFound)
+	require.NoError(t, tt.Delete("1"))
+	require.ErrorIs(t, tt.Delete("1"), ErrNotFound)
+
+	var count int
+	require.NoError(t, tt.db.Get(&count, `SELECT count(1) FROM interval_tombstone`))
+	require.Equal(t, 1, count)
+}
+
+func TestAdjacentIntervals(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 2, 25, hour, 0, 0, 0, time.UTC)
+	}
+
+	t.Run("start at previous stop", func(t *testing.T) {
+		tt := setupTT(t)
+
+		require.NoError(t, tt.Start(at(10), nil))
+		require.NoError(t, tt.StopAt(at(11)))
+		require.NoError(t, tt.Start(at(11), nil))
+		require.NoError(t, tt.StopAt(at(12)))
+		require.NoError(t, tt.Continue(at(12), "", nil))
+		require.NoError(t, tt.StopAt(at(13)))
+
+		require.NoError(t, NewSanity(tt.db).Check())
+	})
+
+	t.Run("stop at next start", func(t *testing.T) {
+		tt := setupTT(t)
+
+		require.NoError(t, tt.Start(at(11), nil))
+		require.NoError(t, tt.StopAt(at(12)))
+		require.NoError(t, tt.Start(at(10), nil))
+		require.NoError(t, tt.StopAt(at(11)))
+
+		require.NoError(t, NewSanity(tt.db).Check())
+	})
+
+	t.Run("fill a gap exactly", func(t *testing.T) {
+		tt := setupTT(t)
+
+		require.NoError(t, tt.Start(at(10), nil))
+		require.NoError(t, tt.StopAt(at(11)))
+		require.NoError(t, tt.Start(at(12), nil))
+		require.NoError(t, tt.StopAt(at(13)))
+		require.NoError(t, tt.Start(at(11), nil))
+		require.NoError(t, tt.StopAt(at(12)))
+
+		require.NoError(t, NewSanity(tt.db).Check())
+
+		itv, err := tt.List(at(0), at(23))
+		require.NoError(t, err)
+		require.Len(t, itv, 3)
+		for idx := 1; idx < len(itv); idx++ {
+			require.True(t, itv[idx-1].StopTimestamp.Equal(itv[idx].StartTimestamp))
+		}
+	})
+
+	t.Run("one second overlap rejected", func(t *testing.T) {
+		tt := setupTT(t)
+
+		require.NoError(t, tt.Start(at(11), nil))
+		require.NoError(t, tt.StopAt(at(12)))
+
+		require.ErrorIs(t, tt.Start(at(12).Add(-time.Second), nil), ErrInvalidStartTimestamp)
+		require.ErrorIs(t, tt.Continue(at(12).Add(-time.Second), "", nil), ErrInvalidStartTimestamp)
+
+		require.NoError(t, tt.Start(at(10), nil))
+		require.ErrorIs(t, tt.StopAt(at(11).Add(time.Second)), ErrInvalidStopTimestamp)
+		require.NoError(t, tt.StopAt(at(11)))
+	})
+
+	t.Run("sanity detects one second overlap", func(t *testing.T) {
+		tt := setupTT(t)
+
+		require.NoError(t, tt.Start(at(10), nil))
+		require.NoError(t, tt.StopAt(at(11)))
+		require.NoError(t, tt.Start(at(11), nil))
+		require.NoError(t, tt.StopAt(at(12)))
+		require.NoError(t, NewSanity(tt.db).Check())
+
+		_, err := tt.db.Exec(`
+			UPDATE interval_start SET start_timestamp = ? WHERE id = 2`,
+			at(11).Add(-time.Second).Unix())
+		require.NoError(t, err)
+		require.ErrorIs(t, NewSanity(tt.db).checkNoOverlap(), ErrInvalidStartTimestamp)
+
+		// restore the database for the final sanity check
+		_, err = tt.db.Exec(`
+			UPDATE interval_start SET start_timestamp = ? WHERE id = 2`, at(11).Unix())
+		require.NoError(t, err)
+	})
+}
+
+func TestAt(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2022, 2, 25, hour, minute, 0, 0, time.UTC)
+	}
+
+	tt := setupTT(t)
+	require.NoError(t, tt.Start(at(10, 0), []string{"tag1"}))
+	require.NoError(t, tt.StopAt(at(11, 0)))
+	require.NoError(t, tt.Start(at(11, 0), []string{"tag2"}))
+	require.NoError(t, tt.StopAt(at(12, 0)))
+	require.NoError(t, tt.Start(at(13, 0), []string{"tag3"}))
+	require.NoError(t, tt.StopAt(at(14, 0)))
+	require.NoError(t, tt.Delete("3"))
+	require.NoError(t, tt.Start(at(15, 0), []string{"tag4"}))
+
+	for _, tc := range []struct {
+		name     string
+		at       time.Time
+		expected *TaggedInterval
+	}{
+		{
+			name: "inside a closed interval",
+			at:   at(10, 30),
+			expected: &TaggedInterval{
+				Interval: Interval{ID: "1", StartTimestamp: at(10, 0), StopTimestamp: at(11, 0)},
+				Tags:     []string{"tag1"},
+			},
+		},
+		{
+			name: "on a boundary",
+			at:   at(11, 0),
+			expected: &TaggedInterval{
+				Interval: Interval{ID: "2", StartTimestamp: at(11, 0), StopTimestamp: at(12, 0)},
+				Tags:     []string{"tag2"},
+			},
+		},
+		{
+			name: "on a stop boundary followed by a gap",
+			at:   at(12, 0),
+		},
+		{
+			name: "inside a deleted interval",
+			at:   at(13, 30),
+		},
+		{
+			name: "before any interval",
+			at:   at(9, 0),
+		},
+		{
+			name: "inside the opened interval",
+			at:   at(16, 0),
+			expected: &TaggedInterval{
+				Interval: Interval{ID: "4", StartTimestamp: at(15, 0)},
+				Tags:     []string{"tag4"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			itv, err := tt.At(tc.at)
+			require.NoError(t, err)
+			if itv != nil {
+				itv.UUID = ""
+				itv.Location = nil
+				itv.StartTimestamp = itv.StartTimestamp.UTC()
+				if !itv.StopTimestamp.IsZero() {
+					itv.StopTimestamp = itv.StopTimestamp.UTC()
+				}
+			}
+			require.Equal(t, tc.expected, itv)
+		})
+	}
+}
+
+func TestIntervalLocation(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	t.Run("explicit location", func(t *testing.T) {
+		tt := setupTT(t)
+		start := time.Date(2022, 3, 1, 9, 0, 0, 0, newYork)
+		require.NoError(t, tt.Start(start, []string{"a"}))
+
+		current, err := tt.Current()
+		require.NoError(t, err)
+		require.Equal(t, "America/New_York", current.Location.String())
+		require.Equal(t, 9, current.OriginalStart().Hour())
+
+		require.NoError(t, tt.StopAt(start.Add(time.Hour)))
+		itvs, err := tt.List(start.Add(-time.Hour), start.Add(2*time.Hour))
+		require.NoError(t, err)
+		require.Len(t, itvs, 1)
+		require.Equal(t, "America/New_York", itvs[0].Location.String())
+		require.Equal(t, 10, itvs[0].OriginalStop().Hour())
+
+		itv, err := tt.At(start)
+		require.NoError(t, err)
+		require.Equal(t, "America/New_York", itv.Location.String())
+	})
+
+	t.Run("local zone from environment", func(t *testing.T) {
+		t.Setenv("TZ", "Asia/Tokyo")
+		tt := setupTT(t)
+		require.NoError(t, tt.Start(time.Now(), nil))
+
+		current, err := tt.Current()
+		require.NoError(t, err)
+		require.Equal(t, "Asia/Tokyo", current.Location.String())
+	})
+
+	t.Run("unknown zone", func(t *testing.T) {
+		tt := setupTT(t)
+		start := time.Date(2022, 3, 1, 9, 0, 0, 0, time.FixedZone("", 3600))
+		require.NoError(t, tt.Start(start, nil))
+
+		current, err := tt.Current()
+		require.NoError(t, err)
+		require.Nil(t, current.Location)
+		require.True(t, current.OriginalStart().Equal(start))
+	})
+}
+
+func TestClockGuard(t *testing.T) {
+	clock := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	setup := func(t *testing.T, guard ClockGuard) *TimeTracker {
+		tt := setupTT(t)
+		tt.now = func() time.Time { return clock }
+		require.NoError(t, tt.Start(clock.Add(-time.Hour), []string{"a"}))
+		require.NoError(t, tt.StopAt(clock))
+		tt.SetClockGuard(guard, time.Minute)
+		return tt
+	}
+
+	t.Run("clock jumped backward", func(t *testing.T) {
+		tt := setup(t, ClockGuardError)
+		tt.now = func() time.Time { return clock.Add(-10 * time.Minute) }
+		require.ErrorIs(t, tt.Start(clock.Add(-10*time.Minute), nil), ErrClockSkew)
+	})
+
+	t.Run("continue with the clock jumped backward", func(t *testing.T) {
+		tt := setup(t, ClockGuardError)
+		tt.now = func() time.Time { return clock.Add(-10 * time.Minute) }
+		require.ErrorIs(t, tt.Continue(clock.Add(-10*time.Minute), "1", nil), ErrClockSkew)
+		tt.now = func() time.Time { return clock }
+		require.NoError(t, tt.Continue(clock, "1", nil))
+	})
+
+	t.Run("within tolerance", func(t *testing.T) {
+		tt := setup(t, ClockGuardError)
+		tt.now = func() time.Time { return clock.Add(-30 * time.Second) }
+		require.NoError(t, tt.Start(clock, nil))
+	})
+
+	t.Run("warning only", func(t *testing.T) {
+		tt := setup(t, ClockGuardWarn)
+		tt.now = func() time.Time { return clock.Add(-10 * time.Minute) }
+		require.NoError(t, tt.Start(clock, nil))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		tt := setup(t, ClockGuardOff)
+		tt.now = func() time.Time { return clock.Add(-10 * time.Minute) }
+		require.NoError(t, tt.Start(clock, nil))
+	})
+}
+
+func TestTagMany(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 2, 25, hour, 0, 0, 0, time.UTC)
+	}
+
+	setup := func(t *testing.T) *TimeTracker {
+		tt := setupTT(t)
+		for hour := 10; hour < 13; hour++ {
+			require.NoError(t, tt.Start(at(hour), []string{"a"}))
+			require.NoError(t, tt.StopAt(at(hour+1)))
+		}
+		return tt
+	}
+
+	tagsOf := func(t *testing.T, tt *TimeTracker) map[string][]string {
+		itvs, err := tt.List(at(0), at(23))
+		require.NoError(t, err)
+		ret := map[string][]string{}
+		for _, itv := range itvs {
+			ret[itv.ID] = itv.Tags
+		}
+		return ret
+	}
+
+	t.Run("several ids", func(t *testing.T) {
+		tt := setup(t)
+		require.NoError(t, tt.TagMany([]string{"1", "3"}, []string{"billable", "b"}))
+		require.Equal(t, map[string][]string{
+			"1": {"a", "billable", "b"},
+			"2": {"a"},
+			"3": {"a", "billable", "b"},
+		}, tagsOf(t, tt))
+	})
+
+	t.Run("rollback on unknown id", func(t *testing.T) {
+		tt := setup(t)
+		err := tt.TagMany([]string{"1", "42", "3"}, []string{"billable"})
+		require.ErrorIs(t, err, ErrNotFound)
+		require.Equal(t, map[string][]string{
+			"1": {"a"},
+			"2": {"a"},
+			"3": {"a"},
+		}, tagsOf(t, tt))
+	})
+}
+
+func TestPreserveTagTimestamps(t *testing.T) {
+	clock := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	liveCreatedAt := func(t *testing.T, tt *TimeTracker) int64 {
+		var createdAt int64
+		require.NoError(t, tt.db.Get(&createdAt, `
+			SELECT interval_tags.created_at
+			FROM interval_tags
+				JOIN tags ON interval_tags.tag_id = tags.id
+				LEFT JOIN interval_tags_tombstone
+					ON interval_tags.uuid = interval_tags_tombstone.interval_tag_uuid
+			WHERE interval_tags_tombstone.uuid IS NULL AND tags.name = 'b'`))
+		return createdAt
+	}
+
+	for _, tc := range []struct {
+		name     string
+		preserve bool
+		expected time.Time
+	}{
+		{"disabled", false, clock.Add(2 * time.Hour)},
+		{"enabled", true, clock},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tt := setupTT(t)
+			tt.SetPreserveTagTimestamps(tc.preserve)
+
+			tt.now = func() time.Time { return clock }
+			require.NoError(t, tt.Start(clock.Add(-time.Hour), []string{"a"}))
+			require.NoError(t, tt.Tag("1", []string{"b"}))
+
+			tt.now = func() time.Time { return clock.Add(time.Hour) }
+			require.NoError(t, tt.Untag("1", []string{"b"}))
+
+			tt.now = func() time.Time { return clock.Add(2 * time.Hour) }
+			require.NoError(t, tt.Tag("1", []string{"b"}))
+
+			require.Equal(t, tc.expected.Unix(), liveCreatedAt(t, tt))
+		})
+	}
+}
+
+func TestStartMerging(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2022, 3, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	setup := func(t *testing.T) *TimeTracker {
+		tt := setupTT(t)
+		require.NoError(t, tt.Start(at(10, 0), []string{"a", "b"}))
+		require.NoError(t, tt.StopAt(at(11, 0)))
+		return tt
+	}
+
+	for _, tc := range []struct {
+		name     string
+		start    time.Time
+		tags     []string
+		merged   bool
+		expected []TaggedInterval
+	}{
+		{
+			name:   "within gap same tags",
+			start:  at(11, 3),
+			tags:   []string{"b", "a"},
+			merged: true,
+			expected: []TaggedInterval{
+				{Interval: Interval{ID: "2", StartTimestamp: at(10, 0)}, Tags: []string{"a", "b"}},
+			},
+		},
+		{
+			name:  "within gap different tags",
+			start: at(11, 3),
+			tags:  []string{"a"},
+			expected: []TaggedInterval{
+				{Interval: Interval{ID: "1", StartTimestamp: at(10, 0), StopTimestamp: at(11, 0)}, Tags: []string{"a", "b"}},
+				{Interval: Interval{ID: "2", StartTimestamp: at(11, 3)}, Tags: []string{"a"}},
+			},
+		},
+		{
+			name:  "beyond gap",
+			start: at(11, 10),
+			tags:  []string{"a", "b"},
+			expected: []TaggedInterval{
+				{Interval: Interval{ID: "1", StartTimestamp: at(10, 0), StopTimestamp: at(11, 0)}, Tags: []string{"a", "b"}},
+				{Interval: Interval{ID: "2", StartTimestamp: at(11, 10)}, Tags: []string{"a", "b"}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tt := setup(t)
+			merged, err := tt.StartMerging(tc.start, tc.tags, 5*time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, tc.merged, merged)
+
+			itvs, err := tt.List(at(0, 0), at(23, 0))
+			require.NoError(t, err)
+			for idx := range itvs {
+				itvs[idx].UUID = ""
+				itvs[idx].Location = nil
+				itvs[idx].StartTimestamp = itvs[idx].StartTimestamp.UTC()
+				if !itvs[idx].StopTimestamp.IsZero() {
+					itvs[idx].StopTimestamp = itvs[idx].StopTimestamp.UTC()
+				}
+			}
+			require.Equal(t, tc.expected, itvs)
+		})
+	}
+
+	t.Run("reopened interval keeps its metadata", func(t *testing.T) {
+		paris, err := time.LoadLocation("Europe/Paris")
+		require.NoError(t, err)
+		tt := setupTT(t)
+		tt.SetPlace("Lyon")
+		require.NoError(t, tt.Start(at(10, 0).In(paris), []string{"a"}))
+		require.NoError(t, tt.StopAt(at(11, 0)))
+		require.NoError(t, tt.SetEstimate("1", 2*time.Hour))
+		require.NoError(t, tt.SetBillable("1", true))
+
+		tt.SetPlace("")
+		merged, err := tt.StartMerging(at(11, 3), []string{"a"}, 5*time.Minute)
+		require.NoError(t, err)
+		require.True(t, merged)
+
+		current, err := tt.Current()
+		require.NoError(t, err)
+		require.True(t, at(10, 0).Equal(current.StartTimestamp))
+		require.Equal(t, 2*time.Hour, current.Estimate)
+		require.True(t, current.Billable)
+		require.Equal(t, "Lyon", current.Place)
+		require.Equal(t, "Europe/Paris", current.Location.String())
+	})
+
+	t.Run("locked interval is not reopened", func(t *testing.T) {
+		tt := setup(t)
+		tt.SetLockDate(at(10, 30))
+		merged, err := tt.StartMerging(at(11, 3), []string{"a", "b"}, 5*time.Minute)
+		require.NoError(t, err)
+		require.False(t, merged)
+	})
+}
+
+func TestPurge(t *testing.T) {
+	tables := []string{
+		"tags",
+		"interval_start",
+		"interval_stop",
+		"interval_tombstone",
+		"interval_tags",
+		"interval_tags_tombstone",
+	}
+	count := func(t *testing.T, tt *TimeTracker, table string) int {
+		var c int
+		require.NoError(t, tt.db.Get(&c, `SELECT count(1) FROM `+table))
+		return c
+	}
+
+	for _, syncHistory := range []bool{false, true} {
+		t.Run(fmt.Sprintf("sync history %v", syncHistory), func(t *testing.T) {
+			tt := setupTT(t)
+			now := time.Now()
+			require.NoError(t, tt.Start(now.Add(-3*time.Hour), []string{"a", "b"}))
+			require.NoError(t, tt.StopAt(now.Add(-2*time.Hour)))
+			require.NoError(t, tt.Untag("1", []string{"b"}))
+			require.NoError(t, tt.Start(now.Add(-time.Hour), []string{"c"}))
+			require.NoError(t, tt.Delete("2"))
+			_, err := tt.db.Exec(`INSERT INTO sync_history (sync_timestamp) VALUES (?)`, now.Unix())
+			require.NoError(t, err)
+			for _, table := range tables {
+				require.NotZero(t, count(t, tt, table), table)
+			}
+
+			var migrations int
+			require.NoError(t, tt.db.Get(&migrations, `SELECT count(1) FROM darwin_migrations`))
+
+			require.NoError(t, tt.Purge(syncHistory))
+			for _, table := range tables {
+				require.Zero(t, count(t, tt, table), table)
+			}
+			if syncHistory {
+				require.Zero(t, count(t, tt, "sync_history"))
+			} else {
+				require.Equal(t, 1, count(t, tt, "sync_history"))
+			}
+
+			var after int
+			require.NoError(t, tt.db.Get(&after, `SELECT count(1) FROM darwin_migrations`))
+			require.Equal(t, migrations, after)
+
+			require.NoError(t, tt.Start(now, []string{"a"}))
+		})
+	}
+}
+
+func TestVacuum(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2022, 3, day, hour, 0, 0, 0, time.UTC)
+	}
+	count := func(t *testing.T, tt *TimeTracker, table string) int {
+		var c int
+		require.NoError(t, tt.db.Get(&c, `SELECT count(1) FROM `+table))
+		return c
+	}
+	counts := func(t *testing.T, tt *TimeTracker) map[string]int {
+		ret := map[string]int{}
+		for _, table := range []string{
+			"tags",
+			"interval_start",
+			"interval_stop",
+			"interval_tombstone",
+			"interval_tags",
+			"interval_tags_tombstone",
+			"interval_estimate",
+			"interval_billable",
+		} {
+			ret[table] = count(t, tt, table)
+		}
+		return ret
+	}
+
+	tt := setupTT(t)
+	tt.now = func() time.Time { return at(1, 20) }
+	require.NoError(t, tt.Start(at(1, 9), []string{"a", "b"}))
+	require.NoError(t, tt.StopAt(at(1, 10)))
+	require.NoError(t, tt.Untag("1", []string{"b"}))
+	require.NoError(t, tt.Start(at(1, 10), []string{"c"}))
+	require.NoError(t, tt.StopAt(at(1, 11)))
+	require.NoError(t, tt.SetEstimate("2", time.Hour))
+	require.NoError(t, tt.SetBillable("2", true))
+	require.NoError(t, tt.Delete("2"))
+
+	// Soft deleted after the checkpoint
+	tt.now = func() time.Time { return at(3, 20) }
+	require.NoError(t, tt.Start(at(3, 9), []string{"d"}))
+	require.NoError(t, tt.StopAt(at(3, 10)))
+	require.NoError(t, tt.Delete("3"))
+	require.NoError(t, tt.Untag("1", []string{"a"}))
+
+	require.Equal(t, map[string]int{
+		"tags":                    4,
+		"interval_start":          3,
+		"interval_stop":           3,
+		"interval_tombstone":      2,
+		"interval_tags":           4,
+		"interval_tags_tombstone": 2,
+		"interval_estimate":       1,
+		"interval_billable":       1,
+	}, counts(t, tt))
+	live, err := tt.List(at(1, 0), at(4, 0))
+	require.NoError(t, err)
+	total, err := tt.TotalDuration()
+	require.NoError(t, err)
+
+	require.NoError(t, tt.Vacuum(at(2, 0)))
+	require.Equal(t, map[string]int{
+		"tags":                    2,
+		"interval_start":          2,
+		"interval_stop":           2,
+		"interval_tombstone":      1,
+		"interval_tags":           2,
+		"interval_tags_tombstone": 1,
+		"interval_estimate":       0,
+		"interval_billable":       0,
+	}, counts(t, tt))
+
+	intervals, err := tt.List(at(1, 0), at(4, 0))
+	require.NoError(t, err)
+	require.Equal(t, live, intervals)
+	after, err := tt.TotalDuration()
+	require.NoError(t, err)
+	require.Equal(t, total, after)
+
+	require.NoError(t, tt.Vacuum(at(4, 0)))
+	require.Equal(t, map[string]int{
+		"tags":                    0,
+		"interval_start":          1,
+		"interval_stop":           1,
+		"interval_tombstone":      0,
+		"interval_tags":           0,
+		"interval_tags_tombstone": 0,
+		"interval_estimate":       0,
+		"interval_billable":       0,
+	}, counts(t, tt))
+	intervals, err = tt.List(at(1, 0), at(4, 0))
+	require.NoError(t, err)
+	require.Len(t, intervals, 1)
+	require.Equal(t, live[0].Interval, intervals[0].Interval)
+}
+
+func TestForeignKeyCheck(t *testing.T) {
+	now := time.Now()
+
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("enabled %v", enabled), func(t *testing.T) {
+			tt := setupTT(t)
+			tt.SetForeignKeyCheck(enabled)
+			require.NoError(t, tt.Start(now.Add(-2*time.Hour), []string{"a"}))
+			require.NoError(t, tt.StopAt(now.Add(-time.Hour)))
+
+			_, err := tt.db.Exec(`PRAGMA foreign_keys = OFF`)
+			require.NoError(t, err)
+			_, err = tt.db.Exec(`
+				INSERT INTO interval_tags (uuid, interval_start_uuid, tag_id, created_at)
+				SELECT 'crafted', 'unknown', id, ? FROM tags WHERE name = 'a'`, now.Unix())
+			require.NoError(t, err)
+			_, err = tt.db.Exec(`PRAGMA foreign_keys = ON`)
+			require.NoError(t, err)
+
+			err = tt.Start(now, []string{"b"})
+			if enabled {
+				require.ErrorIs(t, err, ErrForeignKeyViolation)
+			} else {
+				require.NoError(t, err)
+			}
+
+			// restore the database for the final sanity check
+			_, err = tt.db.Exec(`DELETE FROM interval_tags WHERE uuid = 'crafted'`)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestContext(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2022, 3, 1, hour, minute, 0, 0, time.Local)
+	}
+
+	tt := setupTT(t)
+	require.NoError(t, tt.Start(at(10, 0), []string{"a"}))
+	require.ErrorIs(t, tt.Start(at(10, 15), []string{"b"}), ErrExistingOpenInterval)
+
+	tt.SetContext("side")
+	current, err := tt.Current()
+	require.NoError(t, err)
+	require.Nil(t, current)
+	require.NoError(t, tt.Start(at(10, 30), []string{"b"}))
+	require.ErrorIs(t, tt.Start(at(10, 45), []string{"c"}), ErrExistingOpenInterval)
+
+	current, err = tt.Current()
+	require.NoError(t, err)
+	require.Equal(t, "2", current.ID)
+	require.Equal(t, "side", current.Context)
+
+	require.NoError(t, tt.StopAt(at(11, 0)))
+	require.ErrorIs(t, tt.StopAt(at(11, 30)), ErrNoOpenInterval)
+
+	tt.SetContext("")
+	current, err = tt.Current()
+	require.NoError(t, err)
+	require.Equal(t, "1", current.ID)
+	require.Equal(t, "", current.Context)
+	require.NoError(t, tt.StopAt(at(12, 0)))
+
+	intervals, err := tt.List(at(0, 0), at(23, 0))
+	require.NoError(t, err)
+	for i := range intervals {
+		intervals[i].Interval.UUID = ""
+		intervals[i].Interval.Location = nil
+	}
+	require.Equal(t, []TaggedInterval{
+		{Interval: Interval{ID: "1", StartTimestamp: at(10, 0), StopTimestamp: at(12, 0)}, Tags: []string{"a"}},
+		{Interval: Interval{ID: "2", StartTimestamp: at(10, 30), StopTimestamp: at(11, 0), Context: "side"}, Tags: []string{"b"}},
+	}, intervals)
+
+	t.Run("continue", func(t *testing.T) {
+		tt := setupTT(t)
+		tt.SetContext("a")
+		require.NoError(t, tt.Start(at(9, 0), []string{"a"}))
+		tt.SetContext("b")
+		require.NoError(t, tt.Start(at(9, 30), []string{"b"}))
+		require.NoError(t, tt.StopAt(at(10, 0)))
+
+		// The opened interval and the intervals of context a are ignored
+		tt.SetPlace("Lyon")
+		require.NoError(t, tt.Continue(at(10, 15), "", nil))
+		require.ErrorIs(t, tt.Continue(at(10, 30), "", nil), ErrMultipleOpenInterval)
+
+		current, err := tt.Current()
+		require.NoError(t, err)
+		require.Equal(t, "3", current.ID)
+		require.Equal(t, "b", current.Context)
+		require.Equal(t, "Lyon", current.Place)
+		require.Equal(t, []string{"b"}, current.Tags)
+
+		tt.SetContext("a")
+		current, err = tt.Current()
+		require.NoError(t, err)
+		require.Equal(t, "1", current.ID)
+	})
+}
+
+func TestPlace(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+
+	tt := setupTT(t)
+	require.NoError(t, tt.Start(at(9), []string{"office"}))
+	require.NoError(t, tt.StopAt(at(10)))
+
+	tt.SetPlace("Lyon")
+	require.NoError(t, tt.Start(at(10), []string{"travel"}))
+	current, err := tt.Current()
+	require.NoError(t, err)
+	require.Equal(t, "Lyon", current.Place)
+	require.NoError(t, tt.StopAt(at(11)))
+
+	active, err := tt.At(at(10))
+	require.NoError(t, err)
+	require.Equal(t, "Lyon", active.Place)
+
+	intervals, err := tt.List(at(0), at(23))
+	require.NoError(t, err)
+	require.Len(t, intervals, 2)
+	require.Empty(t, intervals[0].Place)
+	require.Equal(t, "Lyon", intervals[1].Place)
+
+	recent, err := tt.RecentClosed(1)
+	require.NoError(t, err)
+	require.Equal(t, "Lyon", recent[0].Place)
+}
+
+func TestLastIntervalTags(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.Local)
+	}
+
+	tt := setupTT(t)
+	_, err := tt.LastIntervalTags(nil)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, tt.Start(at(10), []string{"a"}))
+	require.NoError(t, tt.StopAt(at(11)))
+	require.NoError(t, tt.Start(at(11), []string{"project", "meeting", "client"}))
+
+	tags, err := tt.LastIntervalTags([]string{"meeting"})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"project", "client"}, tags)
+
+	require.NoError(t, tt.StopAt(at(12)))
+	tags, err = tt.LastIntervalTags([]string{"meeting", "unknown"})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"project", "client"}, tags)
+
+	tags, err = tt.LastIntervalTags(nil)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"project", "meeting", "client"}, tags)
+}
+
+func TestIntervalTags(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+
+	tt := setupTT(t)
+	require.NoError(t, tt.Start(at(10), []string{"b", "a", "c"}))
+	require.NoError(t, tt.StopAt(at(11)))
+	require.NoError(t, tt.Untag("1", []string{"a"}))
+	require.NoError(t, tt.Tag("1", []string{"a"}))
+	require.NoError(t, tt.Start(at(11), nil))
+
+	tags, err := tt.IntervalTags("1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"b", "c", "a"}, tags)
+
+	tags, err = tt.IntervalTags("2")
+	require.NoError(t, err)
+	require.Empty(t, tags)
+
+	_, err = tt.IntervalTags("3")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, tt.Delete("1"))
+	_, err = tt.IntervalTags("1")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestRetag(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+	associations := func(t *testing.T, tt *TimeTracker) (live, all int) {
+		t.Helper()
+		require.NoError(t, tt.db.Get(&all, `
+			SELECT count(1)
+			FROM interval_tags JOIN tags ON interval_tags.tag_id = tags.id
+			WHERE tags.name = 'a'`))
+		require.NoError(t, tt.db.Get(&live, `
+			SELECT count(1)
+			FROM interval_tags
+				JOIN tags ON interval_tags.tag_id = tags.id
+				LEFT JOIN interval_tags_tombstone
+					ON interval_tags.uuid = interval_tags_tombstone.interval_tag_uuid
+			WHERE tags.name = 'a'
+				AND interval_tags_tombstone.uuid IS NULL`))
+		return live, all
+	}
+
+	tt := setupTT(t)
+	require.NoError(t, tt.Start(at(10), []string{"b"}))
+	for round := 1; round <= 2; round++ {
+		require.NoError(t, tt.Tag("1", []string{"a"}))
+		require.ErrorIs(t, tt.Tag("1", []string{"a"}), ErrDuplicatedIntervalTag)
+		live, all := associations(t, tt)
+		require.Equal(t, 1, live)
+		require.Equal(t, round, all)
+
+		require.NoError(t, tt.Untag("1", []string{"a"}))
+		live, _ = associations(t, tt)
+		require.Zero(t, live)
+	}
+
+	require.NoError(t, tt.Tag("1", []string{"a"}))
+	current, err := tt.Current()
+	require.NoError(t, err)
+	require.Equal(t, []string{"b", "a"}, current.Tags)
+	require.NoError(t, tt.StopAt(at(11)))
+
+	require.NoError(t, tt.Continue(at(12), "1", nil))
+	current, err = tt.Current()
+	require.NoError(t, err)
+	require.Equal(t, []string{"b", "a"}, current.Tags)
+	live, _ := associations(t, tt)
+	require.Equal(t, 2, live)
+
+	require.NoError(t, NewSanity(tt.db).Check())
+}
+
+func TestRecentClosed(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+
+	tt := setupTT(t)
+	intervals, err := tt.RecentClosed(5)
+	require.NoError(t, err)
+	require.Empty(t, intervals)
+
+	for idx, tags := range [][]string{{"a"}, {"b", "c"}, nil, {"d"}} {
+		require.NoError(t, tt.Start(at(10+idx), tags))
+		require.NoError(t, tt.StopAt(at(11+idx)))
+	}
+	require.NoError(t, tt.Delete("4"))
+	tt.SetContext("other")
+	require.NoError(t, tt.Start(at(20), []string{"e"}))
+	require.NoError(t, tt.StopAt(at(21)))
+	tt.SetContext("")
+	require.NoError(t, tt.Start(at(22), []string{"f"}))
+
+	intervals, err = tt.RecentClosed(2)
+	require.NoError(t, err)
+	require.Len(t, intervals, 2)
+	require.Equal(t, "3", intervals[0].ID)
+	require.Nil(t, intervals[0].Tags)
+	require.True(t, intervals[0].StartTimestamp.Equal(at(12)))
+	require.True(t, intervals[0].StopTimestamp.Equal(at(13)))
+	require.Equal(t, "2", intervals[1].ID)
+	require.Equal(t, []string{"b", "c"}, intervals[1].Tags)
+
+	intervals, err = tt.RecentClosed(5)
+	require.NoError(t, err)
+	require.Len(t, intervals, 3)
+	require.Equal(t, "1", intervals[2].ID)
+	require.Equal(t, []string{"a"}, intervals[2].Tags)
+}
+
+func TestTaglessIntervalRepresentation(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.Local)
+	}
+
+	tt := setupTT(t)
+	require.NoError(t, tt.Start(at(10), nil))
+	require.NoError(t, tt.StopAt(at(11)))
+	require.NoError(t, tt.Start(at(11), []string{"a"}))
+	require.NoError(t, tt.Untag("2", []string{"a"}))
+
+	intervals, err := tt.List(at(0), at(23))
+	require.NoError(t, err)
+	require.Len(t, intervals, 2)
+	require.Nil(t, intervals[0].Tags)
+	require.Nil(t, intervals[1].Tags)
+
+	current, err := tt.Current()
+	require.NoError(t, err)
+	require.Nil(t, current.Tags)
+	require.Equal(t, intervals[1], *current)
+
+	closed, err := tt.At(at(10))
+	require.NoError(t, err)
+	require.Nil(t, closed.Tags)
+	require.Equal(t, intervals[0], *closed)
+}
+
+func TestTagAliases(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.Local)
+	}
+
+	tt := setupTT(t)
+	tt.SetTagAliases(map[string]string{"wip": "work-in-progress", "mtg": "meeting"})
+
+	require.NoError(t, tt.Start(at(10), []string{"wip", "other"}))
+	require.NoError(t, tt.StopAt(at(11)))
+	require.NoError(t, tt.Start(at(11), []string{"work-in-progress", "wip"}))
+	require.NoError(t, tt.StopAt(at(12)))
+	require.NoError(t, tt.Tag("2", []string{"mtg"}))
+	require.NoError(t, tt.Continue(at(13), "", []string{"wip", "other"}))
+
+	intervals, err := tt.List(at(0), at(23))
+	require.NoError(t, err)
+	require.Len(t, intervals, 3)
+	require.Equal(t, []string{"work-in-progress", "other"}, intervals[0].Tags)
+	require.Equal(t, []string{"work-in-progress", "meeting"}, intervals[1].Tags)
+	require.ElementsMatch(t, []string{"work-in-progress", "other"}, intervals[2].Tags)
+}
+
+func TestTagWhitespace(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.Local)
+	}
+
+	tt := setupTT(t)
+	tt.SetTagAliases(map[string]string{"wip": "work-in-progress"})
+
+	require.NoError(t, tt.Start(at(10), []string{" work ", "work", "\twip "}))
+	require.NoError(t, tt.StopAt(at(11)))
+	require.ErrorIs(t, tt.Tag("1", []string{"work "}), ErrDuplicatedIntervalTag)
+	require.ErrorIs(t, tt.Tag("1", []string{"  "}), ErrInvalidTag)
+	require.ErrorIs(t, tt.Start(at(11), []string{" "}), ErrInvalidTag)
+	require.NoError(t, tt.Start(at(11), []string{"work"}))
+	require.NoError(t, tt.StopAt(at(12)))
+	require.NoError(t, tt.Untag("2", []string{" work"}))
+
+	intervals, err := tt.List(at(0), at(23))
+	require.NoError(t, err)
+	require.Len(t, intervals, 2)
+	require.Equal(t, []string{"work", "work-in-progress"}, intervals[0].Tags)
+	require.Empty(t, intervals[1].Tags)
+
+	var tags []string
+	require.NoError(t, tt.db.Select(&tags, `SELECT name FROM tags ORDER BY name`))
+	require.Equal(t, []string{"work", "work-in-progress"}, tags)
+}
+
+func TestMaxTags(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.Local)
+	}
+
+	tt := setupTT(t)
+	tt.SetMaxTags(3)
+
+	// Start
+	require.ErrorIs(t, tt.Start(at(8), []string{"a", "b", "c", "d"}), ErrTooManyTags)
+	require.NoError(t, tt.Start(at(9), []string{"a", "b"}))
+	require.NoError(t, tt.StopAt(at(10)))
+	require.NoError(t, tt.Start(at(10), []string{"a", "b", "c"}))
+	require.NoError(t, tt.StopAt(at(11)))
+
+	// Incremental tagging counts the live tags
+	require.NoError(t, tt.Tag("1", []string{"c"}))
+	require.ErrorIs(t, tt.Tag("1", []string{"d"}), ErrTooManyTags)
+	require.ErrorIs(t, tt.Tag("2", []string{"d", "e"}), ErrTooManyTags)
+	// A tag already set is reported as such even when the interval is full
+	require.ErrorIs(t, tt.Tag("2", []string{"a"}), ErrDuplicatedIntervalTag)
+	require.NoError(t, tt.Untag("1", []string{"a"}))
+	require.NoError(t, tt.Tag("1", []string{"d"}))
+
+	// Continue with the default tags
+	require.NoError(t, tt.SetDefaultTags([]string{"e"}))
+	require.ErrorIs(t, tt.Continue(at(12), "2", nil), ErrTooManyTags)
+	require.NoError(t, tt.SetDefaultTags(nil))
+	require.NoError(t, tt.Continue(at(12), "2", nil))
+	require.NoError(t, tt.StopAt(at(13)))
+
+	intervals, err := tt.List(at(0), at(23))
+	require.NoError(t, err)
+	require.Len(t, intervals, 3)
+	require.Equal(t, []string{"b", "c", "d"}, intervals[0].Tags)
+	require.Equal(t, []string{"a", "b", "c"}, intervals[1].Tags)
+	require.Equal(t, []string{"a", "b", "c"}, intervals[2].Tags)
+
+	// Unlimited
+	tt.SetMaxTags(0)
+	require.NoError(t, tt.Tag("2", []string{"d", "e"}))
+}
+
+func TestStopStale(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2022, 3, day, hour, 0, 0, 0, time.Local)
+	}
+
+	for _, tc := range []struct {
+		name      string
+		threshold time.Duration
+		tag       bool
+		stop      time.Time
+	}{
+		{name: "disabled"},
+		{name: "below threshold", threshold: 48 * time.Hour, tag: true},
+		{name: "stopped at last activity", threshold: 12 * time.Hour, tag: true, stop: at(1, 10)},
+		{name: "stopped at threshold without activity", threshold: 12 * time.Hour, stop: at(1, 21)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tt := setupTT(t)
+			tt.SetAutoStopStale(tc.threshold)
+
+			tt.now = func() time.Time { return at(1, 9) }
+			require.NoError(t, tt.Start(at(1, 9), []string{"a"}))
+			if tc.tag {
+				tt.now = func() time.Time { return at(1, 10) }
+				require.NoError(t, tt.Tag("1", []string{"b"}))
+			}
+
+			tt.now = func() time.Time { return at(2, 8) }
+			require.NoError(t, tt.StopStale())
+
+			itv, err := tt.At(at(1, 9))
+			require.NoError(t, err)
+			require.Equal(t, tc.stop, itv.StopTimestamp)
+		})
+	}
+}
+
+func TestRepairMigrations(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tt.db")
+	tt := setupTT(t, file)
+	require.NoError(t, tt.Start(time.Now().Add(-time.Hour), []string{"a"}))
+
+	repaired, err := RepairMigrations(file)
+	require.NoError(t, err)
+	require.Empty(t, repaired)
+
+	_, err = tt.db.Exec(`UPDATE darwin_migrations SET checksum = 'tampered' WHERE version IN (3, 7)`)
+	require.NoError(t, err)
+	_, err = New(file)
+	require.ErrorIs(t, err, ErrMigrationChecksum)
+
+	repaired, err = RepairMigrations(file)
+	require.NoError(t, err)
+	require.Equal(t, []float64{3, 7}, repaired)
+
+	other, err := New(file)
+	require.NoError(t, err)
+	require.NoError(t, other.Close())
+
+	t.Run("refused on a different schema", func(t *testing.T) {
+		_, err = tt.db.Exec(`UPDATE darwin_migrations SET checksum = 'tampered' WHERE version = 9`)
+		require.NoError(t, err)
+		_, err = tt.db.Exec(`CREATE INDEX unexpected ON interval_estimate (estimate)`)
+		require.NoError(t, err)
+
+		_, err = RepairMigrations(file)
+		require.ErrorIs(t, err, ErrSchemaMismatch)
+		_, err = New(file)
+		require.ErrorIs(t, err, ErrMigrationChecksum)
+
+		_, err = tt.db.Exec(`DROP INDEX unexpected`)
+		require.NoError(t, err)
+		repaired, err = RepairMigrations(file)
+		require.NoError(t, err)
+		require.Equal(t, []float64{9}, repaired)
+	})
+}
+
+func TestDefaultTags(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.Local)
+	}
+
+	tt := setupTT(t)
+	require.ErrorIs(t, tt.SetDefaultTags([]string{"me", "a,b"}), ErrInvalidTag)
+	require.NoError(t, tt.SetDefaultTags([]string{"me"}))
+	tt.SetTagAliases(map[string]string{"myself": "me"})
+
+	require.NoError(t, tt.Start(at(10), []string{"a", "myself"}))
+	require.NoError(t, tt.StopAt(at(11)))
+	require.NoError(t, tt.Start(at(11), nil))
+	require.NoError(t, tt.StopAt(at(12)))
+	require.NoError(t, tt.Continue(at(12), "1", nil))
+	require.NoError(t, tt.StopAt(at(13)))
+	merged, err := tt.StartMerging(at(13), []string{"a"}, time.Minute)
+	require.NoError(t, err)
+	require.True(t, merged)
+	require.NoError(t, tt.StopAt(at(14)))
+
+	require.NoError(t, tt.SetDefaultTags(nil))
+	require.NoError(t, tt.Start(at(14), []string{"b"}))
+
+	intervals, err := tt.List(at(0), at(23))
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"a", "me"}, {"me"}, {"a", "me"}, {"b"}},
+		funk.Map(intervals, func(_ int, itv TaggedInterval) []string { return itv.Tags }))
+}
+
+func TestTagIDMigration(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+
+	// Record an interval with the interval tags holding the tag names
+	file := filepath.Join(t.TempDir(), "tt.db")
+	db, err := sql.Open(customSqliteDriverName, file)
+	require.NoError(t, err)
+	require.NoError(t, darwin.Migrate(
+		darwin.NewGenericDriver(db, darwin.SqliteDialect{}), sqliteMigrations[:10], nil))
+	_, err = db.Exec(`
+		INSERT INTO tags (name, created_at) VALUES ('b', 1), ('a', 2), ('c', 3);
+		INSERT INTO interval_start (uuid, start_timestamp, created_at)
+		VALUES ('00000000-0000-0000-0000-000000000001', 1646128800, 1646128800);
+		INSERT INTO interval_stop (uuid, start_uuid, stop_timestamp, created_at)
+		VALUES ('00000000-0000-0000-0000-000000000002', '00000000-0000-0000-0000-000000000001',
+			1646132400, 1646132400);
+		INSERT INTO interval_tags (uuid, interval_start_uuid, tag, created_at)
+		VALUES ('00000000-0000-0000-0000-000000000010', '00000000-0000-0000-0000-000000000001',
+				'c', 1646128800),
+			('00000000-0000-0000-0000-000000000011', '00000000-0000-0000-0000-000000000001',
+				'a', 1646128800),
+			('00000000-0000-0000-0000-000000000012', '00000000-0000-0000-0000-000000000001',
+				'b', 1646128800);
+		INSERT INTO interval_tags_tombstone (uuid, interval_tag_uuid, created_at)
+		VALUES ('00000000-0000-0000-0000-000000000100', '00000000-0000-0000-0000-000000000011',
+			1646132400);`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	tt := setupTT(t, file)
+
+	var tags []struct {
+		Name      string `db:"name"`
+		CreatedAt int64  `db:"created_at"`
+	}
+	require.NoError(t, tt.db.Select(&tags, `SELECT name, created_at FROM tags ORDER BY id`))
+	require.Equal(t, []struct {
+		Name      string `db:"name"`
+		CreatedAt int64  `db:"created_at"`
+	}{{"b", 1}, {"a", 2}, {"c", 3}}, tags)
+
+	itv, err := tt.At(at(10))
+	require.NoError(t, err)
+	require.Equal(t, []string{"c", "b"}, itv.Tags)
+
+	require.NoError(t, tt.Tag("1", []string{"a", "d"}))
+	require.NoError(t, tt.Untag("1", []string{"c"}))
+	_, err = tt.RenameTag("b", "e", false)
+	require.NoError(t, err)
+	require.NoError(t, tt.Continue(at(12), "1", nil))
+	require.NoError(t, tt.StopAt(at(13)))
+
+	intervals, err := tt.List(at(0), at(23))
+	require.NoError(t, err)
+	require.Len(t, intervals, 2)
+	require.Equal(t, []string{"a", "d", "e"}, intervals[0].Tags)
+	require.Equal(t, []string{"a", "d", "e"}, intervals[1].Tags)
+
+	current, longest, err := tt.Streaks("d", time.UTC)
+	require.NoError(t, err)
+	require.Equal(t, 0, current)
+	require.Equal(t, 1, longest)
+}
+
+func TestMissingTable(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tt.db")
+	tt := setupTT(t, file)
+	require.NoError(t, tt.Start(time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC), []string{"a"}))
+
+	// A newer version of the application may have dropped or renamed a table
+	_, err := tt.db.Exec(`ALTER TABLE interval_tags_tombstone RENAME TO interval_tags_deletion`)
+	require.NoError(t, err)
+
+	_, err = New(file)
+	require.ErrorIs(t, err, ErrMissingTable)
+	require.ErrorContains(t, err, "interval_tags_tombstone, the database may have been written")
+	_, err = NewReadOnly(file)
+	require.ErrorIs(t, err, ErrMissingTable)
+
+	_, err = tt.db.Exec(`ALTER TABLE interval_tags_deletion RENAME TO interval_tags_tombstone`)
+	require.NoError(t, err)
+	other, err := New(file)
+	require.NoError(t, err)
+	require.NoError(t, other.Close())
+}
+
+func TestReadOnly(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.Local)
+	}
+
+	file := filepath.Join(t.TempDir(), "tt.db")
+	tt := setupTT(t, file)
+	require.NoError(t, tt.Start(at(10), []string{"a"}))
+	require.NoError(t, tt.StopAt(at(11)))
+	require.NoError(t, tt.Start(at(12), []string{"b"}))
+
+	ro, err := NewReadOnly(file)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, ro.Close()) })
+
+	intervals, err := ro.List(at(0), at(23))
+	require.NoError(t, err)
+	require.Len(t, intervals, 2)
+	require.Equal(t, []string{"a"}, intervals[0].Tags)
+
+	current, err := ro.Current()
+	require.NoError(t, err)
+	require.Equal(t, []string{"b"}, current.Tags)
+
+	require.Error(t, ro.StopAt(at(13)))
+	require.Error(t, ro.Tag("1", []string{"c"}))
+
+	current, err = tt.Current()
+	require.NoError(t, err)
+	require.True(t, current.StopTimestamp.IsZero())
+
+	t.Run("outdated schema", func(t *testing.T) {
+		last := sqliteMigrations[len(sqliteMigrations)-1]
+		_, err := tt.db.Exec(`DELETE FROM darwin_migrations WHERE version = ?`, last.Version)
+		require.NoError(t, err)
+		_, err = NewReadOnly(file)
+		require.ErrorIs(t, err, ErrSchemaMismatch)
+
+		_, err = tt.db.Exec(`
+			INSERT INTO darwin_migrations (version, description, checksum, applied_at, execution_time)
+			VALUES (?, ?, ?, 0, 0)`, last.Version, last.Description, last.Checksum())
+		require.NoError(t, err)
+	})
+
+	t.Run("missing database", func(t *testing.T) {
+		_, err := NewReadOnly(filepath.Join(t.TempDir(), "missing.db"))
+		require.Error(t, err)
+	})
+
+	t.Run("uri characters in path", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "tt")
+		require.NoError(t, os.Mkdir(dir, 0o700))
+		writer, err := New(filepath.Join(dir, "tt.db"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Start(at(10), []string{"a"}))
+		require.NoError(t, writer.Close())
+		special := dir + "?mode=rw#"
+		require.NoError(t, os.Rename(dir, special))
+
+		ro, err := NewReadOnly(filepath.Join(special, "tt.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, ro.Close()) })
+		current, err := ro.Current()
+		require.NoError(t, err)
+		require.Equal(t, []string{"a"}, current.Tags)
+	})
+}
+
+func TestConcurrentReadWrite(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.Local)
+	}
+
+	file := filepath.Join(t.TempDir(), "tt.db")
+	writer := setupTT(t, file)
+	require.NoError(t, writer.Start(at(10), []string{"a"}))
+	require.NoError(t, writer.StopAt(at(11)))
+
+	var mode string
+	require.NoError(t, writer.db.Get(&mode, `PRAGMA journal_mode`))
+	require.Equal(t, "wal", mode)
+
+	reader, err := NewReadOnly(file)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, reader.Close()) })
+
+	// A long read transaction holds a snapshot of the database
+	tx, err := reader.db.Beginx()
+	require.NoError(t, err)
+	defer func() { _ = tx.Rollback() }()
+	count := func() int {
+		var count int
+		require.NoError(t, tx.Get(&count, `SELECT count(1) FROM interval_start`))
+		return count
+	}
+	require.Equal(t, 1, count())
+
+	done := make(chan error, 1)
+	go func() {
+		if err := writer.Start(at(12), []string{"b"}); err != nil {
+			done <- err
+			return
+		}
+		done <- writer.StopAt(at(13))
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("the write is blocked by the read transaction")
+	}
+
+	require.Equal(t, 1, count())
+	require.NoError(t, tx.Rollback())
+
+	intervals, err := reader.List(at(0), at(23))
+	require.NoError(t, err)
+	require.Len(t, intervals, 2)
+}
+
+func TestCurrentTags(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+
+	tt := setupTT(t)
+	require.NoError(t, tt.Start(at(9), []string{"a", "b"}))
+	require.NoError(t, tt.StopAt(at(10)))
+	require.NoError(t, tt.Start(at(10), []string{"c", "d", "e"}))
+	require.NoError(t, tt.Untag("2", []string{"d"}))
+	require.NoError(t, tt.SetEstimate("2", time.Hour))
+	require.NoError(t, tt.SetBillable("2", true))
+
+	current, err := tt.Current()
+	require.NoError(t, err)
+	require.Equal(t, "2", current.ID)
+	require.Equal(t, at(10), current.StartTimestamp.UTC())
+	require.Equal(t, []string{"c", "e"}, current.Tags)
+	require.Equal(t, time.Hour, current.Estimate)
+	require.True(t, current.Billable)
+
+	require.NoError(t, tt.Untag("2", []string{"c", "e"}))
+	current, err = tt.Current()
+	require.NoError(t, err)
+	require.Equal(t, "2", current.ID)
+	require.Empty(t, current.Tags)
+
+	require.NoError(t, tt.Delete("2"))
+	current, err = tt.Current()
+	require.NoError(t, err)
+	require.Nil(t, current)
+}
+
+func BenchmarkCurrent(b *testing.B) {
+	tt, err := New(":memory:")
+	require.NoError(b, err)
+	b.Cleanup(func() { require.NoError(b, tt.Close()) })
+
+	start := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	for idx := 0; idx < 100; idx++ {
+		require.NoError(b, tt.Start(start.Add(time.Duration(idx)*time.Hour), []string{"a", "b"}))
+		require.NoError(b, tt.StopAt(start.Add(time.Duration(idx)*time.Hour+time.Minute)))
+	}
+	require.NoError(b, tt.Start(start.Add(100*time.Hour), []string{"a", "b", "c"}))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := tt.Current(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkList(b *testing.B) {
+	tt, err := New(":memory:")
+	require.NoError(b, err)
+	b.Cleanup(func() { require.NoError(b, tt.Close()) })
+
+	start := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	for idx := 0; idx < 500; idx++ {
+		tags := []string{"a", "b", fmt.Sprintf("tag%d", idx%20)}
+		require.NoError(b, tt.Start(start.Add(time.Duration(idx)*time.Hour), tags))
+		require.NoError(b, tt.StopAt(start.Add(time.Duration(idx)*time.Hour+time.Minute)))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		itvs, err := tt.List(start, start.Add(500*time.Hour))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(itvs) != 500 || len(itvs[499].Tags) != 3 {
+			b.Fatalf("unexpected intervals: %d", len(itvs))
+		}
+	}
 }
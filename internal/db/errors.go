@@ -5,14 +5,28 @@ import (
 )
 
 var (
+	ErrClockSkew             = fmt.Errorf("system clock skew")
+	ErrDanglingStop          = fmt.Errorf("dangling interval stop")
 	ErrDuplicatedIntervalTag = fmt.Errorf("duplicated interval tags")
+	ErrDuplicatedUUID        = fmt.Errorf("duplicated uuid")
 	ErrExistingOpenInterval  = fmt.Errorf("already existing opened interval")
+	ErrForeignKeyViolation   = fmt.Errorf("foreign key violation")
+	ErrIntervalLocked        = fmt.Errorf("locked interval")
 	ErrIntervalTagsUnicity   = fmt.Errorf("interval_tags unicity failed")
 	ErrInvalidInterval       = fmt.Errorf("invalid interval")
 	ErrInvalidParam          = fmt.Errorf("invalid parameter")
+	ErrInvalidTag            = fmt.Errorf("invalid tag")
 	ErrInvalidStartTimestamp = fmt.Errorf("invalid start timestamp")
 	ErrInvalidStopTimestamp  = fmt.Errorf("invalid stop timestamp")
+	ErrInvalidUUID           = fmt.Errorf("invalid uuid")
+	ErrMigrationChecksum     = fmt.Errorf("migration checksum mismatch")
+	ErrMissingTable          = fmt.Errorf("missing table")
 	ErrMultipleOpenInterval  = fmt.Errorf("multiple opened interval")
+	ErrNoOpenInterval        = fmt.Errorf("no opened interval")
 	ErrNotFound              = fmt.Errorf("not found entity")
 	ErrNotImplemented        = fmt.Errorf("operation not implemented")
+	ErrOverlappingInterval   = fmt.Errorf("overlapping interval")
+	ErrReplicaDivergence     = fmt.Errorf("diverging replicas")
+	ErrSchemaMismatch        = fmt.Errorf("database schema mismatch")
+	ErrTooManyTags           = fmt.Errorf("too many tags")
 )
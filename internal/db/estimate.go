@@ -0,0 +1,82 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SetEstimate records the planned duration of the interval identified by id,
+// replacing any previous estimate. A zero duration removes the estimate.
+// Like tags, estimates are never updated: the previous one is tombstoned.
+func (tt *TimeTracker) SetEstimate(id string, estimate time.Duration) (ret error) {
+	if estimate < 0 {
+		return fmt.Errorf("%w: negative estimate %s", ErrInvalidParam, estimate)
+	}
+
+	tx, err := tt.db.Begin()
+	if err != nil {
+		return fmt.Errorf("cannot start a transaction: %w", err)
+	}
+	defer tt.completeTransaction(tx, &ret)
+
+	if err := tt.checkLock(tx, id); err != nil {
+		return err
+	}
+
+	row := tx.QueryRow(`
+		SELECT interval_start.uuid
+		FROM interval_start
+			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+		WHERE interval_tombstone.uuid IS NULL
+			AND interval_start.id = ?`, id)
+	var intervalUUID string
+	if err := row.Scan(&intervalUUID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: id %s", ErrNotFound, id)
+		}
+		return fmt.Errorf("cannot retrieve uuid from database scan: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO interval_estimate_tombstone (uuid, estimate_uuid, created_at)
+		SELECT uuid(), interval_estimate.uuid, ?
+		FROM interval_estimate
+			LEFT JOIN interval_estimate_tombstone
+				ON interval_estimate.uuid = interval_estimate_tombstone.estimate_uuid
+		WHERE interval_estimate.start_uuid = ?
+			AND interval_estimate_tombstone.uuid IS NULL`,
+		tt.now().Unix(), intervalUUID); err != nil {
+		return fmt.Errorf("cannot delete previous estimate of interval %s: %w", id, err)
+	}
+
+	if estimate == 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO interval_estimate (uuid, start_uuid, estimate, created_at)
+		VALUES (uuid(), ?, ?, ?)`,
+		intervalUUID, int64(estimate.Seconds()), tt.now().Unix()); err != nil {
+		return fmt.Errorf("cannot insert estimate of interval %s: %w", id, err)
+	}
+
+	return nil
+}
+
+// getIntervalEstimate returns the estimate of an interval, zero when it has none.
+func getIntervalEstimate(tx rowQueryer, intervalUUID string) (time.Duration, error) {
+	var seconds sql.NullInt64
+	row := tx.QueryRow(`
+		SELECT estimate
+		FROM interval_estimate
+			LEFT JOIN interval_estimate_tombstone
+				ON interval_estimate.uuid = interval_estimate_tombstone.estimate_uuid
+		WHERE start_uuid = ?
+			AND interval_estimate_tombstone.uuid IS NULL`, intervalUUID)
+	if err := row.Scan(&seconds); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("cannot retrieve estimate: %w", err)
+	}
+	return time.Duration(seconds.Int64) * time.Second, nil
+}
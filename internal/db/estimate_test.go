@@ -0,0 +1,100 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dgsb/tt/internal/funk"
+)
+
+func TestSetEstimate(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.Local)
+	}
+
+	tt := setupTT(t)
+	require.NoError(t, tt.Start(at(10), []string{"a"}))
+	require.NoError(t, tt.StopAt(at(11)))
+	require.NoError(t, tt.Start(at(11), []string{"b"}))
+
+	estimates := func(t *testing.T) []time.Duration {
+		intervals, err := tt.List(at(0), at(23))
+		require.NoError(t, err)
+		return funk.Map(intervals, func(_ int, itv TaggedInterval) time.Duration {
+			return itv.Estimate
+		})
+	}
+
+	require.Equal(t, []time.Duration{0, 0}, estimates(t))
+
+	require.NoError(t, tt.SetEstimate("1", 2*time.Hour))
+	require.NoError(t, tt.SetEstimate("2", 30*time.Minute))
+	require.Equal(t, []time.Duration{2 * time.Hour, 30 * time.Minute}, estimates(t))
+
+	current, err := tt.Current()
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Minute, current.Estimate)
+
+	require.NoError(t, tt.SetEstimate("1", 45*time.Minute))
+	closed, err := tt.At(at(10))
+	require.NoError(t, err)
+	require.Equal(t, 45*time.Minute, closed.Estimate)
+
+	require.NoError(t, tt.SetEstimate("2", 0))
+	require.Equal(t, []time.Duration{45 * time.Minute, 0}, estimates(t))
+
+	require.ErrorIs(t, tt.SetEstimate("3", time.Hour), ErrNotFound)
+	require.ErrorIs(t, tt.SetEstimate("1", -time.Hour), ErrInvalidParam)
+
+	tt.SetLockDate(at(11))
+	require.ErrorIs(t, tt.SetEstimate("1", time.Hour), ErrIntervalLocked)
+}
+
+func TestSyncEstimates(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+
+	tt1 := setupTT(t)
+	tt2 := setupTT(t)
+
+	require.NoError(t, tt1.Start(at(10), []string{"a"}))
+	require.NoError(t, tt1.StopAt(at(11)))
+	require.NoError(t, tt1.SetEstimate("1", time.Hour))
+	require.NoError(t, tt1.SetEstimate("1", 90*time.Minute))
+
+	synchronise := func(syncTime time.Time) {
+		tx1, err := tt1.db.Beginx()
+		require.NoError(t, err)
+		defer commit(t, tx1)
+		tx2, err := tt2.db.Beginx()
+		require.NoError(t, err)
+		defer commit(t, tx2)
+
+		require.NoError(t, funk.CallAbortOnError(
+			func() error { return synchroniseTags(tx1, tx2, syncTime, MergeUnion) },
+			func() error { return synchroniseIntervalStart(tx1, tx2, syncTime, MergeUnion) },
+			func() error { return synchroniseIntervalStop(tx1, tx2, syncTime, MergeUnion) },
+			func() error { return synchroniseIntervalTombstone(tx1, tx2, syncTime, MergeUnion) },
+			func() error { return synchroniseIntervalTags(tx1, tx2, syncTime, MergeUnion) },
+			func() error { return synchroniseIntervalTagsTombstone(tx1, tx2, syncTime, MergeUnion) },
+			func() error { return synchroniseIntervalEstimate(tx1, tx2, syncTime, MergeUnion) },
+			func() error { return synchroniseIntervalEstimateTombstone(tx1, tx2, syncTime, MergeUnion) },
+		))
+	}
+
+	synchronise(time.Now())
+
+	itv, err := tt2.At(at(10))
+	require.NoError(t, err)
+	require.Equal(t, 90*time.Minute, itv.Estimate)
+
+	for _, table := range []string{"interval_estimate", "interval_estimate_tombstone"} {
+		var count1, count2 int
+		require.NoError(t, tt1.db.Get(&count1, `SELECT count(1) FROM `+table))
+		require.NoError(t, tt2.db.Get(&count2, `SELECT count(1) FROM `+table))
+		require.Equal(t, count1, count2, table)
+	}
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// FixLast adds and removes tags on the most recently started closed interval
+// of the current context in a single transaction. The removal is applied first.
+// It returns ErrNotFound when no closed interval has been recorded.
+func (tt *TimeTracker) FixLast(add, remove []string) (ret error) {
+	tx, err := tt.db.Begin()
+	if err != nil {
+		return fmt.Errorf("cannot start a transaction: %w", err)
+	}
+	defer tt.completeTransaction(tx, &ret)
+
+	var id string
+	row := tx.QueryRow(`
+		SELECT interval_start.id
+		FROM interval_start
+			JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+		WHERE interval_tombstone.uuid IS NULL
+			AND context = ?
+		ORDER BY start_timestamp DESC, interval_start.uuid DESC
+		LIMIT 1`, tt.context)
+	if err := row.Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: no closed interval recorded", ErrNotFound)
+		}
+		return fmt.Errorf("cannot retrieve last closed interval: %w", err)
+	}
+
+	if err := tt.checkLock(tx, id); err != nil {
+		return err
+	}
+	if err := tt.untag(tx, id, remove); err != nil {
+		return err
+	}
+	return tt.tag(tx, id, add)
+}
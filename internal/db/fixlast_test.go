@@ -0,0 +1,41 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixLast(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+
+	tt := setupTT(t)
+	require.ErrorIs(t, tt.FixLast([]string{"a"}, nil), ErrNotFound)
+
+	require.NoError(t, tt.Start(at(10), []string{"a", "b"}))
+	require.ErrorIs(t, tt.FixLast([]string{"c"}, nil), ErrNotFound)
+	require.NoError(t, tt.StopAt(at(11)))
+	require.NoError(t, tt.Start(at(11), []string{"a", "b"}))
+	require.NoError(t, tt.StopAt(at(12)))
+	require.NoError(t, tt.Start(at(12), []string{"a"}))
+
+	// The opened interval is left untouched
+	require.NoError(t, tt.FixLast([]string{"c"}, []string{"b"}))
+	require.NoError(t, tt.FixLast([]string{"b"}, []string{"a"}))
+
+	// A failing addition rolls back the removal
+	require.ErrorIs(t, tt.FixLast([]string{"b"}, []string{"c"}), ErrDuplicatedIntervalTag)
+
+	intervals, err := tt.List(at(0), at(23))
+	require.NoError(t, err)
+	require.Len(t, intervals, 3)
+	require.Equal(t, []string{"a", "b"}, intervals[0].Tags)
+	require.Equal(t, []string{"c", "b"}, intervals[1].Tags)
+	require.Equal(t, []string{"a"}, intervals[2].Tags)
+
+	tt.SetLockDate(at(12))
+	require.ErrorIs(t, tt.FixLast([]string{"d"}, nil), ErrIntervalLocked)
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/jmoiron/sqlx"
@@ -39,3 +40,66 @@ func getRows[T any](db Queryer, query string) (t []T, ret error) {
 	}
 	return
 }
+
+// maxQueryParameters is the sqlite default limit of parameters in a single statement.
+const maxQueryParameters = 999
+
+// insertRows inserts rows into table with multi-row INSERT statements
+// holding as many rows as the parameters limit allows for the columns.
+// Rows conflicting with existing ones are ignored. Each row must hold
+// a value for every column.
+func insertRows(tx *sqlx.Tx, table string, columns []string, rows [][]any) error {
+	return insertRowsOnConflict(tx, table, columns, rows, "ON CONFLICT DO NOTHING")
+}
+
+// overwriteRows behaves like insertRows except that rows conflicting on the key
+// column replace the existing ones when any of the compared columns differs.
+// Rows conflicting on another unique constraint make the insertion fail.
+func overwriteRows(
+	tx *sqlx.Tx, table, key string, compared, columns []string, rows [][]any,
+) error {
+	var set, distinct []string
+	for _, c := range columns {
+		if c != key {
+			set = append(set, fmt.Sprintf("%s = excluded.%s", c, c))
+		}
+	}
+	for _, c := range compared {
+		distinct = append(distinct, fmt.Sprintf("%s.%s IS DISTINCT FROM excluded.%s", table, c, c))
+	}
+
+	return insertRowsOnConflict(tx, table, columns, rows, fmt.Sprintf(
+		"ON CONFLICT (%s) DO UPDATE SET %s WHERE %s",
+		key, strings.Join(set, ", "), strings.Join(distinct, " OR ")))
+}
+
+func insertRowsOnConflict(
+	tx *sqlx.Tx, table string, columns []string, rows [][]any, onConflict string,
+) error {
+	batchSize := maxQueryParameters / len(columns)
+	placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+
+	for len(rows) > 0 {
+		batch := rows
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		rows = rows[len(batch):]
+
+		values := make([]string, 0, len(batch))
+		args := make([]any, 0, len(batch)*len(columns))
+		for _, r := range batch {
+			values = append(values, placeholders)
+			args = append(args, r...)
+		}
+
+		query := fmt.Sprintf(
+			"INSERT INTO %s (%s) VALUES %s %s",
+			table, strings.Join(columns, ", "), strings.Join(values, ", "), onConflict)
+		if _, err := tx.Exec(tx.Rebind(query), args...); err != nil {
+			return fmt.Errorf("cannot insert rows in %s table: %w", table, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// OverlapResolution is the decision taken when an imported interval
+// overlaps an already registered one.
+type OverlapResolution int
+
+const (
+	// OverlapAbort aborts the whole import.
+	OverlapAbort OverlapResolution = iota
+	// OverlapSkip ignores the imported interval.
+	OverlapSkip
+	// OverlapSnap shrinks the imported interval so that it ends when the registered
+	// one starts or starts when the registered one stops. The imported interval is
+	// ignored if nothing remains.
+	OverlapSnap
+	// OverlapMergeTags adds the imported tags to the registered interval instead
+	// of importing a duplicate when both start and stop at the same second.
+	// Any other overlap aborts the whole import.
+	OverlapMergeTags
+)
+
+// OverlapResolver is called when an imported interval overlaps an existing one.
+type OverlapResolver func(imported TaggedInterval, existing Interval) (OverlapResolution, error)
+
+// ImportError reports the imported interval which made an import fail.
+type ImportError struct {
+	// Index is the position of the interval in the imported list.
+	Index int
+	Err   error
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("cannot import interval %d: %s", e.Index, e.Err)
+}
+
+func (e *ImportError) Unwrap() error {
+	return e.Err
+}
+
+// Import registers a list of closed intervals in a single transaction.
+// When an imported interval overlaps an already registered one, resolve is
+// called to decide what to do. A nil resolve aborts the import on the first overlap.
+// Imported intervals are checked against the previously imported ones as well.
+// A failure related to a given interval is reported as an *ImportError.
+func (tt *TimeTracker) Import(intervals []TaggedInterval, resolve OverlapResolver) (ret error) {
+	tx, err := tt.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("cannot start transaction: %w", err)
+	}
+	defer tt.completeTransaction(tx, &ret)
+
+	for idx, itv := range intervals {
+		if itv.StartTimestamp.Unix() >= itv.StopTimestamp.Unix() {
+			return &ImportError{Index: idx, Err: fmt.Errorf("%w: stops before it starts", ErrInvalidInterval)}
+		}
+		if err := tt.importInterval(tx, itv, resolve); err != nil {
+			return &ImportError{Index: idx, Err: err}
+		}
+	}
+
+	return nil
+}
+
+func (tt *TimeTracker) importInterval(tx *sqlx.Tx, itv TaggedInterval, resolve OverlapResolver) error {
+	for {
+		existing, err := findOverlap(tx, tt.context, itv.StartTimestamp, itv.StopTimestamp)
+		if err != nil {
+			return err
+		}
+
+		if existing == nil {
+			newUUID, err := tt.insertIntervalStart(tx, itv.StartTimestamp, itv.Tags)
+			if err != nil {
+				return err
+			}
+			return tt.insertIntervalStop(tx, newUUID, itv.StopTimestamp)
+		}
+
+		resolution := OverlapAbort
+		if resolve != nil {
+			if resolution, err = resolve(itv, *existing); err != nil {
+				return fmt.Errorf("cannot resolve overlap with interval %s: %w", existing.ID, err)
+			}
+		}
+
+		switch resolution {
+		case OverlapSkip:
+			return nil
+		case OverlapSnap:
+			if existing.StartTimestamp.Unix() <= itv.StartTimestamp.Unix() {
+				if existing.StopTimestamp.IsZero() {
+					return nil
+				}
+				itv.StartTimestamp = existing.StopTimestamp
+			} else {
+				itv.StopTimestamp = existing.StartTimestamp
+			}
+			if itv.StartTimestamp.Unix() >= itv.StopTimestamp.Unix() {
+				return nil
+			}
+		case OverlapMergeTags:
+			if existing.StartTimestamp.Unix() != itv.StartTimestamp.Unix() ||
+				existing.StopTimestamp.IsZero() ||
+				existing.StopTimestamp.Unix() != itv.StopTimestamp.Unix() {
+				return fmt.Errorf("%w: overlaps interval %s without matching its time range",
+					ErrOverlappingInterval, existing.ID)
+			}
+			return tt.mergeTags(tx, *existing, itv.Tags)
+		default:
+			return fmt.Errorf("%w: overlaps interval %s", ErrOverlappingInterval, existing.ID)
+		}
+	}
+}
+
+// mergeTags adds to the registered interval the tags it doesn't carry yet.
+func (tt *TimeTracker) mergeTags(tx *sqlx.Tx, existing Interval, tags []string) error {
+	current, err := getIntervalTags(tx, existing.UUID)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, tag := range tt.resolveTags(tags) {
+		if !containsTag(current, tag) && !containsTag(missing, tag) {
+			missing = append(missing, tag)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return tt.tag(tx.Tx, existing.ID, missing)
+}
+
+// findOverlap returns the first registered interval of context overlapping [start, stop) if any.
+// The opened interval is considered to never stop.
+func findOverlap(tx *sqlx.Tx, context string, start, stop time.Time) (*Interval, error) {
+	var (
+		interval           Interval
+		unixStartTimestamp int64
+		unixStopTimestamp  sql.NullInt64
+	)
+	row := tx.QueryRow(`
+		SELECT id, interval_start.uuid, start_timestamp, stop_timestamp
+		FROM interval_start
+			LEFT JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+		WHERE interval_tombstone.uuid IS NULL
+			AND start_timestamp < ?2
+			AND (stop_timestamp > ?1 OR stop_timestamp IS NULL)
+			AND context = ?3
+		ORDER BY start_timestamp, interval_start.uuid
+		LIMIT 1`, start.Unix(), stop.Unix(), context)
+	if err := row.Scan(
+		&interval.ID, &interval.UUID, &unixStartTimestamp, &unixStopTimestamp,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot scan overlapping interval: %w", err)
+	}
+
+	interval.Context = context
+	interval.StartTimestamp = time.Unix(unixStartTimestamp, 0)
+	if unixStopTimestamp.Valid {
+		interval.StopTimestamp = time.Unix(unixStopTimestamp.Int64, 0)
+	}
+
+	return &interval, nil
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestImport(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2022, 3, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	setup := func(t *testing.T) *TimeTracker {
+		tt := setupTT(t)
+		require.NoError(t, tt.Start(at(10, 0), []string{"existing"}))
+		require.NoError(t, tt.StopAt(at(11, 0)))
+		require.NoError(t, tt.Start(at(14, 0), []string{"existing"}))
+		require.NoError(t, tt.StopAt(at(15, 0)))
+		return tt
+	}
+
+	payload := []TaggedInterval{
+		{Interval: Interval{StartTimestamp: at(8, 0), StopTimestamp: at(9, 0)}, Tags: []string{"a"}},
+		{Interval: Interval{StartTimestamp: at(10, 30), StopTimestamp: at(11, 30)}, Tags: []string{"b"}},
+		{Interval: Interval{StartTimestamp: at(13, 0), StopTimestamp: at(14, 30)}, Tags: []string{"c"}},
+	}
+
+	t.Run("abort without resolver", func(t *testing.T) {
+		tt := setup(t)
+		require.ErrorIs(t, tt.Import(payload, nil), ErrOverlappingInterval)
+
+		itvs, err := tt.List(at(0, 0), at(23, 0))
+		require.NoError(t, err)
+		require.Len(t, itvs, 2)
+	})
+
+	t.Run("invalid interval", func(t *testing.T) {
+		tt := setup(t)
+		err := tt.Import([]TaggedInterval{
+			{Interval: Interval{StartTimestamp: at(9, 0), StopTimestamp: at(8, 0)}},
+		}, nil)
+		require.ErrorIs(t, err, ErrInvalidInterval)
+	})
+
+	t.Run("scripted resolution", func(t *testing.T) {
+		tt := setup(t)
+		decisions := []OverlapResolution{OverlapSkip, OverlapSnap}
+		var seen []string
+		err := tt.Import(payload, func(imported TaggedInterval, existing Interval) (OverlapResolution, error) {
+			seen = append(seen, existing.ID)
+			ret := decisions[0]
+			decisions = decisions[1:]
+			return ret, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"1", "2"}, seen)
+
+		itvs, err := tt.List(at(0, 0), at(23, 0))
+		require.NoError(t, err)
+		require.Len(t, itvs, 4)
+
+		got := map[string][2]time.Time{}
+		for _, itv := range itvs {
+			got[itv.Tags[0]+itv.ID] = [2]time.Time{itv.StartTimestamp, itv.StopTimestamp}
+		}
+		require.Equal(t, [2]time.Time{at(8, 0).Local(), at(9, 0).Local()}, got["a3"])
+		require.Equal(t, [2]time.Time{at(13, 0).Local(), at(14, 0).Local()}, got["c4"])
+	})
+
+	t.Run("snap fully covered", func(t *testing.T) {
+		tt := setup(t)
+		err := tt.Import([]TaggedInterval{
+			{Interval: Interval{StartTimestamp: at(10, 15), StopTimestamp: at(10, 45)}, Tags: []string{"d"}},
+		}, func(TaggedInterval, Interval) (OverlapResolution, error) {
+			return OverlapSnap, nil
+		})
+		require.NoError(t, err)
+
+		itvs, err := tt.List(at(0, 0), at(23, 0))
+		require.NoError(t, err)
+		require.Len(t, itvs, 2)
+	})
+	mergeTags := func(TaggedInterval, Interval) (OverlapResolution, error) {
+		return OverlapMergeTags, nil
+	}
+
+	t.Run("merge tags on exact match", func(t *testing.T) {
+		tt := setup(t)
+		err := tt.Import([]TaggedInterval{
+			{Interval: Interval{StartTimestamp: at(8, 0), StopTimestamp: at(9, 0)}, Tags: []string{"a"}},
+			{Interval: Interval{StartTimestamp: at(10, 0), StopTimestamp: at(11, 0)}, Tags: []string{"existing", "b"}},
+		}, mergeTags)
+		require.NoError(t, err)
+
+		itvs, err := tt.List(at(0, 0), at(23, 0))
+		require.NoError(t, err)
+		require.Len(t, itvs, 3)
+		require.Equal(t, []string{"a"}, itvs[0].Tags)
+		require.Equal(t, "1", itvs[1].ID)
+		require.Equal(t, []string{"existing", "b"}, itvs[1].Tags)
+		require.Equal(t, []string{"existing"}, itvs[2].Tags)
+	})
+
+	t.Run("merge tags rejects partial overlap", func(t *testing.T) {
+		tt := setup(t)
+		err := tt.Import([]TaggedInterval{
+			{Interval: Interval{StartTimestamp: at(10, 0), StopTimestamp: at(11, 0)}, Tags: []string{"b"}},
+			{Interval: Interval{StartTimestamp: at(14, 0), StopTimestamp: at(14, 30)}, Tags: []string{"c"}},
+		}, mergeTags)
+		require.ErrorIs(t, err, ErrOverlappingInterval)
+		var importErr *ImportError
+		require.ErrorAs(t, err, &importErr)
+		require.Equal(t, 1, importErr.Index)
+
+		// Nothing is merged when the import fails
+		itvs, err := tt.List(at(0, 0), at(23, 0))
+		require.NoError(t, err)
+		require.Len(t, itvs, 2)
+		require.Equal(t, []string{"existing"}, itvs[0].Tags)
+	})
+}
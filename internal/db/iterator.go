@@ -0,0 +1,245 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// IntervalIterator streams the intervals selected by Iterate.
+// It must be closed once done with it.
+type IntervalIterator struct {
+	rows    *sql.Rows
+	current TaggedInterval
+	err     error
+}
+
+// ListFilter restricts the intervals returned by Iterate.
+// Zero values do not filter anything.
+type ListFilter struct {
+	// CreatedAfter keeps intervals recorded at or after this timestamp.
+	CreatedAfter time.Time
+	// CreatedBefore keeps intervals recorded before this timestamp.
+	CreatedBefore time.Time
+	// Tags keeps intervals carrying all these tags, aliases being resolved.
+	// The returned intervals still hold all their tags.
+	Tags []string
+}
+
+// unixOrNil returns the unix timestamp of t or nil for a zero t.
+func unixOrNil(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Unix()
+}
+
+// Iterate returns an iterator over the intervals started or stopped
+// between since and until sorted by start timestamp.
+// Intervals started in the same second are sorted by uuid so that they come
+// in the same order on every synchronised database, where their creation
+// timestamps differ.
+// The opened interval is always returned unless excluded by filter.
+// A read-write TimeTracker has a single database connection which the
+// iterator holds until it is closed: any other call on tt meanwhile blocks
+// forever.
+func (tt *TimeTracker) Iterate(
+	ctx context.Context, since, until time.Time, filter ListFilter,
+) (*IntervalIterator, error) {
+	tags := tt.resolveTags(filter.Tags)
+	if tags == nil {
+		tags = []string{}
+	}
+	jsonTags, err := json.Marshal(tags)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal filter tags: %w", err)
+	}
+
+	rows, err := tt.db.QueryContext(ctx, `
+		SELECT id, interval_start.uuid, start_timestamp, stop_timestamp, tz, context,
+			coalesce(location, ''),
+			(
+				SELECT json_group_array(name) FROM (
+					SELECT tags.name
+					FROM interval_tags
+						JOIN tags ON interval_tags.tag_id = tags.id
+						LEFT JOIN interval_tags_tombstone
+							ON interval_tags.uuid = interval_tags_tombstone.interval_tag_uuid
+					WHERE interval_start_uuid = interval_start.uuid
+						AND interval_tags_tombstone.uuid IS NULL
+					ORDER BY interval_tags.rowid
+				)
+			) tags,
+			(
+				SELECT estimate
+				FROM interval_estimate
+					LEFT JOIN interval_estimate_tombstone
+						ON interval_estimate.uuid = interval_estimate_tombstone.estimate_uuid
+				WHERE interval_estimate.start_uuid = interval_start.uuid
+					AND interval_estimate_tombstone.uuid IS NULL
+			) estimate,
+			EXISTS (
+				SELECT 1
+				FROM interval_billable
+					LEFT JOIN interval_billable_tombstone
+						ON interval_billable.uuid = interval_billable_tombstone.billable_uuid
+				WHERE interval_billable.start_uuid = interval_start.uuid
+					AND interval_billable_tombstone.uuid IS NULL
+			) billable
+		FROM interval_start
+			LEFT JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+		WHERE
+			(
+				(start_timestamp >= ?1  AND start_timestamp < ?2)
+				OR (stop_timestamp >= ?1 AND stop_timestamp < ?2)
+				OR stop_timestamp IS NULL
+			) AND interval_tombstone.uuid IS NULL
+			AND (?3 IS NULL OR interval_start.created_at >= ?3)
+			AND (?4 IS NULL OR interval_start.created_at < ?4)
+			AND (
+				SELECT count(DISTINCT interval_tags.tag_id)
+				FROM interval_tags
+					JOIN tags ON interval_tags.tag_id = tags.id
+					LEFT JOIN interval_tags_tombstone
+						ON interval_tags.uuid = interval_tags_tombstone.interval_tag_uuid
+				WHERE interval_start_uuid = interval_start.uuid
+					AND interval_tags_tombstone.uuid IS NULL
+					AND tags.name IN (SELECT value FROM json_each(?5))
+			) = json_array_length(?5)
+		ORDER BY start_timestamp, interval_start.uuid`,
+		since.Unix(), until.Unix(), unixOrNil(filter.CreatedAfter), unixOrNil(filter.CreatedBefore),
+		string(jsonTags))
+	if err != nil {
+		return nil, fmt.Errorf("cannot query for interval: %w", err)
+	}
+
+	return &IntervalIterator{rows: rows}, nil
+}
+
+// Count returns the number of intervals List would return between since and until
+// without fetching them.
+func (tt *TimeTracker) Count(since, until time.Time) (int, error) {
+	var count int
+	row := tt.db.QueryRow(`
+		SELECT count(1)
+		FROM interval_start
+			LEFT JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+		WHERE
+			(
+				(start_timestamp >= ?1  AND start_timestamp < ?2)
+				OR (stop_timestamp >= ?1 AND stop_timestamp < ?2)
+				OR stop_timestamp IS NULL
+			) AND interval_tombstone.uuid IS NULL`,
+		since.Unix(), until.Unix())
+	if err := row.Scan(&count); err != nil {
+		return 0, fmt.Errorf("cannot count intervals: %w", err)
+	}
+	return count, nil
+}
+
+// Next moves the iterator to the next interval. It returns false
+// when there is no more interval or an error occurred.
+func (it *IntervalIterator) Next() bool {
+	if it.err != nil || !it.rows.Next() {
+		return false
+	}
+
+	var (
+		unixStartTimestamp int64
+		unixStopTimestamp  sql.NullInt64
+		tz                 sql.NullString
+		tags               string
+		estimate           sql.NullInt64
+		interval           TaggedInterval
+	)
+	if err := it.rows.Scan(
+		&interval.Interval.ID,
+		&interval.Interval.UUID,
+		&unixStartTimestamp,
+		&unixStopTimestamp,
+		&tz,
+		&interval.Interval.Context,
+		&interval.Interval.Place,
+		&tags,
+		&estimate,
+		&interval.Billable); err != nil {
+		it.err = fmt.Errorf("cannot scan value for current row: %w", err)
+		return false
+	}
+
+	interval.Interval.Location = loadZone(tz)
+	interval.Interval.StartTimestamp = time.Unix(unixStartTimestamp, 0)
+	if unixStopTimestamp.Valid {
+		interval.Interval.StopTimestamp = time.Unix(unixStopTimestamp.Int64, 0)
+	}
+	if err := json.Unmarshal([]byte(tags), &interval.Tags); err != nil {
+		it.err = fmt.Errorf("cannot decode tags of interval %s: %w", interval.Interval.ID, err)
+		return false
+	}
+	if len(interval.Tags) == 0 {
+		interval.Tags = nil
+	}
+	interval.Estimate = time.Duration(estimate.Int64) * time.Second
+
+	it.current = interval
+	return true
+}
+
+// Interval returns the interval the iterator is positioned on.
+func (it *IntervalIterator) Interval() TaggedInterval {
+	return it.current
+}
+
+// Err returns the error which stopped the iteration if any.
+func (it *IntervalIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	if err := it.rows.Err(); err != nil {
+		return fmt.Errorf("cannot iterate over query returned rows: %w", err)
+	}
+	return nil
+}
+
+// Close releases the resources held by the iterator.
+func (it *IntervalIterator) Close() error {
+	return it.rows.Close()
+}
+
+// ListChan streams the intervals returned by List on the first returned channel.
+// Both channels are closed when all intervals have been sent, on error or when
+// ctx is cancelled. An error is sent on the second channel before it is closed.
+// The intervals are read before the first one is sent so that the consumer can
+// use tt, whose single connection would otherwise be held by the query.
+func (tt *TimeTracker) ListChan(
+	ctx context.Context, since, until time.Time,
+) (<-chan TaggedInterval, <-chan error) {
+	intervals := make(chan TaggedInterval)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(intervals)
+
+		tas, err := tt.listFiltered(ctx, since, until, ListFilter{})
+		if err != nil {
+			errs <- err
+			return
+		}
+
+		for _, ta := range tas {
+			select {
+			case intervals <- ta:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	return intervals, errs
+}
@@ -0,0 +1,268 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListChan(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+
+	tt := setupTT(t)
+	for hour := 8; hour < 18; hour++ {
+		require.NoError(t, tt.Start(at(hour), []string{"a"}))
+		require.NoError(t, tt.StopAt(at(hour+1)))
+	}
+
+	t.Run("full consumption", func(t *testing.T) {
+		expected, err := tt.List(at(0), at(23))
+		require.NoError(t, err)
+		require.Len(t, expected, 10)
+
+		intervals, errs := tt.ListChan(context.Background(), at(0), at(23))
+		var got []TaggedInterval
+		for itv := range intervals {
+			got = append(got, itv)
+		}
+		require.NoError(t, <-errs)
+		require.Equal(t, expected, got)
+	})
+
+	t.Run("early cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		intervals, errs := tt.ListChan(ctx, at(0), at(23))
+		first := <-intervals
+		require.Equal(t, "1", first.ID)
+		cancel()
+
+		require.ErrorIs(t, <-errs, context.Canceled)
+		_, ok := <-intervals
+		require.False(t, ok)
+	})
+
+	t.Run("interleaved calls", func(t *testing.T) {
+		intervals, errs := tt.ListChan(context.Background(), at(0), at(23))
+		done := make(chan int)
+		go func() {
+			defer close(done)
+			count := 0
+			for range intervals {
+				// The read-write database has a single connection
+				if _, err := tt.Current(); err != nil {
+					return
+				}
+				count++
+			}
+			done <- count
+		}()
+
+		select {
+		case count := <-done:
+			require.Equal(t, 10, count)
+		case <-time.After(5 * time.Second):
+			t.Fatal("the consumer is blocked by the intervals query")
+		}
+		require.NoError(t, <-errs)
+	})
+}
+
+func TestListFilteredCreatedAt(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2022, 3, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	tt := setupTT(t)
+	// Intervals of the 1st are recorded on the day they happened,
+	// the last one retroactively on the 3rd.
+	for idx, created := range []time.Time{at(1, 11), at(1, 12), at(3, 9)} {
+		tt.now = func() time.Time { return created }
+		require.NoError(t, tt.Start(at(1, 10+idx), []string{"a"}))
+		require.NoError(t, tt.StopAt(at(1, 11+idx)))
+	}
+
+	for _, tc := range []struct {
+		name     string
+		filter   ListFilter
+		expected []string
+	}{
+		{"no filter", ListFilter{}, []string{"1", "2", "3"}},
+		{"created after", ListFilter{CreatedAfter: at(2, 0)}, []string{"3"}},
+		{"created before", ListFilter{CreatedBefore: at(1, 12)}, []string{"1"}},
+		{"created window", ListFilter{CreatedAfter: at(1, 12), CreatedBefore: at(3, 9)}, []string{"2"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			itvs, err := tt.ListFiltered(at(1, 0), at(2, 0), tc.filter)
+			require.NoError(t, err)
+			ids := []string{}
+			for _, itv := range itvs {
+				ids = append(ids, itv.ID)
+			}
+			require.Equal(t, tc.expected, ids)
+		})
+	}
+}
+
+func TestListFilteredTags(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+
+	tt := setupTT(t)
+	for idx, tags := range [][]string{{"a", "b"}, {"b"}, {"a", "c"}, nil} {
+		require.NoError(t, tt.Start(at(10+idx), tags))
+		require.NoError(t, tt.StopAt(at(11+idx)))
+	}
+	require.NoError(t, tt.Untag("3", []string{"a"}))
+	tt.SetTagAliases(map[string]string{"alias": "b"})
+
+	for _, tc := range []struct {
+		name     string
+		tags     []string
+		expected []string
+	}{
+		{"no filter", nil, []string{"1", "2", "3", "4"}},
+		{"single tag", []string{"a"}, []string{"1"}},
+		{"all tags required", []string{"b", "a"}, []string{"1"}},
+		{"alias", []string{"alias"}, []string{"1", "2"}},
+		{"unknown tag", []string{"z"}, []string{}},
+		{"one tag untagged", []string{"a", "c"}, []string{}},
+		{"remaining tag", []string{"c"}, []string{"3"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			itvs, err := tt.ListFiltered(at(0), at(23), ListFilter{Tags: tc.tags})
+			require.NoError(t, err)
+			ids := []string{}
+			for _, itv := range itvs {
+				ids = append(ids, itv.ID)
+			}
+			require.Equal(t, tc.expected, ids)
+			if len(itvs) > 0 && itvs[0].ID == "1" {
+				require.Equal(t, []string{"a", "b"}, itvs[0].Tags)
+			}
+		})
+	}
+}
+
+func TestListSameStartOrder(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+	uuids := func(t *testing.T, tt *TimeTracker) []string {
+		t.Helper()
+		intervals, err := tt.List(at(0), at(23))
+		require.NoError(t, err)
+		ret := make([]string, 0, len(intervals))
+		for _, itv := range intervals {
+			ret = append(ret, itv.UUID)
+		}
+		return ret
+	}
+
+	tt1 := setupTT(t, filepath.Join(t.TempDir(), "tt1.db"))
+	tt2 := setupTT(t, filepath.Join(t.TempDir(), "tt2.db"))
+	syncer := SQLiteSyncer{Path: filepath.Join(t.TempDir(), "central.db")}
+
+	// Intervals of distinct contexts start in the same second
+	tt1.now = func() time.Time { return at(11) }
+	for _, context := range []string{"a", "b", "c", "d"} {
+		tt1.SetContext(context)
+		require.NoError(t, tt1.Start(at(10), []string{context}))
+		require.NoError(t, tt1.StopAt(at(11)))
+	}
+	// They are recorded in the reverse order of their uuids on tt1 whereas
+	// the synchronisation records them all at the same time on tt2
+	var recorded []string
+	require.NoError(t, tt1.db.Select(&recorded, `SELECT uuid FROM interval_start ORDER BY uuid DESC`))
+	for idx, uuid := range recorded {
+		_, err := tt1.db.Exec(`UPDATE interval_start SET created_at = ? WHERE uuid = ?`,
+			at(10).Unix()+int64(idx), uuid)
+		require.NoError(t, err)
+	}
+
+	tt1.now = func() time.Time { return at(20) }
+	require.NoError(t, tt1.Sync(syncer))
+	tt2.now = func() time.Time { return at(21) }
+	require.NoError(t, tt2.Sync(syncer))
+
+	expected := uuids(t, tt1)
+	require.Len(t, expected, 4)
+	require.True(t, sort.StringsAreSorted(expected), expected)
+	require.Equal(t, expected, uuids(t, tt2))
+	for i := 0; i < 10; i++ {
+		require.Equal(t, expected, uuids(t, tt1))
+	}
+}
+
+func TestCount(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2022, 3, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		setup func(t *testing.T, tt *TimeTracker)
+	}{
+		{
+			name:  "empty",
+			setup: func(t *testing.T, tt *TimeTracker) {},
+		},
+		{
+			name: "closed intervals",
+			setup: func(t *testing.T, tt *TimeTracker) {
+				for hour := 8; hour < 12; hour++ {
+					require.NoError(t, tt.Start(at(1, hour), []string{"a"}))
+					require.NoError(t, tt.StopAt(at(1, hour+1)))
+				}
+			},
+		},
+		{
+			name: "opened and deleted intervals",
+			setup: func(t *testing.T, tt *TimeTracker) {
+				require.NoError(t, tt.Start(at(1, 8), nil))
+				require.NoError(t, tt.StopAt(at(1, 9)))
+				require.NoError(t, tt.Start(at(1, 9), []string{"a", "b"}))
+				require.NoError(t, tt.StopAt(at(1, 10)))
+				require.NoError(t, tt.Delete("1"))
+				require.NoError(t, tt.Start(at(3, 8), nil))
+			},
+		},
+		{
+			name: "intervals across the window bounds",
+			setup: func(t *testing.T, tt *TimeTracker) {
+				require.NoError(t, tt.Start(at(1, 22), nil))
+				require.NoError(t, tt.StopAt(at(2, 1)))
+				require.NoError(t, tt.Start(at(2, 23), nil))
+				require.NoError(t, tt.StopAt(at(3, 2)))
+				require.NoError(t, tt.Start(at(4, 8), nil))
+				require.NoError(t, tt.StopAt(at(4, 9)))
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tt := setupTT(t)
+			tc.setup(t, tt)
+
+			for _, window := range [][2]time.Time{
+				{at(1, 0), at(2, 0)},
+				{at(2, 0), at(3, 0)},
+				{at(1, 0), at(5, 0)},
+				{at(5, 0), at(6, 0)},
+			} {
+				intervals, err := tt.List(window[0], window[1])
+				require.NoError(t, err)
+				count, err := tt.Count(window[0], window[1])
+				require.NoError(t, err)
+				require.Equal(t, len(intervals), count, "window %v", window)
+			}
+		})
+	}
+}
@@ -3,8 +3,11 @@ package db
 import (
 	"database/sql"
 	_ "embed"
+	"fmt"
+	"strings"
 
 	"github.com/GuiaBolso/darwin"
+	"github.com/jmoiron/sqlx"
 )
 
 //go:embed migrations/sqlite/01_base.sql
@@ -25,56 +28,334 @@ var sqliteAddImmutableInterval string
 //go:embed migrations/sqlite/06_not_null_created_at.sql
 var sqliteNotNullCreatedAt string
 
+//go:embed migrations/sqlite/07_interval_start_tz.sql
+var sqliteIntervalStartTZ string
+
+//go:embed migrations/sqlite/08_interval_start_context.sql
+var sqliteIntervalStartContext string
+
+//go:embed migrations/sqlite/09_interval_estimate.sql
+var sqliteIntervalEstimate string
+
+//go:embed migrations/sqlite/10_interval_billable.sql
+var sqliteIntervalBillable string
+
+//go:embed migrations/sqlite/11_interval_tags_tag_id.sql
+var sqliteIntervalTagsTagID string
+
+//go:embed migrations/sqlite/12_interval_start_location.sql
+var sqliteIntervalStartLocation string
+
+//go:embed migrations/sqlite/13_daily_totals.sql
+var sqliteDailyTotals string
+
+//go:embed migrations/sqlite/14_foreign_key_indexes.sql
+var sqliteForeignKeyIndexes string
+
+var sqliteMigrations = []darwin.Migration{
+	{
+		Version:     1,
+		Description: "base table definition to hold configuration variable",
+		Script:      sqliteBaseMigration,
+	},
+	{
+		Version:     2,
+		Description: "add timestamp on all tables",
+		Script:      sqliteAddTimestamp,
+	},
+	{
+		Version:     3,
+		Description: "add uuid unique key as conflict free identifier",
+		Script:      sqliteAddUUIDKey,
+	},
+	{
+		Version:     4,
+		Description: "add a synchronisation history table",
+		Script:      sqliteAddSyncMeta,
+	},
+	{
+		Version:     5,
+		Description: "split intervals table in 3 immutable table",
+		Script:      sqliteAddImmutableInterval,
+	},
+	{
+		Version:     6,
+		Description: "ensure created_at field is not nutll",
+		Script:      sqliteNotNullCreatedAt,
+	},
+	{
+		Version:     7,
+		Description: "record the local time zone of interval start",
+		Script:      sqliteIntervalStartTZ,
+	},
+	{
+		Version:     8,
+		Description: "allow one opened interval per context",
+		Script:      sqliteIntervalStartContext,
+	},
+	{
+		Version:     9,
+		Description: "add interval estimates",
+		Script:      sqliteIntervalEstimate,
+	},
+	{
+		Version:     10,
+		Description: "add interval billable flags",
+		Script:      sqliteIntervalBillable,
+	},
+	{
+		Version:     11,
+		Description: "reference tags by id from interval tags",
+		Script:      sqliteIntervalTagsTagID,
+	},
+	{
+		Version:     12,
+		Description: "record the location of interval start",
+		Script:      sqliteIntervalStartLocation,
+	},
+	{
+		Version:     13,
+		Description: "cache the tracked time per day",
+		Script:      sqliteDailyTotals,
+	},
+	{
+		Version:     14,
+		Description: "index the interval children by their interval",
+		Script:      sqliteForeignKeyIndexes,
+	},
+}
+
 func runSqliteMigrations(db *sql.DB) error {
+	return darwin.Migrate(darwin.NewGenericDriver(db, darwin.SqliteDialect{}), sqliteMigrations, nil)
+}
+
+// checkMigrated ensures all the sqlite migrations have been applied to db.
+func checkMigrated(db *sql.DB) error {
+	var version sql.NullFloat64
+	if err := db.QueryRow(`SELECT max(version) FROM darwin_migrations`).Scan(&version); err != nil {
+		return fmt.Errorf("cannot query darwin_migrations table: %w", err)
+	}
+	if expected := sqliteMigrations[len(sqliteMigrations)-1].Version; version.Float64 != expected {
+		return fmt.Errorf("%w: schema at migration version %g, %g expected",
+			ErrSchemaMismatch, version.Float64, expected)
+	}
+	return nil
+}
+
+// requiredTables are the tables this version of the application queries.
+var requiredTables = []string{
+	"sync_history",
+	"tags",
+	"interval_start",
+	"interval_stop",
+	"interval_tombstone",
+	"interval_tags",
+	"interval_tags_tombstone",
+	"interval_estimate",
+	"interval_estimate_tombstone",
+	"interval_billable",
+	"interval_billable_tombstone",
+	"daily_totals",
+}
+
+// checkRequiredTables ensures all the requiredTables exist in db so that a
+// database whose schema has been changed by a newer version of the application
+// is reported as such instead of failing on the first query.
+func checkRequiredTables(db *sql.DB) error {
+	for _, table := range requiredTables {
+		var count int
+		if err := db.QueryRow(`
+			SELECT count(1) FROM sqlite_master WHERE type = 'table' AND name = ?`, table,
+		).Scan(&count); err != nil {
+			return fmt.Errorf("cannot query sqlite_master table: %w", err)
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: %s, the database may have been written by a newer version of tt, "+
+				"upgrade tt to use it", ErrMissingTable, table)
+		}
+	}
+	return nil
+}
+
+// RepairMigrations re-stamps the checksums recorded in the darwin_migrations
+// table of the sqlite database with the ones of the embedded migration scripts.
+// It refuses to do so unless the database schema is identical to the one a
+// fresh database gets from the same migrations. The versions whose checksum
+// has been repaired are returned.
+func RepairMigrations(databaseName string) (repaired []float64, ret error) {
+	db, err := sqlx.Open(customSqliteDriverName, databaseName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open database %s: %w", databaseName, err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil && ret == nil {
+			repaired, ret = nil, fmt.Errorf("cannot close database %s: %w", databaseName, err)
+		}
+	}()
+
+	type migrationRow struct {
+		Version  float64 `db:"version"`
+		Checksum string  `db:"checksum"`
+	}
+	applied, err := getRows[migrationRow](db, `
+		SELECT version, checksum FROM darwin_migrations ORDER BY version`)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query darwin_migrations table: %w", err)
+	}
+
+	expected := make(map[float64]darwin.Migration, len(sqliteMigrations))
+	for _, m := range sqliteMigrations {
+		expected[m.Version] = m
+	}
+	var migrations []darwin.Migration
+	for _, a := range applied {
+		m, ok := expected[a.Version]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown applied migration version %g", ErrSchemaMismatch, a.Version)
+		}
+		migrations = append(migrations, m)
+		if a.Checksum != m.Checksum() {
+			repaired = append(repaired, a.Version)
+		}
+	}
+	if len(repaired) == 0 {
+		return nil, nil
+	}
+
+	reference, err := sqlx.Open(customSqliteDriverName, ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("cannot open reference database: %w", err)
+	}
+	defer reference.Close()
+	// Each connection to an in memory database gets its own database.
+	reference.SetMaxOpenConns(1)
+	if err := darwin.Migrate(
+		darwin.NewGenericDriver(reference.DB, darwin.SqliteDialect{}), migrations, nil); err != nil {
+		return nil, fmt.Errorf("cannot run schema migration on reference database: %w", err)
+	}
+
+	actualSchema, err := sqliteSchema(db)
+	if err != nil {
+		return nil, err
+	}
+	expectedSchema, err := sqliteSchema(reference)
+	if err != nil {
+		return nil, err
+	}
+	if len(actualSchema) != len(expectedSchema) {
+		return nil, fmt.Errorf("%w: %d schema objects found, %d expected",
+			ErrSchemaMismatch, len(actualSchema), len(expectedSchema))
+	}
+	for i := range actualSchema {
+		if actualSchema[i] != expectedSchema[i] {
+			return nil, fmt.Errorf("%w: found %q, expected %q",
+				ErrSchemaMismatch, actualSchema[i], expectedSchema[i])
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("cannot start a transaction: %w", err)
+	}
+	for _, version := range repaired {
+		if _, err := tx.Exec(`UPDATE darwin_migrations SET checksum = ? WHERE version = ?`,
+			expected[version].Checksum(), version); err != nil {
+			_ = tx.Rollback()
+			return nil, fmt.Errorf("cannot update checksum of migration version %g: %w", version, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("cannot commit transaction: %w", err)
+	}
+
+	return repaired, nil
+}
+
+// sqliteSchema returns the whitespace normalised DDL statements stored
+// in sqlite_master, ordered by object type and name.
+func sqliteSchema(db Queryer) ([]string, error) {
+	type schemaRow struct {
+		Type string `db:"type"`
+		Name string `db:"name"`
+		SQL  string `db:"sql"`
+	}
+	rows, err := getRows[schemaRow](db, `
+		SELECT type, name, sql
+		FROM sqlite_master
+		WHERE sql IS NOT NULL
+		ORDER BY type, name`)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query sqlite_master table: %w", err)
+	}
+
+	schema := make([]string, 0, len(rows))
+	for _, r := range rows {
+		schema = append(schema, fmt.Sprintf("%s %s: %s", r.Type, r.Name, strings.Join(strings.Fields(r.SQL), " ")))
+	}
+	return schema, nil
+}
+
+//go:embed migrations/postgres/01_base.sql
+var postgresBaseMigration string
+
+//go:embed migrations/postgres/02_interval_start_tz.sql
+var postgresIntervalStartTZ string
+
+//go:embed migrations/postgres/03_interval_start_context.sql
+var postgresIntervalStartContext string
+
+//go:embed migrations/postgres/04_interval_estimate.sql
+var postgresIntervalEstimate string
+
+//go:embed migrations/postgres/05_interval_billable.sql
+var postgresIntervalBillable string
+
+//go:embed migrations/postgres/06_interval_start_location.sql
+var postgresIntervalStartLocation string
+
+//go:embed migrations/postgres/07_interval_tags_not_null.sql
+var postgresIntervalTagsNotNull string
+
+func runPostgresMigrations(db *sql.DB) error {
 	return darwin.Migrate(
-		darwin.NewGenericDriver(db, darwin.SqliteDialect{}),
+		darwin.NewGenericDriver(db, darwin.PostgresDialect{}),
 		[]darwin.Migration{
 			{
 				Version:     1,
 				Description: "base table definition to hold configuration variable",
-				Script:      sqliteBaseMigration,
-			},
+				Script:      postgresBaseMigration,
+			}, // This first migration for postgres encompass sqlite migration 1 to 5.
+			// The sync_history table is a temporary table on the remote database.
 			{
 				Version:     2,
-				Description: "add timestamp on all tables",
-				Script:      sqliteAddTimestamp,
+				Description: "record the local time zone of interval start",
+				Script:      postgresIntervalStartTZ,
 			},
 			{
 				Version:     3,
-				Description: "add uuid unique key as conflict free identifier",
-				Script:      sqliteAddUUIDKey,
+				Description: "allow one opened interval per context",
+				Script:      postgresIntervalStartContext,
 			},
 			{
 				Version:     4,
-				Description: "add a synchronisation history table",
-				Script:      sqliteAddSyncMeta,
+				Description: "add interval estimates",
+				Script:      postgresIntervalEstimate,
 			},
 			{
 				Version:     5,
-				Description: "split intervals table in 3 immutable table",
-				Script:      sqliteAddImmutableInterval,
+				Description: "add interval billable flags",
+				Script:      postgresIntervalBillable,
 			},
 			{
 				Version:     6,
-				Description: "ensure created_at field is not nutll",
-				Script:      sqliteNotNullCreatedAt,
+				Description: "record the location of interval start",
+				Script:      postgresIntervalStartLocation,
 			},
-		},
-		nil)
-}
-
-//go:embed migrations/postgres/01_base.sql
-var postgresBaseMigration string
-
-func runPostgresMigrations(db *sql.DB) error {
-	return darwin.Migrate(
-		darwin.NewGenericDriver(db, darwin.PostgresDialect{}),
-		[]darwin.Migration{
 			{
-				Version:     1,
-				Description: "base table definition to hold configuration variable",
-				Script:      postgresBaseMigration,
-			}, // This first migration for postgres encompass sqlite migration 1 to 3
+				Version:     7,
+				Description: "ensure interval tags fields are not null",
+				Script:      postgresIntervalTagsNotNull,
+			},
 		},
 		nil)
 }
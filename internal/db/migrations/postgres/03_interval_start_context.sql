@@ -0,0 +1 @@
+ALTER TABLE interval_start ADD COLUMN context TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,14 @@
+CREATE TABLE interval_estimate (
+    uuid TEXT PRIMARY KEY,
+    start_uuid TEXT NOT NULL,
+    estimate INTEGER NOT NULL,
+    created_at INTEGER NOT NULL,
+    FOREIGN KEY (start_uuid) REFERENCES interval_start(uuid)
+);
+
+CREATE TABLE interval_estimate_tombstone (
+    uuid TEXT PRIMARY KEY,
+    estimate_uuid TEXT UNIQUE NOT NULL,
+    created_at INTEGER NOT NULL,
+    FOREIGN KEY (estimate_uuid) REFERENCES interval_estimate(uuid)
+);
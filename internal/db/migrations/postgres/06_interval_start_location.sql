@@ -0,0 +1 @@
+ALTER TABLE interval_start ADD COLUMN location TEXT;
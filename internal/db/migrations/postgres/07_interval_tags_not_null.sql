@@ -0,0 +1,4 @@
+ALTER TABLE interval_tags
+    ALTER COLUMN interval_start_uuid SET NOT NULL,
+    ALTER COLUMN tag SET NOT NULL,
+    ALTER COLUMN created_at SET NOT NULL;
@@ -0,0 +1,13 @@
+CREATE TABLE interval_billable (
+    uuid TEXT PRIMARY KEY,
+    start_uuid TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    FOREIGN KEY (start_uuid) REFERENCES interval_start(uuid)
+);
+
+CREATE TABLE interval_billable_tombstone (
+    uuid TEXT PRIMARY KEY,
+    billable_uuid TEXT UNIQUE NOT NULL,
+    created_at INTEGER NOT NULL,
+    FOREIGN KEY (billable_uuid) REFERENCES interval_billable(uuid)
+);
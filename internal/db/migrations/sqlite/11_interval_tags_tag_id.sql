@@ -0,0 +1,45 @@
+CREATE TABLE new_tags (
+    id INTEGER PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL,
+    created_at INTEGER
+);
+
+INSERT INTO new_tags (name, created_at)
+SELECT name, created_at FROM tags ORDER BY rowid;
+
+CREATE TABLE new_interval_tags (
+    uuid TEXT PRIMARY KEY,
+    interval_start_uuid TEXT NOT NULL,
+    tag_id INTEGER NOT NULL,
+    created_at INTEGER NOT NULL,
+    FOREIGN KEY(interval_start_uuid) REFERENCES interval_start(uuid),
+    FOREIGN KEY(tag_id) REFERENCES new_tags(id)
+);
+
+INSERT INTO new_interval_tags (uuid, interval_start_uuid, tag_id, created_at)
+SELECT
+    interval_tags.uuid,
+    interval_tags.interval_start_uuid,
+    new_tags.id,
+    interval_tags.created_at
+FROM interval_tags
+    JOIN new_tags ON interval_tags.tag = new_tags.name
+ORDER BY interval_tags.rowid;
+
+CREATE TABLE new_interval_tags_tombstone (
+    uuid TEXT PRIMARY KEY,
+    interval_tag_uuid TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    FOREIGN KEY(interval_tag_uuid) REFERENCES new_interval_tags(uuid)
+);
+
+INSERT INTO new_interval_tags_tombstone (uuid, interval_tag_uuid, created_at)
+SELECT uuid, interval_tag_uuid, created_at FROM interval_tags_tombstone ORDER BY rowid;
+
+DROP TABLE interval_tags_tombstone;
+DROP TABLE interval_tags;
+DROP TABLE tags;
+
+ALTER TABLE new_tags RENAME TO tags;
+ALTER TABLE new_interval_tags RENAME TO interval_tags;
+ALTER TABLE new_interval_tags_tombstone RENAME TO interval_tags_tombstone;
@@ -0,0 +1,94 @@
+CREATE TABLE daily_totals (
+    day INTEGER PRIMARY KEY,
+    duration INTEGER NOT NULL
+);
+
+INSERT INTO daily_totals (day, duration)
+SELECT interval_start.start_timestamp / 86400,
+    sum(interval_stop.stop_timestamp - interval_start.start_timestamp)
+FROM interval_start
+    JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+    LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+WHERE interval_tombstone.uuid IS NULL
+GROUP BY interval_start.start_timestamp / 86400;
+
+CREATE TRIGGER daily_totals_stop_insert AFTER INSERT ON interval_stop
+WHEN NOT EXISTS (SELECT 1 FROM interval_tombstone WHERE start_uuid = NEW.start_uuid)
+BEGIN
+    INSERT INTO daily_totals (day, duration)
+    SELECT start_timestamp / 86400, NEW.stop_timestamp - start_timestamp
+    FROM interval_start WHERE uuid = NEW.start_uuid
+    ON CONFLICT (day) DO UPDATE SET duration = duration + excluded.duration;
+END;
+
+CREATE TRIGGER daily_totals_stop_delete AFTER DELETE ON interval_stop
+WHEN NOT EXISTS (SELECT 1 FROM interval_tombstone WHERE start_uuid = OLD.start_uuid)
+BEGIN
+    INSERT INTO daily_totals (day, duration)
+    SELECT start_timestamp / 86400, start_timestamp - OLD.stop_timestamp
+    FROM interval_start WHERE uuid = OLD.start_uuid
+    ON CONFLICT (day) DO UPDATE SET duration = duration + excluded.duration;
+END;
+
+CREATE TRIGGER daily_totals_stop_update AFTER UPDATE OF start_uuid, stop_timestamp ON interval_stop
+BEGIN
+    INSERT INTO daily_totals (day, duration)
+    SELECT start_timestamp / 86400, start_timestamp - OLD.stop_timestamp
+    FROM interval_start WHERE uuid = OLD.start_uuid
+        AND NOT EXISTS (SELECT 1 FROM interval_tombstone WHERE start_uuid = OLD.start_uuid)
+    ON CONFLICT (day) DO UPDATE SET duration = duration + excluded.duration;
+
+    INSERT INTO daily_totals (day, duration)
+    SELECT start_timestamp / 86400, NEW.stop_timestamp - start_timestamp
+    FROM interval_start WHERE uuid = NEW.start_uuid
+        AND NOT EXISTS (SELECT 1 FROM interval_tombstone WHERE start_uuid = NEW.start_uuid)
+    ON CONFLICT (day) DO UPDATE SET duration = duration + excluded.duration;
+END;
+
+CREATE TRIGGER daily_totals_tombstone_insert AFTER INSERT ON interval_tombstone
+BEGIN
+    INSERT INTO daily_totals (day, duration)
+    SELECT start_timestamp / 86400, start_timestamp - stop_timestamp
+    FROM interval_start JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+    WHERE interval_start.uuid = NEW.start_uuid
+    ON CONFLICT (day) DO UPDATE SET duration = duration + excluded.duration;
+END;
+
+CREATE TRIGGER daily_totals_tombstone_delete AFTER DELETE ON interval_tombstone
+BEGIN
+    INSERT INTO daily_totals (day, duration)
+    SELECT start_timestamp / 86400, stop_timestamp - start_timestamp
+    FROM interval_start JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+    WHERE interval_start.uuid = OLD.start_uuid
+    ON CONFLICT (day) DO UPDATE SET duration = duration + excluded.duration;
+END;
+
+CREATE TRIGGER daily_totals_tombstone_update AFTER UPDATE OF start_uuid ON interval_tombstone
+BEGIN
+    INSERT INTO daily_totals (day, duration)
+    SELECT start_timestamp / 86400, stop_timestamp - start_timestamp
+    FROM interval_start JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+    WHERE interval_start.uuid = OLD.start_uuid
+    ON CONFLICT (day) DO UPDATE SET duration = duration + excluded.duration;
+
+    INSERT INTO daily_totals (day, duration)
+    SELECT start_timestamp / 86400, start_timestamp - stop_timestamp
+    FROM interval_start JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+    WHERE interval_start.uuid = NEW.start_uuid
+    ON CONFLICT (day) DO UPDATE SET duration = duration + excluded.duration;
+END;
+
+CREATE TRIGGER daily_totals_start_update AFTER UPDATE OF start_timestamp ON interval_start
+WHEN EXISTS (SELECT 1 FROM interval_stop WHERE start_uuid = NEW.uuid)
+    AND NOT EXISTS (SELECT 1 FROM interval_tombstone WHERE start_uuid = NEW.uuid)
+BEGIN
+    INSERT INTO daily_totals (day, duration)
+    SELECT OLD.start_timestamp / 86400, OLD.start_timestamp - stop_timestamp
+    FROM interval_stop WHERE start_uuid = NEW.uuid
+    ON CONFLICT (day) DO UPDATE SET duration = duration + excluded.duration;
+
+    INSERT INTO daily_totals (day, duration)
+    SELECT NEW.start_timestamp / 86400, stop_timestamp - NEW.start_timestamp
+    FROM interval_stop WHERE start_uuid = NEW.uuid
+    ON CONFLICT (day) DO UPDATE SET duration = duration + excluded.duration;
+END;
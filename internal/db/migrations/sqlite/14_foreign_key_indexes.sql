@@ -0,0 +1,5 @@
+CREATE INDEX interval_tags_interval_start_uuid ON interval_tags (interval_start_uuid);
+CREATE INDEX interval_tags_tombstone_interval_tag_uuid
+    ON interval_tags_tombstone (interval_tag_uuid);
+CREATE INDEX interval_estimate_start_uuid ON interval_estimate (start_uuid);
+CREATE INDEX interval_billable_start_uuid ON interval_billable (start_uuid);
@@ -8,9 +8,6 @@ CREATE TABLE darwin_migrations
                     execution_time FLOAT    NOT NULL,
                     UNIQUE         (version)
                 );
-CREATE TABLE tags (
-    name TEXT PRIMARY KEY
-, created_at INTEGER);
 CREATE TABLE sqlite_sequence(name,seq);
 CREATE TABLE sync_history (
     sync_timestamp INTEGER PRIMARY KEY
@@ -20,7 +17,7 @@ CREATE TABLE interval_start (
     uuid TEXT UNIQUE NOT NULL,
     start_timestamp INTEGER NOT NULL,
     created_at INTEGER NOT NULL
-);
+, tz TEXT, context TEXT NOT NULL DEFAULT '', location TEXT);
 CREATE TABLE interval_stop (
     uuid TEXT PRIMARY KEY,
     start_uuid TEXT UNIQUE NOT NULL,
@@ -34,13 +31,43 @@ CREATE TABLE interval_tombstone (
     created_at INTEGER NOT NULL,
     FOREIGN KEY (start_uuid) REFERENCES interval_start(uuid)
 );
+CREATE TABLE interval_estimate (
+    uuid TEXT PRIMARY KEY,
+    start_uuid TEXT NOT NULL,
+    estimate INTEGER NOT NULL,
+    created_at INTEGER NOT NULL,
+    FOREIGN KEY (start_uuid) REFERENCES interval_start(uuid)
+);
+CREATE TABLE interval_estimate_tombstone (
+    uuid TEXT PRIMARY KEY,
+    estimate_uuid TEXT UNIQUE NOT NULL,
+    created_at INTEGER NOT NULL,
+    FOREIGN KEY (estimate_uuid) REFERENCES interval_estimate(uuid)
+);
+CREATE TABLE interval_billable (
+    uuid TEXT PRIMARY KEY,
+    start_uuid TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    FOREIGN KEY (start_uuid) REFERENCES interval_start(uuid)
+);
+CREATE TABLE interval_billable_tombstone (
+    uuid TEXT PRIMARY KEY,
+    billable_uuid TEXT UNIQUE NOT NULL,
+    created_at INTEGER NOT NULL,
+    FOREIGN KEY (billable_uuid) REFERENCES interval_billable(uuid)
+);
+CREATE TABLE IF NOT EXISTS "tags" (
+    id INTEGER PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL,
+    created_at INTEGER
+);
 CREATE TABLE IF NOT EXISTS "interval_tags" (
     uuid TEXT PRIMARY KEY,
     interval_start_uuid TEXT NOT NULL,
-    tag TEXT NOT NULL,
+    tag_id INTEGER NOT NULL,
     created_at INTEGER NOT NULL,
     FOREIGN KEY(interval_start_uuid) REFERENCES interval_start(uuid),
-    FOREIGN KEY(tag) REFERENCES tags(name)
+    FOREIGN KEY(tag_id) REFERENCES "tags"(id)
 );
 CREATE TABLE IF NOT EXISTS "interval_tags_tombstone" (
     uuid TEXT PRIMARY KEY,
@@ -48,3 +75,87 @@ CREATE TABLE IF NOT EXISTS "interval_tags_tombstone" (
     created_at INTEGER NOT NULL,
     FOREIGN KEY(interval_tag_uuid) REFERENCES "interval_tags"(uuid)
 );
+CREATE TABLE daily_totals (
+    day INTEGER PRIMARY KEY,
+    duration INTEGER NOT NULL
+);
+CREATE TRIGGER daily_totals_stop_insert AFTER INSERT ON interval_stop
+WHEN NOT EXISTS (SELECT 1 FROM interval_tombstone WHERE start_uuid = NEW.start_uuid)
+BEGIN
+    INSERT INTO daily_totals (day, duration)
+    SELECT start_timestamp / 86400, NEW.stop_timestamp - start_timestamp
+    FROM interval_start WHERE uuid = NEW.start_uuid
+    ON CONFLICT (day) DO UPDATE SET duration = duration + excluded.duration;
+END;
+CREATE TRIGGER daily_totals_stop_delete AFTER DELETE ON interval_stop
+WHEN NOT EXISTS (SELECT 1 FROM interval_tombstone WHERE start_uuid = OLD.start_uuid)
+BEGIN
+    INSERT INTO daily_totals (day, duration)
+    SELECT start_timestamp / 86400, start_timestamp - OLD.stop_timestamp
+    FROM interval_start WHERE uuid = OLD.start_uuid
+    ON CONFLICT (day) DO UPDATE SET duration = duration + excluded.duration;
+END;
+CREATE TRIGGER daily_totals_stop_update AFTER UPDATE OF start_uuid, stop_timestamp ON interval_stop
+BEGIN
+    INSERT INTO daily_totals (day, duration)
+    SELECT start_timestamp / 86400, start_timestamp - OLD.stop_timestamp
+    FROM interval_start WHERE uuid = OLD.start_uuid
+        AND NOT EXISTS (SELECT 1 FROM interval_tombstone WHERE start_uuid = OLD.start_uuid)
+    ON CONFLICT (day) DO UPDATE SET duration = duration + excluded.duration;
+
+    INSERT INTO daily_totals (day, duration)
+    SELECT start_timestamp / 86400, NEW.stop_timestamp - start_timestamp
+    FROM interval_start WHERE uuid = NEW.start_uuid
+        AND NOT EXISTS (SELECT 1 FROM interval_tombstone WHERE start_uuid = NEW.start_uuid)
+    ON CONFLICT (day) DO UPDATE SET duration = duration + excluded.duration;
+END;
+CREATE TRIGGER daily_totals_tombstone_insert AFTER INSERT ON interval_tombstone
+BEGIN
+    INSERT INTO daily_totals (day, duration)
+    SELECT start_timestamp / 86400, start_timestamp - stop_timestamp
+    FROM interval_start JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+    WHERE interval_start.uuid = NEW.start_uuid
+    ON CONFLICT (day) DO UPDATE SET duration = duration + excluded.duration;
+END;
+CREATE TRIGGER daily_totals_tombstone_delete AFTER DELETE ON interval_tombstone
+BEGIN
+    INSERT INTO daily_totals (day, duration)
+    SELECT start_timestamp / 86400, stop_timestamp - start_timestamp
+    FROM interval_start JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+    WHERE interval_start.uuid = OLD.start_uuid
+    ON CONFLICT (day) DO UPDATE SET duration = duration + excluded.duration;
+END;
+CREATE TRIGGER daily_totals_tombstone_update AFTER UPDATE OF start_uuid ON interval_tombstone
+BEGIN
+    INSERT INTO daily_totals (day, duration)
+    SELECT start_timestamp / 86400, stop_timestamp - start_timestamp
+    FROM interval_start JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+    WHERE interval_start.uuid = OLD.start_uuid
+    ON CONFLICT (day) DO UPDATE SET duration = duration + excluded.duration;
+
+    INSERT INTO daily_totals (day, duration)
+    SELECT start_timestamp / 86400, start_timestamp - stop_timestamp
+    FROM interval_start JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+    WHERE interval_start.uuid = NEW.start_uuid
+    ON CONFLICT (day) DO UPDATE SET duration = duration + excluded.duration;
+END;
+CREATE TRIGGER daily_totals_start_update AFTER UPDATE OF start_timestamp ON interval_start
+WHEN EXISTS (SELECT 1 FROM interval_stop WHERE start_uuid = NEW.uuid)
+    AND NOT EXISTS (SELECT 1 FROM interval_tombstone WHERE start_uuid = NEW.uuid)
+BEGIN
+    INSERT INTO daily_totals (day, duration)
+    SELECT OLD.start_timestamp / 86400, OLD.start_timestamp - stop_timestamp
+    FROM interval_stop WHERE start_uuid = NEW.uuid
+    ON CONFLICT (day) DO UPDATE SET duration = duration + excluded.duration;
+
+    INSERT INTO daily_totals (day, duration)
+    SELECT NEW.start_timestamp / 86400, stop_timestamp - NEW.start_timestamp
+    FROM interval_stop WHERE start_uuid = NEW.uuid
+    ON CONFLICT (day) DO UPDATE SET duration = duration + excluded.duration;
+END;
+
+CREATE INDEX interval_tags_interval_start_uuid ON interval_tags (interval_start_uuid);
+CREATE INDEX interval_tags_tombstone_interval_tag_uuid
+    ON interval_tags_tombstone (interval_tag_uuid);
+CREATE INDEX interval_estimate_start_uuid ON interval_estimate (start_uuid);
+CREATE INDEX interval_billable_start_uuid ON interval_billable (start_uuid);
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// TagChange describes the tags of an interval before and after a tag renaming.
+type TagChange struct {
+	ID     string
+	Before []string
+	After  []string
+}
+
+// RenameTag replaces the tag oldName by newName on every interval holding it.
+// An interval already tagged with newName merely loses oldName.
+// newName is replaced by its canonical form if it is an alias.
+// The renaming fails with ErrIntervalLocked if any of these intervals started
+// before the lock date.
+// It returns the changed intervals sorted by id. When dryRun is set, the renaming
+// is performed in a transaction which is rolled back hence the returned changes
+// are exactly the ones an actual renaming would apply.
+// Although interval tags reference the tags by id, the tags row is not renamed
+// in place: only new rows are synchronised so the other databases would keep
+// the old name.
+func (tt *TimeTracker) RenameTag(oldName, newName string, dryRun bool) (changes []TagChange, ret error) {
+	newName = tt.resolveTags([]string{newName})[0]
+	if err := validateTags([]string{newName}); err != nil {
+		return nil, err
+	}
+	if oldName == newName {
+		return nil, fmt.Errorf("%w: tag %q renamed to itself", ErrInvalidParam, oldName)
+	}
+
+	tx, err := tt.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("cannot start a transaction: %w", err)
+	}
+	if dryRun {
+		defer func() {
+			if err := tx.Rollback(); err != nil && ret == nil {
+				ret = fmt.Errorf("cannot rollback dry run transaction: %w", err)
+			}
+		}()
+	} else {
+		defer tt.completeTransaction(tx, &ret)
+	}
+
+	type taggedRow struct {
+		id   string
+		uuid string
+	}
+	var tagged []taggedRow
+	rows, err := tx.Query(`
+		SELECT interval_start.id, interval_start.uuid
+		FROM interval_tags
+			JOIN tags ON interval_tags.tag_id = tags.id
+			JOIN interval_start ON interval_tags.interval_start_uuid = interval_start.uuid
+			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+			LEFT JOIN interval_tags_tombstone
+				ON interval_tags.uuid = interval_tags_tombstone.interval_tag_uuid
+		WHERE tags.name = ?
+			AND interval_tombstone.uuid IS NULL
+			AND interval_tags_tombstone.uuid IS NULL
+		ORDER BY interval_start.id`, oldName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query intervals tagged with %s: %w", oldName, err)
+	}
+	for rows.Next() {
+		var row taggedRow
+		if err := rows.Scan(&row.id, &row.uuid); err != nil {
+			return nil, multierror.Append(fmt.Errorf("cannot scan tagged interval: %w", err), rows.Close())
+		}
+		tagged = append(tagged, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("cannot iterate over tagged intervals: %w", err)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, fmt.Errorf("cannot close tagged intervals rows: %w", err)
+	}
+
+	for _, row := range tagged {
+		if err := tt.checkLock(tx, row.id); err != nil {
+			return nil, err
+		}
+
+		before, err := getIntervalTags(tx, row.uuid)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := tt.untag(tx, row.id, []string{oldName}); err != nil {
+			return nil, err
+		}
+		if !containsTag(before, newName) {
+			if err := tt.tag(tx, row.id, []string{newName}); err != nil {
+				return nil, err
+			}
+		}
+
+		after, err := getIntervalTags(tx, row.uuid)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, TagChange{ID: row.id, Before: before, After: after})
+	}
+
+	return changes, nil
+}
+
+// containsTag reports whether tags holds tag.
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
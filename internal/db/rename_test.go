@@ -0,0 +1,129 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenameTag(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 2, 25, hour, 0, 0, 0, time.Local)
+	}
+
+	setup := func(t *testing.T) *TimeTracker {
+		tt := setupTT(t)
+		require.NoError(t, tt.Start(at(10), []string{"a", "b"}))
+		require.NoError(t, tt.StopAt(at(11)))
+		require.NoError(t, tt.Start(at(11), []string{"c"}))
+		require.NoError(t, tt.StopAt(at(12)))
+		require.NoError(t, tt.Start(at(12), []string{"a", "c"}))
+		require.NoError(t, tt.StopAt(at(13)))
+		return tt
+	}
+
+	list := func(t *testing.T, tt *TimeTracker) []TaggedInterval {
+		intervals, err := tt.List(at(0), at(23))
+		require.NoError(t, err)
+		return intervals
+	}
+
+	expected := []TagChange{
+		{ID: "1", Before: []string{"a", "b"}, After: []string{"b", "c"}},
+		{ID: "3", Before: []string{"a", "c"}, After: []string{"c"}},
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		tt := setup(t)
+		before := list(t, tt)
+
+		changes, err := tt.RenameTag("a", "c", true)
+		require.NoError(t, err)
+		require.Equal(t, expected, changes)
+		require.Equal(t, before, list(t, tt))
+	})
+
+	t.Run("preview matches the actual renaming", func(t *testing.T) {
+		tt := setup(t)
+
+		preview, err := tt.RenameTag("a", "c", true)
+		require.NoError(t, err)
+		changes, err := tt.RenameTag("a", "c", false)
+		require.NoError(t, err)
+		require.Equal(t, preview, changes)
+
+		tags := map[string][]string{}
+		for _, itv := range list(t, tt) {
+			tags[itv.ID] = itv.Tags
+		}
+		require.Len(t, tags, 3)
+		for _, change := range changes {
+			require.ElementsMatch(t, change.After, tags[change.ID])
+		}
+	})
+
+	t.Run("locked interval", func(t *testing.T) {
+		tt := setup(t)
+		tt.SetLockDate(at(11))
+
+		_, err := tt.RenameTag("a", "d", false)
+		require.ErrorIs(t, err, ErrIntervalLocked)
+		require.Equal(t, []string{"a", "b"}, list(t, tt)[0].Tags)
+	})
+
+	t.Run("invalid new name", func(t *testing.T) {
+		tt := setup(t)
+
+		_, err := tt.RenameTag("a", "c,d", false)
+		require.ErrorIs(t, err, ErrInvalidTag)
+		_, err = tt.RenameTag("a", "a", false)
+		require.ErrorIs(t, err, ErrInvalidParam)
+	})
+}
+
+func TestRenameTagSync(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2022, 3, d, 12, 0, 0, 0, time.UTC)
+	}
+	clock := func(tt *TimeTracker, t time.Time) {
+		tt.now = func() time.Time { return t }
+	}
+	tags := func(t *testing.T, tt *TimeTracker) [][]string {
+		t.Helper()
+		intervals, err := tt.List(day(1), day(2))
+		require.NoError(t, err)
+		ret := make([][]string, 0, len(intervals))
+		for _, itv := range intervals {
+			ret = append(ret, itv.Tags)
+		}
+		return ret
+	}
+
+	tt1 := setupTT(t, filepath.Join(t.TempDir(), "tt1.db"))
+	tt2 := setupTT(t, filepath.Join(t.TempDir(), "tt2.db"))
+	syncer := SQLiteSyncer{Path: filepath.Join(t.TempDir(), "central.db")}
+
+	clock(tt1, day(1))
+	require.NoError(t, tt1.Start(day(1), []string{"a", "b"}))
+	require.NoError(t, tt1.StopAt(day(1).Add(time.Hour)))
+	clock(tt1, day(2))
+	require.NoError(t, tt1.Sync(syncer))
+	clock(tt2, day(3))
+	require.NoError(t, tt2.Sync(syncer))
+	require.Equal(t, [][]string{{"a", "b"}}, tags(t, tt2))
+
+	// The renaming done on one database reaches the other one
+	clock(tt1, day(4))
+	_, err := tt1.RenameTag("a", "z", false)
+	require.NoError(t, err)
+	require.NoError(t, tt1.Sync(syncer))
+	clock(tt2, day(5))
+	require.NoError(t, tt2.Sync(syncer))
+	clock(tt1, day(6))
+	require.NoError(t, tt1.Sync(syncer))
+
+	require.Equal(t, tags(t, tt1), tags(t, tt2))
+	require.Equal(t, [][]string{{"b", "z"}}, tags(t, tt2))
+}
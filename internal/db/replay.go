@@ -0,0 +1,209 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// ReplayStep is one operation of a replayed log, in the format logged by the
+// synchronisation property test on failure.
+// Operation is either start, stop or sync. DBIndex designates the replica the
+// start and stop operations apply to, it is ignored by sync which involves
+// every replica.
+type ReplayStep struct {
+	Operation string
+	DBIndex   uint
+	Timestamp time.Time
+}
+
+// ReadReplayLog decodes the JSON array of replay steps read from r.
+func ReadReplayLog(r io.Reader) ([]ReplayStep, error) {
+	var steps []ReplayStep
+	if err := json.NewDecoder(r).Decode(&steps); err != nil {
+		return nil, fmt.Errorf("cannot decode replay log: %w", err)
+	}
+	return steps, nil
+}
+
+// Replay runs steps against fresh in-memory replicas, one per database index
+// of the log, synchronised through a fresh in-memory central database.
+// The clock of the replicas is set to the timestamp of the running step.
+// Following the property test:
+//   - start stops every opened interval, then starts one on its replica,
+//   - stop stops the opened interval of its replica if any,
+//   - sync stops every opened interval and synchronises each replica in turn,
+//     then all but the last one again so that they all converge. The clock
+//     moves one second forward between two synchronisations.
+//
+// The replicas are checked for sanity after each step and, after a sync,
+// they must hold the same intervals or ErrReplicaDivergence is returned.
+// The replicas are returned in their final state and must be closed by
+// the caller.
+func Replay(steps []ReplayStep) (replicas []*TimeTracker, ret error) {
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("%w: empty replay log", ErrInvalidParam)
+	}
+
+	count := uint(0)
+	for _, step := range steps {
+		if step.DBIndex >= count {
+			count = step.DBIndex + 1
+		}
+	}
+
+	closeAll := func(trackers []*TimeTracker) error {
+		var errs error
+		for _, tt := range trackers {
+			if err := tt.Close(); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("cannot close replay database: %w", err))
+			}
+		}
+		return errs
+	}
+
+	central, err := New(":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("cannot setup replay central database: %w", err)
+	}
+	defer func() {
+		if err := closeAll([]*TimeTracker{central}); err != nil {
+			ret = multierror.Append(ret, err)
+		}
+	}()
+
+	now := steps[0].Timestamp
+	clock := func() time.Time { return now }
+	for idx := uint(0); idx < count; idx++ {
+		tt, err := New(":memory:")
+		if err != nil {
+			return nil, multierror.Append(
+				fmt.Errorf("cannot setup replay replica %d: %w", idx, err), closeAll(replicas))
+		}
+		tt.now = clock
+		replicas = append(replicas, tt)
+	}
+
+	for idx, step := range steps {
+		now = step.Timestamp
+		if err := replayStep(replicas, central, step, &now); err != nil {
+			return nil, multierror.Append(
+				fmt.Errorf("step %d, %s of replica %d at %s: %w",
+					idx, step.Operation, step.DBIndex, step.Timestamp.Format(time.RFC3339), err),
+				closeAll(replicas))
+		}
+	}
+
+	return replicas, nil
+}
+
+// replayStep applies step to the replicas and checks the resulting state.
+// now is the clock of the replicas, moved forward between synchronisations.
+func replayStep(
+	replicas []*TimeTracker, central *TimeTracker, step ReplayStep, now *time.Time,
+) error {
+	switch step.Operation {
+	case "start":
+		if err := stopOpened(replicas, *now); err != nil {
+			return err
+		}
+		if err := replicas[step.DBIndex].Start(*now, []string{}); err != nil {
+			return fmt.Errorf("cannot start interval: %w", err)
+		}
+	case "stop":
+		if err := stopOpened(replicas[step.DBIndex:step.DBIndex+1], *now); err != nil {
+			return err
+		}
+	case "sync":
+		if err := stopOpened(replicas, *now); err != nil {
+			return err
+		}
+		order := append(append([]*TimeTracker{}, replicas...), replicas[:len(replicas)-1]...)
+		for idx, tt := range order {
+			if idx > 0 {
+				*now = now.Add(time.Second)
+			}
+			if err := tt.SyncWith(central.db, MergeUnion); err != nil {
+				return fmt.Errorf("cannot synchronise replica: %w", err)
+			}
+		}
+	default:
+		return fmt.Errorf("%w: unknown replay operation %q", ErrInvalidParam, step.Operation)
+	}
+
+	for idx, tt := range replicas {
+		if err := NewSanity(tt.db).Check(); err != nil {
+			return fmt.Errorf("sanity check of replica %d failed: %w", idx, err)
+		}
+	}
+
+	if step.Operation == "sync" {
+		return checkConvergence(replicas, now.Add(time.Second))
+	}
+	return nil
+}
+
+// stopOpened stops the opened interval of each replica at t.
+func stopOpened(replicas []*TimeTracker, t time.Time) error {
+	for idx, tt := range replicas {
+		since, err := tt.OpenSince()
+		if err != nil {
+			return err
+		}
+		if since.IsZero() {
+			continue
+		}
+		if err := tt.StopAt(t); err != nil {
+			return fmt.Errorf("cannot stop interval of replica %d: %w", idx, err)
+		}
+	}
+	return nil
+}
+
+// checkConvergence verifies that every replica holds the same intervals
+// as the first one up to until.
+func checkConvergence(replicas []*TimeTracker, until time.Time) error {
+	reference, err := replicas[0].List(time.Time{}, until)
+	if err != nil {
+		return fmt.Errorf("cannot list intervals of replica 0: %w", err)
+	}
+
+	for idx, tt := range replicas[1:] {
+		intervals, err := tt.List(time.Time{}, until)
+		if err != nil {
+			return fmt.Errorf("cannot list intervals of replica %d: %w", idx+1, err)
+		}
+		if len(intervals) != len(reference) {
+			return fmt.Errorf("%w: replica %d holds %d intervals instead of %d",
+				ErrReplicaDivergence, idx+1, len(intervals), len(reference))
+		}
+		for i := range intervals {
+			if !sameReplicatedInterval(reference[i], intervals[i]) {
+				return fmt.Errorf("%w: replica %d holds interval %s instead of %s",
+					ErrReplicaDivergence, idx+1, intervals[i].UUID, reference[i].UUID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// sameReplicatedInterval reports whether lhs and rhs are the same interval
+// as seen by two replicas. The ids are local to each database and ignored.
+func sameReplicatedInterval(lhs, rhs TaggedInterval) bool {
+	if lhs.UUID != rhs.UUID ||
+		!lhs.StartTimestamp.Equal(rhs.StartTimestamp) ||
+		!lhs.StopTimestamp.Equal(rhs.StopTimestamp) ||
+		len(lhs.Tags) != len(rhs.Tags) {
+		return false
+	}
+	for idx := range lhs.Tags {
+		if lhs.Tags[idx] != rhs.Tags[idx] {
+			return false
+		}
+	}
+	return true
+}
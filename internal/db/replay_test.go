@@ -0,0 +1,63 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplay(t *testing.T) {
+	log := `[
+		{"Operation": "start", "DBIndex": 0, "Timestamp": "2022-03-01T09:00:00Z"},
+		{"Operation": "start", "DBIndex": 1, "Timestamp": "2022-03-01T10:00:00Z"},
+		{"Operation": "stop", "DBIndex": 1, "Timestamp": "2022-03-01T11:00:00Z"},
+		{"Operation": "stop", "DBIndex": 0, "Timestamp": "2022-03-01T11:30:00Z"},
+		{"Operation": "start", "DBIndex": 0, "Timestamp": "2022-03-01T12:00:00Z"},
+		{"Operation": "sync", "DBIndex": 1, "Timestamp": "2022-03-01T13:00:00Z"}
+	]`
+
+	steps, err := ReadReplayLog(strings.NewReader(log))
+	require.NoError(t, err)
+	require.Len(t, steps, 6)
+
+	replicas, err := Replay(steps)
+	require.NoError(t, err)
+	require.Len(t, replicas, 2)
+	t.Cleanup(func() {
+		for _, tt := range replicas {
+			require.NoError(t, tt.Close())
+		}
+	})
+
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+	for _, tt := range replicas {
+		since, err := tt.OpenSince()
+		require.NoError(t, err)
+		require.True(t, since.IsZero())
+
+		intervals, err := tt.List(at(0), at(14))
+		require.NoError(t, err)
+		require.Len(t, intervals, 3)
+		require.True(t, at(9).Equal(intervals[0].StartTimestamp))
+		require.True(t, at(10).Equal(intervals[0].StopTimestamp))
+		require.True(t, at(10).Equal(intervals[1].StartTimestamp))
+		require.True(t, at(11).Equal(intervals[1].StopTimestamp))
+		require.True(t, at(12).Equal(intervals[2].StartTimestamp))
+		require.True(t, at(13).Equal(intervals[2].StopTimestamp))
+	}
+
+	t.Run("unknown operation", func(t *testing.T) {
+		_, err := Replay([]ReplayStep{{Operation: "tag", Timestamp: at(9)}})
+		require.ErrorIs(t, err, ErrInvalidParam)
+		require.ErrorContains(t, err, "step 0")
+	})
+
+	t.Run("empty log", func(t *testing.T) {
+		_, err := Replay(nil)
+		require.ErrorIs(t, err, ErrInvalidParam)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/hashicorp/go-multierror"
 	"github.com/jmoiron/sqlx"
 )
@@ -21,17 +22,57 @@ func NewSanity(db *sqlx.DB) *Sanity {
 	return &Sanity{db: db}
 }
 
+// maxIntervalDuration is the duration above which a closed interval
+// is reported as suspicious by Report.
+const maxIntervalDuration = 24 * time.Hour
+
+// CheckResult is the outcome of a single sanity check.
+// Err is nil when the check passed.
+type CheckResult struct {
+	Name string
+	Err  error
+}
+
+// Sanity returns the sanity checker of the time tracker database.
+func (tt *TimeTracker) Sanity() *Sanity {
+	return NewSanity(tt.db)
+}
+
 // Check performs a full database scan to validate data.
 // It will call:
 //   - checkNoOverlap
 //   - intervalTagsUnicity
+//   - checkIntervalsUpdatedAt
+//   - checkDanglingStop
+//   - checkStopAfterStart
+//   - checkUUIDFormat
+//   - checkUUIDUnicity
 func (s *Sanity) Check() error {
 	err := multierror.Append(nil, s.checkNoOverlap())
 	err = multierror.Append(err, s.intervalTagsUnicity())
 	err = multierror.Append(err, s.checkIntervalsUpdatedAt())
+	err = multierror.Append(err, s.checkDanglingStop())
+	err = multierror.Append(err, s.checkStopAfterStart())
+	err = multierror.Append(err, s.checkUUIDFormat())
+	err = multierror.Append(err, s.checkUUIDUnicity())
 	return err.ErrorOrNil()
 }
 
+// Report runs all the checks performed by Check along with checkDuration
+// and returns the individual result of each of them.
+func (s *Sanity) Report() []CheckResult {
+	return []CheckResult{
+		{Name: "no overlapping intervals", Err: s.checkNoOverlap()},
+		{Name: "interval tags unicity", Err: s.intervalTagsUnicity()},
+		{Name: "intervals updated after creation", Err: s.checkIntervalsUpdatedAt()},
+		{Name: "no dangling interval stop", Err: s.checkDanglingStop()},
+		{Name: "interval stop after start", Err: s.checkStopAfterStart()},
+		{Name: "uuid format", Err: s.checkUUIDFormat()},
+		{Name: "uuid unicity", Err: s.checkUUIDUnicity()},
+		{Name: "interval duration", Err: s.checkDuration()},
+	}
+}
+
 // intervalTagsUnicity checks the database contains a single row
 // for a interval_id, tag tuple with deleted_at being null.
 func (s *Sanity) intervalTagsUnicity() (ret error) {
@@ -40,12 +81,13 @@ func (s *Sanity) intervalTagsUnicity() (ret error) {
 		Tag      string `db:"tag"`
 	}
 	rows, err := getRows[sanityRow](s.db, `
-		SELECT interval_start_uuid, tag
+		SELECT interval_start_uuid, tags.name AS tag
 		FROM interval_tags
+			JOIN tags ON interval_tags.tag_id = tags.id
 			LEFT JOIN interval_tags_tombstone
 				ON interval_tags.uuid = interval_tags_tombstone.interval_tag_uuid
 		WHERE interval_tags_tombstone.uuid IS NULL
-		GROUP BY interval_start_uuid, tag
+		GROUP BY interval_start_uuid, interval_tags.tag_id
 		HAVING count(1) > 1`)
 	if err != nil {
 		return fmt.Errorf("cannot query the database: %w", err)
@@ -63,14 +105,15 @@ func (s *Sanity) intervalTagsUnicity() (ret error) {
 
 // checkNoOverlap browses the full interval table to check that no registered
 // and closed interval overlaps with another one. Each interval validity is individually checked.
+// Intervals are half open so an interval may start exactly when the previous one stops.
 func (s *Sanity) checkNoOverlap() (ret error) {
 	rows, err := s.db.Query(`
-		SELECT id, start_timestamp, stop_timestamp
+		SELECT id, start_timestamp, stop_timestamp, context
 		FROM interval_start
 			JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
 			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
 		WHERE interval_tombstone.uuid IS NULL
-		ORDER BY start_timestamp`)
+		ORDER BY context, start_timestamp`)
 	if err != nil {
 		return fmt.Errorf("cannot query the database: %w", err)
 	}
@@ -93,6 +136,7 @@ func (s *Sanity) checkNoOverlap() (ret error) {
 			&current.ID,
 			&unixStart,
 			&unixStop,
+			&current.Context,
 		); err != nil {
 			return fmt.Errorf("cannot scan table row: %w", err)
 		}
@@ -105,7 +149,8 @@ func (s *Sanity) checkNoOverlap() (ret error) {
 			return fmt.Errorf("%w: %#v", ErrInvalidInterval, *current)
 		}
 
-		if previous == nil {
+		// Intervals of distinct contexts may overlap
+		if previous == nil || previous.Context != current.Context {
 			continue
 		}
 
@@ -122,6 +167,9 @@ func (s *Sanity) checkNoOverlap() (ret error) {
 	return nil
 }
 
+// checkIntervalsUpdatedAt checks no interval stop has been recorded before
+// the interval start it refers to. A start timestamp far from the record
+// time is legitimate for retroactive entries but the records order is not.
 func (s *Sanity) checkIntervalsUpdatedAt() (ret error) {
 	type sanityRow struct {
 		Id   int
@@ -130,7 +178,7 @@ func (s *Sanity) checkIntervalsUpdatedAt() (ret error) {
 	rows, err := getRows[sanityRow](s.db, `
 		SELECT id, 'updated before created' as type
 		FROM interval_start
-			JOIN interval_stop ON interval_start.start_timestamp = interval_stop.uuid
+			JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
 		WHERE interval_start.created_at > interval_stop.created_at`)
 	if err != nil {
 		return fmt.Errorf("cannot query the database: %w", err)
@@ -143,3 +191,133 @@ func (s *Sanity) checkIntervalsUpdatedAt() (ret error) {
 
 	return merr.ErrorOrNil()
 }
+
+// checkDanglingStop checks every interval stop refers to an existing interval start.
+func (s *Sanity) checkDanglingStop() error {
+	var rows []string
+	err := s.db.Select(&rows, `
+		SELECT interval_stop.uuid
+		FROM interval_stop
+			LEFT JOIN interval_start ON interval_stop.start_uuid = interval_start.uuid
+		WHERE interval_start.uuid IS NULL`)
+	if err != nil {
+		return fmt.Errorf("cannot query the database: %w", err)
+	}
+
+	var merr *multierror.Error
+	for _, r := range rows {
+		merr = multierror.Append(merr, fmt.Errorf("%w: %s", ErrDanglingStop, r))
+	}
+
+	return merr.ErrorOrNil()
+}
+
+// checkStopAfterStart checks every interval stop is strictly after the start
+// it refers to, deleted intervals included.
+func (s *Sanity) checkStopAfterStart() error {
+	var rows []string
+	err := s.db.Select(&rows, `
+		SELECT interval_start.id
+		FROM interval_start
+			JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+		WHERE interval_stop.stop_timestamp <= interval_start.start_timestamp`)
+	if err != nil {
+		return fmt.Errorf("cannot query the database: %w", err)
+	}
+
+	var merr *multierror.Error
+	for _, r := range rows {
+		merr = multierror.Append(merr, fmt.Errorf("%w: interval %s stops before it starts", ErrInvalidInterval, r))
+	}
+
+	return merr.ErrorOrNil()
+}
+
+// uuidColumns lists the table columns holding a uuid.
+var uuidColumns = []struct{ table, column string }{
+	{"interval_start", "uuid"},
+	{"interval_stop", "uuid"},
+	{"interval_stop", "start_uuid"},
+	{"interval_tombstone", "uuid"},
+	{"interval_tombstone", "start_uuid"},
+	{"interval_tags", "uuid"},
+	{"interval_tags", "interval_start_uuid"},
+	{"interval_tags_tombstone", "uuid"},
+	{"interval_tags_tombstone", "interval_tag_uuid"},
+	{"interval_estimate", "uuid"},
+	{"interval_estimate", "start_uuid"},
+	{"interval_estimate_tombstone", "uuid"},
+	{"interval_estimate_tombstone", "estimate_uuid"},
+	{"interval_billable", "uuid"},
+	{"interval_billable", "start_uuid"},
+	{"interval_billable_tombstone", "uuid"},
+	{"interval_billable_tombstone", "billable_uuid"},
+}
+
+// checkUUIDFormat checks every uuid column of the interval tables holds a parseable uuid.
+// Malformed values usually come from manual edits or faulty imports.
+func (s *Sanity) checkUUIDFormat() error {
+	var merr *multierror.Error
+	for _, c := range uuidColumns {
+		var values []string
+		if err := s.db.Select(&values, `SELECT `+c.column+` FROM `+c.table); err != nil {
+			return fmt.Errorf("cannot query the database: %w", err)
+		}
+		for _, v := range values {
+			if _, err := uuid.Parse(v); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("%w: %s.%s %q", ErrInvalidUUID, c.table, c.column, v))
+			}
+		}
+	}
+
+	return merr.ErrorOrNil()
+}
+
+// checkUUIDUnicity checks no uuid is held by several rows of the same table.
+// The schema declares uuid columns as primary keys but a database whose
+// schema lacks the constraint could receive duplicates from a synchronisation.
+// It doesn't use any query placeholder so it can check the remote database too.
+func (s *Sanity) checkUUIDUnicity() error {
+	var merr *multierror.Error
+	for _, c := range uuidColumns {
+		if c.column != "uuid" {
+			continue
+		}
+		var values []string
+		if err := s.db.Select(&values, `
+			SELECT uuid FROM `+c.table+`
+			GROUP BY uuid
+			HAVING count(1) > 1`); err != nil {
+			return fmt.Errorf("cannot query the database: %w", err)
+		}
+		for _, v := range values {
+			merr = multierror.Append(merr, fmt.Errorf("%w: %s %q", ErrDuplicatedUUID, c.table, v))
+		}
+	}
+
+	return merr.ErrorOrNil()
+}
+
+// checkDuration reports the closed intervals lasting longer than maxIntervalDuration.
+// They are usually intervals someone forgot to stop.
+func (s *Sanity) checkDuration() error {
+	var rows []int
+	err := s.db.Select(&rows, `
+		SELECT id
+		FROM interval_start
+			JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+		WHERE interval_tombstone.uuid IS NULL
+			AND stop_timestamp - start_timestamp > ?`, int64(maxIntervalDuration.Seconds()))
+	if err != nil {
+		return fmt.Errorf("cannot query the database: %w", err)
+	}
+
+	var merr *multierror.Error
+	for _, r := range rows {
+		merr = multierror.Append(merr, fmt.Errorf(
+			"%w: interval %d lasts more than %s", ErrInvalidInterval, r, maxIntervalDuration))
+	}
+
+	return merr.ErrorOrNil()
+}
@@ -0,0 +1,174 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanityReport(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 2, 25, hour, 0, 0, 0, time.UTC)
+	}
+
+	tt := setupTT(t)
+	require.NoError(t, tt.Start(at(10), []string{"a"}))
+	require.NoError(t, tt.StopAt(at(11)))
+	require.NoError(t, tt.Start(at(11), []string{"b"}))
+	require.NoError(t, tt.StopAt(at(12)))
+
+	t.Run("healthy", func(t *testing.T) {
+		results := tt.Sanity().Report()
+		require.Len(t, results, 8)
+		for _, r := range results {
+			require.NoError(t, r.Err, r.Name)
+		}
+	})
+
+	t.Run("unhealthy", func(t *testing.T) {
+		_, err := tt.db.Exec(`
+			UPDATE interval_stop SET stop_timestamp = ? WHERE start_uuid = (
+				SELECT uuid FROM interval_start WHERE id = 2
+			)`, at(12).Add(48*time.Hour).Unix())
+		require.NoError(t, err)
+		_, err = tt.db.Exec(`PRAGMA foreign_keys = OFF`)
+		require.NoError(t, err)
+		_, err = tt.db.Exec(`
+			INSERT INTO interval_stop (uuid, start_uuid, stop_timestamp, created_at)
+			VALUES ('dangling', 'unknown', ?, ?)`, at(13).Unix(), at(13).Unix())
+		require.NoError(t, err)
+
+		failed := map[string]error{}
+		for _, r := range tt.Sanity().Report() {
+			if r.Err != nil {
+				failed[r.Name] = r.Err
+			}
+		}
+		require.Len(t, failed, 3)
+		require.ErrorIs(t, failed["no dangling interval stop"], ErrDanglingStop)
+		require.ErrorIs(t, failed["interval duration"], ErrInvalidInterval)
+		require.ErrorIs(t, failed["uuid format"], ErrInvalidUUID)
+
+		// restore the database for the final sanity check
+		_, err = tt.db.Exec(`DELETE FROM interval_stop WHERE uuid = 'dangling'`)
+		require.NoError(t, err)
+		_, err = tt.db.Exec(`PRAGMA foreign_keys = ON`)
+		require.NoError(t, err)
+	})
+}
+
+func TestCheckUUIDFormat(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 2, 25, hour, 0, 0, 0, time.UTC)
+	}
+
+	tt := setupTT(t)
+	require.NoError(t, tt.Start(at(10), []string{"a"}))
+	require.NoError(t, tt.StopAt(at(11)))
+	require.NoError(t, tt.SetEstimate("1", time.Hour))
+	require.NoError(t, tt.Untag("1", []string{"a"}))
+	require.NoError(t, tt.Delete("1"))
+
+	t.Run("clean database", func(t *testing.T) {
+		require.NoError(t, tt.Sanity().checkUUIDFormat())
+	})
+
+	t.Run("malformed uuid", func(t *testing.T) {
+		_, err := tt.db.Exec(`
+			INSERT INTO interval_start (uuid, start_timestamp, created_at)
+			VALUES ('not-a-uuid', ?, ?)`, at(12).Unix(), at(12).Unix())
+		require.NoError(t, err)
+
+		err = tt.Sanity().checkUUIDFormat()
+		require.ErrorIs(t, err, ErrInvalidUUID)
+		require.ErrorContains(t, err, `interval_start.uuid "not-a-uuid"`)
+		require.ErrorIs(t, tt.Sanity().Check(), ErrInvalidUUID)
+
+		// restore the database for the final sanity check
+		_, err = tt.db.Exec(`DELETE FROM interval_start WHERE uuid = 'not-a-uuid'`)
+		require.NoError(t, err)
+	})
+}
+
+func TestCheckUUIDUnicity(t *testing.T) {
+	require.NoError(t, setupTT(t).Sanity().checkUUIDUnicity())
+
+	// The application schema rejects duplicated uuids, a schema without
+	// the unique constraint is crafted instead.
+	db, err := sqlx.Open(customSqliteDriverName, ":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, db.Close()) })
+	db.SetMaxOpenConns(1)
+	for _, c := range uuidColumns {
+		if c.column == "uuid" {
+			_, err := db.Exec(`CREATE TABLE ` + c.table + ` (uuid TEXT NOT NULL)`)
+			require.NoError(t, err)
+		}
+	}
+	const duplicated = "4d0b5e8a-3c8e-4d2b-9b8e-2f1c0a7d6e51"
+	_, err = db.Exec(`INSERT INTO interval_start (uuid) VALUES (uuid()), (?), (?)`, duplicated, duplicated)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO interval_stop (uuid) VALUES (uuid()), (uuid())`)
+	require.NoError(t, err)
+
+	err = NewSanity(db).checkUUIDUnicity()
+	require.ErrorIs(t, err, ErrDuplicatedUUID)
+	require.ErrorContains(t, err, `interval_start "`+duplicated+`"`)
+	require.NotContains(t, err.Error(), "interval_stop")
+}
+
+func TestCheckIntervalsChronology(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 2, 25, hour, 0, 0, 0, time.UTC)
+	}
+
+	tt := setupTT(t)
+	require.NoError(t, tt.Start(at(10), []string{"a"}))
+	require.NoError(t, tt.StopAt(at(11)))
+	require.NoError(t, tt.Start(at(11), []string{"b"}))
+	require.NoError(t, tt.StopAt(at(12)))
+	require.NoError(t, tt.Delete("2"))
+
+	require.NoError(t, tt.Sanity().checkIntervalsUpdatedAt())
+	require.NoError(t, tt.Sanity().checkStopAfterStart())
+
+	t.Run("stop recorded before start", func(t *testing.T) {
+		_, err := tt.db.Exec(`
+			UPDATE interval_stop SET created_at = created_at - 3600 WHERE start_uuid = (
+				SELECT uuid FROM interval_start WHERE id = 1
+			)`)
+		require.NoError(t, err)
+
+		err = tt.Sanity().checkIntervalsUpdatedAt()
+		require.ErrorIs(t, err, ErrInvalidInterval)
+		require.ErrorContains(t, err, "updated before created 1")
+		require.ErrorIs(t, tt.Sanity().Check(), ErrInvalidInterval)
+
+		// restore the database for the final sanity check
+		_, err = tt.db.Exec(`
+			UPDATE interval_stop SET created_at = created_at + 3600 WHERE start_uuid = (
+				SELECT uuid FROM interval_start WHERE id = 1
+			)`)
+		require.NoError(t, err)
+	})
+
+	t.Run("deleted interval stopping before its start", func(t *testing.T) {
+		stopOf := func(id string) string {
+			return `WHERE start_uuid = (SELECT uuid FROM interval_start WHERE id = ` + id + `)`
+		}
+		_, err := tt.db.Exec(`UPDATE interval_stop SET stop_timestamp = ? `+stopOf("2"), at(11).Unix())
+		require.NoError(t, err)
+
+		// The deleted interval is ignored by the overlap check
+		require.NoError(t, tt.Sanity().checkNoOverlap())
+		err = tt.Sanity().checkStopAfterStart()
+		require.ErrorIs(t, err, ErrInvalidInterval)
+		require.ErrorContains(t, err, "interval 2 stops before it starts")
+
+		// restore the database for the final sanity check
+		_, err = tt.db.Exec(`UPDATE interval_stop SET stop_timestamp = ? `+stopOf("2"), at(12).Unix())
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DumpSchema returns a textual description of the database schema
+// made of the DDL statements stored in sqlite_master followed by the
+// list of applied migrations. No data row is ever part of the output
+// so it can safely be attached to a bug report.
+func (tt *TimeTracker) DumpSchema() (string, error) {
+	type schemaRow struct {
+		Type string `db:"type"`
+		Name string `db:"name"`
+		SQL  string `db:"sql"`
+	}
+	schema, err := getRows[schemaRow](tt.db, `
+		SELECT type, name, sql
+		FROM sqlite_master
+		WHERE sql IS NOT NULL
+		ORDER BY type DESC, name`)
+	if err != nil {
+		return "", fmt.Errorf("cannot query sqlite_master table: %w", err)
+	}
+
+	type migrationRow struct {
+		Version     float64   `db:"version"`
+		Description string    `db:"description"`
+		Checksum    string    `db:"checksum"`
+		AppliedAt   time.Time `db:"applied_at"`
+	}
+	migrations, err := getRows[migrationRow](tt.db, `
+		SELECT version, description, checksum, applied_at
+		FROM darwin_migrations
+		ORDER BY version`)
+	if err != nil {
+		return "", fmt.Errorf("cannot query darwin_migrations table: %w", err)
+	}
+
+	var b strings.Builder
+	b.WriteString("-- schema\n")
+	for _, r := range schema {
+		fmt.Fprintf(&b, "-- %s %s\n%s;\n", r.Type, r.Name, r.SQL)
+	}
+	b.WriteString("\n-- migrations\n")
+	for _, m := range migrations {
+		fmt.Fprintf(&b, "-- version %g: %s (checksum %s, applied at %s)\n",
+			m.Version, m.Description, m.Checksum, m.AppliedAt.Format(time.RFC3339))
+	}
+
+	return b.String(), nil
+}
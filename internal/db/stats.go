@@ -0,0 +1,228 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// day truncates t to the midnight of its calendar day in loc.
+func day(t time.Time, loc *time.Location) time.Time {
+	year, month, d := t.In(loc).Date()
+	return time.Date(year, month, d, 0, 0, 0, 0, loc)
+}
+
+// Streaks returns the current and the longest runs of consecutive calendar days
+// which have at least one interval tagged with tag. Days are computed in the loc
+// time zone and an interval contributes to every day it spans.
+// The current run is the one ending today or, if nothing has been tracked
+// yet today, the one ending yesterday.
+func (tt *TimeTracker) Streaks(tag string, loc *time.Location) (current, longest int, ret error) {
+	rows, err := tt.db.Query(`
+		SELECT start_timestamp, stop_timestamp
+		FROM interval_start
+			JOIN interval_tags ON interval_start.uuid = interval_tags.interval_start_uuid
+			JOIN tags ON interval_tags.tag_id = tags.id
+			LEFT JOIN interval_tags_tombstone
+				ON interval_tags.uuid = interval_tags_tombstone.interval_tag_uuid
+			LEFT JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+		WHERE tags.name = ?
+			AND interval_tags_tombstone.uuid IS NULL
+			AND interval_tombstone.uuid IS NULL`, tag)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot query tagged intervals: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			current, longest, ret = 0, 0, fmt.Errorf("closing intervals rows object: %w", err)
+		}
+	}()
+
+	now := tt.now()
+	days := map[time.Time]struct{}{}
+	for rows.Next() {
+		var (
+			unixStart int64
+			unixStop  sql.NullInt64
+		)
+		if err := rows.Scan(&unixStart, &unixStop); err != nil {
+			return 0, 0, fmt.Errorf("cannot scan tagged interval: %w", err)
+		}
+
+		stop := now
+		if unixStop.Valid {
+			stop = time.Unix(unixStop.Int64, 0)
+		}
+		last := day(stop.Add(-time.Second), loc)
+		for d := day(time.Unix(unixStart, 0), loc); !d.After(last); d = d.AddDate(0, 0, 1) {
+			days[d] = struct{}{}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("cannot iterate over tagged intervals: %w", err)
+	}
+
+	sortedDays := make([]time.Time, 0, len(days))
+	for d := range days {
+		sortedDays = append(sortedDays, d)
+	}
+	sort.Slice(sortedDays, func(i, j int) bool { return sortedDays[i].Before(sortedDays[j]) })
+
+	run := 0
+	for idx, d := range sortedDays {
+		if idx > 0 && sortedDays[idx-1].AddDate(0, 0, 1).Equal(d) {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longest {
+			longest = run
+		}
+	}
+
+	today := day(now, loc)
+	if len(sortedDays) > 0 {
+		if last := sortedDays[len(sortedDays)-1]; last.Equal(today) || last.AddDate(0, 0, 1).Equal(today) {
+			current = run
+		}
+	}
+
+	return current, longest, nil
+}
+
+// TotalDuration returns the time tracked over the whole history, all contexts
+// included. The opened intervals are considered as stopping now.
+// The closed intervals are accounted through the daily_totals cache
+// which triggers keep up to date on every modification of the intervals.
+// It is the only reader of the cache: keyed by UTC day without the tags,
+// the cache can't answer the per tag reports over local periods like Summarize.
+func (tt *TimeTracker) TotalDuration() (time.Duration, error) {
+	var closed, opened sql.NullInt64
+	if err := tt.db.QueryRow(`SELECT sum(duration) FROM daily_totals`).Scan(&closed); err != nil {
+		return 0, fmt.Errorf("cannot sum daily totals: %w", err)
+	}
+	if err := tt.db.QueryRow(`
+		SELECT sum(? - start_timestamp)
+		FROM interval_start
+			LEFT JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+		WHERE interval_stop.uuid IS NULL
+			AND interval_tombstone.uuid IS NULL`, tt.now().Unix()).Scan(&opened); err != nil {
+		return 0, fmt.Errorf("cannot sum opened interval durations: %w", err)
+	}
+	return time.Duration(closed.Int64+opened.Int64) * time.Second, nil
+}
+
+// ListResult holds the intervals returned by List along with their aggregates.
+// The opened interval is considered as stopping now.
+type ListResult struct {
+	Intervals []TaggedInterval
+	// Total is the distinct tracked time: an interval carrying
+	// several tags is counted once.
+	Total time.Duration
+	// TagTotals is the tracked time per tag.
+	TagTotals map[string]time.Duration
+	// TagCounts is the number of intervals per tag.
+	TagCounts map[string]int
+}
+
+// Summarize returns the intervals List returns between since and until
+// along with their total duration and their durations and counts per tag.
+func (tt *TimeTracker) Summarize(since, until time.Time) (ListResult, error) {
+	intervals, err := tt.List(since, until)
+	if err != nil {
+		return ListResult{}, err
+	}
+
+	result := ListResult{
+		Intervals: intervals,
+		TagTotals: map[string]time.Duration{},
+		TagCounts: map[string]int{},
+	}
+	now := tt.now().Truncate(time.Second)
+	for _, itv := range intervals {
+		stop := itv.StopTimestamp
+		if stop.IsZero() {
+			stop = now
+		}
+		duration := stop.Sub(itv.StartTimestamp)
+		result.Total += duration
+		for _, tag := range itv.Tags {
+			result.TagTotals[tag] += duration
+			result.TagCounts[tag]++
+		}
+	}
+	return result, nil
+}
+
+// RebuildDailyTotals recomputes from scratch the daily_totals cache
+// of the time tracked per UTC day with the closed intervals.
+func (tt *TimeTracker) RebuildDailyTotals() (ret error) {
+	tx, err := tt.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("cannot start transaction: %w", err)
+	}
+	defer tt.completeTransaction(tx, &ret)
+
+	if _, err := tx.Exec(`DELETE FROM daily_totals`); err != nil {
+		return fmt.Errorf("cannot clear daily totals: %w", err)
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO daily_totals (day, duration)
+		SELECT interval_start.start_timestamp / 86400,
+			sum(interval_stop.stop_timestamp - interval_start.start_timestamp)
+		FROM interval_start
+			JOIN interval_stop ON interval_start.uuid = interval_stop.start_uuid
+			LEFT JOIN interval_tombstone ON interval_start.uuid = interval_tombstone.start_uuid
+		WHERE interval_tombstone.uuid IS NULL
+		GROUP BY interval_start.start_timestamp / 86400`); err != nil {
+		return fmt.Errorf("cannot compute daily totals: %w", err)
+	}
+	return nil
+}
+
+// SuggestTags returns the tags most often found on the closed intervals
+// overlapping the hour of the day and the weekday of at, both computed in
+// the loc time zone. Tags are sorted by decreasing frequency then by name
+// and at most limit of them are returned.
+func (tt *TimeTracker) SuggestTags(at time.Time, loc *time.Location, limit int) ([]string, error) {
+	intervals, err := tt.List(time.Unix(0, 0), at)
+	if err != nil {
+		return nil, err
+	}
+
+	at = at.In(loc)
+	frequencies := map[string]int{}
+	for _, itv := range intervals {
+		if itv.StopTimestamp.IsZero() {
+			continue
+		}
+		start := itv.StartTimestamp.In(loc)
+		hour := time.Date(start.Year(), start.Month(), start.Day(), start.Hour(), 0, 0, 0, loc)
+		for ; hour.Before(itv.StopTimestamp); hour = hour.Add(time.Hour) {
+			if hour.Weekday() == at.Weekday() && hour.Hour() == at.Hour() {
+				for _, tag := range itv.Tags {
+					frequencies[tag]++
+				}
+				break
+			}
+		}
+	}
+
+	tags := make([]string, 0, len(frequencies))
+	for tag := range frequencies {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if frequencies[tags[i]] != frequencies[tags[j]] {
+			return frequencies[tags[i]] > frequencies[tags[j]]
+		}
+		return tags[i] < tags[j]
+	})
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	return tags, nil
+}
@@ -0,0 +1,327 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreaks(t *testing.T) {
+	loc := time.FixedZone("test", 2*3600)
+	at := func(day, hour int) time.Time {
+		return time.Date(2022, 3, day, hour, 0, 0, 0, loc)
+	}
+	record := func(t *testing.T, tt *TimeTracker, start, stop time.Time, tags ...string) {
+		t.Helper()
+		require.NoError(t, tt.Start(start, tags))
+		require.NoError(t, tt.StopAt(stop))
+	}
+
+	t.Run("no data", func(t *testing.T) {
+		tt := setupTT(t)
+		current, longest, err := tt.Streaks("gym", loc)
+		require.NoError(t, err)
+		require.Equal(t, 0, current)
+		require.Equal(t, 0, longest)
+	})
+
+	t.Run("gaps and contiguous stretches", func(t *testing.T) {
+		tt := setupTT(t)
+		tt.now = func() time.Time { return at(13, 20) }
+
+		// a 3 days stretch
+		record(t, tt, at(1, 10), at(1, 11), "gym")
+		record(t, tt, at(2, 10), at(2, 11), "gym", "other")
+		record(t, tt, at(3, 10), at(3, 11), "gym")
+		// untracked or other tags only
+		record(t, tt, at(4, 10), at(4, 11), "other")
+		// a 4 days stretch with an interval crossing midnight
+		record(t, tt, at(6, 10), at(6, 11), "gym")
+		record(t, tt, at(7, 23), at(8, 1), "gym")
+		record(t, tt, at(9, 10), at(9, 11), "gym")
+		// current 2 days stretch ending yesterday
+		record(t, tt, at(11, 10), at(11, 11), "gym")
+		record(t, tt, at(12, 10), at(12, 11), "gym")
+
+		current, longest, err := tt.Streaks("gym", loc)
+		require.NoError(t, err)
+		require.Equal(t, 2, current)
+		require.Equal(t, 4, longest)
+
+		// tracking today extends the current stretch
+		record(t, tt, at(13, 10), at(13, 11), "gym")
+		current, longest, err = tt.Streaks("gym", loc)
+		require.NoError(t, err)
+		require.Equal(t, 3, current)
+		require.Equal(t, 4, longest)
+
+		// the stretch is broken when nothing has been tracked for more than a day
+		tt.now = func() time.Time { return at(15, 8) }
+		current, longest, err = tt.Streaks("gym", loc)
+		require.NoError(t, err)
+		require.Equal(t, 0, current)
+		require.Equal(t, 4, longest)
+	})
+
+	t.Run("time zone bucketing", func(t *testing.T) {
+		tt := setupTT(t)
+		tt.now = func() time.Time { return at(3, 12) }
+
+		// 23:00-23:30 UTC are on the next day in the test time zone
+		record(t, tt,
+			time.Date(2022, 3, 1, 23, 0, 0, 0, time.UTC),
+			time.Date(2022, 3, 1, 23, 30, 0, 0, time.UTC), "gym")
+		record(t, tt, at(3, 10), at(3, 11), "gym")
+
+		current, longest, err := tt.Streaks("gym", loc)
+		require.NoError(t, err)
+		require.Equal(t, 2, current)
+		require.Equal(t, 2, longest)
+
+		current, longest, err = tt.Streaks("gym", time.UTC)
+		require.NoError(t, err)
+		require.Equal(t, 1, current)
+		require.Equal(t, 1, longest)
+	})
+
+	t.Run("deleted interval and untagged interval are ignored", func(t *testing.T) {
+		tt := setupTT(t)
+		tt.now = func() time.Time { return at(3, 12) }
+
+		record(t, tt, at(1, 10), at(1, 11), "gym")
+		record(t, tt, at(2, 10), at(2, 11), "gym")
+		record(t, tt, at(3, 10), at(3, 11), "gym")
+		require.NoError(t, tt.Delete("2"))
+		require.NoError(t, tt.Untag("3", []string{"gym"}))
+
+		current, longest, err := tt.Streaks("gym", loc)
+		require.NoError(t, err)
+		require.Equal(t, 0, current)
+		require.Equal(t, 1, longest)
+	})
+}
+
+func TestTotalDuration(t *testing.T) {
+	at := func(year, hour int) time.Time {
+		return time.Date(year, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+
+	tt := setupTT(t)
+	total, err := tt.TotalDuration()
+	require.NoError(t, err)
+	require.Zero(t, total)
+
+	for year := 2015; year < 2023; year++ {
+		require.NoError(t, tt.Start(at(year, 10), []string{"a"}))
+		require.NoError(t, tt.StopAt(at(year, 10+year%3+1)))
+	}
+	require.NoError(t, tt.Delete("2"))
+	tt.SetContext("other")
+	require.NoError(t, tt.Start(at(2022, 10), []string{"b"}))
+	require.NoError(t, tt.StopAt(at(2022, 12)))
+	tt.SetContext("")
+	tt.now = func() time.Time { return at(2023, 12).Add(25 * time.Minute) }
+	require.NoError(t, tt.Start(at(2023, 11), []string{"c"}))
+
+	total, err = tt.TotalDuration()
+	require.NoError(t, err)
+
+	intervals, err := tt.List(at(1970, 0), at(2100, 0))
+	require.NoError(t, err)
+	require.Len(t, intervals, 9)
+	var expected time.Duration
+	for _, itv := range intervals {
+		stop := itv.StopTimestamp
+		if stop.IsZero() {
+			stop = tt.now()
+		}
+		expected += stop.Sub(itv.StartTimestamp)
+	}
+	require.Equal(t, expected, total)
+	require.Equal(t, 1*time.Hour+25*time.Minute+2*time.Hour+15*time.Hour, total)
+}
+
+func TestSummarize(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2022, 3, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	tt := setupTT(t)
+	result, err := tt.Summarize(at(0, 0), at(23, 0))
+	require.NoError(t, err)
+	require.Empty(t, result.Intervals)
+	require.Zero(t, result.Total)
+	require.Empty(t, result.TagTotals)
+
+	require.NoError(t, tt.Start(at(9, 0), []string{"a", "b"}))
+	require.NoError(t, tt.StopAt(at(10, 0)))
+	require.NoError(t, tt.Start(at(10, 0), []string{"a"}))
+	require.NoError(t, tt.StopAt(at(10, 30)))
+	require.NoError(t, tt.Start(at(11, 0), nil))
+	require.NoError(t, tt.StopAt(at(11, 15)))
+	tt.now = func() time.Time { return at(12, 45) }
+	require.NoError(t, tt.Start(at(12, 0), []string{"c"}))
+
+	result, err = tt.Summarize(at(0, 0), at(23, 0))
+	require.NoError(t, err)
+
+	intervals, err := tt.List(at(0, 0), at(23, 0))
+	require.NoError(t, err)
+	require.Equal(t, intervals, result.Intervals)
+
+	var total time.Duration
+	totals := map[string]time.Duration{}
+	counts := map[string]int{}
+	for _, itv := range intervals {
+		stop := itv.StopTimestamp
+		if stop.IsZero() {
+			stop = tt.now()
+		}
+		total += stop.Sub(itv.StartTimestamp)
+		for _, tag := range itv.Tags {
+			totals[tag] += stop.Sub(itv.StartTimestamp)
+			counts[tag]++
+		}
+	}
+	require.Equal(t, total, result.Total)
+	require.Equal(t, totals, result.TagTotals)
+	require.Equal(t, counts, result.TagCounts)
+
+	require.Equal(t, 2*time.Hour+30*time.Minute, result.Total)
+	require.Equal(t, map[string]time.Duration{
+		"a": time.Hour + 30*time.Minute,
+		"b": time.Hour,
+		"c": 45 * time.Minute,
+	}, result.TagTotals)
+	require.Equal(t, map[string]int{"a": 2, "b": 1, "c": 1}, result.TagCounts)
+}
+
+func TestDailyTotals(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2022, 3, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	tt := setupTT(t)
+	totals := func() map[int64]int64 {
+		var rows []struct {
+			Day      int64 `db:"day"`
+			Duration int64 `db:"duration"`
+		}
+		require.NoError(t, tt.db.Select(&rows, `
+			SELECT day, duration FROM daily_totals WHERE duration != 0`))
+		m := map[int64]int64{}
+		for _, r := range rows {
+			m[r.Day] = r.Duration
+		}
+		return m
+	}
+	// requireConsistent compares the cache with the totals recomputed from scratch
+	requireConsistent := func() {
+		cached := totals()
+		require.NoError(t, tt.RebuildDailyTotals())
+		require.Equal(t, totals(), cached)
+	}
+
+	require.NoError(t, tt.Start(at(1, 10), []string{"a"}))
+	require.NoError(t, tt.StopAt(at(1, 12)))
+	require.NoError(t, tt.Start(at(1, 23), []string{"b"}))
+	require.NoError(t, tt.StopAt(at(2, 1)))
+	require.NoError(t, tt.Start(at(2, 9), []string{"c"}))
+	require.NoError(t, tt.StopAt(at(2, 10)))
+	requireConsistent()
+	day := at(1, 0).Unix() / 86400
+	require.Equal(t, map[int64]int64{day: 4 * 3600, day + 1: 3600}, totals())
+
+	// The opened interval is not cached until it is stopped
+	require.NoError(t, tt.Start(at(3, 9), []string{"d"}))
+	requireConsistent()
+	require.NoError(t, tt.StopAt(at(3, 11)))
+	requireConsistent()
+
+	require.NoError(t, tt.Delete("2"))
+	require.NoError(t, tt.Delete("4"))
+	requireConsistent()
+	require.Equal(t, map[int64]int64{day: 2 * 3600, day + 1: 3600}, totals())
+
+	merged, err := tt.StartMerging(at(2, 10), []string{"c"}, time.Minute)
+	require.NoError(t, err)
+	require.True(t, merged)
+	requireConsistent()
+	require.NoError(t, tt.StopAt(at(2, 12)))
+	requireConsistent()
+
+	require.NoError(t, tt.Import([]TaggedInterval{
+		{
+			Interval: Interval{StartTimestamp: at(4, 8), StopTimestamp: at(4, 9)},
+			Tags:     []string{"e"},
+		},
+	}, nil))
+	requireConsistent()
+
+	// Synchronisations overwrite the rows in place
+	_, err = tt.db.Exec(`UPDATE interval_stop SET stop_timestamp = stop_timestamp + 600`)
+	require.NoError(t, err)
+	requireConsistent()
+	_, err = tt.db.Exec(`UPDATE interval_start SET start_timestamp = start_timestamp - 86400`)
+	require.NoError(t, err)
+	requireConsistent()
+
+	total, err := tt.TotalDuration()
+	require.NoError(t, err)
+	var expected time.Duration
+	for _, d := range totals() {
+		expected += time.Duration(d) * time.Second
+	}
+	require.Equal(t, expected, total)
+
+	require.NoError(t, tt.Purge(false))
+	require.Empty(t, totals())
+}
+
+func TestSuggestTags(t *testing.T) {
+	loc := time.FixedZone("test", 2*3600)
+	// 2022-03-07 is a monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2022, 3, day, hour, minute, 0, 0, loc)
+	}
+
+	tt := setupTT(t)
+	suggested, err := tt.SuggestTags(at(28, 9, 10), loc, 3)
+	require.NoError(t, err)
+	require.Empty(t, suggested)
+
+	for _, monday := range []int{7, 14, 21} {
+		require.NoError(t, tt.Start(at(monday, 9, 0), []string{"standup", "team"}))
+		require.NoError(t, tt.StopAt(at(monday, 9, 15)))
+		require.NoError(t, tt.Start(at(monday, 9, 15), []string{"coding"}))
+		require.NoError(t, tt.StopAt(at(monday, 11, 0)))
+	}
+	// Started before 9 and spanning it
+	require.NoError(t, tt.Start(at(14, 8, 0), []string{"review"}))
+	require.NoError(t, tt.StopAt(at(14, 9, 0)))
+	require.NoError(t, tt.Start(at(21, 7, 30), []string{"review"}))
+	require.NoError(t, tt.StopAt(at(21, 9, 0)))
+	// Other weekday and other hour
+	require.NoError(t, tt.Start(at(8, 9, 0), []string{"gym"}))
+	require.NoError(t, tt.StopAt(at(8, 10, 0)))
+	require.NoError(t, tt.Start(at(7, 14, 0), []string{"gym"}))
+	require.NoError(t, tt.StopAt(at(7, 15, 0)))
+	// Deleted and opened intervals are ignored, the 0th of march is a monday too
+	require.NoError(t, tt.Start(at(0, 9, 0), []string{"deleted"}))
+	require.NoError(t, tt.StopAt(at(0, 9, 30)))
+	require.NoError(t, tt.Delete("11"))
+	require.NoError(t, tt.Start(at(28, 9, 0), []string{"mail"}))
+
+	suggested, err = tt.SuggestTags(at(28, 9, 10), loc, 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"coding", "standup"}, suggested)
+
+	suggested, err = tt.SuggestTags(at(28, 9, 10), loc, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"coding", "standup", "team"}, suggested)
+
+	suggested, err = tt.SuggestTags(at(28, 8, 0), loc, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"review"}, suggested)
+}
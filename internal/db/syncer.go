@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"net/url"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
@@ -13,12 +14,76 @@ import (
 	"github.com/dgsb/tt/internal/funk"
 )
 
+// syncLockKey is the postgres advisory lock serialising the synchronisations
+// running against the same remote database.
+const syncLockKey = 0x7474
+
+// MergeStrategy decides how a row known by both databases with different
+// attributes is resolved by Sync. Rows are immutable so this cannot happen
+// as long as intervals are modified through tombstones and new rows.
+type MergeStrategy int
+
+const (
+	// MergeUnion keeps each database version of a diverging row.
+	MergeUnion MergeStrategy = iota
+	// MergeLocalWins replaces the remote version of a diverging row with the local one.
+	MergeLocalWins
+	// MergeRemoteWins replaces the local version of a diverging row with the remote one.
+	MergeRemoteWins
+)
+
+var mergeStrategyNames = []string{"union", "local-wins", "remote-wins"}
+
+func (s MergeStrategy) String() string {
+	if s < 0 || int(s) >= len(mergeStrategyNames) {
+		return fmt.Sprintf("MergeStrategy(%d)", int(s))
+	}
+	return mergeStrategyNames[s]
+}
+
+// ParseMergeStrategy returns the merge strategy named name.
+func ParseMergeStrategy(name string) (MergeStrategy, error) {
+	for idx, n := range mergeStrategyNames {
+		if n == name {
+			return MergeStrategy(idx), nil
+		}
+	}
+	return MergeUnion, fmt.Errorf("%w: unknown merge strategy %s", ErrInvalidParam, name)
+}
+
+// SyncOptions tunes a synchronisation whichever the central database.
+type SyncOptions struct {
+	MergeStrategy MergeStrategy
+	// Chunked commits the synchronisation in several transactions, see SyncChunkedWith.
+	Chunked bool
+}
+
+// Options returns o. It provides the Options method of the Syncer embedding it.
+func (o SyncOptions) Options() SyncOptions {
+	return o
+}
+
+// Syncer is a central database a TimeTracker can be synchronised with.
+type Syncer interface {
+	// Open opens the central database once its schema has been migrated.
+	Open() (*sqlx.DB, error)
+	// Options returns how the synchronisation is performed.
+	Options() SyncOptions
+}
+
+// SyncerConfig is a central postgres database.
 type SyncerConfig struct {
 	Login        string
 	Password     string
 	Hostname     string
 	Port         int
 	DatabaseName string
+	SyncOptions
+}
+
+// Open opens the postgres database and runs its migrations.
+func (cfg SyncerConfig) Open() (*sqlx.DB, error) {
+	return setupSyncerDB(cfg)
 }
 
 func (cfg SyncerConfig) String() string {
@@ -30,6 +95,40 @@ func (cfg SyncerConfig) String() string {
 		cfg.DatabaseName)
 }
 
+// SQLiteSyncer is a central sqlite database holding the same schema as the
+// time tracker databases, like a file shared through a synchronised folder
+// by machines which can't reach a postgres server.
+type SQLiteSyncer struct {
+	Path string
+	SyncOptions
+}
+
+// Open opens the sqlite database, creating it if needed, and runs its migrations.
+// Unlike a time tracker database, it is kept in the rollback journal mode: the
+// write ahead log doesn't work on network filesystems and its companion files
+// would be synchronised apart from the database file.
+func (s SQLiteSyncer) Open() (*sqlx.DB, error) {
+	dsn, err := sqliteURI(s.Path, url.Values{
+		"_journal_mode":       {"DELETE"},
+		"_foreign_keys":       {"1"},
+		"_defer_foreign_keys": {"1"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(customSqliteDriverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open syncer database %s: %w", s.Path, err)
+	}
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("cannot validate syncer database connection %s: %w", s.Path, err)
+	}
+	if err := runSqliteMigrations(db); err != nil {
+		return nil, fmt.Errorf("cannot run schema migration on syncer database %s: %w", s.Path, err)
+	}
+	return sqlx.NewDb(db, "sqlite3"), nil
+}
+
 func setupSyncerDB(cfg SyncerConfig) (*sqlx.DB, error) {
 	db, err := sqlx.Open("pgx", cfg.String())
 	if err != nil {
@@ -46,9 +145,12 @@ func setupSyncerDB(cfg SyncerConfig) (*sqlx.DB, error) {
 }
 
 type intervalStartRow struct {
-	UUID           string `db:"uuid"`
-	StartTimestamp int64  `db:"start_timestamp"`
-	CreatedAt      int64  `db:"created_at"`
+	UUID           string         `db:"uuid"`
+	StartTimestamp int64          `db:"start_timestamp"`
+	CreatedAt      int64          `db:"created_at"`
+	TZ             sql.NullString `db:"tz"`
+	Context        string         `db:"context"`
+	Location       sql.NullString `db:"location"`
 }
 
 type intervalStopRow struct {
@@ -77,6 +179,31 @@ type intervalTagsTombstoneRow struct {
 	CreatedAt       int64  `db:"created_at"`
 }
 
+type intervalEstimateRow struct {
+	UUID      string `db:"uuid"`
+	StartUUID string `db:"start_uuid"`
+	Estimate  int64  `db:"estimate"`
+	CreatedAt int64  `db:"created_at"`
+}
+
+type intervalEstimateTombstoneRow struct {
+	UUID         string `db:"uuid"`
+	EstimateUUID string `db:"estimate_uuid"`
+	CreatedAt    int64  `db:"created_at"`
+}
+
+type intervalBillableRow struct {
+	UUID      string `db:"uuid"`
+	StartUUID string `db:"start_uuid"`
+	CreatedAt int64  `db:"created_at"`
+}
+
+type intervalBillableTombstoneRow struct {
+	UUID         string `db:"uuid"`
+	BillableUUID string `db:"billable_uuid"`
+	CreatedAt    int64  `db:"created_at"`
+}
+
 // setupLastSyncTimestamp setup a sync_history temporary table on the remote server
 // for the queries on the local and remote database to be the same.
 func setupLastSyncTimestamp(tx *sqlx.Tx, lastSync time.Time) error {
@@ -95,6 +222,34 @@ func setupLastSyncTimestamp(tx *sqlx.Tx, lastSync time.Time) error {
 	return nil
 }
 
+// lockSyncerDB waits for the other synchronisations against the remote
+// database to complete. The lock is released at the end of the transaction.
+// Without it, two synchronisations storing the same rows in different orders
+// can deadlock, and rows committed by a concurrent synchronisation after
+// they have been looked for would be missed by the next synchronisation.
+// A sqlite remote database serialises the writing transactions by itself.
+func lockSyncerDB(tx *sqlx.Tx) error {
+	if !remoteSchema(tx) {
+		return nil
+	}
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, syncLockKey); err != nil {
+		return fmt.Errorf("cannot lock syncer database: %w", err)
+	}
+	return nil
+}
+
+// dropLastSyncTimestamp drops the temporary table created by setupLastSyncTimestamp.
+// It would otherwise last as long as the connection to the remote database,
+// preventing another synchronisation through the same connection and hiding
+// the sync_history table of a sqlite remote database.
+// Both sqlite and postgres look for temporary tables first.
+func dropLastSyncTimestamp(tx *sqlx.Tx) error {
+	if _, err := tx.Exec(`DROP TABLE sync_history`); err != nil {
+		return fmt.Errorf("cannot drop sync_timestamp temporary table: %w", err)
+	}
+	return nil
+}
+
 func storeLastSyncTimestamp(tx *sqlx.Tx, syncTime time.Time) error {
 	if _, err := tx.Exec(
 		`INSERT INTO sync_history (sync_timestamp) VALUES (?)`,
@@ -123,6 +278,38 @@ func getLastSyncTimestamp(tx *sqlx.Tx) (time.Time, error) {
 	return time.Unix(lastSync.Int64, 0), nil
 }
 
+// SetLastSync forces the last sync timestamp. Every sync bookmark registered
+// at or after t is dropped so that t becomes the new boundary.
+// A zero t clears the whole sync history hence the next sync will be a full one.
+// A timestamp in the future is rejected as it would prevent later changes
+// to ever be synchronised.
+func (tt *TimeTracker) SetLastSync(t time.Time) (ret error) {
+	if t.After(tt.now()) {
+		return fmt.Errorf("%w: last sync timestamp in the future: %s", ErrInvalidParam, t)
+	}
+
+	tx, err := tt.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("cannot start a transaction: %w", err)
+	}
+	defer tt.completeTransaction(tx, &ret)
+
+	if t.IsZero() {
+		if _, err := tx.Exec(`DELETE FROM sync_history`); err != nil {
+			return fmt.Errorf("cannot clear sync_history table: %w", err)
+		}
+		return nil
+	}
+
+	if _, err := tx.Exec(
+		`DELETE FROM sync_history WHERE sync_timestamp >= ?`, t.Unix(),
+	); err != nil {
+		return fmt.Errorf("cannot delete newer rows from sync_history table: %w", err)
+	}
+
+	return storeLastSyncTimestamp(tx, t)
+}
+
 // getNewTags return all tags created since the last sync operation
 func getNewTags(tx *sqlx.Tx) (newTags []string, ret error) {
 
@@ -151,21 +338,11 @@ func getNewTags(tx *sqlx.Tx) (newTags []string, ret error) {
 	}), nil
 }
 
-func storeNewTags(tx *sqlx.Tx, tags []string, now time.Time) error {
-	for _, tag := range tags {
-		if _, err := tx.Exec(
-			tx.Rebind(`
-				INSERT INTO tags (name, created_at)
-				VALUES (?, ?)
-				ON CONFLICT DO NOTHING`,
-			),
-			tag,
-			now.Unix(),
-		); err != nil {
-			return fmt.Errorf("cannot insert a row in tags: %w", err)
-		}
-	}
-	return nil
+func storeNewTags(tx *sqlx.Tx, tags []string, now time.Time, _ bool) error {
+	return insertRows(tx, "tags", []string{"name", "created_at"},
+		funk.Map(tags, func(_ int, tag string) []any {
+			return []any{tag, now.Unix()}
+		}))
 }
 
 func getNewIntervalStart(tx *sqlx.Tx) (newIntervals []intervalStartRow, ret error) {
@@ -175,11 +352,11 @@ func getNewIntervalStart(tx *sqlx.Tx) (newIntervals []intervalStartRow, ret erro
 			SELECT max(sync_timestamp) last_timestamp
 			FROM sync_history
 		) 
-		SELECT uuid, start_timestamp, created_at
+		SELECT uuid, start_timestamp, created_at, tz, context, location
 		FROM interval_start
 			JOIN last_sync
 				ON (last_timestamp IS NULL OR created_at >= last_timestamp)
-		ORDER BY created_at`)
+		ORDER BY created_at, uuid`)
 
 	if err != nil {
 		return nil, fmt.Errorf("cannot query interval start table: %w", err)
@@ -188,22 +365,20 @@ func getNewIntervalStart(tx *sqlx.Tx) (newIntervals []intervalStartRow, ret erro
 	return newIntervals, nil
 }
 
-func storeNewIntervalStart(tx *sqlx.Tx, newIntervals []intervalStartRow, now time.Time) error {
-	for _, interval := range newIntervals {
-		if _, err := tx.Exec(
-			tx.Rebind(`
-				INSERT INTO interval_start (uuid, start_timestamp, created_at)
-				VALUES (?, ?, ?)
-				ON CONFLICT DO NOTHING`,
-			),
-			interval.UUID,
-			interval.StartTimestamp,
-			now.Unix(),
-		); err != nil {
-			return fmt.Errorf("cannot insert a row in interval_start table: %w", err)
-		}
-	}
-	return nil
+func storeNewIntervalStart(
+	tx *sqlx.Tx,
+	newIntervals []intervalStartRow,
+	now time.Time,
+	overwrite bool,
+) error {
+	return storeRows(tx, overwrite, "interval_start",
+		[]string{"uuid", "start_timestamp", "created_at", "tz", "context", "location"},
+		funk.Map(newIntervals, func(_ int, interval intervalStartRow) []any {
+			return []any{
+				interval.UUID, interval.StartTimestamp, now.Unix(), interval.TZ, interval.Context,
+				interval.Location,
+			}
+		}))
 }
 
 func getNewIntervalStop(tx *sqlx.Tx) ([]intervalStopRow, error) {
@@ -217,7 +392,7 @@ func getNewIntervalStop(tx *sqlx.Tx) ([]intervalStopRow, error) {
 		FROM interval_stop
 			JOIN last_sync
 				ON (last_timestamp IS NULL OR created_at >= last_timestamp)
-		ORDER BY created_at`)
+		ORDER BY created_at, uuid`)
 	if err != nil {
 		return nil, fmt.Errorf("cannot query interval stop table: %w", err)
 	}
@@ -225,23 +400,17 @@ func getNewIntervalStop(tx *sqlx.Tx) ([]intervalStopRow, error) {
 	return newIntervalStop, nil
 }
 
-func storeNewIntervalStop(tx *sqlx.Tx, newIntervalStop []intervalStopRow, now time.Time) error {
-	for _, interval := range newIntervalStop {
-		if _, err := tx.Exec(
-			tx.Rebind(`
-				INSERT INTO interval_stop (uuid, start_uuid, stop_timestamp, created_at)
-				VALUES (?, ?, ?, ?)
-				ON CONFLICT DO NOTHING`,
-			),
-			interval.UUID,
-			interval.StartUUID,
-			interval.StopTimestamp,
-			now.Unix(),
-		); err != nil {
-			return fmt.Errorf("cannot insert a row into inteval_stop table: %w", err)
-		}
-	}
-	return nil
+func storeNewIntervalStop(
+	tx *sqlx.Tx,
+	newIntervalStop []intervalStopRow,
+	now time.Time,
+	overwrite bool,
+) error {
+	return storeRows(tx, overwrite, "interval_stop",
+		[]string{"uuid", "start_uuid", "stop_timestamp", "created_at"},
+		funk.Map(newIntervalStop, func(_ int, interval intervalStopRow) []any {
+			return []any{interval.UUID, interval.StartUUID, interval.StopTimestamp, now.Unix()}
+		}))
 }
 
 func getNewIntervalTombstone(tx *sqlx.Tx) ([]intervalTombstoneRow, error) {
@@ -254,32 +423,48 @@ func getNewIntervalTombstone(tx *sqlx.Tx) ([]intervalTombstoneRow, error) {
 		FROM interval_tombstone
 			JOIN last_sync
 				ON (last_timestamp IS NULL OR created_at >= last_timestamp)
-		ORDER BY created_at`)
+		ORDER BY created_at, uuid`)
 	if err != nil {
 		return nil, fmt.Errorf("cannot query interval_tombstone table: %w", err)
 	}
 	return itr, nil
 }
 
-func storeNewIntervalTombstone(tx *sqlx.Tx, intervals []intervalTombstoneRow, now time.Time) error {
-	for _, i := range intervals {
-		if _, err := tx.Exec(
-			tx.Rebind(`
-				INSERT INTO interval_tombstone (uuid, start_uuid, created_at)
-				VALUES (?, ?, ?)
-				ON CONFLICT DO NOTHING`,
-			),
-			i.UUID, i.StartUUID, now.Unix(),
-		); err != nil {
-			return fmt.Errorf("cannot insert a row in interval_tombstone table: %w", err)
-		}
-	}
-	return nil
+func storeNewIntervalTombstone(
+	tx *sqlx.Tx,
+	intervals []intervalTombstoneRow,
+	now time.Time,
+	overwrite bool,
+) error {
+	return storeRows(tx, overwrite, "interval_tombstone",
+		[]string{"uuid", "start_uuid", "created_at"},
+		funk.Map(intervals, func(_ int, i intervalTombstoneRow) []any {
+			return []any{i.UUID, i.StartUUID, now.Unix()}
+		}))
 }
 
-func getNewIntervalTags(tx *sqlx.Tx) ([]intervalTagsRow, error) {
+// remoteSchema tells if tx runs on the remote postgres database. Its interval_tags
+// table holds the tag names where the local one references the tags by id.
+func remoteSchema(tx *sqlx.Tx) bool {
+	return tx.DriverName() == "pgx"
+}
 
-	newIntervalTags, err := getRows[intervalTagsRow](tx, `
+func getNewIntervalTags(tx *sqlx.Tx) ([]intervalTagsRow, error) {
+	// Tags recorded in the same second are sent in insertion order
+	// which is the order the tags of an interval are listed in.
+	query := `
+		WITH last_sync AS (
+			SELECT max(sync_timestamp) last_timestamp
+			FROM sync_history
+		)
+		SELECT interval_tags.uuid, interval_start_uuid, tags.name AS tag, interval_tags.created_at
+		FROM interval_tags
+			JOIN tags ON interval_tags.tag_id = tags.id
+			JOIN last_sync
+				ON (last_timestamp IS NULL OR interval_tags.created_at >= last_timestamp)
+		ORDER BY interval_tags.created_at, interval_tags.rowid`
+	if remoteSchema(tx) {
+		query = `
 		WITH last_sync AS (
 			SELECT max(sync_timestamp) last_timestamp
 			FROM sync_history
@@ -288,7 +473,10 @@ func getNewIntervalTags(tx *sqlx.Tx) ([]intervalTagsRow, error) {
 		FROM interval_tags
 			JOIN last_sync
 				ON (last_timestamp IS NULL OR created_at >= last_timestamp)
-		ORDER BY created_at`)
+		ORDER BY created_at, uuid`
+	}
+
+	newIntervalTags, err := getRows[intervalTagsRow](tx, query)
 	if err != nil {
 		return nil, fmt.Errorf("cannot query interval_tags table: %w", err)
 	}
@@ -296,20 +484,45 @@ func getNewIntervalTags(tx *sqlx.Tx) ([]intervalTagsRow, error) {
 	return newIntervalTags, nil
 }
 
-func storeNewIntervalTags(tx *sqlx.Tx, newIntervalTags []intervalTagsRow, now time.Time) error {
-	for _, i := range newIntervalTags {
-		if _, err := tx.Exec(
-			tx.Rebind(
-				`INSERT INTO interval_tags (uuid, interval_start_uuid, tag, created_at)
-				VALUES (?, ?, ?, ?)
-				ON CONFLICT DO NOTHING`,
-			),
-			i.UUID, i.StartUUID, i.Tag, now.Unix(),
-		); err != nil {
-			return fmt.Errorf("cannot insert row in interval_tags table: %w", err)
-		}
+func storeNewIntervalTags(
+	tx *sqlx.Tx,
+	newIntervalTags []intervalTagsRow,
+	now time.Time,
+	overwrite bool,
+) error {
+	if remoteSchema(tx) {
+		return storeRows(tx, overwrite, "interval_tags",
+			[]string{"uuid", "interval_start_uuid", "tag", "created_at"},
+			funk.Map(newIntervalTags, func(_ int, i intervalTagsRow) []any {
+				return []any{i.UUID, i.StartUUID, i.Tag, now.Unix()}
+			}))
 	}
-	return nil
+
+	// The tags are synchronised first but a row may reference
+	// a tag created before the last synchronisation
+	if err := storeNewTags(tx, funk.Map(newIntervalTags, func(_ int, i intervalTagsRow) string {
+		return i.Tag
+	}), now, false); err != nil {
+		return err
+	}
+	type tagRow struct {
+		ID   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+	tags, err := getRows[tagRow](tx, `SELECT id, name FROM tags`)
+	if err != nil {
+		return fmt.Errorf("cannot query tags table: %w", err)
+	}
+	ids := make(map[string]int64, len(tags))
+	for _, t := range tags {
+		ids[t.Name] = t.ID
+	}
+
+	return storeRows(tx, overwrite, "interval_tags",
+		[]string{"uuid", "interval_start_uuid", "tag_id", "created_at"},
+		funk.Map(newIntervalTags, func(_ int, i intervalTagsRow) []any {
+			return []any{i.UUID, i.StartUUID, ids[i.Tag], now.Unix()}
+		}))
 }
 
 func getNewIntervalTagsTombstone(tx *sqlx.Tx) ([]intervalTagsTombstoneRow, error) {
@@ -323,7 +536,7 @@ func getNewIntervalTagsTombstone(tx *sqlx.Tx) ([]intervalTagsTombstoneRow, error
 		FROM interval_tags_tombstone
 			JOIN last_sync
 				ON (last_timestamp IS NULL OR created_at >= last_timestamp)
-		ORDER BY created_at`)
+		ORDER BY created_at, uuid`)
 	if err != nil {
 		return nil, fmt.Errorf("cannot query interval_tags_tombstone table: %w", err)
 	}
@@ -335,120 +548,333 @@ func storeNewIntervalTagsTombstone(
 	tx *sqlx.Tx,
 	newIntervalTagsTombstone []intervalTagsTombstoneRow,
 	now time.Time,
+	overwrite bool,
 ) error {
-	for _, i := range newIntervalTagsTombstone {
-		if _, err := tx.Exec(
-			tx.Rebind(
-				`INSERT INTO interval_tags_tombstone (uuid, interval_tag_uuid, created_at)
-				VALUES (?, ?, ?)
-				ON CONFLICT DO NOTHING`,
-			),
-			i.UUID, i.IntervalTagUUID, now.Unix(),
-		); err != nil {
-			return fmt.Errorf("cannot insert row in interval_tags_tombstone table: %w", err)
-		}
+	return storeRows(tx, overwrite, "interval_tags_tombstone",
+		[]string{"uuid", "interval_tag_uuid", "created_at"},
+		funk.Map(newIntervalTagsTombstone, func(_ int, i intervalTagsTombstoneRow) []any {
+			return []any{i.UUID, i.IntervalTagUUID, now.Unix()}
+		}))
+}
+
+func getNewIntervalEstimate(tx *sqlx.Tx) ([]intervalEstimateRow, error) {
+	ier, err := getRows[intervalEstimateRow](tx, `
+		WITH last_sync AS (
+			SELECT max(sync_timestamp) last_timestamp
+			FROM sync_history
+		)
+		SELECT uuid, start_uuid, estimate, created_at
+		FROM interval_estimate
+			JOIN last_sync
+				ON (last_timestamp IS NULL OR created_at >= last_timestamp)
+		ORDER BY created_at, uuid`)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query interval_estimate table: %w", err)
 	}
-	return nil
+	return ier, nil
+}
+
+func storeNewIntervalEstimate(
+	tx *sqlx.Tx,
+	estimates []intervalEstimateRow,
+	now time.Time,
+	overwrite bool,
+) error {
+	return storeRows(tx, overwrite, "interval_estimate",
+		[]string{"uuid", "start_uuid", "estimate", "created_at"},
+		funk.Map(estimates, func(_ int, e intervalEstimateRow) []any {
+			return []any{e.UUID, e.StartUUID, e.Estimate, now.Unix()}
+		}))
+}
+
+func getNewIntervalEstimateTombstone(tx *sqlx.Tx) ([]intervalEstimateTombstoneRow, error) {
+	iet, err := getRows[intervalEstimateTombstoneRow](tx, `
+		WITH last_sync AS (
+			SELECT max(sync_timestamp) last_timestamp
+			FROM sync_history
+		)
+		SELECT uuid, estimate_uuid, created_at
+		FROM interval_estimate_tombstone
+			JOIN last_sync
+				ON (last_timestamp IS NULL OR created_at >= last_timestamp)
+		ORDER BY created_at, uuid`)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query interval_estimate_tombstone table: %w", err)
+	}
+	return iet, nil
+}
+
+func storeNewIntervalEstimateTombstone(
+	tx *sqlx.Tx,
+	tombstones []intervalEstimateTombstoneRow,
+	now time.Time,
+	overwrite bool,
+) error {
+	return storeRows(tx, overwrite, "interval_estimate_tombstone",
+		[]string{"uuid", "estimate_uuid", "created_at"},
+		funk.Map(tombstones, func(_ int, e intervalEstimateTombstoneRow) []any {
+			return []any{e.UUID, e.EstimateUUID, now.Unix()}
+		}))
+}
+
+func getNewIntervalBillable(tx *sqlx.Tx) ([]intervalBillableRow, error) {
+	ibr, err := getRows[intervalBillableRow](tx, `
+		WITH last_sync AS (
+			SELECT max(sync_timestamp) last_timestamp
+			FROM sync_history
+		)
+		SELECT uuid, start_uuid, created_at
+		FROM interval_billable
+			JOIN last_sync
+				ON (last_timestamp IS NULL OR created_at >= last_timestamp)
+		ORDER BY created_at, uuid`)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query interval_billable table: %w", err)
+	}
+	return ibr, nil
 }
 
-func synchroniseObject[T any](
-	trace string,
-	localTx *sqlx.Tx,
-	remoteTx *sqlx.Tx,
-	getFunc func(*sqlx.Tx) ([]T, error),
-	storeFunc func(*sqlx.Tx, []T, time.Time) error,
+func storeNewIntervalBillable(
+	tx *sqlx.Tx,
+	billables []intervalBillableRow,
 	now time.Time,
+	overwrite bool,
 ) error {
-	logrus.Info(trace)
-	logrus.Info(trace + ": getting new local rows")
-	newLocalObjects, err := getFunc(localTx)
+	return storeRows(tx, overwrite, "interval_billable",
+		[]string{"uuid", "start_uuid", "created_at"},
+		funk.Map(billables, func(_ int, b intervalBillableRow) []any {
+			return []any{b.UUID, b.StartUUID, now.Unix()}
+		}))
+}
+
+func getNewIntervalBillableTombstone(tx *sqlx.Tx) ([]intervalBillableTombstoneRow, error) {
+	ibt, err := getRows[intervalBillableTombstoneRow](tx, `
+		WITH last_sync AS (
+			SELECT max(sync_timestamp) last_timestamp
+			FROM sync_history
+		)
+		SELECT uuid, billable_uuid, created_at
+		FROM interval_billable_tombstone
+			JOIN last_sync
+				ON (last_timestamp IS NULL OR created_at >= last_timestamp)
+		ORDER BY created_at, uuid`)
 	if err != nil {
-		return fmt.Errorf("%s: cannot get new local object: %w", trace, err)
+		return nil, fmt.Errorf("cannot query interval_billable_tombstone table: %w", err)
 	}
+	return ibt, nil
+}
+
+func storeNewIntervalBillableTombstone(
+	tx *sqlx.Tx,
+	tombstones []intervalBillableTombstoneRow,
+	now time.Time,
+	overwrite bool,
+) error {
+	return storeRows(tx, overwrite, "interval_billable_tombstone",
+		[]string{"uuid", "billable_uuid", "created_at"},
+		funk.Map(tombstones, func(_ int, b intervalBillableTombstoneRow) []any {
+			return []any{b.UUID, b.BillableUUID, now.Unix()}
+		}))
+}
+
+// storeRows inserts synchronised rows. A row whose uuid is already known is
+// ignored unless overwrite is set: it then replaces the known one if they differ.
+func storeRows(
+	tx *sqlx.Tx, overwrite bool, table string, columns []string, rows [][]any,
+) error {
+	if !overwrite {
+		return insertRows(tx, table, columns, rows)
+	}
+
+	var compared []string
+	for _, c := range columns {
+		if c != "uuid" && c != "created_at" {
+			compared = append(compared, c)
+		}
+	}
+	return overwriteRows(tx, table, "uuid", compared, columns, rows)
+}
+
+// objectSync exchanges the rows of a table returned by get between the local
+// and the remote database. store stamps the rows it stores with the sync
+// time now rather than with their original creation time: the rows created
+// since the last sync are looked for by their created_at value, hence a row
+// keeping an older one would never reach the clients which already
+// synchronised after it was created.
+type objectSync[T any] struct {
+	trace string
+	get   func(*sqlx.Tx) ([]T, error)
+	store func(*sqlx.Tx, []T, time.Time, bool) error
+}
+
+// fetchedObjects holds the new rows of a table read from both databases
+// and not stored yet in the other one.
+type fetchedObjects[T any] struct {
+	objectSync[T]
+	local  []T
+	remote []T
+}
+
+// syncedTable is a table exchanged by the synchronisation.
+type syncedTable interface {
+	fetch(localTx, remoteTx *sqlx.Tx) (fetchedRows, error)
+}
 
-	logrus.Info(trace + ": getting new remote rows")
-	newRemoteObjects, err := getFunc(remoteTx)
+// fetchedRows are the new rows of a table waiting to be stored.
+type fetchedRows interface {
+	storeAll(localTx, remoteTx *sqlx.Tx, now time.Time, strategy MergeStrategy) error
+}
+
+func (s objectSync[T]) fetch(localTx, remoteTx *sqlx.Tx) (fetchedRows, error) {
+	logrus.Info(s.trace)
+	logrus.Info(s.trace + ": getting new local rows")
+	newLocalObjects, err := s.get(localTx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: cannot get new local object: %w", s.trace, err)
+	}
+
+	logrus.Info(s.trace + ": getting new remote rows")
+	newRemoteObjects, err := s.get(remoteTx)
 	if err != nil {
-		return fmt.Errorf("%s: cannot get new remote objects: %w", trace, err)
+		return nil, fmt.Errorf("%s: cannot get new remote objects: %w", s.trace, err)
 	}
 
-	logrus.Info(trace + ": storing locally new remote rows")
-	if err := storeFunc(localTx, newRemoteObjects, now); err != nil {
+	return fetchedObjects[T]{objectSync: s, local: newLocalObjects, remote: newRemoteObjects}, nil
+}
+
+func (f fetchedObjects[T]) storeAll(
+	localTx, remoteTx *sqlx.Tx, now time.Time, strategy MergeStrategy,
+) error {
+	logrus.Info(f.trace + ": storing locally new remote rows")
+	if err := f.store(localTx, f.remote, now, strategy == MergeRemoteWins); err != nil {
 		return fmt.Errorf(
-			"%s: cannot synchronise new remote objects in local database: %w", trace, err)
+			"%s: cannot synchronise new remote objects in local database: %w", f.trace, err)
 	}
 
-	logrus.Info(trace + ": storing remotely new local rows")
-	if err := storeFunc(remoteTx, newLocalObjects, now); err != nil {
+	logrus.Info(f.trace + ": storing remotely new local rows")
+	if err := f.store(remoteTx, f.local, now, strategy == MergeLocalWins); err != nil {
 		return fmt.Errorf(
-			"%s: cannot synchronise new local objects in remote database: %w", trace, err)
+			"%s: cannot synchronise new local objects in remote database: %w", f.trace, err)
 	}
-	logrus.Info(trace + " done")
+	logrus.Info(f.trace + " done")
 	return nil
 }
 
-func synchroniseTags(localTx, remoteTx *sqlx.Tx, now time.Time) error {
-	return synchroniseObject("synchronising tags", localTx, remoteTx, getNewTags, storeNewTags, now)
+// synchronise fetches then stores the new rows of both databases.
+func (s objectSync[T]) synchronise(
+	localTx, remoteTx *sqlx.Tx, now time.Time, strategy MergeStrategy,
+) error {
+	fetched, err := s.fetch(localTx, remoteTx)
+	if err != nil {
+		return err
+	}
+	return fetched.storeAll(localTx, remoteTx, now, strategy)
 }
 
-func synchroniseIntervalStart(localTx, remoteTx *sqlx.Tx, now time.Time) error {
-	return synchroniseObject(
-		"synchronising interval start",
-		localTx,
-		remoteTx,
-		getNewIntervalStart,
-		storeNewIntervalStart,
-		now,
-	)
+var (
+	tagsSync = objectSync[string]{
+		"synchronising tags", getNewTags, storeNewTags}
+	intervalStartSync = objectSync[intervalStartRow]{
+		"synchronising interval start", getNewIntervalStart, storeNewIntervalStart}
+	intervalStopSync = objectSync[intervalStopRow]{
+		"synchronising interval stop", getNewIntervalStop, storeNewIntervalStop}
+	intervalTombstoneSync = objectSync[intervalTombstoneRow]{
+		"synchronising interval tombstone", getNewIntervalTombstone, storeNewIntervalTombstone}
+	intervalTagsSync = objectSync[intervalTagsRow]{
+		"synchronising interval tags", getNewIntervalTags, storeNewIntervalTags}
+	intervalTagsTombstoneSync = objectSync[intervalTagsTombstoneRow]{
+		"synchronising interval tags tombstone",
+		getNewIntervalTagsTombstone, storeNewIntervalTagsTombstone}
+	intervalEstimateSync = objectSync[intervalEstimateRow]{
+		"synchronising interval estimate", getNewIntervalEstimate, storeNewIntervalEstimate}
+	intervalEstimateTombstoneSync = objectSync[intervalEstimateTombstoneRow]{
+		"synchronising interval estimate tombstone",
+		getNewIntervalEstimateTombstone, storeNewIntervalEstimateTombstone}
+	intervalBillableSync = objectSync[intervalBillableRow]{
+		"synchronising interval billable", getNewIntervalBillable, storeNewIntervalBillable}
+	intervalBillableTombstoneSync = objectSync[intervalBillableTombstoneRow]{
+		"synchronising interval billable tombstone",
+		getNewIntervalBillableTombstone, storeNewIntervalBillableTombstone}
+)
+
+// syncChunks are the synchronised tables grouped by the transactions they
+// are committed in by SyncChunkedWith, the referenced tables first.
+// An interval start is committed along with its stop and tombstone as a
+// synchronisation would otherwise see it opened, and every other table
+// along with its tombstones.
+var syncChunks = [][]syncedTable{
+	{tagsSync, intervalStartSync, intervalStopSync, intervalTombstoneSync},
+	{intervalTagsSync, intervalTagsTombstoneSync},
+	{intervalEstimateSync, intervalEstimateTombstoneSync},
+	{intervalBillableSync, intervalBillableTombstoneSync},
 }
 
-func synchroniseIntervalStop(localTx, remoteTx *sqlx.Tx, now time.Time) error {
-	return synchroniseObject(
-		"synchronising interval stop",
-		localTx,
-		remoteTx,
-		getNewIntervalStop,
-		storeNewIntervalStop,
-		now,
-	)
+func synchroniseTags(
+	localTx, remoteTx *sqlx.Tx, now time.Time, strategy MergeStrategy,
+) error {
+	return tagsSync.synchronise(localTx, remoteTx, now, strategy)
 }
 
-func synchroniseIntervalTombstone(localTx, remoteTx *sqlx.Tx, now time.Time) error {
-	return synchroniseObject(
-		"synchronising interval tombstone",
-		localTx,
-		remoteTx,
-		getNewIntervalTombstone,
-		storeNewIntervalTombstone,
-		now,
-	)
+func synchroniseIntervalStart(
+	localTx, remoteTx *sqlx.Tx, now time.Time, strategy MergeStrategy,
+) error {
+	return intervalStartSync.synchronise(localTx, remoteTx, now, strategy)
 }
 
-func synchroniseIntervalTags(localTx, remoteTx *sqlx.Tx, now time.Time) error {
-	return synchroniseObject(
-		"synchronising interval tags",
-		localTx,
-		remoteTx,
-		getNewIntervalTags,
-		storeNewIntervalTags,
-		now,
-	)
+func synchroniseIntervalStop(
+	localTx, remoteTx *sqlx.Tx, now time.Time, strategy MergeStrategy,
+) error {
+	return intervalStopSync.synchronise(localTx, remoteTx, now, strategy)
 }
 
-func synchroniseIntervalTagsTombstone(localTx, remoteTx *sqlx.Tx, now time.Time) error {
-	return synchroniseObject(
-		"synchronising interval tags tombstone",
-		localTx,
-		remoteTx,
-		getNewIntervalTagsTombstone,
-		storeNewIntervalTagsTombstone,
-		now,
-	)
+func synchroniseIntervalTombstone(
+	localTx, remoteTx *sqlx.Tx, now time.Time, strategy MergeStrategy,
+) error {
+	return intervalTombstoneSync.synchronise(localTx, remoteTx, now, strategy)
+}
+
+func synchroniseIntervalTags(
+	localTx, remoteTx *sqlx.Tx, now time.Time, strategy MergeStrategy,
+) error {
+	return intervalTagsSync.synchronise(localTx, remoteTx, now, strategy)
+}
+
+func synchroniseIntervalTagsTombstone(
+	localTx, remoteTx *sqlx.Tx, now time.Time, strategy MergeStrategy,
+) error {
+	return intervalTagsTombstoneSync.synchronise(localTx, remoteTx, now, strategy)
+}
+
+func synchroniseIntervalEstimate(
+	localTx, remoteTx *sqlx.Tx, now time.Time, strategy MergeStrategy,
+) error {
+	return intervalEstimateSync.synchronise(localTx, remoteTx, now, strategy)
 }
 
-// Sync performs a bidirectional synchronisation with the central database.
-func (tt *TimeTracker) Sync(cfg SyncerConfig) (ret error) {
-	syncDB, err := setupSyncerDB(cfg)
+func synchroniseIntervalEstimateTombstone(
+	localTx, remoteTx *sqlx.Tx, now time.Time, strategy MergeStrategy,
+) error {
+	return intervalEstimateTombstoneSync.synchronise(localTx, remoteTx, now, strategy)
+}
+
+func synchroniseIntervalBillable(
+	localTx, remoteTx *sqlx.Tx, now time.Time, strategy MergeStrategy,
+) error {
+	return intervalBillableSync.synchronise(localTx, remoteTx, now, strategy)
+}
+
+func synchroniseIntervalBillableTombstone(
+	localTx, remoteTx *sqlx.Tx, now time.Time, strategy MergeStrategy,
+) error {
+	return intervalBillableTombstoneSync.synchronise(localTx, remoteTx, now, strategy)
+}
+
+// Sync performs a bidirectional synchronisation with the central database
+// of syncer, either postgres or sqlite. Rows known by both databases with
+// different attributes are resolved according to the merge strategy of
+// the syncer options.
+func (tt *TimeTracker) Sync(syncer Syncer) (ret error) {
+	syncDB, err := syncer.Open()
 	if err != nil {
 		return fmt.Errorf("cannot open syncer database: %w", err)
 	}
@@ -458,13 +884,26 @@ func (tt *TimeTracker) Sync(cfg SyncerConfig) (ret error) {
 		}
 	}()
 
+	opts := syncer.Options()
+	if opts.Chunked {
+		return tt.SyncChunkedWith(syncDB, opts.MergeStrategy)
+	}
+	return tt.SyncWith(syncDB, opts.MergeStrategy)
+}
+
+// SyncWith performs a bidirectional synchronisation with an already opened
+// central database. It is either a postgres database whose schema has been
+// migrated or the sqlite database of another TimeTracker.
+// Rows known by both databases with different attributes are resolved
+// according to strategy.
+func (tt *TimeTracker) SyncWith(syncDB *sqlx.DB, strategy MergeStrategy) (ret error) {
 	tx, err := tt.db.Beginx()
 	if err != nil {
 		return fmt.Errorf("cannot start a transaction: %w", err)
 	}
-	defer completeTransaction(tx, &ret)
+	defer tt.completeTransaction(tx, &ret)
 
-	if count, countErr := tt.countOpenedInterval(tx); countErr != nil {
+	if count, countErr := tt.countOpenedInterval(tx, true); countErr != nil {
 		return fmt.Errorf("cannot count opened interval: %w", countErr)
 	} else if count >= 1 {
 		return fmt.Errorf("cannot sync: %w", ErrExistingOpenInterval)
@@ -481,6 +920,10 @@ func (tt *TimeTracker) Sync(cfg SyncerConfig) (ret error) {
 	}
 	defer completeTransaction(syncTx, &ret)
 
+	if err := lockSyncerDB(syncTx); err != nil {
+		return err
+	}
+
 	if err := setupLastSyncTimestamp(syncTx, lastSync); err != nil {
 		return fmt.Errorf("cannot setup last sync temp table on remote database: %w", err)
 	}
@@ -490,17 +933,151 @@ func (tt *TimeTracker) Sync(cfg SyncerConfig) (ret error) {
 	// get all new local and remote data which has been created, update or deleted
 	// after the last sync timestamp
 	return funk.CallAbortOnError(
-		func() error { return synchroniseTags(tx, syncTx, now) },
-		func() error { return synchroniseIntervalStart(tx, syncTx, now) },
-		func() error { return synchroniseIntervalStop(tx, syncTx, now) },
-		func() error { return synchroniseIntervalTombstone(tx, syncTx, now) },
-		func() error { return synchroniseIntervalTags(tx, syncTx, now) },
-		func() error { return synchroniseIntervalTagsTombstone(tx, syncTx, now) },
+		func() error { return synchroniseTags(tx, syncTx, now, strategy) },
+		func() error { return synchroniseIntervalStart(tx, syncTx, now, strategy) },
+		func() error { return synchroniseIntervalStop(tx, syncTx, now, strategy) },
+		func() error { return synchroniseIntervalTombstone(tx, syncTx, now, strategy) },
+		func() error { return synchroniseIntervalTags(tx, syncTx, now, strategy) },
+		func() error { return synchroniseIntervalTagsTombstone(tx, syncTx, now, strategy) },
+		func() error { return synchroniseIntervalEstimate(tx, syncTx, now, strategy) },
+		func() error { return synchroniseIntervalEstimateTombstone(tx, syncTx, now, strategy) },
+		func() error { return synchroniseIntervalBillable(tx, syncTx, now, strategy) },
+		func() error { return synchroniseIntervalBillableTombstone(tx, syncTx, now, strategy) },
 		func() error {
 			if err := storeLastSyncTimestamp(tx, now); err != nil {
 				return fmt.Errorf("cannot store last sync timestamp: %w", err)
 			}
 			return nil
 		},
+		func() error { return dropLastSyncTimestamp(syncTx) },
 	)
 }
+
+// SyncChunkedWith performs the same synchronisation as SyncWith without
+// holding the remote database for the whole exchange. The new rows of both
+// databases are first read at once, then stored chunk by chunk, each one
+// committed in its own pair of transactions, see syncChunks. The other
+// synchronisations can run between two chunks.
+// Each chunk is stamped with the time it is stored at, which is never before
+// the last sync timestamp recorded by a concurrent synchronisation which
+// has not seen its rows.
+// The last sync timestamp is only recorded once every chunk has been stored.
+// A failure leaves the chunks stored so far in both databases, which is a
+// consistent state as their referenced rows have been stored before them,
+// and the next synchronisation sends them again along with the remaining ones.
+// Rows recorded locally in the meantime are left for the next synchronisation.
+func (tt *TimeTracker) SyncChunkedWith(syncDB *sqlx.DB, strategy MergeStrategy) error {
+	now, fetched, err := tt.fetchNewRows(syncDB)
+	if err != nil {
+		return err
+	}
+
+	for _, chunk := range fetched {
+		if err := tt.storeChunk(syncDB, chunk, strategy); err != nil {
+			return err
+		}
+	}
+
+	return tt.recordSync(now)
+}
+
+// recordSync stores now as the last sync timestamp.
+func (tt *TimeTracker) recordSync(now time.Time) (ret error) {
+	tx, err := tt.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("cannot start a transaction: %w", err)
+	}
+	defer tt.completeTransaction(tx, &ret)
+
+	if err := storeLastSyncTimestamp(tx, now); err != nil {
+		return fmt.Errorf("cannot store last sync timestamp: %w", err)
+	}
+	return nil
+}
+
+// fetchNewRows reads the rows of every synchronised table created since
+// the last sync in both databases, grouped by chunk. It returns them along
+// with the time of the synchronisation.
+func (tt *TimeTracker) fetchNewRows(
+	syncDB *sqlx.DB,
+) (now time.Time, fetched [][]fetchedRows, ret error) {
+	tx, err := tt.db.Beginx()
+	if err != nil {
+		return time.Time{}, nil, fmt.Errorf("cannot start a transaction: %w", err)
+	}
+	defer tt.completeTransaction(tx, &ret)
+
+	if count, countErr := tt.countOpenedInterval(tx, true); countErr != nil {
+		return time.Time{}, nil, fmt.Errorf("cannot count opened interval: %w", countErr)
+	} else if count >= 1 {
+		return time.Time{}, nil, fmt.Errorf("cannot sync: %w", ErrExistingOpenInterval)
+	}
+
+	lastSync, err := getLastSyncTimestamp(tx)
+	if err != nil {
+		return time.Time{}, nil, fmt.Errorf("cannot get last sync timestamp: %w", err)
+	}
+
+	syncTx, err := syncDB.Beginx()
+	if err != nil {
+		return time.Time{}, nil, fmt.Errorf("cannot start transaction on syncer db: %w", err)
+	}
+	defer completeTransaction(syncTx, &ret)
+
+	if err := lockSyncerDB(syncTx); err != nil {
+		return time.Time{}, nil, err
+	}
+
+	if err := setupLastSyncTimestamp(syncTx, lastSync); err != nil {
+		return time.Time{}, nil,
+			fmt.Errorf("cannot setup last sync temp table on remote database: %w", err)
+	}
+
+	now = tt.now()
+	for _, chunk := range syncChunks {
+		var chunkRows []fetchedRows
+		for _, table := range chunk {
+			rows, err := table.fetch(tx, syncTx)
+			if err != nil {
+				return time.Time{}, nil, err
+			}
+			chunkRows = append(chunkRows, rows)
+		}
+		fetched = append(fetched, chunkRows)
+	}
+
+	if err := dropLastSyncTimestamp(syncTx); err != nil {
+		return time.Time{}, nil, err
+	}
+	return now, fetched, nil
+}
+
+// storeChunk stores the fetched rows of the tables of a chunk in both
+// databases, stamped with the current time.
+func (tt *TimeTracker) storeChunk(
+	syncDB *sqlx.DB, chunk []fetchedRows, strategy MergeStrategy,
+) (ret error) {
+	tx, err := tt.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("cannot start a transaction: %w", err)
+	}
+	defer tt.completeTransaction(tx, &ret)
+
+	syncTx, err := syncDB.Beginx()
+	if err != nil {
+		return fmt.Errorf("cannot start transaction on syncer db: %w", err)
+	}
+	defer completeTransaction(syncTx, &ret)
+
+	if err := lockSyncerDB(syncTx); err != nil {
+		return err
+	}
+
+	now := tt.now()
+	for _, rows := range chunk {
+		if err := rows.storeAll(tx, syncTx, now, strategy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
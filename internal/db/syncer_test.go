@@ -4,14 +4,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
 	"testing/quick"
 	"time"
 
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/require"
+
+	"github.com/dgsb/tt/internal/funk"
 )
 
 func startPostgres(t *testing.T) SyncerConfig {
@@ -101,6 +107,98 @@ func TestSetupSyncer(t *testing.T) {
 	require.NotNil(t, db)
 }
 
+func TestSyncerUUIDConstraints(t *testing.T) {
+	db, err := setupSyncerDB(startPostgres(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, db.Close()) })
+
+	var tables []string
+	require.NoError(t, db.Select(&tables, `
+		SELECT table_constraints.table_name
+		FROM information_schema.table_constraints
+			JOIN information_schema.constraint_column_usage
+				ON table_constraints.constraint_name = constraint_column_usage.constraint_name
+		WHERE table_constraints.constraint_type IN ('PRIMARY KEY', 'UNIQUE')
+			AND constraint_column_usage.column_name = 'uuid'`))
+	for _, c := range uuidColumns {
+		if c.column == "uuid" {
+			require.Contains(t, tables, c.table)
+		}
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO interval_start (uuid, start_timestamp, created_at)
+		VALUES ('4d0b5e8a-3c8e-4d2b-9b8e-2f1c0a7d6e51', 0, 0)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`
+		INSERT INTO interval_start (uuid, start_timestamp, created_at)
+		VALUES ('4d0b5e8a-3c8e-4d2b-9b8e-2f1c0a7d6e51', 1, 1)`)
+	require.Error(t, err)
+	require.NoError(t, NewSanity(db).checkUUIDUnicity())
+}
+
+func TestPostgresSchema(t *testing.T) {
+	db, err := setupSyncerDB(startPostgres(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, db.Close()) })
+
+	var columns []struct {
+		Table    string `db:"table_name"`
+		Column   string `db:"column_name"`
+		Nullable string `db:"is_nullable"`
+	}
+	require.NoError(t, db.Select(&columns, `
+		SELECT table_name, column_name, is_nullable
+		FROM information_schema.columns
+		WHERE table_schema = 'public'`))
+	nullable := map[string]string{}
+	for _, c := range columns {
+		nullable[c.Table+"."+c.Column] = c.Nullable
+	}
+
+	// The columns written by the syncer, and whether they accept null values
+	for column, expected := range map[string]string{
+		"tags.name":                                 "NO",
+		"tags.created_at":                           "YES",
+		"interval_start.uuid":                       "NO",
+		"interval_start.start_timestamp":            "NO",
+		"interval_start.created_at":                 "NO",
+		"interval_start.tz":                         "YES",
+		"interval_start.context":                    "NO",
+		"interval_start.location":                   "YES",
+		"interval_stop.uuid":                        "NO",
+		"interval_stop.start_uuid":                  "NO",
+		"interval_stop.stop_timestamp":              "NO",
+		"interval_stop.created_at":                  "NO",
+		"interval_tombstone.uuid":                   "NO",
+		"interval_tombstone.start_uuid":             "NO",
+		"interval_tombstone.created_at":             "NO",
+		"interval_tags.uuid":                        "NO",
+		"interval_tags.interval_start_uuid":         "NO",
+		"interval_tags.tag":                         "NO",
+		"interval_tags.created_at":                  "NO",
+		"interval_tags_tombstone.uuid":              "NO",
+		"interval_tags_tombstone.interval_tag_uuid": "NO",
+		"interval_tags_tombstone.created_at":        "NO",
+		"interval_estimate.uuid":                    "NO",
+		"interval_estimate.start_uuid":              "NO",
+		"interval_estimate.estimate":                "NO",
+		"interval_estimate.created_at":              "NO",
+		"interval_estimate_tombstone.uuid":          "NO",
+		"interval_estimate_tombstone.estimate_uuid": "NO",
+		"interval_estimate_tombstone.created_at":    "NO",
+		"interval_billable.uuid":                    "NO",
+		"interval_billable.start_uuid":              "NO",
+		"interval_billable.created_at":              "NO",
+		"interval_billable_tombstone.uuid":          "NO",
+		"interval_billable_tombstone.billable_uuid": "NO",
+		"interval_billable_tombstone.created_at":    "NO",
+	} {
+		require.Contains(t, nullable, column)
+		require.Equal(t, expected, nullable[column], column)
+	}
+}
+
 func TestSync(t *testing.T) {
 	t.Run("get tags - null last sync", func(t *testing.T) {
 		tt := setupTT(t)
@@ -163,7 +261,7 @@ func TestSync(t *testing.T) {
 			_, err := tt.db.Exec(`
 				INSERT INTO interval_start (uuid, start_timestamp, created_at)
 				VALUES (?, ?, ?)`,
-				fmt.Sprintf("%d", idx+1),
+				fmt.Sprintf("00000000-0000-0000-0000-%012d", idx+1),
 				data.StartTimestamp,
 				data.CreatedAt)
 			require.NoError(t, err)
@@ -177,12 +275,12 @@ func TestSync(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, []intervalStartRow{
 			{
-				UUID:           "1",
+				UUID:           "00000000-0000-0000-0000-000000000001",
 				StartTimestamp: now.Add(-6 * time.Hour).Unix(),
 				CreatedAt:      now.Add(-3 * time.Hour).Unix(),
 			},
 			{
-				UUID:           "2",
+				UUID:           "00000000-0000-0000-0000-000000000002",
 				StartTimestamp: now.Add(-4 * time.Hour).Unix(),
 				CreatedAt:      now.Add(-2 * time.Hour).Unix(),
 			},
@@ -216,7 +314,7 @@ func TestSync(t *testing.T) {
 			_, err := tt.db.Exec(`
 				INSERT INTO interval_start (uuid, start_timestamp, created_at)
 				VALUES (?, ?, ?)`,
-				fmt.Sprintf("%d", idx+1),
+				fmt.Sprintf("00000000-0000-0000-0000-%012d", idx+1),
 				data.StartTimestamp,
 				data.CreatedAt)
 			require.NoError(t, err)
@@ -230,7 +328,7 @@ func TestSync(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, []intervalStartRow{
 			{
-				UUID:           "1",
+				UUID:           "00000000-0000-0000-0000-000000000001",
 				StartTimestamp: now.Add(-6 * time.Hour).Unix(),
 				CreatedAt:      now.Add(-3 * time.Hour).Unix(),
 			},
@@ -243,17 +341,17 @@ func TestSync(t *testing.T) {
 
 		for idx, r := range []intervalStartRow{
 			{
-				UUID:           "1",
+				UUID:           "00000000-0000-0000-0000-000000000001",
 				StartTimestamp: now.Add(-8 * time.Hour).Unix(),
 				CreatedAt:      now.Add(-8 * time.Hour).Unix(),
 			},
 			{
-				UUID:           "2",
+				UUID:           "00000000-0000-0000-0000-000000000002",
 				StartTimestamp: now.Add(-7 * time.Hour).Unix(),
 				CreatedAt:      now.Add(-7 * time.Hour).Unix(),
 			},
 			{
-				UUID:           "3",
+				UUID:           "00000000-0000-0000-0000-000000000003",
 				StartTimestamp: now.Add(-4 * time.Hour).Unix(),
 				CreatedAt:      now.Add(-4 * time.Hour).Unix(),
 			},
@@ -261,7 +359,7 @@ func TestSync(t *testing.T) {
 			_, err := tt.db.Exec(`
 				INSERT INTO interval_start(uuid, start_timestamp, created_at)
 				VALUES (?, ?, ?)`,
-				fmt.Sprintf("%d", idx+1),
+				fmt.Sprintf("00000000-0000-0000-0000-%012d", idx+1),
 				r.StartTimestamp,
 				r.CreatedAt)
 			require.NoError(t, err)
@@ -269,20 +367,20 @@ func TestSync(t *testing.T) {
 
 		for _, r := range []intervalStopRow{
 			{
-				UUID:          "4",
-				StartUUID:     "1",
+				UUID:          "00000000-0000-0000-0000-000000000004",
+				StartUUID:     "00000000-0000-0000-0000-000000000001",
 				StopTimestamp: now.Add(-7 * time.Hour).Unix(),
 				CreatedAt:     now.Add(-7 * time.Hour).Unix(),
 			},
 			{
-				UUID:          "5",
-				StartUUID:     "2",
+				UUID:          "00000000-0000-0000-0000-000000000005",
+				StartUUID:     "00000000-0000-0000-0000-000000000002",
 				StopTimestamp: now.Add(-5 * time.Hour).Unix(),
 				CreatedAt:     now.Add(-5 * time.Hour).Unix(),
 			},
 			{
-				UUID:          "6",
-				StartUUID:     "3",
+				UUID:          "00000000-0000-0000-0000-000000000006",
+				StartUUID:     "00000000-0000-0000-0000-000000000003",
 				StopTimestamp: now.Add(-3 * time.Hour).Unix(),
 				CreatedAt:     now.Add(-3 * time.Hour).Unix(),
 			},
@@ -305,20 +403,20 @@ func TestSync(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, []intervalStopRow{
 			{
-				UUID:          "4",
-				StartUUID:     "1",
+				UUID:          "00000000-0000-0000-0000-000000000004",
+				StartUUID:     "00000000-0000-0000-0000-000000000001",
 				StopTimestamp: now.Add(-7 * time.Hour).Unix(),
 				CreatedAt:     now.Add(-7 * time.Hour).Unix(),
 			},
 			{
-				UUID:          "5",
-				StartUUID:     "2",
+				UUID:          "00000000-0000-0000-0000-000000000005",
+				StartUUID:     "00000000-0000-0000-0000-000000000002",
 				StopTimestamp: now.Add(-5 * time.Hour).Unix(),
 				CreatedAt:     now.Add(-5 * time.Hour).Unix(),
 			},
 			{
-				UUID:          "6",
-				StartUUID:     "3",
+				UUID:          "00000000-0000-0000-0000-000000000006",
+				StartUUID:     "00000000-0000-0000-0000-000000000003",
 				StopTimestamp: now.Add(-3 * time.Hour).Unix(),
 				CreatedAt:     now.Add(-3 * time.Hour).Unix(),
 			},
@@ -339,17 +437,17 @@ func TestSync(t *testing.T) {
 
 		for idx, r := range []intervalStartRow{
 			{
-				UUID:           "1",
+				UUID:           "00000000-0000-0000-0000-000000000001",
 				StartTimestamp: now.Add(-8 * time.Hour).Unix(),
 				CreatedAt:      now.Add(-8 * time.Hour).Unix(),
 			},
 			{
-				UUID:           "2",
+				UUID:           "00000000-0000-0000-0000-000000000002",
 				StartTimestamp: now.Add(-7 * time.Hour).Unix(),
 				CreatedAt:      now.Add(-7 * time.Hour).Unix(),
 			},
 			{
-				UUID:           "3",
+				UUID:           "00000000-0000-0000-0000-000000000003",
 				StartTimestamp: now.Add(-4 * time.Hour).Unix(),
 				CreatedAt:      now.Add(-4 * time.Hour).Unix(),
 			},
@@ -357,7 +455,7 @@ func TestSync(t *testing.T) {
 			_, err := tt.db.Exec(`
 				INSERT INTO interval_start(uuid, start_timestamp, created_at)
 				VALUES (?, ?, ?)`,
-				fmt.Sprintf("%d", idx+1),
+				fmt.Sprintf("00000000-0000-0000-0000-%012d", idx+1),
 				r.StartTimestamp,
 				r.CreatedAt)
 			require.NoError(t, err)
@@ -365,20 +463,20 @@ func TestSync(t *testing.T) {
 
 		for _, r := range []intervalStopRow{
 			{
-				UUID:          "4",
-				StartUUID:     "1",
+				UUID:          "00000000-0000-0000-0000-000000000004",
+				StartUUID:     "00000000-0000-0000-0000-000000000001",
 				StopTimestamp: now.Add(-7 * time.Hour).Unix(),
 				CreatedAt:     now.Add(-7 * time.Hour).Unix(),
 			},
 			{
-				UUID:          "5",
-				StartUUID:     "2",
+				UUID:          "00000000-0000-0000-0000-000000000005",
+				StartUUID:     "00000000-0000-0000-0000-000000000002",
 				StopTimestamp: now.Add(-5 * time.Hour).Unix(),
 				CreatedAt:     now.Add(-5 * time.Hour).Unix(),
 			},
 			{
-				UUID:          "6",
-				StartUUID:     "3",
+				UUID:          "00000000-0000-0000-0000-000000000006",
+				StartUUID:     "00000000-0000-0000-0000-000000000003",
 				StopTimestamp: now.Add(-3 * time.Hour).Unix(),
 				CreatedAt:     now.Add(-3 * time.Hour).Unix(),
 			},
@@ -401,14 +499,14 @@ func TestSync(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, []intervalStopRow{
 			{
-				UUID:          "5",
-				StartUUID:     "2",
+				UUID:          "00000000-0000-0000-0000-000000000005",
+				StartUUID:     "00000000-0000-0000-0000-000000000002",
 				StopTimestamp: now.Add(-5 * time.Hour).Unix(),
 				CreatedAt:     now.Add(-5 * time.Hour).Unix(),
 			},
 			{
-				UUID:          "6",
-				StartUUID:     "3",
+				UUID:          "00000000-0000-0000-0000-000000000006",
+				StartUUID:     "00000000-0000-0000-0000-000000000003",
 				StopTimestamp: now.Add(-3 * time.Hour).Unix(),
 				CreatedAt:     now.Add(-3 * time.Hour).Unix(),
 			},
@@ -423,7 +521,7 @@ func TestSync(t *testing.T) {
 
 		for _, r := range []intervalStartRow{
 			{
-				UUID:           "1",
+				UUID:           "00000000-0000-0000-0000-000000000001",
 				StartTimestamp: now.Add(-6 * time.Hour).Unix(),
 				CreatedAt:      now.Add(-3 * time.Hour).Unix(),
 			},
@@ -439,8 +537,8 @@ func TestSync(t *testing.T) {
 
 		for _, r := range []intervalTombstoneRow{
 			{
-				UUID:      "10",
-				StartUUID: "1",
+				UUID:      "00000000-0000-0000-0000-000000000010",
+				StartUUID: "00000000-0000-0000-0000-000000000001",
 				CreatedAt: now.Unix(),
 			},
 		} {
@@ -461,8 +559,8 @@ func TestSync(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, []intervalTombstoneRow{
 			{
-				UUID:      "10",
-				StartUUID: "1",
+				UUID:      "00000000-0000-0000-0000-000000000010",
+				StartUUID: "00000000-0000-0000-0000-000000000001",
 				CreatedAt: now.Unix(),
 			},
 		}, ir)
@@ -484,17 +582,17 @@ func TestSync(t *testing.T) {
 
 		for _, r := range []intervalStartRow{
 			{
-				UUID:           "1",
+				UUID:           "00000000-0000-0000-0000-000000000001",
 				StartTimestamp: now.Add(-8 * time.Hour).Unix(),
 				CreatedAt:      now.Add(-8 * time.Hour).Unix(),
 			},
 			{
-				UUID:           "2",
+				UUID:           "00000000-0000-0000-0000-000000000002",
 				StartTimestamp: now.Add(-7 * time.Hour).Unix(),
 				CreatedAt:      now.Add(-7 * time.Hour).Unix(),
 			},
 			{
-				UUID:           "3",
+				UUID:           "00000000-0000-0000-0000-000000000003",
 				StartTimestamp: now.Add(-4 * time.Hour).Unix(),
 				CreatedAt:      now.Add(-4 * time.Hour).Unix(),
 			},
@@ -510,18 +608,18 @@ func TestSync(t *testing.T) {
 
 		for _, r := range []intervalTombstoneRow{
 			{
-				UUID:      "10",
-				StartUUID: "1",
+				UUID:      "00000000-0000-0000-0000-000000000010",
+				StartUUID: "00000000-0000-0000-0000-000000000001",
 				CreatedAt: now.Add(-7 * time.Hour).Unix(),
 			},
 			{
-				UUID:      "11",
-				StartUUID: "2",
+				UUID:      "00000000-0000-0000-0000-000000000011",
+				StartUUID: "00000000-0000-0000-0000-000000000002",
 				CreatedAt: now.Add(-5 * time.Hour).Unix(),
 			},
 			{
-				UUID:      "12",
-				StartUUID: "3",
+				UUID:      "00000000-0000-0000-0000-000000000012",
+				StartUUID: "00000000-0000-0000-0000-000000000003",
 				CreatedAt: now.Add(-3 * time.Hour).Unix(),
 			},
 		} {
@@ -542,13 +640,13 @@ func TestSync(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, []intervalTombstoneRow{
 			{
-				UUID:      "11",
-				StartUUID: "2",
+				UUID:      "00000000-0000-0000-0000-000000000011",
+				StartUUID: "00000000-0000-0000-0000-000000000002",
 				CreatedAt: now.Add(-5 * time.Hour).Unix(),
 			},
 			{
-				UUID:      "12",
-				StartUUID: "3",
+				UUID:      "00000000-0000-0000-0000-000000000012",
+				StartUUID: "00000000-0000-0000-0000-000000000003",
 				CreatedAt: now.Add(-3 * time.Hour).Unix(),
 			},
 		}, ir)
@@ -568,7 +666,7 @@ func TestSync(t *testing.T) {
 
 		for _, ir := range []intervalStartRow{
 			{
-				UUID:           "1",
+				UUID:           "00000000-0000-0000-0000-000000000001",
 				StartTimestamp: now.Add(-24 * time.Hour).Unix(),
 				CreatedAt:      now.Add(-24 * time.Hour).Unix(),
 			},
@@ -584,27 +682,27 @@ func TestSync(t *testing.T) {
 
 		for _, data := range []intervalTagsRow{
 			{
-				UUID:      "10",
-				StartUUID: "1",
+				UUID:      "00000000-0000-0000-0000-000000000010",
+				StartUUID: "00000000-0000-0000-0000-000000000001",
 				Tag:       "a",
 				CreatedAt: now.Add(-24 * time.Hour).Unix(),
 			},
 			{
-				UUID:      "11",
-				StartUUID: "1",
+				UUID:      "00000000-0000-0000-0000-000000000011",
+				StartUUID: "00000000-0000-0000-0000-000000000001",
 				Tag:       "b",
 				CreatedAt: now.Add(-23 * time.Hour).Unix(),
 			},
 			{
-				UUID:      "12",
-				StartUUID: "1",
+				UUID:      "00000000-0000-0000-0000-000000000012",
+				StartUUID: "00000000-0000-0000-0000-000000000001",
 				Tag:       "c",
 				CreatedAt: now.Add(-22 * time.Hour).Unix(),
 			},
 		} {
 			_, err := tt.db.Exec(`
-				INSERT INTO interval_tags (uuid, interval_start_uuid, tag, created_at)
-				VALUES (?, ?, ?, ?)`,
+				INSERT INTO interval_tags (uuid, interval_start_uuid, tag_id, created_at)
+				VALUES (?, ?, (SELECT id FROM tags WHERE name = ?), ?)`,
 				data.UUID, data.StartUUID, data.Tag, data.CreatedAt)
 			require.NoError(t, err)
 		}
@@ -617,20 +715,20 @@ func TestSync(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, []intervalTagsRow{
 			{
-				UUID:      "10",
-				StartUUID: "1",
+				UUID:      "00000000-0000-0000-0000-000000000010",
+				StartUUID: "00000000-0000-0000-0000-000000000001",
 				Tag:       "a",
 				CreatedAt: now.Add(-24 * time.Hour).Unix(),
 			},
 			{
-				UUID:      "11",
-				StartUUID: "1",
+				UUID:      "00000000-0000-0000-0000-000000000011",
+				StartUUID: "00000000-0000-0000-0000-000000000001",
 				Tag:       "b",
 				CreatedAt: now.Add(-23 * time.Hour).Unix(),
 			},
 			{
-				UUID:      "12",
-				StartUUID: "1",
+				UUID:      "00000000-0000-0000-0000-000000000012",
+				StartUUID: "00000000-0000-0000-0000-000000000001",
 				Tag:       "c",
 				CreatedAt: now.Add(-22 * time.Hour).Unix(),
 			},
@@ -659,12 +757,12 @@ func TestSync(t *testing.T) {
 
 		for _, ir := range []intervalStartRow{
 			{
-				UUID:           "1",
+				UUID:           "00000000-0000-0000-0000-000000000001",
 				StartTimestamp: now.Add(-24 * time.Hour).Unix(),
 				CreatedAt:      now.Add(-24 * time.Hour).Unix(),
 			},
 			{
-				UUID:           "2",
+				UUID:           "00000000-0000-0000-0000-000000000002",
 				StartTimestamp: now.Add(-22 * time.Hour).Unix(),
 				CreatedAt:      now.Add(-22 * time.Hour).Unix(),
 			},
@@ -680,33 +778,33 @@ func TestSync(t *testing.T) {
 
 		for _, data := range []intervalTagsRow{
 			{
-				UUID:      "10",
-				StartUUID: "1",
+				UUID:      "00000000-0000-0000-0000-000000000010",
+				StartUUID: "00000000-0000-0000-0000-000000000001",
 				Tag:       "a",
 				CreatedAt: now.Add(-24 * time.Hour).Unix(),
 			},
 			{
-				UUID:      "11",
-				StartUUID: "1",
+				UUID:      "00000000-0000-0000-0000-000000000011",
+				StartUUID: "00000000-0000-0000-0000-000000000001",
 				Tag:       "b",
 				CreatedAt: now.Add(-24 * time.Hour).Unix(),
 			},
 			{
-				UUID:      "12",
-				StartUUID: "1",
+				UUID:      "00000000-0000-0000-0000-000000000012",
+				StartUUID: "00000000-0000-0000-0000-000000000001",
 				Tag:       "c",
 				CreatedAt: now.Add(-4 * time.Hour).Unix(),
 			},
 			{
-				UUID:      "13",
-				StartUUID: "2",
+				UUID:      "00000000-0000-0000-0000-000000000013",
+				StartUUID: "00000000-0000-0000-0000-000000000002",
 				Tag:       "a",
 				CreatedAt: now.Add(-3 * time.Hour).Unix(),
 			},
 		} {
 			_, err := tt.db.Exec(`
-					INSERT INTO interval_tags (uuid, interval_start_uuid, tag, created_at)
-					VALUES (?, ?, ?, ?)`,
+					INSERT INTO interval_tags (uuid, interval_start_uuid, tag_id, created_at)
+					VALUES (?, ?, (SELECT id FROM tags WHERE name = ?), ?)`,
 				data.UUID, data.StartUUID, data.Tag, data.CreatedAt)
 			require.NoError(t, err)
 		}
@@ -719,14 +817,14 @@ func TestSync(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, []intervalTagsRow{
 			{
-				UUID:      "12",
-				StartUUID: "1",
+				UUID:      "00000000-0000-0000-0000-000000000012",
+				StartUUID: "00000000-0000-0000-0000-000000000001",
 				Tag:       "c",
 				CreatedAt: now.Add(-4 * time.Hour).Unix(),
 			},
 			{
-				UUID:      "13",
-				StartUUID: "2",
+				UUID:      "00000000-0000-0000-0000-000000000013",
+				StartUUID: "00000000-0000-0000-0000-000000000002",
 				Tag:       "a",
 				CreatedAt: now.Add(-3 * time.Hour).Unix(),
 			},
@@ -746,7 +844,7 @@ func TestSync(t *testing.T) {
 
 		for _, ir := range []intervalStartRow{
 			{
-				UUID:           "1",
+				UUID:           "00000000-0000-0000-0000-000000000001",
 				StartTimestamp: now.Add(-24 * time.Hour).Unix(),
 				CreatedAt:      now.Add(-24 * time.Hour).Unix(),
 			},
@@ -762,34 +860,34 @@ func TestSync(t *testing.T) {
 
 		for _, data := range []intervalTagsRow{
 			{
-				UUID:      "10",
-				StartUUID: "1",
+				UUID:      "00000000-0000-0000-0000-000000000010",
+				StartUUID: "00000000-0000-0000-0000-000000000001",
 				Tag:       "a",
 				CreatedAt: now.Add(-24 * time.Hour).Unix(),
 			},
 			{
-				UUID:      "11",
-				StartUUID: "1",
+				UUID:      "00000000-0000-0000-0000-000000000011",
+				StartUUID: "00000000-0000-0000-0000-000000000001",
 				Tag:       "b",
 				CreatedAt: now.Add(-23 * time.Hour).Unix(),
 			},
 		} {
 			_, err := tt.db.Exec(`
-				INSERT INTO interval_tags (uuid, interval_start_uuid, tag, created_at)
-				VALUES (?, ?, ?, ?)`,
+				INSERT INTO interval_tags (uuid, interval_start_uuid, tag_id, created_at)
+				VALUES (?, ?, (SELECT id FROM tags WHERE name = ?), ?)`,
 				data.UUID, data.StartUUID, data.Tag, data.CreatedAt)
 			require.NoError(t, err)
 		}
 
 		for _, data := range []intervalTagsTombstoneRow{
 			{
-				UUID:            "100",
-				IntervalTagUUID: "10",
+				UUID:            "00000000-0000-0000-0000-000000000100",
+				IntervalTagUUID: "00000000-0000-0000-0000-000000000010",
 				CreatedAt:       now.Add(-1 * time.Hour).Unix(),
 			},
 			{
-				UUID:            "101",
-				IntervalTagUUID: "11",
+				UUID:            "00000000-0000-0000-0000-000000000101",
+				IntervalTagUUID: "00000000-0000-0000-0000-000000000011",
 				CreatedAt:       now.Add(-1 * time.Minute).Unix(),
 			},
 		} {
@@ -808,13 +906,13 @@ func TestSync(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, []intervalTagsTombstoneRow{
 			{
-				UUID:            "100",
-				IntervalTagUUID: "10",
+				UUID:            "00000000-0000-0000-0000-000000000100",
+				IntervalTagUUID: "00000000-0000-0000-0000-000000000010",
 				CreatedAt:       now.Add(-1 * time.Hour).Unix(),
 			},
 			{
-				UUID:            "101",
-				IntervalTagUUID: "11",
+				UUID:            "00000000-0000-0000-0000-000000000101",
+				IntervalTagUUID: "00000000-0000-0000-0000-000000000011",
 				CreatedAt:       now.Add(-1 * time.Minute).Unix(),
 			},
 		}, data)
@@ -842,12 +940,12 @@ func TestSync(t *testing.T) {
 
 		for _, ir := range []intervalStartRow{
 			{
-				UUID:           "1",
+				UUID:           "00000000-0000-0000-0000-000000000001",
 				StartTimestamp: now.Add(-24 * time.Hour).Unix(),
 				CreatedAt:      now.Add(-24 * time.Hour).Unix(),
 			},
 			{
-				UUID:           "2",
+				UUID:           "00000000-0000-0000-0000-000000000002",
 				StartTimestamp: now.Add(-23 * time.Hour).Unix(),
 				CreatedAt:      now.Add(-23 * time.Hour).Unix(),
 			},
@@ -863,8 +961,8 @@ func TestSync(t *testing.T) {
 
 		for _, ir := range []intervalStopRow{
 			{
-				UUID:          "11",
-				StartUUID:     "1",
+				UUID:          "00000000-0000-0000-0000-000000000011",
+				StartUUID:     "00000000-0000-0000-0000-000000000001",
 				StopTimestamp: now.Add(-23 * time.Hour).Unix(),
 				CreatedAt:     now.Add(-23 * time.Hour).Unix(),
 			},
@@ -881,21 +979,21 @@ func TestSync(t *testing.T) {
 
 		for _, ir := range []intervalTagsRow{
 			{
-				UUID:      "101",
-				StartUUID: "1",
+				UUID:      "00000000-0000-0000-0000-000000000101",
+				StartUUID: "00000000-0000-0000-0000-000000000001",
 				Tag:       "a",
 				CreatedAt: now.Add(-24 * time.Hour).Unix(),
 			},
 			{
-				UUID:      "102",
-				StartUUID: "2",
+				UUID:      "00000000-0000-0000-0000-000000000102",
+				StartUUID: "00000000-0000-0000-0000-000000000002",
 				Tag:       "b",
 				CreatedAt: now.Add(-23 * time.Hour).Unix(),
 			},
 		} {
 			_, err := tt.db.Exec(`
-				INSERT INTO interval_tags (uuid, interval_start_uuid, tag, created_at)
-				VALUES (?, ?, ?, ?)`,
+				INSERT INTO interval_tags (uuid, interval_start_uuid, tag_id, created_at)
+				VALUES (?, ?, (SELECT id FROM tags WHERE name = ?), ?)`,
 				ir.UUID,
 				ir.StartUUID,
 				ir.Tag,
@@ -905,13 +1003,13 @@ func TestSync(t *testing.T) {
 
 		for _, ir := range []intervalTagsTombstoneRow{
 			{
-				UUID:            "1001",
-				IntervalTagUUID: "101",
+				UUID:            "00000000-0000-0000-0000-000000001001",
+				IntervalTagUUID: "00000000-0000-0000-0000-000000000101",
 				CreatedAt:       now.Add(-4 * time.Hour).Unix(),
 			},
 			{
-				UUID:            "1002",
-				IntervalTagUUID: "102",
+				UUID:            "00000000-0000-0000-0000-000000001002",
+				IntervalTagUUID: "00000000-0000-0000-0000-000000000102",
 				CreatedAt:       now.Add(-24 * 2 * time.Hour).Unix(),
 			},
 		} {
@@ -932,8 +1030,8 @@ func TestSync(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, []intervalTagsTombstoneRow{
 			{
-				UUID:            "1001",
-				IntervalTagUUID: "101",
+				UUID:            "00000000-0000-0000-0000-000000001001",
+				IntervalTagUUID: "00000000-0000-0000-0000-000000000101",
 				CreatedAt:       now.Add(-4 * time.Hour).Unix(),
 			},
 		}, data)
@@ -978,6 +1076,46 @@ func TestSync(t *testing.T) {
 	})
 }
 
+func TestSyncConcurrent(t *testing.T) {
+	syncCfg := startPostgres(t)
+	now := time.Now()
+
+	trackers := []*TimeTracker{setupTT(t), setupTT(t)}
+	for idx, tt := range trackers {
+		for i := 0; i < 50; i++ {
+			start := now.Add(-time.Duration(100*idx+2*i+2) * time.Hour)
+			require.NoError(t, tt.Start(start, []string{"shared", fmt.Sprintf("tag%d", i)}))
+			require.NoError(t, tt.StopAt(start.Add(time.Hour)))
+		}
+	}
+
+	errs := make(chan error, len(trackers))
+	for _, tt := range trackers {
+		go func(tt *TimeTracker) { errs <- tt.Sync(syncCfg) }(tt)
+	}
+	for range trackers {
+		require.NoError(t, <-errs)
+	}
+
+	// workaround for the timestamp primary key in the sync_history table
+	time.Sleep(time.Second)
+	for _, tt := range trackers {
+		require.NoError(t, tt.Sync(syncCfg))
+	}
+
+	var lists [][]TaggedInterval
+	for _, tt := range trackers {
+		itv, err := tt.List(now.Add(-300*time.Hour), now)
+		require.NoError(t, err)
+		require.Len(t, itv, 100)
+		for idx := range itv {
+			itv[idx].Interval.ID = ""
+		}
+		lists = append(lists, itv)
+	}
+	require.Equal(t, lists[0], lists[1])
+}
+
 func jsonMarshal(t *testing.T, input any) []byte {
 	t.Helper()
 	payload, err := json.Marshal(input)
@@ -1003,13 +1141,8 @@ func TestSyncQuick(t *testing.T) {
 	tt1.now = getNow
 	tt2.now = getNow
 
-	type iteration struct {
-		Operation string
-		DBIndex   uint
-		Timestamp time.Time
-	}
-
-	iterRecords := []iteration{}
+	// iterRecords is logged on failure, it can be replayed with tt debug replay
+	iterRecords := []ReplayStep{}
 
 	equalFunc := func(t *testing.T, lhs []TaggedInterval, rhs []TaggedInterval) {
 		t.Helper()
@@ -1042,7 +1175,7 @@ func TestSyncQuick(t *testing.T) {
 
 		now = now.Add(time.Duration(timeOffset) * time.Second)
 
-		iterRecords = append(iterRecords, iteration{
+		iterRecords = append(iterRecords, ReplayStep{
 			Operation: operations[opIndex],
 			DBIndex:   dbIndex,
 			Timestamp: now,
@@ -1133,3 +1266,539 @@ func TestSyncQuick(t *testing.T) {
 
 	t.Log("iteration run", i)
 }
+
+func TestSetLastSync(t *testing.T) {
+	lastSync := func(t *testing.T, tt *TimeTracker) time.Time {
+		t.Helper()
+		tx, err := tt.db.Beginx()
+		require.NoError(t, err)
+		defer commit(t, tx)
+		ts, err := getLastSyncTimestamp(tx)
+		require.NoError(t, err)
+		return ts
+	}
+
+	t.Run("set", func(t *testing.T) {
+		tt := setupTT(t)
+		now := time.Now().Truncate(time.Second)
+
+		_, err := tt.db.Exec(`
+			INSERT INTO sync_history (sync_timestamp)
+			VALUES (?), (?)`, now.Add(-3*time.Hour).Unix(), now.Add(-time.Hour).Unix())
+		require.NoError(t, err)
+
+		err = tt.SetLastSync(now.Add(-2 * time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, now.Add(-2*time.Hour), lastSync(t, tt))
+
+		err = tt.SetLastSync(now.Add(-30 * time.Minute))
+		require.NoError(t, err)
+		require.Equal(t, now.Add(-30*time.Minute), lastSync(t, tt))
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		tt := setupTT(t)
+		now := time.Now().Truncate(time.Second)
+
+		_, err := tt.db.Exec(`
+			INSERT INTO sync_history (sync_timestamp)
+			VALUES (?), (?)`, now.Add(-3*time.Hour).Unix(), now.Add(-time.Hour).Unix())
+		require.NoError(t, err)
+
+		err = tt.SetLastSync(time.Time{})
+		require.NoError(t, err)
+		require.True(t, lastSync(t, tt).IsZero())
+	})
+
+	t.Run("future timestamp rejected", func(t *testing.T) {
+		tt := setupTT(t)
+		now := time.Now().Truncate(time.Second)
+		tt.now = func() time.Time { return now }
+
+		_, err := tt.db.Exec(
+			`INSERT INTO sync_history (sync_timestamp) VALUES (?)`, now.Add(-time.Hour).Unix())
+		require.NoError(t, err)
+
+		err = tt.SetLastSync(now.Add(time.Minute))
+		require.ErrorIs(t, err, ErrInvalidParam)
+		require.Equal(t, now.Add(-time.Hour), lastSync(t, tt))
+	})
+}
+
+func TestSyncBatchedInsert(t *testing.T) {
+	tt1 := setupTT(t)
+	tt2 := setupTT(t)
+
+	start := time.Date(2022, 2, 25, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 300; i++ {
+		itvStart := start.Add(time.Duration(i) * time.Hour)
+		require.NoError(t, tt1.Start(itvStart, []string{"tag", fmt.Sprintf("tag%d", i%7)}))
+		require.NoError(t, tt1.StopAt(itvStart.Add(30*time.Minute)))
+	}
+	for i := 1; i <= 300; i += 10 {
+		require.NoError(t, tt1.Delete(strconv.Itoa(i)))
+		require.NoError(t, tt1.Untag(strconv.Itoa(i+1), []string{"tag"}))
+	}
+
+	syncTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	synchronise := func() {
+		tx1, err := tt1.db.Beginx()
+		require.NoError(t, err)
+		defer commit(t, tx1)
+		tx2, err := tt2.db.Beginx()
+		require.NoError(t, err)
+		defer commit(t, tx2)
+
+		err = funk.CallAbortOnError(
+			func() error { return synchroniseTags(tx1, tx2, syncTime, MergeUnion) },
+			func() error { return synchroniseIntervalStart(tx1, tx2, syncTime, MergeUnion) },
+			func() error { return synchroniseIntervalStop(tx1, tx2, syncTime, MergeUnion) },
+			func() error { return synchroniseIntervalTombstone(tx1, tx2, syncTime, MergeUnion) },
+			func() error { return synchroniseIntervalTags(tx1, tx2, syncTime, MergeUnion) },
+			func() error { return synchroniseIntervalTagsTombstone(tx1, tx2, syncTime, MergeUnion) },
+		)
+		require.NoError(t, err)
+	}
+
+	// Synchronising twice checks conflicting rows are ignored
+	synchronise()
+	synchronise()
+
+	itv1, err := tt1.List(start, start.Add(400*time.Hour))
+	require.NoError(t, err)
+	itv2, err := tt2.List(start, start.Add(400*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, itv1, 270)
+	for idx := range itv1 {
+		itv1[idx].ID = ""
+		itv2[idx].ID = ""
+	}
+	require.Equal(t, itv1, itv2)
+	require.Equal(t, time.UTC, itv2[0].Location)
+
+	for _, table := range []string{
+		"tags",
+		"interval_start",
+		"interval_stop",
+		"interval_tombstone",
+		"interval_tags",
+		"interval_tags_tombstone",
+	} {
+		var count1, count2, countSyncTime int
+		require.NoError(t, tt1.db.Get(&count1, `SELECT count(1) FROM `+table))
+		require.NoError(t, tt2.db.Get(&count2, `SELECT count(1) FROM `+table))
+		require.NoError(t, tt2.db.Get(&countSyncTime,
+			`SELECT count(1) FROM `+table+` WHERE created_at = ?`, syncTime.Unix()))
+		require.Equal(t, count1, count2, table)
+		require.Equal(t, count2, countSyncTime, table)
+	}
+}
+
+func TestSyncMergeStrategy(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 2, 25, hour, 0, 0, 0, time.UTC)
+	}
+	synchronise := func(t *testing.T, tt1, tt2 *TimeTracker, strategy MergeStrategy) {
+		syncTime := time.Now()
+		tx1, err := tt1.db.Beginx()
+		require.NoError(t, err)
+		defer commit(t, tx1)
+		tx2, err := tt2.db.Beginx()
+		require.NoError(t, err)
+		defer commit(t, tx2)
+
+		require.NoError(t, funk.CallAbortOnError(
+			func() error { return synchroniseTags(tx1, tx2, syncTime, strategy) },
+			func() error { return synchroniseIntervalStart(tx1, tx2, syncTime, strategy) },
+			func() error { return synchroniseIntervalStop(tx1, tx2, syncTime, strategy) },
+			func() error { return synchroniseIntervalTags(tx1, tx2, syncTime, strategy) },
+		))
+	}
+	stop := func(t *testing.T, tt *TimeTracker) time.Time {
+		itv, err := tt.At(at(10))
+		require.NoError(t, err)
+		return itv.StopTimestamp.UTC()
+	}
+
+	for _, tc := range []struct {
+		strategy MergeStrategy
+		local    time.Time
+		remote   time.Time
+	}{
+		{strategy: MergeUnion, local: at(12), remote: at(11)},
+		{strategy: MergeLocalWins, local: at(12), remote: at(12)},
+		{strategy: MergeRemoteWins, local: at(11), remote: at(11)},
+	} {
+		t.Run(tc.strategy.String(), func(t *testing.T) {
+			tt1 := setupTT(t)
+			tt2 := setupTT(t)
+			require.NoError(t, tt1.Start(at(10), []string{"a"}))
+			require.NoError(t, tt1.StopAt(at(12)))
+			synchronise(t, tt1, tt2, MergeUnion)
+
+			// Craft a divergence which can't happen through the API
+			_, err := tt2.db.Exec(`UPDATE interval_stop SET stop_timestamp = ?`, at(11).Unix())
+			require.NoError(t, err)
+
+			synchronise(t, tt1, tt2, tc.strategy)
+			require.Equal(t, tc.local, stop(t, tt1))
+			require.Equal(t, tc.remote, stop(t, tt2))
+
+			for _, tt := range []*TimeTracker{tt1, tt2} {
+				var count int
+				require.NoError(t, tt.db.Get(&count, `SELECT count(1) FROM interval_stop`))
+				require.Equal(t, 1, count)
+			}
+		})
+	}
+}
+
+func TestSyncTagIDs(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 2, 25, hour, 0, 0, 0, time.UTC)
+	}
+
+	tt1 := setupTT(t)
+	tt2 := setupTT(t)
+
+	synchronise := func(t *testing.T) {
+		syncTime := time.Now()
+		tx1, err := tt1.db.Beginx()
+		require.NoError(t, err)
+		defer commit(t, tx1)
+		tx2, err := tt2.db.Beginx()
+		require.NoError(t, err)
+		defer commit(t, tx2)
+
+		require.NoError(t, funk.CallAbortOnError(
+			func() error { return synchroniseTags(tx1, tx2, syncTime, MergeUnion) },
+			func() error { return synchroniseIntervalStart(tx1, tx2, syncTime, MergeUnion) },
+			func() error { return synchroniseIntervalStop(tx1, tx2, syncTime, MergeUnion) },
+			func() error { return synchroniseIntervalTags(tx1, tx2, syncTime, MergeUnion) },
+			func() error { return synchroniseIntervalTagsTombstone(tx1, tx2, syncTime, MergeUnion) },
+		))
+	}
+	tags := func(t *testing.T, tt *TimeTracker) [][]string {
+		intervals, err := tt.List(at(0), at(23))
+		require.NoError(t, err)
+		return funk.Map(intervals, func(_ int, itv TaggedInterval) []string { return itv.Tags })
+	}
+
+	// The same tags get different ids in both databases
+	require.NoError(t, tt1.Start(at(10), []string{"a", "b"}))
+	require.NoError(t, tt1.StopAt(at(11)))
+	require.NoError(t, tt2.Start(at(12), []string{"c", "b"}))
+	require.NoError(t, tt2.StopAt(at(13)))
+
+	synchronise(t)
+	require.Equal(t, [][]string{{"a", "b"}, {"c", "b"}}, tags(t, tt1))
+	require.Equal(t, [][]string{{"a", "b"}, {"c", "b"}}, tags(t, tt2))
+
+	require.NoError(t, tt1.Untag("2", []string{"b"}))
+	synchronise(t)
+	require.Equal(t, [][]string{{"a", "b"}, {"c"}}, tags(t, tt2))
+}
+
+func TestSyncWithSqlite(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 2, 25, hour, 0, 0, 0, time.UTC)
+	}
+
+	tt1 := setupTT(t, filepath.Join(t.TempDir(), "tt1.db"))
+	tt2 := setupTT(t, filepath.Join(t.TempDir(), "tt2.db"))
+	remote := setupTT(t, filepath.Join(t.TempDir(), "remote.db"))
+
+	tt1.SetPlace("Lyon")
+	require.NoError(t, tt1.Start(at(10), []string{"a", "b"}))
+	require.NoError(t, tt1.StopAt(at(11)))
+	require.NoError(t, tt2.Start(at(12), []string{"c"}))
+	require.NoError(t, tt2.StopAt(at(13)))
+	require.NoError(t, tt2.Start(at(13), []string{"d"}))
+	require.NoError(t, tt2.StopAt(at(14)))
+	require.NoError(t, tt2.Delete("2"))
+
+	// The remote handle is reused by every synchronisation
+	require.NoError(t, tt1.SyncWith(remote.db, MergeUnion))
+	require.NoError(t, tt2.SyncWith(remote.db, MergeUnion))
+	// A single synchronisation per second is recorded
+	tt1.now = func() time.Time { return time.Now().Add(time.Second) }
+	require.NoError(t, tt1.SyncWith(remote.db, MergeUnion))
+
+	list := func(tt *TimeTracker) []TaggedInterval {
+		itvs, err := tt.List(at(0), at(23))
+		require.NoError(t, err)
+		for idx := range itvs {
+			itvs[idx].ID = ""
+		}
+		return itvs
+	}
+	itvs := list(tt1)
+	require.Len(t, itvs, 2)
+	require.Equal(t, []string{"a", "b"}, itvs[0].Tags)
+	require.Equal(t, "Lyon", itvs[0].Place)
+	require.Equal(t, []string{"c"}, itvs[1].Tags)
+	require.Empty(t, itvs[1].Place)
+	require.Equal(t, itvs, list(tt2))
+	require.Equal(t, itvs, list(remote))
+
+	// Only the local databases record the synchronisations
+	var count int
+	require.NoError(t, remote.db.Get(&count, `SELECT count(1) FROM sync_history`))
+	require.Zero(t, count)
+}
+
+func TestSyncCreatedAt(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2022, 3, d, 12, 0, 0, 0, time.UTC)
+	}
+	clock := func(tt *TimeTracker, t time.Time) {
+		tt.now = func() time.Time { return t }
+	}
+
+	tt1 := setupTT(t, filepath.Join(t.TempDir(), "tt1.db"))
+	tt2 := setupTT(t, filepath.Join(t.TempDir(), "tt2.db"))
+	remote := setupTT(t, filepath.Join(t.TempDir(), "remote.db"))
+
+	clock(tt1, day(1))
+	require.NoError(t, tt1.Start(day(1), []string{"a"}))
+	require.NoError(t, tt1.StopAt(day(1).Add(time.Hour)))
+
+	// tt2 synchronises after the interval creation but before tt1 sends it
+	clock(tt2, day(2))
+	require.NoError(t, tt2.SyncWith(remote.db, MergeUnion))
+	clock(tt1, day(3))
+	require.NoError(t, tt1.SyncWith(remote.db, MergeUnion))
+	clock(tt2, day(4))
+	require.NoError(t, tt2.SyncWith(remote.db, MergeUnion))
+
+	var createdAt []int64
+	require.NoError(t, remote.db.Select(&createdAt, `
+		SELECT created_at FROM interval_start
+		UNION ALL SELECT created_at FROM interval_stop
+		UNION ALL SELECT created_at FROM interval_tags`))
+	require.Equal(t, []int64{day(3).Unix(), day(3).Unix(), day(3).Unix()}, createdAt)
+
+	itvs, err := tt2.List(day(1), day(2))
+	require.NoError(t, err)
+	require.Len(t, itvs, 1)
+	require.Equal(t, []string{"a"}, itvs[0].Tags)
+
+	var syncs []int64
+	require.NoError(t, tt2.db.Select(&syncs,
+		`SELECT sync_timestamp FROM sync_history ORDER BY sync_timestamp`))
+	require.Equal(t, []int64{day(2).Unix(), day(4).Unix()}, syncs)
+}
+
+func TestParseMergeStrategy(t *testing.T) {
+	for _, strategy := range []MergeStrategy{MergeUnion, MergeLocalWins, MergeRemoteWins} {
+		parsed, err := ParseMergeStrategy(strategy.String())
+		require.NoError(t, err)
+		require.Equal(t, strategy, parsed)
+	}
+	_, err := ParseMergeStrategy("newest-wins")
+	require.ErrorIs(t, err, ErrInvalidParam)
+}
+
+func BenchmarkInsertRows(b *testing.B) {
+	tt, err := New(":memory:")
+	require.NoError(b, err)
+	b.Cleanup(func() { require.NoError(b, tt.Close()) })
+
+	rows := make([][]any, 500)
+	for idx := range rows {
+		rows[idx] = []any{fmt.Sprintf("uuid-%d", idx), int64(idx), int64(idx)}
+	}
+	columns := []string{"uuid", "start_timestamp", "created_at"}
+
+	for _, bc := range []struct {
+		name   string
+		insert func(tx *sqlx.Tx) error
+	}{
+		{"per-row", func(tx *sqlx.Tx) error {
+			for _, row := range rows {
+				if err := insertRows(tx, "interval_start", columns, [][]any{row}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{"batched", func(tx *sqlx.Tx) error {
+			return insertRows(tx, "interval_start", columns, rows)
+		}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				tx, err := tt.db.Beginx()
+				require.NoError(b, err)
+				require.NoError(b, bc.insert(tx))
+				require.NoError(b, tx.Rollback())
+			}
+		})
+	}
+}
+
+func TestStoreRowsParametersLimit(t *testing.T) {
+	tt := setupTT(t)
+
+	// The bundled sqlite accepts more parameters than the default limit
+	conn, err := tt.db.Conn(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, conn.Raw(func(driverConn any) error {
+		driverConn.(*sqlite3.SQLiteConn).SetLimit(sqlite3.SQLITE_LIMIT_VARIABLE_NUMBER, maxQueryParameters)
+		return nil
+	}))
+	require.NoError(t, conn.Close())
+
+	columns := []string{"uuid", "start_timestamp", "created_at", "tz", "context", "location"}
+	rows := make([][]any, 3*maxQueryParameters/len(columns))
+	for idx := range rows {
+		uuid := fmt.Sprintf("00000000-0000-4000-8000-%012d", idx)
+		rows[idx] = []any{uuid, int64(idx), int64(idx), nil, "", nil}
+	}
+
+	for _, overwrite := range []bool{false, true} {
+		tx, err := tt.db.Beginx()
+		require.NoError(t, err)
+		err = storeRows(tx, overwrite, "interval_start", columns, rows)
+		if err != nil {
+			require.NoError(t, tx.Rollback())
+		}
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+	}
+
+	var count int
+	require.NoError(t, tt.db.Get(&count, `SELECT count(1) FROM interval_start`))
+	require.Equal(t, len(rows), count)
+}
+
+func TestSyncChunked(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2022, 3, d, 12, 0, 0, 0, time.UTC)
+	}
+	clock := func(tt *TimeTracker, t time.Time) {
+		tt.now = func() time.Time { return t }
+	}
+	list := func(t *testing.T, tt *TimeTracker) []TaggedInterval {
+		t.Helper()
+		itvs, err := tt.List(day(1), day(2))
+		require.NoError(t, err)
+		for idx := range itvs {
+			itvs[idx].ID = ""
+			itvs[idx].Location = nil
+		}
+		return itvs
+	}
+
+	tt1 := setupTT(t, filepath.Join(t.TempDir(), "tt1.db"))
+	tt2 := setupTT(t, filepath.Join(t.TempDir(), "tt2.db"))
+	remote := setupTT(t, filepath.Join(t.TempDir(), "remote.db"))
+
+	clock(tt1, day(1))
+	require.NoError(t, tt1.Start(day(1), []string{"a", "b"}))
+	require.NoError(t, tt1.StopAt(day(1).Add(time.Hour)))
+	require.NoError(t, tt1.Start(day(1).Add(2*time.Hour), []string{"c"}))
+	require.NoError(t, tt1.StopAt(day(1).Add(3*time.Hour)))
+	require.NoError(t, tt1.SetEstimate("2", time.Hour))
+
+	// The failure happens once the intervals have been committed
+	// and before their tags are
+	_, err := remote.db.Exec(`
+		CREATE TRIGGER fail_interval_tags BEFORE INSERT ON interval_tags
+		BEGIN
+			SELECT RAISE(ABORT, 'injected failure');
+		END`)
+	require.NoError(t, err)
+
+	clock(tt1, day(2))
+	require.ErrorContains(t, tt1.SyncChunkedWith(remote.db, MergeUnion), "injected failure")
+
+	count := func(t *testing.T, tt *TimeTracker, table string) int {
+		t.Helper()
+		var count int
+		require.NoError(t, tt.db.Get(&count, `SELECT count(1) FROM `+table))
+		return count
+	}
+	require.Equal(t, 2, count(t, remote, "interval_start"))
+	require.Equal(t, 2, count(t, remote, "interval_stop"))
+	require.Equal(t, 0, count(t, remote, "interval_tags"))
+	require.Equal(t, 0, count(t, remote, "interval_estimate"))
+	require.Equal(t, 0, count(t, tt1, "sync_history"))
+	require.NoError(t, NewSanity(remote.db).Check())
+
+	// Another client sees the stored intervals, without their tags yet
+	clock(tt2, day(3))
+	require.NoError(t, tt2.SyncChunkedWith(remote.db, MergeUnion))
+	itvs := list(t, tt2)
+	require.Len(t, itvs, 2)
+	require.Nil(t, itvs[0].Tags)
+
+	// The next synchronisation completes the failed one
+	_, err = remote.db.Exec(`DROP TRIGGER fail_interval_tags`)
+	require.NoError(t, err)
+	clock(tt1, day(4))
+	require.NoError(t, tt1.SyncChunkedWith(remote.db, MergeUnion))
+	require.Equal(t, 1, count(t, tt1, "sync_history"))
+	clock(tt2, day(5))
+	require.NoError(t, tt2.SyncChunkedWith(remote.db, MergeUnion))
+
+	expected := list(t, tt1)
+	require.Equal(t, []string{"a", "b"}, expected[0].Tags)
+	require.Equal(t, time.Hour, expected[1].Estimate)
+	require.Equal(t, expected, list(t, tt2))
+	require.Equal(t, 2, count(t, remote, "interval_start"))
+	require.Equal(t, 3, count(t, remote, "interval_tags"))
+}
+
+func TestSQLiteSyncer(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 2, 26, hour, 0, 0, 0, time.UTC)
+	}
+
+	tt1 := setupTT(t, filepath.Join(t.TempDir(), "tt1.db"))
+	tt2 := setupTT(t, filepath.Join(t.TempDir(), "tt2.db"))
+	// The central database file is created by the first synchronisation
+	central := filepath.Join(t.TempDir(), "central.db")
+
+	require.NoError(t, tt1.Start(at(10), []string{"a"}))
+	require.NoError(t, tt1.StopAt(at(11)))
+	require.NoError(t, tt2.Start(at(12), []string{"b"}))
+	require.NoError(t, tt2.StopAt(at(13)))
+
+	require.NoError(t, tt1.Sync(SQLiteSyncer{Path: central}))
+	require.NoError(t, tt2.Sync(SQLiteSyncer{
+		Path:        central,
+		SyncOptions: SyncOptions{Chunked: true},
+	}))
+	tt1.now = func() time.Time { return time.Now().Add(time.Second) }
+	require.NoError(t, tt1.Sync(SQLiteSyncer{Path: central}))
+
+	list := func(tt *TimeTracker) []TaggedInterval {
+		itvs, err := tt.List(at(0), at(23))
+		require.NoError(t, err)
+		for idx := range itvs {
+			itvs[idx].ID = ""
+		}
+		return itvs
+	}
+	itvs := list(tt1)
+	require.Len(t, itvs, 2)
+	require.Equal(t, []string{"a"}, itvs[0].Tags)
+	require.Equal(t, []string{"b"}, itvs[1].Tags)
+	require.Equal(t, itvs, list(tt2))
+
+	// The shared file has no write ahead log companion files
+	syncDB, err := SQLiteSyncer{Path: central}.Open()
+	require.NoError(t, err)
+	var mode string
+	require.NoError(t, syncDB.Get(&mode, `PRAGMA journal_mode`))
+	require.Equal(t, "delete", mode)
+	require.NoError(t, syncDB.Close())
+	_, err = os.Stat(central + "-wal")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	remote, err := NewReadOnly(central)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, remote.Close()) })
+	require.Equal(t, itvs, list(remote))
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// TagEvent describes a tag being added to or removed from an interval.
+type TagEvent struct {
+	At      time.Time
+	ID      string
+	Tag     string
+	Removed bool
+}
+
+// TagChanges returns the tags added to and removed from intervals between
+// since included and until excluded, in chronological order.
+// The events are dated by the creation of the interval tags and of their
+// tombstones, which is the synchronisation time for the ones recorded
+// by another database. The events of deleted intervals are returned as well.
+func (tt *TimeTracker) TagChanges(since, until time.Time) (events []TagEvent, retErr error) {
+	rows, err := tt.db.Query(`
+		SELECT created_at, id, name, removed
+		FROM (
+			SELECT interval_tags.created_at, interval_start.id, tags.name, FALSE removed,
+				interval_tags.rowid tag_rowid
+			FROM interval_tags
+				JOIN interval_start ON interval_tags.interval_start_uuid = interval_start.uuid
+				JOIN tags ON interval_tags.tag_id = tags.id
+			UNION ALL
+			SELECT interval_tags_tombstone.created_at, interval_start.id, tags.name, TRUE removed,
+				interval_tags.rowid tag_rowid
+			FROM interval_tags_tombstone
+				JOIN interval_tags
+					ON interval_tags_tombstone.interval_tag_uuid = interval_tags.uuid
+				JOIN interval_start ON interval_tags.interval_start_uuid = interval_start.uuid
+				JOIN tags ON interval_tags.tag_id = tags.id
+		)
+		WHERE created_at >= ? AND created_at < ?
+		ORDER BY created_at, removed, id, tag_rowid`, since.Unix(), until.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("cannot query tag changes: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil && retErr == nil {
+			retErr = fmt.Errorf("cannot close tag changes rows: %w", err)
+		}
+	}()
+
+	for rows.Next() {
+		var (
+			createdAt int64
+			event     TagEvent
+		)
+		if err := rows.Scan(&createdAt, &event.ID, &event.Tag, &event.Removed); err != nil {
+			return nil, fmt.Errorf("cannot scan tag changes row: %w", err)
+		}
+		event.At = time.Unix(createdAt, 0)
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("cannot iterate over tag changes rows: %w", err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagChanges(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2022, 3, day, hour, 0, 0, 0, time.Local)
+	}
+	clock := func(tt *TimeTracker, t time.Time) {
+		tt.now = func() time.Time { return t }
+	}
+
+	tt := setupTT(t)
+	clock(tt, at(1, 12))
+	require.NoError(t, tt.Start(at(1, 9), []string{"a", "b"}))
+	require.NoError(t, tt.StopAt(at(1, 10)))
+	clock(tt, at(2, 12))
+	require.NoError(t, tt.Tag("1", []string{"c"}))
+	require.NoError(t, tt.Untag("1", []string{"a"}))
+	clock(tt, at(3, 12))
+	require.NoError(t, tt.Start(at(3, 9), []string{"d"}))
+	require.NoError(t, tt.StopAt(at(3, 10)))
+	require.NoError(t, tt.Delete("2"))
+	clock(tt, at(4, 12))
+	require.NoError(t, tt.Untag("1", []string{"b"}))
+
+	events, err := tt.TagChanges(at(2, 0), at(4, 0))
+	require.NoError(t, err)
+	require.Equal(t, []TagEvent{
+		{At: at(2, 12), ID: "1", Tag: "c"},
+		{At: at(2, 12), ID: "1", Tag: "a", Removed: true},
+		{At: at(3, 12), ID: "2", Tag: "d"},
+	}, events)
+
+	events, err = tt.TagChanges(at(1, 0), at(5, 0))
+	require.NoError(t, err)
+	require.Len(t, events, 6)
+	require.Equal(t, TagEvent{At: at(1, 12), ID: "1", Tag: "a"}, events[0])
+	require.Equal(t, TagEvent{At: at(4, 12), ID: "1", Tag: "b", Removed: true}, events[5])
+
+	events, err = tt.TagChanges(at(5, 0), at(6, 0))
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
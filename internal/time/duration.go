@@ -0,0 +1,65 @@
+package time
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnparsableDurationFormat = fmt.Errorf("unparsable duration format")
+
+// iso8601Duration matches the ISO 8601 durations made of weeks, days,
+// hours, minutes and seconds. Years and months are not supported as
+// their duration depends on the date they apply to.
+var iso8601Duration = regexp.MustCompile(
+	`^P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:[.,]\d+)?)S)?)?$`)
+
+// Duration is a time.Duration which can be unmarshaled either from
+// the time.ParseDuration format or from an ISO 8601 duration like PT1H30M.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(data []byte) error {
+	s := string(data)
+
+	if goD, err := time.ParseDuration(s); err == nil {
+		*d = Duration(goD)
+		return nil
+	}
+
+	isoD, err := parseISO8601Duration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(isoD)
+	return nil
+}
+
+func (d *Duration) Duration() time.Duration {
+	return time.Duration(*d)
+}
+
+func parseISO8601Duration(s string) (time.Duration, error) {
+	matches := iso8601Duration.FindStringSubmatch(s)
+	if matches == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("%w: %s", ErrUnparsableDurationFormat, s)
+	}
+
+	var ret time.Duration
+	for idx, unit := range []time.Duration{
+		7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second,
+	} {
+		value := matches[idx+1]
+		if value == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %s: %v", ErrUnparsableDurationFormat, s, err)
+		}
+		ret += time.Duration(f * float64(unit))
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,35 @@
+package time
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuration_UnmarshalText(t *testing.T) {
+	for _, tc := range []struct {
+		input    string
+		expected time.Duration
+	}{
+		{"1h30m", 90 * time.Minute},
+		{"PT90M", 90 * time.Minute},
+		{"PT1H30M", 90 * time.Minute},
+		{"P1DT2H", 26 * time.Hour},
+		{"P1W", 7 * 24 * time.Hour},
+		{"PT1.5S", 1500 * time.Millisecond},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			var d Duration
+			require.NoError(t, d.UnmarshalText([]byte(tc.input)))
+			require.Equal(t, tc.expected, d.Duration())
+		})
+	}
+
+	for _, input := range []string{"P1M", "P1Y", "P", "PT", "P1DT", "1 hour"} {
+		t.Run("reject "+input, func(t *testing.T) {
+			var d Duration
+			require.ErrorIs(t, d.UnmarshalText([]byte(input)), ErrUnparsableDurationFormat)
+		})
+	}
+}
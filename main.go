@@ -2,11 +2,13 @@
 package main
 
 import (
-	"database/sql"
+	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/alecthomas/kong"
@@ -19,36 +21,98 @@ import (
 
 var (
 	errInvalidParameter = fmt.Errorf("invalid parameter")
+	errNotRunning       = fmt.Errorf("no running interval")
+	errQuotaExceeded    = fmt.Errorf("quota exceeded")
+	errSanityCheck      = fmt.Errorf("sanity check failed")
+	errServerFailure    = fmt.Errorf("server request failed")
 )
 
 const (
 	appName = "github.com/dgsb/tt"
+
+	// defaultClockTolerance is the backward clock jump accepted by the clock guard
+	// when no clock_guard_tolerance configuration is set.
+	defaultClockTolerance = time.Minute
 )
 
+// clockGuards maps the supported clock_guard configuration values.
+var clockGuards = map[string]db.ClockGuard{
+	"off":   db.ClockGuardOff,
+	"warn":  db.ClockGuardWarn,
+	"error": db.ClockGuardError,
+}
+
+// ttProvider lazily opens the application database.
+type ttProvider func() (*db.TimeTracker, error)
+
 type CommonConfig struct {
-	Database string `name:"db" type:"file" default:"${home}/.tt.db" help:"the sqlite database to use for application data"`
+	Database   string `name:"db" type:"file" default:"${home}/.tt.db" help:"the sqlite database to use for application data"`
+	ConfigDB   string `name:"config-db" type:"file" default:"${config_db}" help:"the sqlite database holding the application configuration"`
+	FKCheck    bool   `name:"fk-check" help:"verify the database foreign keys after each modification"`
+	JSONErrors bool   `name:"json-errors" help:"report failures as a JSON object on the standard error"`
+	Color      string `default:"auto" enum:"auto,always,never" help:"colorize the tags in reports, auto only colorizes a terminal output"`
+	DayStart   string `name:"day-start" help:"the hh:mm time the logical days start at, overriding the day_start configuration"`
 }
 
 type StartCmd struct {
-	At   itime.Time    `help:"specify the start timestamp in RFC3339 format" group:"time" xor:"time"`
-	Ago  time.Duration `help:"specify the start timestamp as a duration in the past" group:"time" xor:"time"`
-	Tags []string      `arg:"" optional:"" help:"the value to tag the interval with"`
+	At             itime.Time     `help:"specify the start timestamp in RFC3339 format" group:"time" xor:"time"`
+	Ago            itime.Duration `help:"specify the start timestamp as a duration in the past" group:"time" xor:"time"`
+	MergeGap       time.Duration  `name:"merge-gap" help:"reopen the last interval instead if it has the same tags and stopped less than this duration ago"`
+	Context        string         `help:"start the interval in this context, one interval can be opened per context"`
+	ContinueExcept []string       `name:"continue-except" help:"copy the tags of the last interval except these ones, the given tags are added"`
+	NoDefaultTags  bool           `name:"no-default-tags" help:"do not add the configured default tags"`
+	StopPrevAt     itime.Time     `name:"stop-prev-at" help:"stop the running interval at this timestamp instead of the new start timestamp"`
+	Location       string         `help:"record the location the interval is started at, like a city"`
+	Tags           []string       `arg:"" optional:"" help:"the value to tag the interval with"`
 }
 
 func (cmd *StartCmd) Run(tt *db.TimeTracker) error {
+	tt.SetContext(cmd.Context)
+	tt.SetPlace(cmd.Location)
+	if cmd.NoDefaultTags {
+		if err := tt.SetDefaultTags(nil); err != nil {
+			return err
+		}
+	}
+
 	startTime := time.Now()
 	if !cmd.At.Time().IsZero() {
 		startTime = cmd.At.Time()
 	} else if cmd.Ago != 0 {
-		startTime = time.Now().Add(-cmd.Ago)
+		startTime = time.Now().Add(-cmd.Ago.Duration())
+	}
+
+	tags := cmd.Tags
+	if len(cmd.ContinueExcept) > 0 {
+		// Given tags are excluded too so they are not copied twice
+		lastTags, err := tt.LastIntervalTags(append(cmd.ContinueExcept, cmd.Tags...))
+		if err != nil {
+			return fmt.Errorf("cannot retrieve last interval tags: %w", err)
+		}
+		tags = append(lastTags, cmd.Tags...)
 	}
 
 	// Stop the current interval before opening a new one
-	if err := tt.StopAt(startTime); err != nil && !errors.Is(err, sql.ErrNoRows) {
+	stopTime := startTime
+	if !cmd.StopPrevAt.Time().IsZero() {
+		stopTime = cmd.StopPrevAt.Time()
+		if !stopTime.Before(startTime) {
+			return fmt.Errorf("%w: the previous interval must be stopped before the new start",
+				errInvalidParameter)
+		}
+	}
+	if err := tt.StopAt(stopTime); err != nil && !errors.Is(err, db.ErrNoOpenInterval) {
 		return fmt.Errorf("cannot stop currently opened interval: %w", err)
 	}
 
-	if err := tt.Start(startTime, cmd.Tags); err != nil {
+	if cmd.MergeGap > 0 {
+		if _, err := tt.StartMerging(startTime, tags, cmd.MergeGap); err != nil {
+			return fmt.Errorf("cannot start a new opened interval: %w", err)
+		}
+		return nil
+	}
+
+	if err := tt.Start(startTime, tags); err != nil {
 		return fmt.Errorf("cannot start a new opened interval: %w", err)
 	}
 
@@ -56,14 +120,17 @@ func (cmd *StartCmd) Run(tt *db.TimeTracker) error {
 }
 
 type StopCmd struct {
-	At  itime.Time    `help:"specify the stop timestamp in RFC3339 format" group:"time" xor:"time"`
-	Ago time.Duration `help:"specify the stop timestamp as a duration in the past" group:"time" xor:"time"`
-	For time.Duration `help:"specify the stop timestamp as the wanted duration for closed interval" group:"time" xor:"time"`
+	At      itime.Time     `help:"specify the stop timestamp in RFC3339 format" group:"time" xor:"time"`
+	Ago     itime.Duration `help:"specify the stop timestamp as a duration in the past" group:"time" xor:"time"`
+	For     itime.Duration `help:"specify the stop timestamp as the wanted duration for closed interval" group:"time" xor:"time"`
+	Context string         `help:"stop the interval opened in this context"`
 }
 
 func (cmd *StopCmd) Run(tt *db.TimeTracker) error {
+	tt.SetContext(cmd.Context)
+
 	if cmd.For != 0 {
-		if err := tt.StopFor(cmd.For); err != nil {
+		if err := tt.StopFor(cmd.For.Duration()); err != nil {
 			return fmt.Errorf("cannot stop a currently opened interval: %w", err)
 		}
 		return nil
@@ -73,7 +140,7 @@ func (cmd *StopCmd) Run(tt *db.TimeTracker) error {
 	if !cmd.At.Time().IsZero() {
 		stopTime = cmd.At.Time()
 	} else if cmd.Ago != 0 {
-		stopTime = time.Now().Add(-cmd.Ago)
+		stopTime = time.Now().Add(-cmd.Ago.Duration())
 	}
 
 	if err := tt.StopAt(stopTime); err != nil {
@@ -84,63 +151,216 @@ func (cmd *StopCmd) Run(tt *db.TimeTracker) error {
 }
 
 type ListCmd struct {
-	At     itime.Time `help:"another starting point for the required time period instead of now"`
-	Tag    string     `help:"a tag to output filter on"`
-	Period string     `arg:"" help:"a logical description of the time period to look at" default:":day" enum:":week,:day,:month,:year"`
-}
-
-func (cmd *ListCmd) Run(tt *db.TimeTracker) error {
-	startTime := cmd.At.Time()
-	if startTime.IsZero() {
-		startTime = time.Now()
-	}
-
-	var stopTime time.Time
-	switch cmd.Period {
-	case ":day":
-		year, month, day := startTime.Date()
-		startTime = time.Date(year, month, day, 0, 0, 0, 0, time.Local)
-		stopTime = time.Date(year, month, day+1, 0, 0, 0, 0, time.Local)
-	case ":week":
-		year, month, day := startTime.Date()
-		weekday := startTime.Weekday()
-		if weekday == time.Sunday {
-			weekday = time.Saturday + 1
-		}
-		startTime = time.Date(year, month, day-int(weekday-time.Monday), 0, 0, 0, 0, time.Local)
-		stopTime = time.Date(year, month, day+1+int(time.Saturday+1-weekday), 0, 0, 0, 0, time.Local)
-	case ":month":
-		year, month, _ := startTime.Date()
-		startTime = time.Date(year, month, 1, 0, 0, 0, 0, time.Local)
-		stopTime = time.Date(year, month+1, 1, 0, 0, 0, 0, time.Local)
-	case ":year":
-		year, _, _ := startTime.Date()
-		startTime = time.Date(year, time.January, 1, 0, 0, 0, 0, time.Local)
-		stopTime = time.Date(year+1, time.January, 1, 0, 0, 0, 0, time.Local)
-	default:
-		return fmt.Errorf("%w: time range not implemented %s", errInvalidParameter, cmd.Period)
+	At            periodAnchor `help:"another starting point for the required time period instead of now, or the day, month or year itself like 2023-02"`
+	Tag           []string     `help:"list only the intervals carrying all these tags"`
+	Precision     string       `help:"the precision of displayed timestamps and durations" default:"second" enum:"second,minute,hour"`
+	IDs           string       `name:"ids" help:"display canonical identifiers or a per day sequence followed by the canonical identifier" default:"canonical" enum:"canonical,daily"`
+	Reverse       bool         `help:"display the newest intervals first"`
+	CreatedAfter  itime.Time   `name:"created-after" help:"only list intervals recorded at or after this timestamp"`
+	CreatedBefore itime.Time   `name:"created-before" help:"only list intervals recorded before this timestamp"`
+	Count         bool         `help:"only print the number of intervals"`
+	NoTotal       bool         `name:"no-total" help:"do not print the total time footer"`
+	Location      bool         `help:"display the location the intervals have been started at"`
+	OriginalTZ    bool         `name:"original-tz" help:"display the timestamps in the time zone the intervals have been started in"`
+	Format        string       `help:"the output format: table, csv or a markdown table" default:"table" enum:"table,csv,md"`
+	Period        string       `arg:"" help:"a logical description of the time period to look at" default:":day" enum:":week,:day,:month,:year"`
+}
+
+func (cmd *ListCmd) Run(tt *db.TimeTracker, common *CommonConfig, repo *configlite.Repository, offset dayStart) error {
+	// The csv records are meant to be processed rather than read
+	if cmd.Format == "csv" && (cmd.Reverse || idDisplays[cmd.IDs] != CanonicalIDs || cmd.Location) {
+		return fmt.Errorf("%w: --reverse, --ids daily and --location don't apply to csv",
+			errInvalidParameter)
 	}
 
-	taggedIntervals, err := tt.List(startTime, stopTime)
+	startTime, stopTime, err := cmd.At.bounds(cmd.Period, offset)
+	if err != nil {
+		return err
+	}
+
+	// Without any filter the intervals don't need to be fetched to be counted
+	if cmd.Count && len(cmd.Tag) == 0 && cmd.CreatedAfter.Time().IsZero() && cmd.CreatedBefore.Time().IsZero() {
+		count, err := tt.Count(startTime, stopTime)
+		if err != nil {
+			return fmt.Errorf("cannot count recorded interval: %w", err)
+		}
+		fmt.Println(count)
+		return nil
+	}
+
+	taggedIntervals, err := tt.ListFiltered(startTime, stopTime, db.ListFilter{
+		CreatedAfter:  cmd.CreatedAfter.Time(),
+		CreatedBefore: cmd.CreatedBefore.Time(),
+		Tags:          cmd.Tag,
+	})
 	if err != nil {
 		return fmt.Errorf("cannot list recorded interval: %w", err)
 	}
 
-	filteredTaggedIntervals := make([]db.TaggedInterval, 0, len(taggedIntervals))
-	if cmd.Tag == "" {
-		filteredTaggedIntervals = taggedIntervals
-	} else {
-		for _, itv := range taggedIntervals {
-			for _, t := range itv.Tags {
-				if t == cmd.Tag {
-					filteredTaggedIntervals = append(filteredTaggedIntervals, itv)
-					break
-				}
-			}
+	if cmd.Count {
+		fmt.Println(len(taggedIntervals))
+		return nil
+	}
+	if cmd.OriginalTZ {
+		taggedIntervals = originalTimes(taggedIntervals)
+	}
+
+	switch cmd.Format {
+	case "csv":
+		return CSVReport(taggedIntervals, os.Stdout)
+	case "md":
+		return MarkdownReport(taggedIntervals, os.Stdout, MarkdownReportOptions{
+			Precision: precisions[cmd.Precision],
+			NoTotal:   cmd.NoTotal,
+			IDs:       idDisplays[cmd.IDs],
+			Reverse:   cmd.Reverse,
+			Location:  cmd.Location,
+		})
+	}
+
+	opts := FlatReportOptions{
+		Precision: precisions[cmd.Precision],
+		IDs:       idDisplays[cmd.IDs],
+		Reverse:   cmd.Reverse,
+		Color:     useColor(common.Color, os.Stdout),
+		NoTotal:   cmd.NoTotal,
+		Location:  cmd.Location,
+	}
+	if opts.Color {
+		if opts.TagColors, err = tagColors(repo); err != nil {
+			return err
 		}
 	}
+	return FlatReport(taggedIntervals, os.Stdout, opts)
+}
+
+type SummaryCmd struct {
+	At        periodAnchor `help:"another starting point for the required time period instead of now, or the day, month or year itself like 2023-02"`
+	Format    string       `help:"the output format" default:"table" enum:"table,csv"`
+	Billable  string       `help:"restrict the summary to billable (yes) or non billable (no) intervals" default:"all" enum:"all,yes,no"`
+	WorkHours string       `name:"work-hours" help:"only account the time within this daily hh:mm-hh:mm window, like 09:00-18:00"`
+	Period    string       `arg:"" help:"a logical description of the time period to look at" default:":day" enum:":week,:day,:month,:year"`
+}
+
+func (cmd *SummaryCmd) Run(tt *db.TimeTracker, offset dayStart) error {
+	hours, err := parseWorkHours(cmd.WorkHours)
+	if err != nil {
+		return err
+	}
+	startTime, stopTime, err := cmd.At.bounds(cmd.Period, offset)
+	if err != nil {
+		return err
+	}
+
+	taggedIntervals, err := tt.List(startTime, stopTime)
+	if err != nil {
+		return fmt.Errorf("cannot list recorded interval: %w", err)
+	}
+	if cmd.Billable != "all" {
+		taggedIntervals = filterBillable(taggedIntervals, cmd.Billable == "yes")
+	}
+
+	if cmd.Format == "csv" {
+		return SummaryCSV(taggedIntervals, os.Stdout, hours)
+	}
+	return SummaryReport(taggedIntervals, os.Stdout, hours)
+}
+
+type EstimateCmd struct {
+	ID       string         `arg:"" help:"the interval id to estimate"`
+	Estimate itime.Duration `arg:"" help:"the planned duration of the interval, 0 removes the estimate"`
+}
+
+func (cmd *EstimateCmd) Run(tt *db.TimeTracker) error {
+	if err := tt.SetEstimate(cmd.ID, cmd.Estimate.Duration()); err != nil {
+		return fmt.Errorf("cannot estimate %s: %w", cmd.ID, err)
+	}
+	return nil
+}
+
+type BillCmd struct {
+	ID  string `arg:"" help:"the interval id to flag as billable"`
+	Off bool   `help:"clear the billable flag instead"`
+}
+
+func (cmd *BillCmd) Run(tt *db.TimeTracker) error {
+	if err := tt.SetBillable(cmd.ID, !cmd.Off); err != nil {
+		return fmt.Errorf("cannot change billable flag of %s: %w", cmd.ID, err)
+	}
+	return nil
+}
+
+type VarianceCmd struct {
+	At     periodAnchor `help:"another starting point for the required time period instead of now, or the day, month or year itself like 2023-02"`
+	Period string       `arg:"" help:"a logical description of the time period to look at" default:":week" enum:":week,:day,:month,:year"`
+}
+
+func (cmd *VarianceCmd) Run(tt *db.TimeTracker, offset dayStart) error {
+	startTime, stopTime, err := cmd.At.bounds(cmd.Period, offset)
+	if err != nil {
+		return err
+	}
+
+	taggedIntervals, err := tt.List(startTime, stopTime)
+	if err != nil {
+		return fmt.Errorf("cannot list recorded interval: %w", err)
+	}
+
+	return EstimateReport(taggedIntervals, os.Stdout)
+}
+
+type HeatmapCmd struct {
+	At     periodAnchor `help:"another starting point for the required time period instead of now, or the day, month or year itself like 2023-02"`
+	Period string       `help:"a logical description of the time period to look at" default:":week" enum:":week,:day,:month,:year"`
+}
+
+func (cmd *HeatmapCmd) Run(tt *db.TimeTracker, offset dayStart) error {
+	startTime, stopTime, err := cmd.At.bounds(cmd.Period, offset)
+	if err != nil {
+		return err
+	}
+
+	taggedIntervals, err := tt.List(startTime, stopTime)
+	if err != nil {
+		return fmt.Errorf("cannot list recorded interval: %w", err)
+	}
+
+	return HeatmapReport(taggedIntervals, startTime, stopTime, os.Stdout)
+}
+
+type HistogramCmd struct {
+	At     periodAnchor `help:"another starting point for the required time period instead of now, or the day, month or year itself like 2023-02"`
+	Period string       `help:"a logical description of the time period to look at" default:":week" enum:":week,:day,:month,:year"`
+}
+
+func (cmd *HistogramCmd) Run(tt *db.TimeTracker, offset dayStart) error {
+	startTime, stopTime, err := cmd.At.bounds(cmd.Period, offset)
+	if err != nil {
+		return err
+	}
+
+	taggedIntervals, err := tt.List(startTime, stopTime)
+	if err != nil {
+		return fmt.Errorf("cannot list recorded interval: %w", err)
+	}
 
-	return FlatReport(filteredTaggedIntervals, os.Stdout)
+	return HistogramReport(taggedIntervals, startTime, stopTime, os.Stdout)
+}
+
+type AmendCmd struct {
+	Start itime.Time `help:"the new start timestamp of the interval"`
+	Stop  itime.Time `help:"the new stop timestamp of the interval, it cannot be set on the opened interval"`
+	ID    string     `arg:"" help:"the id of the interval to amend"`
+}
+
+func (cmd *AmendCmd) Run(tt *db.TimeTracker) error {
+	if cmd.Start.Time().IsZero() && cmd.Stop.Time().IsZero() {
+		return fmt.Errorf("%w: --start or --stop must be set", errInvalidParameter)
+	}
+	if err := tt.Amend(cmd.ID, cmd.Start.Time(), cmd.Stop.Time()); err != nil {
+		return fmt.Errorf("cannot amend interval %s: %w", cmd.ID, err)
+	}
+	return nil
 }
 
 type DeleteCmd struct {
@@ -158,12 +378,13 @@ func (cmd *DeleteCmd) Run(tt *db.TimeTracker) error {
 }
 
 type TagCmd struct {
-	ID   string   `arg:"" help:"the interval id to tag"`
+	ID   string   `arg:"" help:"the interval id to tag, several comma separated ids are accepted"`
 	Tags []string `arg:"" help:"values to tag the interval with"`
 }
 
 func (cmd *TagCmd) Run(tt *db.TimeTracker) error {
-	if err := tt.Tag(cmd.ID, cmd.Tags); err != nil {
+	ids := strings.Split(cmd.ID, ",")
+	if err := tt.TagMany(ids, cmd.Tags); err != nil {
 		return fmt.Errorf("cannot tag interval %s with %s: %w", cmd.ID, cmd.Tags, err)
 	}
 
@@ -182,41 +403,400 @@ func (cmd *UntagCmd) Run(tt *db.TimeTracker) error {
 	return nil
 }
 
+type FixLastCmd struct {
+	Add     []string `help:"the tags to add to the last closed interval"`
+	Remove  []string `help:"the tags to remove from the last closed interval"`
+	Context string   `help:"fix the last closed interval of this context"`
+}
+
+func (cmd *FixLastCmd) Run(tt *db.TimeTracker) error {
+	if len(cmd.Add) == 0 && len(cmd.Remove) == 0 {
+		return fmt.Errorf("%w: no tag to add or remove", errInvalidParameter)
+	}
+	tt.SetContext(cmd.Context)
+	if err := tt.FixLast(cmd.Add, cmd.Remove); err != nil {
+		return fmt.Errorf("cannot fix tags of the last closed interval: %w", err)
+	}
+	return nil
+}
+
+type TagChangesCmd struct {
+	At     periodAnchor `help:"another starting point for the required time period instead of now, or the day, month or year itself like 2023-02"`
+	Period string       `help:"a logical description of the time period to look at" default:":week" enum:":week,:day,:month,:year"`
+}
+
+func (cmd *TagChangesCmd) Run(tt *db.TimeTracker, offset dayStart) error {
+	startTime, stopTime, err := cmd.At.bounds(cmd.Period, offset)
+	if err != nil {
+		return err
+	}
+
+	events, err := tt.TagChanges(startTime, stopTime)
+	if err != nil {
+		return fmt.Errorf("cannot list tag changes: %w", err)
+	}
+
+	for _, event := range events {
+		change := "added"
+		if event.Removed {
+			change = "removed"
+		}
+		if _, err := fmt.Fprintf(os.Stdout, "%s %s %s %s\n",
+			event.At.Format(time.RFC3339), event.ID, change, event.Tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type IntervalTagsCmd struct {
+	ID string `arg:"" help:"the interval id whose tags are printed"`
+}
+
+func (cmd *IntervalTagsCmd) Run(tt *db.TimeTracker) error {
+	tags, err := tt.IntervalTags(cmd.ID)
+	if err != nil {
+		return fmt.Errorf("cannot retrieve tags of interval %s: %w", cmd.ID, err)
+	}
+	for _, tag := range tags {
+		if _, err := fmt.Fprintln(os.Stdout, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type RenameTagCmd struct {
+	DryRun bool   `help:"only report the intervals which would be changed"`
+	From   string `arg:"" help:"the tag to rename"`
+	To     string `arg:"" help:"the new tag name"`
+}
+
+func (cmd *RenameTagCmd) Run(tt *db.TimeTracker) error {
+	changes, err := tt.RenameTag(cmd.From, cmd.To, cmd.DryRun)
+	if err != nil {
+		return fmt.Errorf("cannot rename tag %s to %s: %w", cmd.From, cmd.To, err)
+	}
+	return renameTagReport(changes, cmd.DryRun, os.Stdout)
+}
+
+// renameTagReport writes the tags of each changed interval before and after the renaming.
+func renameTagReport(changes []db.TagChange, dryRun bool, out io.Writer) error {
+	for _, change := range changes {
+		if _, err := fmt.Fprintf(out, "%s: %s -> %s\n",
+			change.ID, strings.Join(change.Before, ","), strings.Join(change.After, ",")); err != nil {
+			return fmt.Errorf("cannot write rename report: %w", err)
+		}
+	}
+
+	verb := "changed"
+	if dryRun {
+		verb = "would be changed"
+	}
+	if _, err := fmt.Fprintf(out, "%d intervals %s\n", len(changes), verb); err != nil {
+		return fmt.Errorf("cannot write rename report: %w", err)
+	}
+	return nil
+}
+
 type CurrentCmd struct {
+	Socket     string `help:"query a tt serve process listening on this unix socket, if any" env:"TT_SOCKET"`
+	Context    string `help:"show the interval opened in this context, the socket is not queried"`
+	StartEpoch bool   `name:"start-epoch" help:"only print the start of the opened interval as unix seconds, the socket is not queried"`
 }
 
-func (cmd *CurrentCmd) Run(tt *db.TimeTracker) error {
+func (cmd *CurrentCmd) Run(open ttProvider) error {
+	if cmd.Socket != "" && cmd.Context == "" && !cmd.StartEpoch {
+		if err := queryServer(cmd.Socket, requestCurrent, os.Stdout); err == nil {
+			return nil
+		}
+	}
+
+	tt, err := open()
+	if err != nil {
+		return err
+	}
+	tt.SetContext(cmd.Context)
+
+	if cmd.StartEpoch {
+		since, err := tt.OpenSince()
+		if err != nil {
+			return fmt.Errorf("cannot retrieve current interval start: %w", err)
+		}
+		if !since.IsZero() {
+			_, err = fmt.Fprintln(os.Stdout, since.Unix())
+		}
+		return err
+	}
+
 	interval, err := tt.Current()
 	if err != nil {
 		return fmt.Errorf("cannot retrieve current interval: %w", err)
 	}
 	if interval != nil {
-		return FlatReport([]db.TaggedInterval{*interval}, os.Stdout)
+		return FlatReport([]db.TaggedInterval{*interval}, os.Stdout, FlatReportOptions{})
+	}
+	return nil
+}
+
+type RunningCmd struct {
+	Context string `help:"probe the interval opened in this context"`
+}
+
+// Run prints nothing, the outcome is only reported through the exit status.
+func (cmd *RunningCmd) Run(tt *db.TimeTracker) error {
+	tt.SetContext(cmd.Context)
+
+	ok, err := running(tt)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errNotRunning
+	}
+	return nil
+}
+
+// running reports whether an interval is opened in the current context.
+func running(tt *db.TimeTracker) (bool, error) {
+	interval, err := tt.Current()
+	if err != nil {
+		return false, fmt.Errorf("cannot retrieve current interval: %w", err)
+	}
+	return interval != nil, nil
+}
+
+type AtCmd struct {
+	At itime.Time `arg:"" help:"the timestamp to look the active interval at"`
+}
+
+func (cmd *AtCmd) Run(tt *db.TimeTracker) error {
+	interval, err := tt.At(cmd.At.Time())
+	if err != nil {
+		return fmt.Errorf("cannot retrieve interval active at %s: %w", cmd.At.Time(), err)
+	}
+	if interval != nil {
+		return FlatReport([]db.TaggedInterval{*interval}, os.Stdout, FlatReportOptions{})
 	}
 	return nil
 }
 
 type ContinueCmd struct {
-	ID string `long:"id" help:"specify an interval ID to continue"`
+	ID            string   `long:"id" help:"specify an interval ID to continue" xor:"selection"`
+	RequireTags   []string `name:"require-tags" help:"continue the last interval carrying all these tags" xor:"selection"`
+	List          bool     `help:"list the last closed intervals and ask which one to continue" xor:"selection"`
+	Count         int      `help:"the number of intervals listed by --list" default:"10"`
+	NoDefaultTags bool     `name:"no-default-tags" help:"do not add the configured default tags"`
+	Context       string   `help:"continue the last interval of this context, one interval can be opened per context"`
+	Location      string   `help:"record the location the interval is continued at, like a city"`
 }
 
 func (cmd *ContinueCmd) Run(tt *db.TimeTracker) error {
-	if err := tt.Continue(time.Now(), cmd.ID); err != nil {
+	tt.SetContext(cmd.Context)
+	tt.SetPlace(cmd.Location)
+	if cmd.NoDefaultTags {
+		if err := tt.SetDefaultTags(nil); err != nil {
+			return err
+		}
+	}
+
+	if cmd.List {
+		tas, err := tt.RecentClosed(cmd.Count)
+		if err != nil {
+			return fmt.Errorf("cannot list recent intervals: %w", err)
+		}
+		if len(tas) == 0 {
+			return fmt.Errorf("cannot find interval to continue: %w", db.ErrNotFound)
+		}
+		if err := printRecentIntervals(tas, os.Stdout); err != nil {
+			return err
+		}
+		id, err := selectInterval(tas, os.Stdin, os.Stdout)
+		if err != nil || id == "" {
+			return err
+		}
+		cmd.ID = id
+	}
+
+	if err := tt.Continue(time.Now(), cmd.ID, cmd.RequireTags); err != nil {
 		return fmt.Errorf("cannot continue a previously closed interval: %w", err)
 	}
 
 	return nil
 }
 
+// printRecentIntervals writes the intervals numbered from 1
+// along with their id, bounds and tags.
+func printRecentIntervals(tas []db.TaggedInterval, out io.Writer) error {
+	for idx, ta := range tas {
+		line := fmt.Sprintf("%d) id:%s %s - %s %s",
+			idx+1, ta.ID,
+			ta.StartTimestamp.Format("2006-01-02 15:04"), ta.StopTimestamp.Format("15:04"),
+			strings.Join(ta.Tags, ","))
+		if _, err := fmt.Fprintln(out, strings.TrimSpace(line)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selectInterval asks on out for the number of one of the intervals listed by
+// printRecentIntervals and returns its id. An empty answer selects nothing
+// and an empty id is returned.
+func selectInterval(tas []db.TaggedInterval, in io.Reader, out io.Writer) (string, error) {
+	if _, err := fmt.Fprintf(out, "Interval to continue [1-%d]: ", len(tas)); err != nil {
+		return "", err
+	}
+
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("cannot read answer: %w", err)
+	}
+
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return "", nil
+	}
+	number, err := strconv.Atoi(answer)
+	if err != nil || number < 1 || number > len(tas) {
+		return "", fmt.Errorf("%w: no interval numbered %q", errInvalidParameter, answer)
+	}
+	return tas[number-1].ID, nil
+}
+
+type DoctorCmd struct {
+	DumpSchema       bool `name:"dump-schema" help:"print the database schema and the applied migrations instead of running the sanity checks"`
+	RepairMigrations bool `name:"repair-migrations" help:"re-stamp the checksums of the applied migrations when the database schema is the expected one"`
+	IUnderstand      bool `name:"i-understand" help:"acknowledge that repairing the migrations may hide a real schema corruption"`
+}
+
+func (cmd *DoctorCmd) Run(common *CommonConfig, open ttProvider) error {
+	if cmd.RepairMigrations {
+		return cmd.repairMigrations(common.Database, os.Stdin, os.Stdout)
+	}
+
+	tt, err := open()
+	if err != nil {
+		return err
+	}
+
+	if cmd.DumpSchema {
+		schema, err := tt.DumpSchema()
+		if err != nil {
+			return fmt.Errorf("cannot dump database schema: %w", err)
+		}
+
+		_, err = fmt.Fprint(os.Stdout, schema)
+		return err
+	}
+
+	return doctorReport(tt.Sanity().Report(), os.Stdout)
+}
+
+// repairMigrations runs the migrations checksum repair on the database
+// once the user has acknowledged the risk twice: with the --i-understand
+// flag and with an interactive confirmation.
+func (cmd *DoctorCmd) repairMigrations(database string, in io.Reader, out io.Writer) error {
+	if !cmd.IUnderstand {
+		return fmt.Errorf("%w: --repair-migrations requires --i-understand", errInvalidParameter)
+	}
+
+	ok, err := confirm(in, out, "The recorded migration checksums will be overwritten.")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	repaired, err := db.RepairMigrations(database)
+	if err != nil {
+		return fmt.Errorf("cannot repair migrations: %w", err)
+	}
+	for _, version := range repaired {
+		if _, err := fmt.Fprintf(out, "repaired migration version %g\n", version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// doctorReport prints a pass/fail line per check result and returns
+// errSanityCheck if any of them failed.
+func doctorReport(results []db.CheckResult, out io.Writer) error {
+	var failed int
+	for _, r := range results {
+		if r.Err == nil {
+			if _, err := fmt.Fprintf(out, "PASS\t%s\n", r.Name); err != nil {
+				return err
+			}
+			continue
+		}
+		failed++
+		if _, err := fmt.Fprintf(out, "FAIL\t%s: %s\n", r.Name, r.Err); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d checks failed", errSanityCheck, failed, len(results))
+	}
+	return nil
+}
+
+type PurgeCmd struct {
+	All         bool `required:"" help:"delete all recorded intervals and tags"`
+	SyncHistory bool `name:"sync-history" help:"also forget about past synchronisations"`
+	Yes         bool `short:"y" help:"do not ask for confirmation"`
+}
+
+func (cmd *PurgeCmd) Run(tt *db.TimeTracker) error {
+	if !cmd.Yes {
+		ok, err := confirm(os.Stdin, os.Stdout, "All recorded intervals and tags will be deleted.")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+	}
+
+	if err := tt.Purge(cmd.SyncHistory); err != nil {
+		return fmt.Errorf("cannot purge the database: %w", err)
+	}
+
+	return nil
+}
+
+// confirm prints message on out and reads a yes/no answer from in.
+// Anything but an explicit yes is considered as a no.
+func confirm(in io.Reader, out io.Writer, message string) (bool, error) {
+	if _, err := fmt.Fprintf(out, "%s Continue? [y/N] ", message); err != nil {
+		return false, err
+	}
+
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("cannot read answer: %w", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
 type VacuumCmd struct {
-	Since  time.Duration `required:"" help:"specify the duration to delete data before" group:"time" xor:"time"`
-	Before time.Time     `required:"" help:"specify the timestamp to delete data before" group:"time" xor:"time"`
+	Since  itime.Duration `required:"" help:"specify the duration to delete data before" group:"time" xor:"time"`
+	Before time.Time      `required:"" help:"specify the timestamp to delete data before" group:"time" xor:"time"`
 }
 
 func (cmd *VacuumCmd) Run(tt *db.TimeTracker) error {
 	checkpoint := cmd.Before
 	if checkpoint.IsZero() {
-		checkpoint = time.Now().Add(-cmd.Since)
+		checkpoint = time.Now().Add(-cmd.Since.Duration())
 	}
 
 	if err := tt.Vacuum(checkpoint); err != nil {
@@ -243,20 +823,32 @@ func (cmd *RecordCmd) Run(tt *db.TimeTracker) error {
 }
 
 type SyncCmd struct {
-	Login        string `long:"login" short:"l" help:"remote database user login"`
-	Password     string `long:"password" help:"remote database password" env:"TT_SYNC_PASSWORD"`
-	Hostname     string `long:"host" help:"remote database host name"`
-	Port         string `long:"port" short:"p" help:"remote database connection port"`
-	DatabaseName string `long:"dbname" help:"remote database name"`
+	Login         string `long:"login" short:"l" help:"remote database user login"`
+	Password      string `long:"password" help:"remote database password" env:"TT_SYNC_PASSWORD"`
+	Hostname      string `long:"host" help:"remote database host name"`
+	Port          string `long:"port" short:"p" help:"remote database connection port"`
+	DatabaseName  string `long:"dbname" help:"remote database name"`
+	SQLite        string `name:"sqlite" type:"path" help:"synchronise through this sqlite database file, like one in a shared folder, instead of a postgres server"`
+	MergeStrategy string `name:"merge-strategy" enum:"union,local-wins,remote-wins" default:"union" help:"how rows known by both databases with different attributes are resolved: union, local-wins or remote-wins"`
+	Chunked       bool   `help:"commit the synchronisation in several shorter transactions, a failure keeping what has been stored and leaving the rest to the next synchronisation"`
 }
 
-func (cmd *SyncCmd) Run(tt *db.TimeTracker) error {
+func (cmd *SyncCmd) Run(tt *db.TimeTracker, repo *configlite.Repository) error {
+	strategy, err := db.ParseMergeStrategy(cmd.MergeStrategy)
+	if err != nil {
+		return err
+	}
+	opts := db.SyncOptions{MergeStrategy: strategy, Chunked: cmd.Chunked}
 
-	repo, err := configlite.New(configlite.DefaultConfigurationFile())
+	sqlitePath, err := cmd.sqlitePath(repo)
 	if err != nil {
-		return fmt.Errorf("cannot open configuration repository: %w", err)
+		return err
+	}
+	if sqlitePath != "" {
+		return tt.Sync(db.SQLiteSyncer{Path: sqlitePath, SyncOptions: opts})
 	}
 
+	err = nil
 	if cmd.Login == "" {
 		cmd.Login, err = repo.GetConfig(appName, "syncer_login")
 	}
@@ -289,12 +881,263 @@ func (cmd *SyncCmd) Run(tt *db.TimeTracker) error {
 			Hostname:     cmd.Hostname,
 			Port:         portInt,
 			DatabaseName: cmd.DatabaseName,
+			SyncOptions:  opts,
 		})
 	}
 
 	return err
 }
 
+// sqlitePath returns the sqlite database to synchronise with, empty for the
+// postgres server. The syncer_sqlite configuration is ignored when a postgres
+// server flag is given.
+func (cmd *SyncCmd) sqlitePath(repo *configlite.Repository) (string, error) {
+	postgres := cmd.Login != "" || cmd.Hostname != "" || cmd.Port != "" || cmd.DatabaseName != ""
+	if cmd.SQLite != "" {
+		if postgres {
+			return "", fmt.Errorf("%w: --sqlite cannot be used with the postgres server flags",
+				errInvalidParameter)
+		}
+		return cmd.SQLite, nil
+	}
+	if postgres {
+		return "", nil
+	}
+
+	path, err := repo.GetConfig(appName, "syncer_sqlite")
+	if err != nil && !errors.Is(err, configlite.ErrConfigNotFound) {
+		return "", fmt.Errorf("cannot read sqlite syncer configuration: %w", err)
+	}
+	return path, nil
+}
+
+type SyncResetCmd struct {
+	To itime.Time `help:"the new last sync timestamp, the sync history is cleared when not set"`
+}
+
+func (cmd *SyncResetCmd) Run(tt *db.TimeTracker) error {
+	if err := tt.SetLastSync(cmd.To.Time()); err != nil {
+		return fmt.Errorf("cannot reset the last sync timestamp: %w", err)
+	}
+	return nil
+}
+
+type LockCmd struct {
+	Before itime.Time `arg:"" optional:"" help:"intervals started before this timestamp can't be modified anymore nor recorded, unlock all intervals when not set"`
+}
+
+func (cmd *LockCmd) Run(repo *configlite.Repository) error {
+	var lockDate string
+	if t := cmd.Before.Time(); !t.IsZero() {
+		lockDate = t.Format(time.RFC3339)
+	}
+
+	if err := repo.RegisterApplication(appName); err != nil {
+		return fmt.Errorf("cannot register application in configuration repository: %w", err)
+	}
+	if err := repo.UpsertConfig(appName, "lock_date", lockDate); err != nil {
+		return fmt.Errorf("cannot store lock date: %w", err)
+	}
+
+	return nil
+}
+
+type StreakCmd struct {
+	Tag string `required:"" help:"the tag to compute the consecutive tracked days of"`
+}
+
+func (cmd *StreakCmd) Run(tt *db.TimeTracker) error {
+	current, longest, err := tt.Streaks(cmd.Tag, time.Local)
+	if err != nil {
+		return fmt.Errorf("cannot compute %s streaks: %w", cmd.Tag, err)
+	}
+
+	_, err = fmt.Printf("current: %d days\nlongest: %d days\n", current, longest)
+	return err
+}
+
+type SuggestTagsCmd struct {
+	Limit int `help:"the maximum number of suggested tags" default:"5"`
+}
+
+func (cmd *SuggestTagsCmd) Run(tt *db.TimeTracker) error {
+	tags, err := tt.SuggestTags(time.Now(), time.Local, cmd.Limit)
+	if err != nil {
+		return fmt.Errorf("cannot suggest tags: %w", err)
+	}
+
+	for _, tag := range tags {
+		if _, err := fmt.Println(tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type TotalCmd struct{}
+
+func (cmd *TotalCmd) Run(tt *db.TimeTracker) error {
+	total, err := tt.TotalDuration()
+	if err != nil {
+		return fmt.Errorf("cannot compute total tracked time: %w", err)
+	}
+
+	_, err = fmt.Println(total)
+	return err
+}
+
+type RebuildCacheCmd struct{}
+
+func (cmd *RebuildCacheCmd) Run(tt *db.TimeTracker) error {
+	if err := tt.RebuildDailyTotals(); err != nil {
+		return fmt.Errorf("cannot rebuild cache: %w", err)
+	}
+	return nil
+}
+
+// configure applies to the TimeTracker object the settings
+// stored in the configuration repository.
+func configure(tt *db.TimeTracker, repo *configlite.Repository) error {
+	lockDate, err := repo.GetConfig(appName, "lock_date")
+	if err != nil && !errors.Is(err, configlite.ErrConfigNotFound) {
+		return fmt.Errorf("cannot read lock date configuration: %w", err)
+	}
+	if lockDate != "" {
+		t, err := time.Parse(time.RFC3339, lockDate)
+		if err != nil {
+			return fmt.Errorf("cannot parse lock date configuration %s: %w", lockDate, err)
+		}
+		tt.SetLockDate(t)
+	}
+
+	preserve, err := repo.GetConfig(appName, "preserve_tag_timestamps")
+	if err != nil && !errors.Is(err, configlite.ErrConfigNotFound) {
+		return fmt.Errorf("cannot read tag timestamps configuration: %w", err)
+	}
+	if preserve != "" {
+		b, err := strconv.ParseBool(preserve)
+		if err != nil {
+			return fmt.Errorf("cannot parse tag timestamps configuration %s: %w", preserve, err)
+		}
+		tt.SetPreserveTagTimestamps(b)
+	}
+
+	guard, err := repo.GetConfig(appName, "clock_guard")
+	if err != nil && !errors.Is(err, configlite.ErrConfigNotFound) {
+		return fmt.Errorf("cannot read clock guard configuration: %w", err)
+	}
+	if guard != "" {
+		clockGuard, ok := clockGuards[guard]
+		if !ok {
+			return fmt.Errorf("%w: unknown clock guard configuration %s", errInvalidParameter, guard)
+		}
+
+		tolerance := defaultClockTolerance
+		toleranceConfig, err := repo.GetConfig(appName, "clock_guard_tolerance")
+		if err != nil && !errors.Is(err, configlite.ErrConfigNotFound) {
+			return fmt.Errorf("cannot read clock guard tolerance configuration: %w", err)
+		}
+		if toleranceConfig != "" {
+			if tolerance, err = time.ParseDuration(toleranceConfig); err != nil {
+				return fmt.Errorf("cannot parse clock guard tolerance configuration %s: %w", toleranceConfig, err)
+			}
+		}
+		tt.SetClockGuard(clockGuard, tolerance)
+	}
+
+	autoStop, err := repo.GetConfig(appName, "auto_stop_stale")
+	if err != nil && !errors.Is(err, configlite.ErrConfigNotFound) {
+		return fmt.Errorf("cannot read auto stop configuration: %w", err)
+	}
+	if autoStop != "" {
+		threshold, err := time.ParseDuration(autoStop)
+		if err != nil {
+			return fmt.Errorf("cannot parse auto stop configuration %s: %w", autoStop, err)
+		}
+		tt.SetAutoStopStale(threshold)
+	}
+
+	maxTags, err := repo.GetConfig(appName, "max_tags")
+	if err != nil && !errors.Is(err, configlite.ErrConfigNotFound) {
+		return fmt.Errorf("cannot read max tags configuration: %w", err)
+	}
+	if maxTags != "" {
+		limit, err := strconv.Atoi(maxTags)
+		if err != nil || limit < 0 {
+			return fmt.Errorf("%w: invalid max tags configuration %s", errInvalidParameter, maxTags)
+		}
+		tt.SetMaxTags(limit)
+	}
+
+	aliases, err := tagAliases(repo)
+	if err != nil {
+		return err
+	}
+	tt.SetTagAliases(aliases)
+
+	tags, err := defaultTags(repo)
+	if err != nil {
+		return err
+	}
+	if err := tt.SetDefaultTags(tags); err != nil {
+		return fmt.Errorf("cannot configure default tags: %w", err)
+	}
+
+	return nil
+}
+
+// readOnlyCommands are the commands which open the database read-only
+// so that they can report while another process writes.
+var readOnlyCommands = map[string]bool{
+	"at":            true,
+	"current":       true,
+	"export":        true,
+	"heatmap":       true,
+	"histogram":     true,
+	"interval-tags": true,
+	"list":          true,
+	"quota":         true,
+	"running":       true,
+	"streak":        true,
+	"suggest-tags":  true,
+	"summary":       true,
+	"tag-changes":   true,
+	"total":         true,
+	"variance":      true,
+}
+
+// openConfigRepository opens the configuration repository stored in path
+// and registers the application in it on first use.
+func openConfigRepository(path string) (*configlite.Repository, error) {
+	repo, err := configlite.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open configuration repository: %w", err)
+	}
+	if err := repo.RegisterApplication(appName); err != nil {
+		repo.Close()
+		return nil, fmt.Errorf("cannot register application in configuration repository: %w", err)
+	}
+	return repo, nil
+}
+
+// openDatabase opens the application database. A read-only database is
+// opened for writing instead when it doesn't exist yet or when its schema
+// has to be migrated first.
+func openDatabase(path string, readOnly bool) (*db.TimeTracker, error) {
+	if !readOnly {
+		return db.New(path)
+	}
+
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return db.New(path)
+	}
+	tt, err := db.NewReadOnly(path)
+	if errors.Is(err, db.ErrSchemaMismatch) {
+		return db.New(path)
+	}
+	return tt, err
+}
+
 func main() {
 
 	homeDir, err := os.UserHomeDir()
@@ -305,32 +1148,137 @@ func main() {
 	var CLI struct {
 		CommonConfig
 
-		Continue ContinueCmd `cmd:"" help:"start a new interval with same tags as the last closed one"`
-		Current  CurrentCmd  `default:"1" cmd:"" help:"return the current opened interval"`
-		Delete   DeleteCmd   `cmd:"" help:"delete a registered interval"`
-		List     ListCmd     `cmd:"" help:"list intervals"`
-		Record   RecordCmd   `cmd:"" help:"record a new closed interval with it tags"`
-		Start    StartCmd    `cmd:"" help:"start tracking a new time interval"`
-		Stop     StopCmd     `cmd:"" help:"stop tracking the current opened interval"`
-		Sync     SyncCmd     `cmd:"" help:"synchronise with remote central database"`
-		Tag      TagCmd      `cmd:"" help:"tag an interval with given values"`
-		Untag    UntagCmd    `cmd:"" help:"remove tags from an interval"`
-		Vacuum   VacuumCmd   `cmd:"" help:"hard delete old soft deleted data"`
+		Alias        AliasCmd        `cmd:"" help:"replace a tag by a canonical one when it is stored"`
+		Amend        AmendCmd        `cmd:"" help:"change the start or stop timestamp of an interval"`
+		At           AtCmd           `cmd:"" help:"return the interval active at a given timestamp"`
+		Bill         BillCmd         `cmd:"" help:"flag an interval as billable"`
+		Continue     ContinueCmd     `cmd:"" help:"start a new interval with same tags as the last closed one"`
+		Current      CurrentCmd      `default:"1" cmd:"" help:"return the current opened interval"`
+		Debug        DebugCmd        `cmd:"" help:"developer commands to investigate issues"`
+		DefaultTags  DefaultTagsCmd  `cmd:"" help:"configure the tags added to every started interval"`
+		Delete       DeleteCmd       `cmd:"" help:"delete a registered interval"`
+		Doctor       DoctorCmd       `cmd:"" help:"diagnose the application database"`
+		Estimate     EstimateCmd     `cmd:"" help:"set the planned duration of an interval"`
+		Export       ExportCmd       `cmd:"" help:"export recorded intervals"`
+		FixLast      FixLastCmd      `cmd:"" help:"add and remove tags on the last closed interval"`
+		Gaps         GapsCmd         `cmd:"" help:"list the untracked periods of time and optionally fill them"`
+		Heatmap      HeatmapCmd      `cmd:"" help:"print a bar per day proportional to the tracked time"`
+		Histogram    HistogramCmd    `cmd:"" help:"print a bar per hour of the day proportional to the tracked time"`
+		Import       ImportCmd       `cmd:"" help:"import closed intervals from a JSON or CSV file"`
+		IntervalTags IntervalTagsCmd `cmd:"" help:"print the tags of an interval, one per line"`
+		List         ListCmd         `cmd:"" help:"list intervals"`
+		Lock         LockCmd         `cmd:"" help:"prevent modification of intervals started before a timestamp"`
+		Purge        PurgeCmd        `cmd:"" help:"delete all recorded data"`
+		Quota        QuotaCmd        `cmd:"" help:"exit successfully if the tracked time is within a limit over a period"`
+		RebuildCache RebuildCacheCmd `cmd:"" help:"recompute the cached daily totals from the recorded intervals"`
+		Record       RecordCmd       `cmd:"" help:"record a new closed interval with it tags"`
+		RenameTag    RenameTagCmd    `cmd:"" help:"rename a tag on all the intervals"`
+		Running      RunningCmd      `cmd:"" help:"exit successfully if an interval is running, without any output"`
+		Serve        ServeCmd        `cmd:"" help:"serve current and status requests on a unix socket"`
+		Start        StartCmd        `cmd:"" help:"start tracking a new time interval"`
+		Stop         StopCmd         `cmd:"" help:"stop tracking the current opened interval"`
+		Streak       StreakCmd       `cmd:"" help:"show the current and longest runs of consecutive days tracked with a tag"`
+		SuggestTags  SuggestTagsCmd  `cmd:"" help:"print the tags usually tracked at this hour of this weekday"`
+		Summary      SummaryCmd      `cmd:"" help:"print the tracked time per tag"`
+		Sync         SyncCmd         `cmd:"" help:"synchronise with remote central database"`
+		SyncReset    SyncResetCmd    `cmd:"" help:"force the last synchronisation timestamp"`
+		Tag          TagCmd          `cmd:"" help:"tag an interval with given values"`
+		TagChanges   TagChangesCmd   `cmd:"" help:"list the tags added to and removed from intervals over a period"`
+		TagColor     TagColorCmd     `cmd:"" help:"configure the color a tag is displayed with in reports"`
+		Total        TotalCmd        `cmd:"" help:"print the time tracked over the whole history"`
+		Untag        UntagCmd        `cmd:"" help:"remove tags from an interval"`
+		Vacuum       VacuumCmd       `cmd:"" help:"hard delete old soft deleted data"`
+		Variance     VarianceCmd     `cmd:"" help:"compare the estimated and actual durations of intervals"`
 	}
 
-	ctx := kong.Parse(&CLI, kong.Vars{"home": homeDir})
+	ctx := kong.Parse(&CLI, kong.Vars{
+		"home":      homeDir,
+		"config_db": configlite.DefaultConfigurationFile(),
+	})
 
-	tt, err := db.New(CLI.CommonConfig.Database)
-	if err != nil {
-		logrus.WithError(err).Fatal("cannot setup application database")
+	// The configuration repository and the application database are lazily
+	// opened so that commands which don't need them stay fast.
+	var repo *configlite.Repository
+	defer func() {
+		if repo != nil {
+			repo.Close()
+		}
+	}()
+	openRepo := func() (*configlite.Repository, error) {
+		if repo == nil {
+			r, err := openConfigRepository(CLI.CommonConfig.ConfigDB)
+			if err != nil {
+				return nil, err
+			}
+			repo = r
+		}
+		return repo, nil
 	}
+
+	var tt *db.TimeTracker
 	defer func() {
+		if tt == nil {
+			return
+		}
 		if err := tt.Close(); err != nil {
 			logrus.WithError(err).Fatal("cannot close TimeTracker object")
 		}
 	}()
+	openTT := func() (*db.TimeTracker, error) {
+		if tt == nil {
+			r, err := openRepo()
+			if err != nil {
+				return nil, err
+			}
+			readOnly := readOnlyCommands[strings.Fields(ctx.Command())[0]]
+			t, err := openDatabase(CLI.CommonConfig.Database, readOnly)
+			if errors.Is(err, db.ErrMigrationChecksum) {
+				return nil, fmt.Errorf("cannot setup application database, see tt doctor --repair-migrations: %w", err)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("cannot setup application database: %w", err)
+			}
+			tt = t
+			tt.SetForeignKeyCheck(CLI.CommonConfig.FKCheck)
+			if err := configure(tt, r); err != nil {
+				return nil, fmt.Errorf("cannot configure application: %w", err)
+			}
+			if readOnly {
+				return tt, nil
+			}
+			if err := tt.StopStale(); err != nil {
+				return nil, fmt.Errorf("cannot stop stale opened interval: %w", err)
+			}
+		}
+		return tt, nil
+	}
+
+	ctx.Bind(&CLI.CommonConfig)
+	ctx.Bind(ttProvider(openTT))
+	if err := ctx.BindToProvider(openTT); err != nil {
+		logrus.WithError(err).Fatal("cannot bind application database")
+	}
+	if err := ctx.BindToProvider(openRepo); err != nil {
+		logrus.WithError(err).Fatal("cannot bind configuration repository")
+	}
+	if err := ctx.BindToProvider(func() (dayStart, error) {
+		r, err := openRepo()
+		if err != nil {
+			return 0, err
+		}
+		return configuredDayStart(r, CLI.CommonConfig.DayStart)
+	}); err != nil {
+		logrus.WithError(err).Fatal("cannot bind day start")
+	}
 
-	if err := ctx.Run(tt); err != nil {
-		logrus.WithError(err).WithField("command", ctx.Command).Fatal("cannot run command")
+	if err := ctx.Run(); err != nil {
+		// Probe failures are only reported through the exit status
+		if errors.Is(err, errNotRunning) || errors.Is(err, errQuotaExceeded) {
+			os.Exit(1)
+		}
+		if CLI.CommonConfig.JSONErrors {
+			os.Exit(writeJSONError(os.Stderr, err))
+		}
+		logrus.WithError(err).WithField("command", ctx.Command()).Fatal("cannot run command")
 	}
 }
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgsb/configlite"
+
+	itime "github.com/dgsb/tt/internal/time"
+)
+
+// minPeriodTime and maxPeriodTime bound the periods which can be queried.
+// They match the range of dates handled by sqlite date and time functions.
+var (
+	minPeriodTime = time.Date(0, time.January, 1, 0, 0, 0, 0, time.UTC)
+	maxPeriodTime = time.Date(10000, time.January, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// dayStart is the offset from midnight at which the logical days start,
+// like 4h for people working at night. It shifts the day, week, month
+// and year boundaries alike.
+type dayStart time.Duration
+
+// clockOffset parses a time of the day in the hh:mm format and returns
+// its offset from midnight.
+func clockOffset(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// parseDayStart parses a day start given in the hh:mm format.
+func parseDayStart(value string) (dayStart, error) {
+	offset, err := clockOffset(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: day start %s is not in the hh:mm format",
+			errInvalidParameter, value)
+	}
+	return dayStart(offset), nil
+}
+
+// configuredDayStart returns the day start given by override when it is set,
+// otherwise the one stored in the day_start configuration. Days start
+// at midnight when neither is set.
+func configuredDayStart(repo *configlite.Repository, override string) (dayStart, error) {
+	if override != "" {
+		return parseDayStart(override)
+	}
+
+	value, err := repo.GetConfig(appName, "day_start")
+	if err != nil && !errors.Is(err, configlite.ErrConfigNotFound) {
+		return 0, fmt.Errorf("cannot read day start configuration: %w", err)
+	}
+	if value == "" {
+		return 0, nil
+	}
+	offset, err := parseDayStart(value)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse day start configuration: %w", err)
+	}
+	return offset, nil
+}
+
+// dayBoundary returns the local timestamp the logical day of the given date starts at.
+// The offset is applied on the wall clock so that days keep starting at the same hour
+// across daylight saving time changes.
+func dayBoundary(year int, month time.Month, day int, offset dayStart) time.Time {
+	return time.Date(year, month, day, 0, 0, int(time.Duration(offset)/time.Second), 0, time.Local)
+}
+
+// periodBounds returns the boundaries of the logical period containing at,
+// the logical days starting at offset after midnight.
+// A zero at stands for now. An error is returned if the period cannot be queried,
+// see checkPeriod.
+func periodBounds(
+	at time.Time, period string, offset dayStart,
+) (startTime, stopTime time.Time, err error) {
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	// Before the day start, at belongs to the logical day of the previous date
+	year, month, day := at.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, at.Location())
+	if at.Sub(midnight) < time.Duration(offset) {
+		year, month, day = time.Date(year, month, day-1, 0, 0, 0, 0, time.Local).Date()
+	}
+
+	return dateBounds(year, month, day, period, offset)
+}
+
+// dateBounds returns the boundaries of the logical period containing the
+// logical day of the given date, see periodBounds.
+func dateBounds(
+	year int, month time.Month, day int, period string, offset dayStart,
+) (startTime, stopTime time.Time, err error) {
+	switch period {
+	case ":day":
+		startTime = dayBoundary(year, month, day, offset)
+		stopTime = dayBoundary(year, month, day+1, offset)
+	case ":week":
+		weekday := time.Date(year, month, day, 0, 0, 0, 0, time.Local).Weekday()
+		if weekday == time.Sunday {
+			weekday = time.Saturday + 1
+		}
+		startTime = dayBoundary(year, month, day-int(weekday-time.Monday), offset)
+		stopTime = dayBoundary(year, month, day+1+int(time.Saturday+1-weekday), offset)
+	case ":month":
+		startTime = dayBoundary(year, month, 1, offset)
+		stopTime = dayBoundary(year, month+1, 1, offset)
+	case ":year":
+		startTime = dayBoundary(year, time.January, 1, offset)
+		stopTime = dayBoundary(year+1, time.January, 1, offset)
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: time range not implemented %s", errInvalidParameter, period)
+	}
+
+	if err := checkPeriod(startTime, stopTime); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	return startTime, stopTime, nil
+}
+
+// checkPeriod returns an error if [startTime, stopTime) is empty or
+// doesn't fit between minPeriodTime and maxPeriodTime.
+func checkPeriod(startTime, stopTime time.Time) error {
+	if !stopTime.After(startTime) {
+		return fmt.Errorf("%w: empty period [%s, %s)", errInvalidParameter, startTime, stopTime)
+	}
+	if startTime.Before(minPeriodTime) || stopTime.After(maxPeriodTime) {
+		return fmt.Errorf("%w: period [%s, %s) out of the supported range [%s, %s)",
+			errInvalidParameter, startTime, stopTime, minPeriodTime, maxPeriodTime)
+	}
+	return nil
+}
+
+// periodSpans orders the periods from the shortest to the longest.
+var periodSpans = map[string]int{":day": 0, ":week": 1, ":month": 2, ":year": 3}
+
+// coarseDateLayouts are the calendar dates a period can be anchored to,
+// with the period each one designates.
+var coarseDateLayouts = []struct {
+	layout string
+	period string
+}{
+	{layout: "2006-01-02", period: ":day"},
+	{layout: "2006-01", period: ":month"},
+	{layout: "2006", period: ":year"},
+}
+
+// periodAnchor is the starting point of a period. On top of the timestamps
+// understood by itime.Time, it accepts a coarse calendar date like 2023-02-14,
+// 2023-02 or 2023 which designates the logical day, month or year itself.
+type periodAnchor struct {
+	itime.Time
+	// granularity is the period designated by a coarse date, empty for a timestamp.
+	granularity string
+}
+
+func (a *periodAnchor) UnmarshalText(data []byte) error {
+	for _, coarse := range coarseDateLayouts {
+		t, err := time.ParseInLocation(coarse.layout, string(data), time.Local)
+		if err == nil {
+			a.Time, a.granularity = itime.Time(t), coarse.period
+			return nil
+		}
+	}
+
+	a.granularity = ""
+	return a.Time.UnmarshalText(data)
+}
+
+// bounds returns the boundaries of the logical period anchored at a.
+// A coarse date is taken as a logical date, whatever the day start, and
+// must be at least as precise as the period: 2023-02 anchors a month or
+// a year but not a week.
+func (a *periodAnchor) bounds(
+	period string, offset dayStart,
+) (startTime, stopTime time.Time, err error) {
+	if a.granularity == "" {
+		return periodBounds(a.Time.Time(), period, offset)
+	}
+
+	if span, ok := periodSpans[period]; ok && span < periodSpans[a.granularity] {
+		return time.Time{}, time.Time{}, fmt.Errorf(
+			"%w: a %s date is too coarse to anchor a %s period",
+			errInvalidParameter, a.granularity, period)
+	}
+	year, month, day := a.Time.Time().Date()
+	return dateBounds(year, month, day, period, offset)
+}
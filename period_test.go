@@ -0,0 +1,170 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeriodBounds(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+	}
+	anchor := time.Date(2022, time.February, 23, 15, 4, 5, 0, time.Local)
+
+	for _, tc := range []struct {
+		name   string
+		at     time.Time
+		period string
+		start  time.Time
+		stop   time.Time
+		err    error
+	}{
+		{name: "day", at: anchor, period: ":day", start: date(2022, 2, 23), stop: date(2022, 2, 24)},
+		{name: "week", at: anchor, period: ":week", start: date(2022, 2, 21), stop: date(2022, 2, 28)},
+		{name: "week on sunday", at: date(2022, 2, 27), period: ":week", start: date(2022, 2, 21), stop: date(2022, 2, 28)},
+		{name: "month", at: anchor, period: ":month", start: date(2022, 2, 1), stop: date(2022, 3, 1)},
+		{name: "year", at: anchor, period: ":year", start: date(2022, 1, 1), stop: date(2023, 1, 1)},
+		{name: "december month", at: date(2021, 12, 15), period: ":month", start: date(2021, 12, 1), stop: date(2022, 1, 1)},
+		{name: "far future day", at: date(9999, 6, 15), period: ":day", start: date(9999, 6, 15), stop: date(9999, 6, 16)},
+		{name: "beyond the supported range", at: date(10000, 6, 15), period: ":year", err: errInvalidParameter},
+		{name: "before the supported range", at: date(-1, 6, 15), period: ":month", err: errInvalidParameter},
+		{name: "unknown period", at: anchor, period: ":decade", err: errInvalidParameter},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			start, stop, err := periodBounds(tc.at, tc.period, 0)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.start, start)
+			require.Equal(t, tc.stop, stop)
+		})
+	}
+
+	t.Run("zero anchor stands for now", func(t *testing.T) {
+		start, stop, err := periodBounds(time.Time{}, ":day", 0)
+		require.NoError(t, err)
+		now := time.Now()
+		require.False(t, now.Before(start))
+		require.True(t, now.Before(stop))
+	})
+}
+
+func TestCheckPeriod(t *testing.T) {
+	at := time.Date(2022, time.February, 23, 0, 0, 0, 0, time.UTC)
+
+	require.NoError(t, checkPeriod(at, at.Add(time.Second)))
+	require.ErrorIs(t, checkPeriod(at, at), errInvalidParameter)
+	require.ErrorIs(t, checkPeriod(at, at.Add(-time.Hour)), errInvalidParameter)
+	require.NoError(t, checkPeriod(minPeriodTime, maxPeriodTime))
+	require.ErrorIs(t, checkPeriod(minPeriodTime.Add(-time.Second), at), errInvalidParameter)
+	require.ErrorIs(t, checkPeriod(at, maxPeriodTime.Add(time.Second)), errInvalidParameter)
+}
+
+func TestPeriodBoundsDayStart(t *testing.T) {
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2022, month, day, hour, 0, 0, 0, time.Local)
+	}
+	offset := dayStart(4 * time.Hour)
+
+	// A session from Sunday 23:00 to Monday 02:00 belongs to Sunday's logical day
+	sessionStart, sessionStop := at(time.February, 27, 23), at(time.February, 28, 2)
+
+	for _, tc := range []struct {
+		name   string
+		at     time.Time
+		period string
+		start  time.Time
+		stop   time.Time
+	}{
+		{name: "day before midnight", at: sessionStart, period: ":day", start: at(2, 27, 4), stop: at(2, 28, 4)},
+		{name: "day after midnight", at: sessionStop, period: ":day", start: at(2, 27, 4), stop: at(2, 28, 4)},
+		{name: "day after the day start", at: at(2, 28, 5), period: ":day", start: at(2, 28, 4), stop: at(3, 1, 4)},
+		{name: "week before midnight", at: sessionStart, period: ":week", start: at(2, 21, 4), stop: at(2, 28, 4)},
+		{name: "week after midnight", at: sessionStop, period: ":week", start: at(2, 21, 4), stop: at(2, 28, 4)},
+		{name: "week after the day start", at: at(2, 28, 5), period: ":week", start: at(2, 28, 4), stop: at(3, 7, 4)},
+		{name: "month after midnight", at: at(3, 1, 2), period: ":month", start: at(2, 1, 4), stop: at(3, 1, 4)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			start, stop, err := periodBounds(tc.at, tc.period, offset)
+			require.NoError(t, err)
+			require.Equal(t, tc.start, start)
+			require.Equal(t, tc.stop, stop)
+		})
+	}
+
+	t.Run("session is not split", func(t *testing.T) {
+		for _, period := range []string{":day", ":week"} {
+			start, stop, err := periodBounds(sessionStart, period, offset)
+			require.NoError(t, err)
+			require.False(t, sessionStart.Before(start))
+			require.True(t, sessionStop.Before(stop))
+		}
+	})
+
+	t.Run("parse", func(t *testing.T) {
+		offset, err := parseDayStart("04:30")
+		require.NoError(t, err)
+		require.Equal(t, dayStart(4*time.Hour+30*time.Minute), offset)
+
+		_, err = parseDayStart("4am")
+		require.ErrorIs(t, err, errInvalidParameter)
+	})
+}
+
+func TestPeriodAnchor(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		at     string
+		period string
+		offset dayStart
+		start  time.Time
+		stop   time.Time
+		err    error
+	}{
+		{name: "month", at: "2023-02", period: ":month", start: date(2023, 2, 1), stop: date(2023, 3, 1)},
+		{name: "year", at: "2023", period: ":year", start: date(2023, 1, 1), stop: date(2024, 1, 1)},
+		{name: "year of a month", at: "2023-02", period: ":year", start: date(2023, 1, 1), stop: date(2024, 1, 1)},
+		{name: "week of a day", at: "2023-02-15", period: ":week", start: date(2023, 2, 13), stop: date(2023, 2, 20)},
+		{name: "month after the day start", at: "2023-02", period: ":month", offset: dayStart(4 * time.Hour),
+			start: date(2023, 2, 1).Add(4 * time.Hour), stop: date(2023, 3, 1).Add(4 * time.Hour)},
+		{name: "timestamp", at: "2023-02-15T10:00:00", period: ":day", start: date(2023, 2, 15), stop: date(2023, 2, 16)},
+		{name: "month too coarse for a week", at: "2023-02", period: ":week", err: errInvalidParameter},
+		{name: "year too coarse for a month", at: "2023", period: ":month", err: errInvalidParameter},
+		{name: "unknown period", at: "2023", period: ":decade", err: errInvalidParameter},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var anchor periodAnchor
+			require.NoError(t, anchor.UnmarshalText([]byte(tc.at)))
+			start, stop, err := anchor.bounds(tc.period, tc.offset)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.start, start)
+			require.Equal(t, tc.stop, stop)
+		})
+	}
+
+	t.Run("unparsable", func(t *testing.T) {
+		var anchor periodAnchor
+		require.Error(t, anchor.UnmarshalText([]byte("2023-13")))
+	})
+
+	t.Run("zero anchor stands for now", func(t *testing.T) {
+		var anchor periodAnchor
+		start, stop, err := anchor.bounds(":day", 0)
+		require.NoError(t, err)
+		now := time.Now()
+		require.False(t, now.Before(start))
+		require.True(t, now.Before(stop))
+	})
+}
@@ -0,0 +1,50 @@
+//nolint:lll // we accept long lines for struct field tags used by kong
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/dgsb/tt/internal/db"
+	itime "github.com/dgsb/tt/internal/time"
+)
+
+// quotaReport writes the time tracked by the intervals against limit.
+// The opened interval is considered as stopping at now.
+// It returns errQuotaExceeded when the tracked time is over the limit.
+func quotaReport(tas []db.TaggedInterval, limit time.Duration, now time.Time, out io.Writer) error {
+	var total time.Duration
+	for _, ta := range tas {
+		total += intervalDurationAt(ta, now)
+	}
+
+	if total > limit {
+		if _, err := fmt.Fprintf(out, "%s tracked, %s over the %s limit\n", total, total-limit, limit); err != nil {
+			return err
+		}
+		return errQuotaExceeded
+	}
+	_, err := fmt.Fprintf(out, "%s tracked, %s left before the %s limit\n", total, limit-total, limit)
+	return err
+}
+
+type QuotaCmd struct {
+	At     periodAnchor   `help:"another starting point for the required time period instead of now, or the day, month or year itself like 2023-02"`
+	Limit  itime.Duration `required:"" help:"the maximum tracked time over the period"`
+	Period string         `arg:"" help:"a logical description of the time period to look at" default:":week" enum:":week,:day,:month,:year"`
+}
+
+func (cmd *QuotaCmd) Run(tt *db.TimeTracker, offset dayStart) error {
+	startTime, stopTime, err := cmd.At.bounds(cmd.Period, offset)
+	if err != nil {
+		return err
+	}
+
+	taggedIntervals, err := tt.List(startTime, stopTime)
+	if err != nil {
+		return fmt.Errorf("cannot list recorded interval: %w", err)
+	}
+	return quotaReport(taggedIntervals, cmd.Limit.Duration(), time.Now(), os.Stdout)
+}
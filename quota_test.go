@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dgsb/tt/internal/db"
+)
+
+func TestQuotaReport(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2022, 3, day, hour, 0, 0, 0, time.UTC)
+	}
+	tas := []db.TaggedInterval{
+		{Interval: db.Interval{StartTimestamp: at(1, 8), StopTimestamp: at(1, 18)}},
+		{Interval: db.Interval{StartTimestamp: at(2, 8), StopTimestamp: at(2, 18)}},
+		// The opened interval is counted up to now
+		{Interval: db.Interval{StartTimestamp: at(3, 8)}},
+	}
+	now := at(3, 12)
+
+	for _, tc := range []struct {
+		name  string
+		limit time.Duration
+		out   string
+		err   error
+	}{
+		{name: "under", limit: 40 * time.Hour, out: "24h0m0s tracked, 16h0m0s left before the 40h0m0s limit\n"},
+		{name: "exactly at", limit: 24 * time.Hour, out: "24h0m0s tracked, 0s left before the 24h0m0s limit\n"},
+		{name: "over", limit: 20 * time.Hour, out: "24h0m0s tracked, 4h0m0s over the 20h0m0s limit\n", err: errQuotaExceeded},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := quotaReport(tas, tc.limit, now, &out)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.out, out.String())
+		})
+	}
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"encoding/csv"
 	"fmt"
 	"io"
 	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -17,13 +19,131 @@ func sameDate(t1, t2 time.Time) bool {
 	return year1 == year2 && month1 == month2 && day1 == day2
 }
 
-func FlatReport(tas []db.TaggedInterval, out io.Writer) error {
+// precisions maps the supported report precision names to
+// the duration used to truncate displayed timestamps and round durations.
+var precisions = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+}
+
+// truncateClock truncates the wall clock of t to the given precision.
+// Unlike time.Truncate, it is computed from the local midnight so that
+// time zones with a non whole hour offset are properly handled.
+func truncateClock(t time.Time, precision time.Duration) time.Time {
+	year, month, day := t.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	return midnight.Add(t.Sub(midnight).Truncate(precision))
+}
+
+// clockLayout returns the time layout displaying a wall clock at the given precision.
+func clockLayout(precision time.Duration) string {
+	if precision < time.Minute {
+		return "15:04:05"
+	}
+	return "15:04"
+}
+
+// IDDisplay selects how interval identifiers are rendered in reports.
+type IDDisplay int
+
+const (
+	// CanonicalIDs displays the global interval identifiers.
+	CanonicalIDs IDDisplay = iota
+	// DailyIDs displays a per day sequence followed by the canonical identifier.
+	DailyIDs
+)
+
+// idDisplays maps the supported id display names to their value.
+var idDisplays = map[string]IDDisplay{
+	"canonical": CanonicalIDs,
+	"daily":     DailyIDs,
+}
+
+// reportDay returns the day an interval is reported on.
+func reportDay(ta db.TaggedInterval) string {
+	return ta.Interval.StartTimestamp.Format("2006-01-02")
+}
+
+// dailyIndices numbers the intervals of each day starting from 1.
+// The returned map is keyed by canonical interval identifier.
+// tas must be sorted by start timestamp.
+func dailyIndices(tas []db.TaggedInterval) map[string]int {
+	indices := make(map[string]int, len(tas))
+	var (
+		prevDay string
+		index   int
+	)
+	for _, ta := range tas {
+		day := reportDay(ta)
+		if day != prevDay {
+			index = 0
+			prevDay = day
+		}
+		index++
+		indices[ta.Interval.ID] = index
+	}
+	return indices
+}
+
+// originalTimes returns a copy of tas with the timestamps of each interval
+// in the time zone it has been started in. The intervals without a recorded
+// time zone are kept as is.
+func originalTimes(tas []db.TaggedInterval) []db.TaggedInterval {
+	ret := make([]db.TaggedInterval, len(tas))
+	for idx, ta := range tas {
+		ta.Interval.StartTimestamp = ta.Interval.OriginalStart()
+		ta.Interval.StopTimestamp = ta.Interval.OriginalStop()
+		ret[idx] = ta
+	}
+	return ret
+}
+
+// FlatReportOptions holds the rendering parameters of FlatReport.
+type FlatReportOptions struct {
+	// Precision is used to truncate displayed timestamps and round durations.
+	// It defaults to time.Second.
+	Precision time.Duration
+	// IDs selects how interval identifiers are displayed.
+	IDs IDDisplay
+	// Reverse displays the intervals newest first. The input must
+	// still be sorted by ascending start timestamp.
+	Reverse bool
+	// Color displays each tag with its color from TagColors,
+	// or with a color derived from its name when it has none.
+	Color     bool
+	TagColors map[string]string
+	// NoTotal suppresses the total time footer.
+	NoTotal bool
+	// Location adds a column with the location each interval has been started at.
+	Location bool
+	// Now is the timestamp the opened interval is considered as stopping at.
+	// It defaults to the current time.
+	Now time.Time
+}
+
+func FlatReport(tas []db.TaggedInterval, out io.Writer, opts FlatReportOptions) error {
 	if !sort.SliceIsSorted(tas, func(i, j int) bool {
 		return tas[i].Interval.StartTimestamp.Unix() < tas[j].Interval.StartTimestamp.Unix()
 	}) {
 		return fmt.Errorf("%w: input tagged interval is not sorted", errInvalidParameter)
 	}
 
+	precision := opts.Precision
+	if precision == 0 {
+		precision = time.Second
+	}
+	layout := clockLayout(precision)
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	var indices map[string]int
+	if opts.IDs == DailyIDs {
+		indices = dailyIndices(tas)
+	}
+
 	tab := tabwriter.NewWriter(out, 16, 4, 0, ' ', 0)
 
 	var prevStartTime time.Time
@@ -37,40 +157,490 @@ func FlatReport(tas []db.TaggedInterval, out io.Writer) error {
 	}
 	for i := 0; i < len(tas) && err == nil; i++ {
 		ta := tas[i]
+		if opts.Reverse {
+			ta = tas[len(tas)-1-i]
+		}
 		if !sameDate(prevStartTime, ta.Interval.StartTimestamp) {
 			twrite(ta.Interval.StartTimestamp.Format("2006-01-02"))
 		}
 		twrite("\t")
-		twrite(ta.Interval.ID)
-		twrite("\t")
-		twrite(ta.Interval.StartTimestamp.Format("15:04:05"))
+		if indices != nil {
+			twrite(fmt.Sprintf("%d [%s]", indices[ta.Interval.ID], ta.Interval.ID))
+		} else {
+			twrite(ta.Interval.ID)
+		}
 		twrite("\t")
-		twrite(ta.Interval.StopTimestamp.Format("15:04:05"))
+		twrite(truncateClock(ta.Interval.StartTimestamp, precision).Format(layout))
 		twrite("\t")
-
 		if ta.Interval.StopTimestamp.IsZero() {
-			ta.Interval.StopTimestamp = time.Now().Truncate(time.Second)
+			twrite("(running)")
+		} else {
+			twrite(truncateClock(ta.Interval.StopTimestamp, precision).Format(layout))
 		}
-		duration := ta.Interval.StopTimestamp.Sub(ta.Interval.StartTimestamp)
+		twrite("\t")
+
+		duration := intervalDurationAt(ta, now)
 		totalDuration += duration
-		twrite(duration.String())
+		twrite(duration.Round(precision).String())
 		twrite("\t")
 
-		twrite(strings.Join(ta.Tags, ","))
+		tags := ta.Tags
+		if opts.Color {
+			tags = make([]string, len(ta.Tags))
+			for i, tag := range ta.Tags {
+				tags[i] = colorizeTag(tag, opts.TagColors)
+			}
+		}
+		twrite(strings.Join(tags, ","))
 		twrite("\t")
 
+		if opts.Location {
+			twrite(ta.Interval.Place)
+			twrite("\t")
+		}
+
 		twrite("\n")
 
 		prevStartTime = ta.Interval.StartTimestamp
 	}
-	twrite("\n")
-	twrite("Total time")
-	twrite("\t\t\t\t")
-	twrite(totalDuration.String())
-	twrite("\n")
+	if !opts.NoTotal {
+		twrite("\n")
+		twrite("Total time")
+		twrite("\t\t\t\t")
+		twrite(totalDuration.Round(precision).String())
+		twrite("\n")
+	}
 	if err == nil {
 		err = tab.Flush()
 	}
 
 	return err
 }
+
+// CSVReport writes the intervals as CSV records with a header line:
+// id,start,stop,duration_seconds,tags. Timestamps are RFC 3339 and durations
+// integer seconds. Unlike the export, tags are joined with semicolons so that
+// spreadsheets don't split them. The stop field is empty for the opened interval.
+func CSVReport(tas []db.TaggedInterval, out io.Writer) error {
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"id", "start", "stop", "duration_seconds", "tags"}); err != nil {
+		return fmt.Errorf("cannot write csv header: %w", err)
+	}
+
+	for _, ta := range tas {
+		var stop string
+		if !ta.Interval.StopTimestamp.IsZero() {
+			stop = ta.Interval.StopTimestamp.Format(time.RFC3339)
+		}
+		if err := w.Write([]string{
+			ta.Interval.ID,
+			ta.Interval.StartTimestamp.Format(time.RFC3339),
+			stop,
+			strconv.FormatInt(int64(intervalDuration(ta).Seconds()), 10),
+			strings.Join(ta.Tags, ";"),
+		}); err != nil {
+			return fmt.Errorf("cannot write csv record: %w", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("cannot flush csv output: %w", err)
+	}
+	return nil
+}
+
+// MarkdownReportOptions holds the rendering parameters of MarkdownReport.
+type MarkdownReportOptions struct {
+	// Precision is used to truncate displayed timestamps and round durations.
+	// It defaults to time.Second.
+	Precision time.Duration
+	// NoTotal suppresses the total time row.
+	NoTotal bool
+	// IDs selects how interval identifiers are displayed.
+	IDs IDDisplay
+	// Reverse displays the intervals newest first. The input must
+	// still be sorted by ascending start timestamp.
+	Reverse bool
+	// Location adds a column with the location each interval has been started at.
+	Location bool
+}
+
+// MarkdownReport writes the intervals as a GitHub flavored markdown table
+// with the columns Date, ID, Start, Stop, Duration and Tags followed by a total
+// row unless NoTotal is set. The pipes of the tags and locations are escaped
+// so that they don't split the cells.
+func MarkdownReport(tas []db.TaggedInterval, out io.Writer, opts MarkdownReportOptions) error {
+	precision := opts.Precision
+	if precision == 0 {
+		precision = time.Second
+	}
+	layout := clockLayout(precision)
+	escape := strings.NewReplacer("|", `\|`)
+	row := func(cells ...string) error {
+		_, err := fmt.Fprintf(out, "| %s |\n", strings.Join(cells, " | "))
+		return err
+	}
+	// withLocation appends the location cell when the column is displayed
+	withLocation := func(cells []string, location string) []string {
+		if opts.Location {
+			cells = append(cells, location)
+		}
+		return cells
+	}
+
+	var indices map[string]int
+	if opts.IDs == DailyIDs {
+		indices = dailyIndices(tas)
+	}
+
+	if err := row(withLocation(
+		[]string{"Date", "ID", "Start", "Stop", "Duration", "Tags"}, "Location")...); err != nil {
+		return err
+	}
+	if err := row(withLocation(
+		[]string{"---", "---", "---", "---", "---:", "---"}, "---")...); err != nil {
+		return err
+	}
+
+	var total time.Duration
+	for i := range tas {
+		ta := tas[i]
+		if opts.Reverse {
+			ta = tas[len(tas)-1-i]
+		}
+		stop := "(running)"
+		if !ta.Interval.StopTimestamp.IsZero() {
+			stop = truncateClock(ta.Interval.StopTimestamp, precision).Format(layout)
+		}
+		duration := intervalDuration(ta)
+		total += duration
+
+		tags := make([]string, len(ta.Tags))
+		for idx, tag := range ta.Tags {
+			tags[idx] = escape.Replace(tag)
+		}
+
+		id := ta.Interval.ID
+		if indices != nil {
+			id = fmt.Sprintf("%d [%s]", indices[ta.Interval.ID], ta.Interval.ID)
+		}
+
+		if err := row(withLocation([]string{
+			ta.Interval.StartTimestamp.Format("2006-01-02"),
+			id,
+			truncateClock(ta.Interval.StartTimestamp, precision).Format(layout),
+			stop,
+			duration.Round(precision).String(),
+			strings.Join(tags, ", "),
+		}, escape.Replace(ta.Interval.Place))...); err != nil {
+			return err
+		}
+	}
+
+	if opts.NoTotal {
+		return nil
+	}
+	return row(withLocation(
+		[]string{"**Total**", "", "", "", total.Round(precision).String(), ""}, "")...)
+}
+
+// tagSummary is the tracked time aggregated for a single tag.
+type tagSummary struct {
+	Tag      string
+	Count    int
+	Duration time.Duration
+}
+
+// intervalDuration returns the duration of an interval. The opened interval
+// is considered as stopping now.
+func intervalDuration(ta db.TaggedInterval) time.Duration {
+	return intervalDurationAt(ta, time.Now())
+}
+
+// intervalDurationAt returns the duration of an interval. The opened interval
+// is considered as stopping at now.
+func intervalDurationAt(ta db.TaggedInterval, now time.Time) time.Duration {
+	stop := ta.Interval.StopTimestamp
+	if stop.IsZero() {
+		stop = now.Truncate(time.Second)
+	}
+	return stop.Sub(ta.Interval.StartTimestamp)
+}
+
+// untaggedSummary is the summary entry of the intervals without any tag.
+// The parentheses keep it apart from a tag named untagged.
+const untaggedSummary = "(untagged)"
+
+// summarize aggregates interval durations per tag sorted by descending duration.
+// An interval carrying several tags contributes its whole duration to each of them,
+// the intervals without any tag are aggregated under untaggedSummary.
+// It also returns the distinct tracked time where each interval is counted once
+// whatever its number of tags. Only the time within hours is accounted.
+func summarize(
+	tas []db.TaggedInterval, hours workHours,
+) (summaries []tagSummary, total time.Duration) {
+	durations := map[string]time.Duration{}
+	counts := map[string]int{}
+	for _, ta := range tas {
+		duration := hours.intervalDuration(ta)
+		total += duration
+		if len(ta.Tags) == 0 {
+			durations[untaggedSummary] += duration
+			counts[untaggedSummary]++
+		}
+		for _, tag := range ta.Tags {
+			durations[tag] += duration
+			counts[tag]++
+		}
+	}
+
+	for tag, duration := range durations {
+		summaries = append(summaries, tagSummary{Tag: tag, Count: counts[tag], Duration: duration})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Duration != summaries[j].Duration {
+			return summaries[i].Duration > summaries[j].Duration
+		}
+		return summaries[i].Tag < summaries[j].Tag
+	})
+
+	return summaries, total
+}
+
+// filterBillable returns the intervals whose billable flag is billable.
+func filterBillable(tas []db.TaggedInterval, billable bool) []db.TaggedInterval {
+	var filtered []db.TaggedInterval
+	for _, ta := range tas {
+		if ta.Billable == billable {
+			filtered = append(filtered, ta)
+		}
+	}
+	return filtered
+}
+
+// percent formats the share of total represented by d with one decimal.
+// "-" is returned when total is zero.
+func percent(d, total time.Duration) string {
+	if total == 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(float64(d)/float64(total)*100, 'f', 1, 64) + "%"
+}
+
+// SummaryReport prints the tracked time and the number of intervals per tag
+// along with its share of the total.
+// The total is the distinct tracked time: an interval carrying several tags is
+// counted once. As such the percentages of multi tagged intervals add up to more than 100%.
+// Only the time within hours is accounted.
+func SummaryReport(tas []db.TaggedInterval, out io.Writer, hours workHours) error {
+	summaries, total := summarize(tas, hours)
+
+	tab := tabwriter.NewWriter(out, 16, 4, 0, ' ', 0)
+	for _, s := range summaries {
+		if _, err := fmt.Fprintf(tab, "%s\t%s\t%d\t%s\t\n",
+			s.Tag, s.Duration, s.Count, percent(s.Duration, total)); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(tab, "\nTotal time\t%s\t\n", total); err != nil {
+		return err
+	}
+
+	return tab.Flush()
+}
+
+// SummaryCSV writes the summary computed by SummaryReport as CSV records with a header
+// line: group,count,duration_seconds,percent. Durations are integer seconds and
+// percentages have one decimal without the percent sign. The percent field is empty
+// when nothing has been tracked.
+func SummaryCSV(tas []db.TaggedInterval, out io.Writer, hours workHours) error {
+	summaries, total := summarize(tas, hours)
+
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"group", "count", "duration_seconds", "percent"}); err != nil {
+		return fmt.Errorf("cannot write csv header: %w", err)
+	}
+	for _, s := range summaries {
+		share := ""
+		if total != 0 {
+			share = strconv.FormatFloat(float64(s.Duration)/float64(total)*100, 'f', 1, 64)
+		}
+		if err := w.Write([]string{
+			s.Tag,
+			strconv.Itoa(s.Count),
+			strconv.FormatInt(int64(s.Duration.Seconds()), 10),
+			share,
+		}); err != nil {
+			return fmt.Errorf("cannot write csv record: %w", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("cannot flush csv output: %w", err)
+	}
+	return nil
+}
+
+// heatmapWidth is the number of characters of the longest heatmap bar.
+const heatmapWidth = 40
+
+// dayTotal is the tracked time of a single day.
+type dayTotal struct {
+	Day      time.Time
+	Duration time.Duration
+}
+
+// dailyTotals sums the tracked time of each day between from and until.
+// from is expected to be a midnight. Intervals spanning midnight are split
+// among the days they cover. The opened interval is considered as stopping now.
+func dailyTotals(tas []db.TaggedInterval, from, until time.Time) []dayTotal {
+	var totals []dayTotal
+	for day := from; day.Before(until); day = day.AddDate(0, 0, 1) {
+		next := day.AddDate(0, 0, 1)
+		total := dayTotal{Day: day}
+		for _, ta := range tas {
+			start := ta.Interval.StartTimestamp
+			stop := start.Add(intervalDuration(ta))
+			if start.Before(day) {
+				start = day
+			}
+			if stop.After(next) {
+				stop = next
+			}
+			if stop.After(start) {
+				total.Duration += stop.Sub(start)
+			}
+		}
+		totals = append(totals, total)
+	}
+	return totals
+}
+
+// heatmapBar returns a bar whose length is proportional to d, max being
+// displayed with heatmapWidth characters.
+func heatmapBar(d, max time.Duration) string {
+	if max == 0 {
+		return ""
+	}
+	length := int(float64(d)/float64(max)*heatmapWidth + 0.5)
+	return strings.Repeat("█", length)
+}
+
+// HeatmapReport prints a bar per day between from and until
+// proportional to the time tracked that day.
+func HeatmapReport(tas []db.TaggedInterval, from, until time.Time, out io.Writer) error {
+	totals := dailyTotals(tas, from, until)
+
+	var max time.Duration
+	for _, t := range totals {
+		if t.Duration > max {
+			max = t.Duration
+		}
+	}
+
+	tab := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, t := range totals {
+		if _, err := fmt.Fprintf(tab, "%s\t%s\t%s\n",
+			t.Day.Format("Mon 2006-01-02"), heatmapBar(t.Duration, max), t.Duration); err != nil {
+			return err
+		}
+	}
+
+	return tab.Flush()
+}
+
+// hourlyTotals sums the tracked time between from and until per hour of the day
+// in the time zone of from. Intervals are split at each hour boundary they cross:
+// an interval from 10:45 to 11:15 contributes 15 minutes to hours 10 and 11.
+// The opened interval is considered as stopping now.
+func hourlyTotals(tas []db.TaggedInterval, from, until time.Time) [24]time.Duration {
+	var totals [24]time.Duration
+	loc := from.Location()
+	for _, ta := range tas {
+		start := ta.Interval.StartTimestamp
+		stop := start.Add(intervalDuration(ta))
+		if start.Before(from) {
+			start = from
+		}
+		if stop.After(until) {
+			stop = until
+		}
+		for start.Before(stop) {
+			local := start.In(loc)
+			next := time.Date(local.Year(), local.Month(), local.Day(), local.Hour()+1, 0, 0, 0, loc)
+			if next.After(stop) {
+				next = stop
+			}
+			totals[local.Hour()] += next.Sub(start)
+			start = next
+		}
+	}
+	return totals
+}
+
+// HistogramReport prints a bar per hour of the day proportional to
+// the time tracked during this hour between from and until.
+func HistogramReport(tas []db.TaggedInterval, from, until time.Time, out io.Writer) error {
+	totals := hourlyTotals(tas, from, until)
+
+	var max time.Duration
+	for _, d := range totals {
+		if d > max {
+			max = d
+		}
+	}
+
+	tab := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for hour, d := range totals {
+		if _, err := fmt.Fprintf(tab, "%02d:00\t%s\t%s\n", hour, heatmapBar(d, max), d); err != nil {
+			return err
+		}
+	}
+
+	return tab.Flush()
+}
+
+// estimateVariance compares the actual duration of an interval with its estimate.
+// The variance is positive when the interval took longer than estimated.
+func estimateVariance(actual, estimate time.Duration) (variance string, verdict string) {
+	switch d := actual - estimate; {
+	case d > 0:
+		return "+" + d.String(), "over"
+	case d < 0:
+		return d.String(), "under"
+	default:
+		return d.String(), "on estimate"
+	}
+}
+
+// EstimateReport compares the estimate of each interval with its actual duration.
+// Intervals without estimate are ignored. The opened interval is considered as stopping now.
+func EstimateReport(tas []db.TaggedInterval, out io.Writer) error {
+	tab := tabwriter.NewWriter(out, 8, 4, 0, ' ', 0)
+
+	var totalEstimate, totalActual time.Duration
+	for _, ta := range tas {
+		if ta.Estimate == 0 {
+			continue
+		}
+		actual := intervalDuration(ta)
+		totalEstimate += ta.Estimate
+		totalActual += actual
+
+		variance, verdict := estimateVariance(actual, ta.Estimate)
+		if _, err := fmt.Fprintf(tab, "%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			ta.Interval.ID, strings.Join(ta.Tags, ","), ta.Estimate, actual, variance, verdict); err != nil {
+			return err
+		}
+	}
+
+	variance, verdict := estimateVariance(totalActual, totalEstimate)
+	if _, err := fmt.Fprintf(tab, "\nTotal\t\t%s\t%s\t%s\t%s\t\n",
+		totalEstimate, totalActual, variance, verdict); err != nil {
+		return err
+	}
+
+	return tab.Flush()
+}
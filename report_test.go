@@ -0,0 +1,591 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dgsb/tt/internal/db"
+)
+
+func TestFlatReport(t *testing.T) {
+	t.Run("precision", func(t *testing.T) {
+		tas := []db.TaggedInterval{
+			{
+				Interval: db.Interval{
+					ID:             "1",
+					StartTimestamp: time.Date(2022, 2, 25, 12, 14, 35, 0, time.UTC),
+					StopTimestamp:  time.Date(2022, 2, 25, 13, 49, 50, 0, time.UTC),
+				},
+				Tags: []string{"tag1"},
+			},
+		}
+
+		for _, tc := range []struct {
+			precision string
+			expected  []string
+		}{
+			{"second", []string{"12:14:35", "13:49:50", "1h35m15s"}},
+			{"minute", []string{"12:14 ", "13:49 ", "1h35m0s"}},
+			{"hour", []string{"12:00 ", "13:00 ", "2h0m0s"}},
+		} {
+			t.Run(tc.precision, func(t *testing.T) {
+				var out bytes.Buffer
+				err := FlatReport(tas, &out, FlatReportOptions{Precision: precisions[tc.precision]})
+				require.NoError(t, err)
+				for _, e := range tc.expected {
+					require.Contains(t, out.String(), e)
+				}
+			})
+		}
+	})
+
+	t.Run("precision with half hour time zone", func(t *testing.T) {
+		loc := time.FixedZone("test", 5*3600+1800)
+		tas := []db.TaggedInterval{
+			{
+				Interval: db.Interval{
+					ID:             "1",
+					StartTimestamp: time.Date(2022, 2, 25, 12, 14, 35, 0, loc),
+					StopTimestamp:  time.Date(2022, 2, 25, 13, 49, 50, 0, loc),
+				},
+			},
+		}
+
+		var out bytes.Buffer
+		err := FlatReport(tas, &out, FlatReportOptions{Precision: time.Hour})
+		require.NoError(t, err)
+		require.Contains(t, out.String(), "12:00 ")
+		require.Contains(t, out.String(), "13:00 ")
+	})
+	t.Run("daily ids", func(t *testing.T) {
+		tas := []db.TaggedInterval{
+			{Interval: db.Interval{
+				ID:             "12",
+				StartTimestamp: time.Date(2022, 2, 25, 9, 0, 0, 0, time.UTC),
+				StopTimestamp:  time.Date(2022, 2, 25, 10, 0, 0, 0, time.UTC),
+			}},
+			{Interval: db.Interval{
+				ID:             "15",
+				StartTimestamp: time.Date(2022, 2, 25, 11, 0, 0, 0, time.UTC),
+				StopTimestamp:  time.Date(2022, 2, 25, 12, 0, 0, 0, time.UTC),
+			}},
+			{Interval: db.Interval{
+				ID:             "16",
+				StartTimestamp: time.Date(2022, 2, 26, 9, 0, 0, 0, time.UTC),
+				StopTimestamp:  time.Date(2022, 2, 26, 10, 0, 0, 0, time.UTC),
+			}},
+		}
+
+		require.Equal(t, map[string]int{"12": 1, "15": 2, "16": 1}, dailyIndices(tas))
+
+		var out bytes.Buffer
+		require.NoError(t, FlatReport(tas, &out, FlatReportOptions{IDs: DailyIDs}))
+		require.Contains(t, out.String(), "1 [12]")
+		require.Contains(t, out.String(), "2 [15]")
+		require.Contains(t, out.String(), "1 [16]")
+	})
+	t.Run("reverse", func(t *testing.T) {
+		tas := []db.TaggedInterval{
+			{Interval: db.Interval{
+				ID:             "1",
+				StartTimestamp: time.Date(2022, 2, 25, 9, 0, 0, 0, time.UTC),
+				StopTimestamp:  time.Date(2022, 2, 25, 10, 0, 0, 0, time.UTC),
+			}},
+			{Interval: db.Interval{
+				ID:             "2",
+				StartTimestamp: time.Date(2022, 2, 25, 11, 0, 0, 0, time.UTC),
+				StopTimestamp:  time.Date(2022, 2, 25, 12, 0, 0, 0, time.UTC),
+			}},
+			{Interval: db.Interval{
+				ID:             "3",
+				StartTimestamp: time.Date(2022, 2, 26, 9, 0, 0, 0, time.UTC),
+				StopTimestamp:  time.Date(2022, 2, 26, 10, 0, 0, 0, time.UTC),
+			}},
+		}
+
+		var out bytes.Buffer
+		require.NoError(t, FlatReport(tas, &out, FlatReportOptions{Reverse: true}))
+		lines := strings.Split(out.String(), "\n")
+		require.Regexp(t, `^2022-02-26 +3 +09:00:00`, lines[0])
+		require.Regexp(t, `^2022-02-25 +2 +11:00:00`, lines[1])
+		require.Regexp(t, `^ +1 +09:00:00`, lines[2])
+	})
+	t.Run("no total", func(t *testing.T) {
+		tas := []db.TaggedInterval{
+			{Interval: db.Interval{
+				ID:             "1",
+				StartTimestamp: time.Date(2022, 2, 25, 9, 0, 0, 0, time.UTC),
+				StopTimestamp:  time.Date(2022, 2, 25, 10, 0, 0, 0, time.UTC),
+			}},
+		}
+
+		var out bytes.Buffer
+		require.NoError(t, FlatReport(tas, &out, FlatReportOptions{}))
+		require.Contains(t, out.String(), "Total time")
+
+		out.Reset()
+		require.NoError(t, FlatReport(tas, &out, FlatReportOptions{NoTotal: true}))
+		require.NotContains(t, out.String(), "Total time")
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		require.Len(t, lines, 1)
+		require.Regexp(t, `^2022-02-25 +1 +09:00:00`, lines[0])
+	})
+
+	t.Run("running interval", func(t *testing.T) {
+		tas := []db.TaggedInterval{
+			{Interval: db.Interval{
+				ID:             "1",
+				StartTimestamp: time.Date(2022, 2, 25, 9, 0, 0, 0, time.UTC),
+				StopTimestamp:  time.Date(2022, 2, 25, 10, 0, 0, 0, time.UTC),
+			}},
+			{Interval: db.Interval{
+				ID:             "2",
+				StartTimestamp: time.Date(2022, 2, 25, 10, 30, 0, 0, time.UTC),
+			}},
+		}
+
+		var out bytes.Buffer
+		now := time.Date(2022, 2, 25, 11, 15, 20, 0, time.UTC)
+		require.NoError(t, FlatReport(tas, &out, FlatReportOptions{Now: now}))
+		lines := strings.Split(out.String(), "\n")
+		require.Regexp(t, `^ +2 +10:30:00 +\(running\) +45m20s `, lines[1])
+		require.NotContains(t, out.String(), "00:00:00")
+		require.Regexp(t, `^Total time +1h45m20s`, lines[3])
+	})
+
+	t.Run("location", func(t *testing.T) {
+		tas := []db.TaggedInterval{
+			{Interval: db.Interval{
+				ID:             "1",
+				StartTimestamp: time.Date(2022, 2, 25, 9, 0, 0, 0, time.UTC),
+				StopTimestamp:  time.Date(2022, 2, 25, 10, 0, 0, 0, time.UTC),
+				Place:          "Lyon",
+			}, Tags: []string{"travel"}},
+		}
+
+		var out bytes.Buffer
+		require.NoError(t, FlatReport(tas, &out, FlatReportOptions{}))
+		require.NotContains(t, out.String(), "Lyon")
+
+		out.Reset()
+		require.NoError(t, FlatReport(tas, &out, FlatReportOptions{Location: true}))
+		require.Regexp(t, `^2022-02-25 +1 +09:00:00 +10:00:00 +1h0m0s +travel +Lyon`, out.String())
+	})
+}
+
+func TestCSVReport(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+	tas := []db.TaggedInterval{
+		{
+			Interval: db.Interval{ID: "1", StartTimestamp: at(8), StopTimestamp: at(9)},
+			Tags:     []string{"a", "b"},
+		},
+		{
+			Interval: db.Interval{ID: "2", StartTimestamp: at(9), StopTimestamp: at(11)},
+			Tags:     []string{"c"},
+		},
+		{
+			Interval: db.Interval{ID: "3", StartTimestamp: time.Now().Add(-time.Minute)},
+		},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, CSVReport(tas, &out))
+	require.NotContains(t, out.String(), `"`)
+
+	records, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, [][]string{
+		{"id", "start", "stop", "duration_seconds", "tags"},
+		{"1", "2022-03-01T08:00:00Z", "2022-03-01T09:00:00Z", "3600", "a;b"},
+		{"2", "2022-03-01T09:00:00Z", "2022-03-01T11:00:00Z", "7200", "c"},
+	}, records[:3])
+	require.Equal(t, "", records[3][2])
+	require.Equal(t, "", records[3][4])
+}
+
+func TestOriginalTimes(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+	tas := []db.TaggedInterval{
+		{Interval: db.Interval{ID: "1", StartTimestamp: at(8), StopTimestamp: at(9), Location: tokyo}},
+		{Interval: db.Interval{ID: "2", StartTimestamp: at(10), StopTimestamp: at(11)}},
+		{Interval: db.Interval{ID: "3", StartTimestamp: at(12), Location: tokyo}},
+	}
+
+	original := originalTimes(tas)
+	require.Len(t, original, len(tas))
+	for idx := range tas {
+		require.True(t, original[idx].Interval.StartTimestamp.Equal(tas[idx].Interval.StartTimestamp))
+		require.True(t, original[idx].Interval.StopTimestamp.Equal(tas[idx].Interval.StopTimestamp))
+	}
+	require.Equal(t, tokyo, original[0].Interval.StartTimestamp.Location())
+	require.Equal(t, time.UTC, original[1].Interval.StartTimestamp.Location())
+	require.True(t, original[2].Interval.StopTimestamp.IsZero())
+	// The input is left untouched
+	require.Equal(t, time.UTC, tas[0].Interval.StartTimestamp.Location())
+
+	var out bytes.Buffer
+	require.NoError(t, FlatReport(original, &out, FlatReportOptions{Now: at(13)}))
+	require.Contains(t, out.String(), "17:00:00")
+	require.Contains(t, out.String(), "18:00:00")
+	require.Contains(t, out.String(), "10:00:00")
+	require.Contains(t, out.String(), "21:00:00")
+}
+
+func TestMarkdownReport(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2022, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+	tas := []db.TaggedInterval{
+		{
+			Interval: db.Interval{ID: "1", StartTimestamp: at(8), StopTimestamp: at(9)},
+			Tags:     []string{"a", "b"},
+		},
+		{
+			Interval: db.Interval{ID: "2", StartTimestamp: at(9), StopTimestamp: at(11)},
+			Tags:     []string{"x|y"},
+		},
+		{
+			Interval: db.Interval{ID: "3", StartTimestamp: at(12), StopTimestamp: at(12)},
+		},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, MarkdownReport(tas, &out, MarkdownReportOptions{}))
+	require.Equal(t, `| Date | ID | Start | Stop | Duration | Tags |
+| --- | --- | --- | --- | ---: | --- |
+| 2022-03-01 | 1 | 08:00:00 | 09:00:00 | 1h0m0s | a, b |
+| 2022-03-01 | 2 | 09:00:00 | 11:00:00 | 2h0m0s | x\|y |
+| 2022-03-01 | 3 | 12:00:00 | 12:00:00 | 0s |  |
+| **Total** |  |  |  | 3h0m0s |  |
+`, out.String())
+
+	// Every row holds the same number of cells once the escaped pipes are ignored
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		require.True(t, strings.HasPrefix(line, "| ") && strings.HasSuffix(line, " |"), line)
+		require.Equal(t, 7, strings.Count(strings.ReplaceAll(line, `\|`, ""), "|"), line)
+	}
+
+	out.Reset()
+	// Recorded timestamps have a second precision
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	running := []db.TaggedInterval{{Interval: db.Interval{ID: "4", StartTimestamp: start}}}
+	require.NoError(t, MarkdownReport(running, &out, MarkdownReportOptions{}))
+	require.Contains(t, out.String(), "| (running) | 1h0m")
+
+	out.Reset()
+	require.NoError(t, MarkdownReport(tas, &out, MarkdownReportOptions{
+		Precision: time.Minute,
+		NoTotal:   true,
+	}))
+	require.Equal(t, `| Date | ID | Start | Stop | Duration | Tags |
+| --- | --- | --- | --- | ---: | --- |
+| 2022-03-01 | 1 | 08:00 | 09:00 | 1h0m0s | a, b |
+| 2022-03-01 | 2 | 09:00 | 11:00 | 2h0m0s | x\|y |
+| 2022-03-01 | 3 | 12:00 | 12:00 | 0s |  |
+`, out.String())
+}
+
+func TestMarkdownReportOptions(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2022, 3, day, hour, 0, 0, 0, time.UTC)
+	}
+	tas := []db.TaggedInterval{
+		{Interval: db.Interval{ID: "4", StartTimestamp: at(1, 8), StopTimestamp: at(1, 9), Place: "Lyon"}},
+		{Interval: db.Interval{ID: "7", StartTimestamp: at(1, 9), StopTimestamp: at(1, 10)}},
+		{Interval: db.Interval{ID: "9", StartTimestamp: at(2, 8), StopTimestamp: at(2, 9), Place: "a|b"}},
+	}
+	rows := func(t *testing.T, opts MarkdownReportOptions) []string {
+		var out bytes.Buffer
+		require.NoError(t, MarkdownReport(tas, &out, opts))
+		return strings.Split(strings.TrimSpace(out.String()), "\n")
+	}
+
+	t.Run("reverse", func(t *testing.T) {
+		lines := rows(t, MarkdownReportOptions{Reverse: true, NoTotal: true})
+		require.Len(t, lines, 5)
+		require.Contains(t, lines[2], "| 9 |")
+		require.Contains(t, lines[3], "| 7 |")
+		require.Contains(t, lines[4], "| 4 |")
+	})
+
+	t.Run("daily ids", func(t *testing.T) {
+		lines := rows(t, MarkdownReportOptions{IDs: DailyIDs, NoTotal: true})
+		require.Contains(t, lines[2], "| 1 [4] |")
+		require.Contains(t, lines[3], "| 2 [7] |")
+		require.Contains(t, lines[4], "| 1 [9] |")
+	})
+
+	t.Run("location", func(t *testing.T) {
+		lines := rows(t, MarkdownReportOptions{Location: true})
+		require.Equal(t, "| Date | ID | Start | Stop | Duration | Tags | Location |", lines[0])
+		require.True(t, strings.HasSuffix(lines[2], "|  | Lyon |"), lines[2])
+		require.True(t, strings.HasSuffix(lines[4], `|  | a\|b |`), lines[4])
+		for _, line := range lines {
+			require.Equal(t, 8, strings.Count(strings.ReplaceAll(line, `\|`, ""), "|"), line)
+		}
+	})
+}
+
+func TestListCSVDisplayFlags(t *testing.T) {
+	for _, cmd := range []ListCmd{
+		{Format: "csv", IDs: "canonical", Reverse: true},
+		{Format: "csv", IDs: "daily"},
+		{Format: "csv", IDs: "canonical", Location: true},
+	} {
+		cmd := cmd
+		err := cmd.Run(nil, &CommonConfig{}, nil, 0)
+		require.ErrorIs(t, err, errInvalidParameter)
+	}
+}
+
+func TestSummaryReport(t *testing.T) {
+	interval := func(id string, start, stop int, tags ...string) db.TaggedInterval {
+		return db.TaggedInterval{
+			Interval: db.Interval{
+				ID:             id,
+				StartTimestamp: time.Date(2022, 2, 25, start, 0, 0, 0, time.UTC),
+				StopTimestamp:  time.Date(2022, 2, 25, stop, 0, 0, 0, time.UTC),
+			},
+			Tags: tags,
+		}
+	}
+
+	t.Run("single tag percentages", func(t *testing.T) {
+		tas := []db.TaggedInterval{
+			interval("1", 8, 9, "a"),
+			interval("2", 9, 11, "b"),
+			interval("3", 11, 12, "a"),
+			interval("4", 12, 14, "c"),
+		}
+
+		summaries, total := summarize(tas, workHours{})
+		require.Equal(t, 6*time.Hour, total)
+		require.Equal(t, []tagSummary{
+			{Tag: "a", Count: 2, Duration: 2 * time.Hour},
+			{Tag: "b", Count: 1, Duration: 2 * time.Hour},
+			{Tag: "c", Count: 1, Duration: 2 * time.Hour},
+		}, summaries)
+
+		var out bytes.Buffer
+		require.NoError(t, SummaryReport(tas, &out, workHours{}))
+		require.Equal(t, 3, strings.Count(out.String(), "33.3%"))
+		require.Contains(t, out.String(), "Total time      6h0m0s")
+	})
+
+	t.Run("multi tagged intervals are counted once in the total", func(t *testing.T) {
+		tas := []db.TaggedInterval{
+			interval("1", 8, 9, "a", "b"),
+			interval("2", 9, 10, "a"),
+		}
+
+		var out bytes.Buffer
+		require.NoError(t, SummaryReport(tas, &out, workHours{}))
+		require.Contains(t, out.String(), "100.0%")
+		require.Contains(t, out.String(), "50.0%")
+		require.Contains(t, out.String(), "Total time      2h0m0s")
+	})
+
+	t.Run("zero total", func(t *testing.T) {
+		require.Equal(t, "-", percent(0, 0))
+
+		var out bytes.Buffer
+		tas := []db.TaggedInterval{interval("1", 8, 8, "a")}
+		require.NoError(t, SummaryReport(tas, &out, workHours{}))
+		require.Contains(t, out.String(), "0s              1               -")
+	})
+
+	t.Run("untagged intervals", func(t *testing.T) {
+		tas := []db.TaggedInterval{
+			interval("1", 8, 9, "a"),
+			interval("2", 9, 11),
+			interval("3", 11, 14),
+		}
+
+		summaries, total := summarize(tas, workHours{})
+		require.Equal(t, 6*time.Hour, total)
+		require.Equal(t, []tagSummary{
+			{Tag: untaggedSummary, Count: 2, Duration: 5 * time.Hour},
+			{Tag: "a", Count: 1, Duration: time.Hour},
+		}, summaries)
+
+		var out bytes.Buffer
+		require.NoError(t, SummaryReport(tas, &out, workHours{}))
+		require.Contains(t, out.String(), "(untagged)      5h0m0s          2               83.3%")
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		tas := []db.TaggedInterval{
+			interval("1", 8, 9, "a", "b"),
+			interval("2", 9, 11, "a"),
+			interval("3", 11, 12, "c"),
+		}
+
+		var out bytes.Buffer
+		require.NoError(t, SummaryCSV(tas, &out, workHours{}))
+		require.NotContains(t, out.String(), `"`)
+
+		records, err := csv.NewReader(&out).ReadAll()
+		require.NoError(t, err)
+		require.Equal(t, []string{"group", "count", "duration_seconds", "percent"}, records[0])
+		require.Len(t, records, 4)
+		require.Equal(t, []string{"a", "2", "10800", "75.0"}, records[1])
+	})
+}
+
+func TestHeatmapReport(t *testing.T) {
+	day := func(d, hour int) time.Time {
+		return time.Date(2022, 2, d, hour, 0, 0, 0, time.UTC)
+	}
+	interval := func(start, stop time.Time) db.TaggedInterval {
+		return db.TaggedInterval{Interval: db.Interval{StartTimestamp: start, StopTimestamp: stop}}
+	}
+
+	t.Run("proportional bars", func(t *testing.T) {
+		tas := []db.TaggedInterval{
+			interval(day(21, 8), day(21, 12)),
+			interval(day(22, 9), day(22, 10)),
+			interval(day(23, 22), day(24, 2)),
+		}
+
+		totals := dailyTotals(tas, day(21, 0), day(28, 0))
+		require.Len(t, totals, 7)
+		require.Equal(t, 4*time.Hour, totals[0].Duration)
+		require.Equal(t, time.Hour, totals[1].Duration)
+		require.Equal(t, 2*time.Hour, totals[2].Duration)
+		require.Equal(t, 2*time.Hour, totals[3].Duration)
+		require.Zero(t, totals[4].Duration)
+
+		var out bytes.Buffer
+		require.NoError(t, HeatmapReport(tas, day(21, 0), day(28, 0), &out))
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		require.Len(t, lines, 7)
+		for idx, expected := range []int{40, 10, 20, 20, 0, 0, 0} {
+			require.Equal(t, expected, strings.Count(lines[idx], "█"), lines[idx])
+		}
+		require.True(t, strings.HasPrefix(lines[0], "Mon 2022-02-21"))
+	})
+
+	t.Run("no data", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, HeatmapReport(nil, day(21, 0), day(28, 0), &out))
+		require.Equal(t, 7, strings.Count(out.String(), "\n"))
+		require.NotContains(t, out.String(), "█")
+	})
+}
+
+func TestEstimateReport(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2022, 2, 25, hour, minute, 0, 0, time.UTC)
+	}
+	interval := func(id string, start, stop time.Time, estimate time.Duration) db.TaggedInterval {
+		return db.TaggedInterval{
+			Interval: db.Interval{ID: id, StartTimestamp: start, StopTimestamp: stop},
+			Tags:     []string{"task" + id},
+			Estimate: estimate,
+		}
+	}
+
+	tas := []db.TaggedInterval{
+		interval("1", at(8, 0), at(9, 30), time.Hour),
+		interval("2", at(9, 30), at(10, 0), time.Hour),
+		interval("3", at(10, 0), at(11, 0), 0),
+		interval("4", at(11, 0), at(12, 0), time.Hour),
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, EstimateReport(tas, &out))
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 5)
+	require.Equal(t, []string{"1", "task1", "1h0m0s", "1h30m0s", "+30m0s", "over"}, strings.Fields(lines[0]))
+	require.Equal(t, []string{"2", "task2", "1h0m0s", "30m0s", "-30m0s", "under"}, strings.Fields(lines[1]))
+	require.Equal(t, []string{"4", "task4", "1h0m0s", "1h0m0s", "0s", "on", "estimate"}, strings.Fields(lines[2]))
+	require.Empty(t, strings.TrimSpace(lines[3]))
+	require.Equal(t, []string{"Total", "3h0m0s", "3h0m0s", "0s", "on", "estimate"}, strings.Fields(lines[4]))
+}
+
+func TestHistogramReport(t *testing.T) {
+	at := func(d, hour, minute int) time.Time {
+		return time.Date(2022, 2, d, hour, minute, 0, 0, time.UTC)
+	}
+	interval := func(start, stop time.Time) db.TaggedInterval {
+		return db.TaggedInterval{Interval: db.Interval{StartTimestamp: start, StopTimestamp: stop}}
+	}
+
+	t.Run("split across hours", func(t *testing.T) {
+		tas := []db.TaggedInterval{
+			interval(at(21, 10, 45), at(21, 11, 15)),
+			interval(at(21, 13, 20), at(21, 16, 10)),
+			interval(at(22, 10, 0), at(22, 10, 30)),
+			interval(at(22, 23, 30), at(23, 1, 0)),
+		}
+
+		totals := hourlyTotals(tas, at(21, 0, 0), at(28, 0, 0))
+		expected := [24]time.Duration{}
+		expected[0] = time.Hour
+		expected[10] = 45 * time.Minute
+		expected[11] = 15 * time.Minute
+		expected[13] = 40 * time.Minute
+		expected[14] = time.Hour
+		expected[15] = time.Hour
+		expected[16] = 10 * time.Minute
+		expected[23] = 30 * time.Minute
+		require.Equal(t, expected, totals)
+
+		var out bytes.Buffer
+		require.NoError(t, HistogramReport(tas, at(21, 0, 0), at(28, 0, 0), &out))
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		require.Len(t, lines, 24)
+		require.True(t, strings.HasPrefix(lines[10], "10:00"))
+		require.Equal(t, 40, strings.Count(lines[0], "█"), lines[0])
+		require.Equal(t, 30, strings.Count(lines[10], "█"), lines[10])
+		require.Equal(t, 7, strings.Count(lines[16], "█"), lines[16])
+		require.Zero(t, strings.Count(lines[12], "█"), lines[12])
+	})
+
+	t.Run("clipped to the period", func(t *testing.T) {
+		tas := []db.TaggedInterval{interval(at(20, 23, 15), at(21, 0, 45))}
+
+		totals := hourlyTotals(tas, at(21, 0, 0), at(28, 0, 0))
+		require.Equal(t, 45*time.Minute, totals[0])
+		require.Zero(t, totals[23])
+	})
+
+	t.Run("other time zone", func(t *testing.T) {
+		loc := time.FixedZone("UTC+0530", 5*3600+1800)
+		tas := []db.TaggedInterval{interval(at(21, 4, 0), at(21, 5, 0))}
+
+		totals := hourlyTotals(tas, at(21, 0, 0).In(loc), at(28, 0, 0).In(loc))
+		require.Equal(t, 30*time.Minute, totals[9])
+		require.Equal(t, 30*time.Minute, totals[10])
+	})
+}
+
+func TestFilterBillable(t *testing.T) {
+	tas := []db.TaggedInterval{
+		{Interval: db.Interval{ID: "1"}, Billable: true},
+		{Interval: db.Interval{ID: "2"}},
+		{Interval: db.Interval{ID: "3"}, Billable: true},
+	}
+
+	ids := func(tas []db.TaggedInterval) []string {
+		var ids []string
+		for _, ta := range tas {
+			ids = append(ids, ta.Interval.ID)
+		}
+		return ids
+	}
+	require.Equal(t, []string{"1", "3"}, ids(filterBillable(tas, true)))
+	require.Equal(t, []string{"2"}, ids(filterBillable(tas, false)))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dgsb/tt/internal/db"
+)
+
+func TestRunning(t *testing.T) {
+	tt, err := db.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, tt.Close()) })
+
+	// run calls the command with the standard output captured
+	run := func(t *testing.T, cmd RunningCmd) (string, error) {
+		r, w, err := os.Pipe()
+		require.NoError(t, err)
+		stdout := os.Stdout
+		os.Stdout = w
+		defer func() { os.Stdout = stdout }()
+
+		runErr := cmd.Run(tt)
+		require.NoError(t, w.Close())
+		out, err := io.ReadAll(r)
+		require.NoError(t, err)
+		return string(out), runErr
+	}
+
+	ok, err := running(tt)
+	require.NoError(t, err)
+	require.False(t, ok)
+	out, err := run(t, RunningCmd{})
+	require.ErrorIs(t, err, errNotRunning)
+	require.Empty(t, out)
+
+	require.NoError(t, tt.Start(time.Now().Add(-time.Hour), []string{"a"}))
+	ok, err = running(tt)
+	require.NoError(t, err)
+	require.True(t, ok)
+	out, err = run(t, RunningCmd{})
+	require.NoError(t, err)
+	require.Empty(t, out)
+
+	out, err = run(t, RunningCmd{Context: "other"})
+	require.ErrorIs(t, err, errNotRunning)
+	require.Empty(t, out)
+}
+
+func TestCurrentStartEpoch(t *testing.T) {
+	tt, err := db.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, tt.Close()) })
+	open := func() (*db.TimeTracker, error) { return tt, nil }
+
+	// run calls the command with the standard output captured
+	run := func(t *testing.T) string {
+		r, w, err := os.Pipe()
+		require.NoError(t, err)
+		stdout := os.Stdout
+		os.Stdout = w
+		defer func() { os.Stdout = stdout }()
+
+		require.NoError(t, (&CurrentCmd{StartEpoch: true}).Run(open))
+		require.NoError(t, w.Close())
+		out, err := io.ReadAll(r)
+		require.NoError(t, err)
+		return string(out)
+	}
+
+	require.Empty(t, run(t))
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, tt.Start(start, []string{"a"}))
+	require.Equal(t, strconv.FormatInt(start.Unix(), 10)+"\n", run(t))
+
+	require.NoError(t, tt.StopAt(time.Now()))
+	require.Empty(t, run(t))
+}
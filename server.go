@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/dgsb/tt/internal/db"
+)
+
+// The serve protocol is a single request line per connection. The server
+// writes back the response and closes the connection.
+const (
+	requestCurrent = "current"
+	requestStatus  = "status"
+)
+
+const serverTimeout = 5 * time.Second
+
+// server answers read only requests from a long lived TimeTracker object.
+// The database accesses are serialized.
+type server struct {
+	mu sync.Mutex
+	tt *db.TimeTracker
+}
+
+// handle writes on w the response to a single request.
+func (s *server) handle(w io.Writer, request string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch strings.TrimSpace(request) {
+	case requestCurrent:
+		interval, err := s.tt.Current()
+		if err != nil {
+			return fmt.Errorf("cannot retrieve current interval: %w", err)
+		}
+		if interval == nil {
+			return nil
+		}
+		return FlatReport([]db.TaggedInterval{*interval}, w, FlatReportOptions{})
+	case requestStatus:
+		interval, err := s.tt.Current()
+		if err != nil {
+			return fmt.Errorf("cannot retrieve current interval: %w", err)
+		}
+		if interval == nil {
+			_, err = fmt.Fprintln(w, "idle")
+			return err
+		}
+		_, err = fmt.Fprintf(w, "running %s %s\n",
+			interval.ID, interval.StartTimestamp.Format(time.RFC3339))
+		return err
+	default:
+		return fmt.Errorf("%w: unknown request %q", errInvalidParameter, request)
+	}
+}
+
+func (s *server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(serverTimeout)); err != nil {
+		logrus.WithError(err).Error("cannot set connection deadline")
+		return
+	}
+
+	request, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		logrus.WithError(err).Error("cannot read request")
+		return
+	}
+
+	if err := s.handle(conn, request); err != nil {
+		logrus.WithError(err).WithField("request", request).Error("cannot handle request")
+		fmt.Fprintf(conn, "error: %s\n", err)
+	}
+}
+
+// queryServer sends a single request to a tt serve process
+// and copies its response to out. Nothing is written to out when the
+// response cannot be read entirely or when the server reports an error,
+// errServerFailure being returned in the latter case.
+func queryServer(socket, request string, out io.Writer) error {
+	conn, err := net.DialTimeout("unix", socket, serverTimeout)
+	if err != nil {
+		return fmt.Errorf("cannot connect to %s: %w", socket, err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(serverTimeout)); err != nil {
+		return fmt.Errorf("cannot set connection deadline: %w", err)
+	}
+	if _, err := fmt.Fprintln(conn, request); err != nil {
+		return fmt.Errorf("cannot send request: %w", err)
+	}
+	var response bytes.Buffer
+	if _, err := io.Copy(&response, conn); err != nil {
+		return fmt.Errorf("cannot read response: %w", err)
+	}
+	if reply := response.String(); strings.HasPrefix(reply, "error: ") {
+		return fmt.Errorf("%w: %s",
+			errServerFailure, strings.TrimSpace(strings.TrimPrefix(reply, "error: ")))
+	}
+
+	_, err = response.WriteTo(out)
+	return err
+}
+
+type ServeCmd struct {
+	Socket string `required:"" help:"the unix socket to listen on" env:"TT_SOCKET"`
+}
+
+func (cmd *ServeCmd) Run(tt *db.TimeTracker) error {
+	if conn, err := net.Dial("unix", cmd.Socket); err == nil {
+		conn.Close()
+		return fmt.Errorf("%w: socket %s is already served", errInvalidParameter, cmd.Socket)
+	}
+	if err := os.Remove(cmd.Socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("cannot remove stale socket %s: %w", cmd.Socket, err)
+	}
+
+	listener, err := net.Listen("unix", cmd.Socket)
+	if err != nil {
+		return fmt.Errorf("cannot listen on %s: %w", cmd.Socket, err)
+	}
+	defer listener.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	srv := &server{tt: tt}
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("cannot accept connection: %w", err)
+		}
+		go srv.serveConn(conn)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dgsb/tt/internal/db"
+)
+
+func TestServer(t *testing.T) {
+	tt, err := db.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, tt.Close())
+	})
+	srv := &server{tt: tt}
+
+	t.Run("idle", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, srv.handle(&out, "current\n"))
+		require.Empty(t, out.String())
+
+		out.Reset()
+		require.NoError(t, srv.handle(&out, "status\n"))
+		require.Equal(t, "idle\n", out.String())
+	})
+
+	t.Run("running", func(t *testing.T) {
+		start := time.Now().Add(-time.Hour).Truncate(time.Second)
+		require.NoError(t, tt.Start(start, []string{"tag1", "tag2"}))
+		t.Cleanup(func() {
+			require.NoError(t, tt.StopAt(start.Add(time.Minute)))
+		})
+
+		var out bytes.Buffer
+		require.NoError(t, srv.handle(&out, "current\n"))
+		require.Contains(t, out.String(), start.Format("15:04:05"))
+		require.Contains(t, out.String(), "tag1,tag2")
+
+		out.Reset()
+		require.NoError(t, srv.handle(&out, "status\n"))
+		require.Equal(t, "running 1 "+start.Format(time.RFC3339)+"\n", out.String())
+	})
+
+	t.Run("unknown request", func(t *testing.T) {
+		var out bytes.Buffer
+		require.ErrorIs(t, srv.handle(&out, "list\n"), errInvalidParameter)
+	})
+}
+
+func TestQueryServer(t *testing.T) {
+	tt, err := db.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, tt.Close())
+	})
+
+	socket := filepath.Join(t.TempDir(), "tt.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	srv := &server{tt: tt}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			srv.serveConn(conn)
+		}
+	}()
+
+	var out bytes.Buffer
+	require.NoError(t, queryServer(socket, requestStatus, &out))
+	require.Equal(t, "idle\n", out.String())
+
+	out.Reset()
+	require.ErrorIs(t, queryServer(socket, "list", &out), errServerFailure)
+	require.Empty(t, out.String())
+}
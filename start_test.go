@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dgsb/tt/internal/db"
+	itime "github.com/dgsb/tt/internal/time"
+)
+
+func TestStartStopPrevAt(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2022, 3, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	tt, err := db.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, tt.Close()) })
+
+	start := StartCmd{At: itime.Time(at(10, 0)), Tags: []string{"a"}}
+	require.NoError(t, start.Run(tt))
+
+	// The running interval is stopped at the new start by default
+	start = StartCmd{At: itime.Time(at(11, 0)), Tags: []string{"b"}}
+	require.NoError(t, start.Run(tt))
+
+	// The previous interval must be stopped before the new start
+	for _, stop := range []time.Time{at(12, 0), at(12, 30)} {
+		start = StartCmd{At: itime.Time(at(12, 0)), StopPrevAt: itime.Time(stop)}
+		start.Tags = []string{"c"}
+		require.ErrorIs(t, start.Run(tt), errInvalidParameter)
+	}
+
+	start = StartCmd{At: itime.Time(at(12, 0)), StopPrevAt: itime.Time(at(11, 45))}
+	start.Tags = []string{"c"}
+	require.NoError(t, start.Run(tt))
+	require.NoError(t, tt.StopAt(at(13, 0)))
+
+	intervals, err := tt.List(at(0, 0), at(23, 0))
+	require.NoError(t, err)
+	require.Len(t, intervals, 3)
+	for idx, expected := range []struct {
+		tag         string
+		start, stop time.Time
+	}{
+		{"a", at(10, 0), at(11, 0)},
+		{"b", at(11, 0), at(11, 45)},
+		{"c", at(12, 0), at(13, 0)},
+	} {
+		require.Equal(t, []string{expected.tag}, intervals[idx].Tags)
+		require.True(t, expected.start.Equal(intervals[idx].StartTimestamp))
+		require.True(t, expected.stop.Equal(intervals[idx].StopTimestamp))
+	}
+}
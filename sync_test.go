@@ -0,0 +1,37 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/dgsb/configlite"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncSQLitePath(t *testing.T) {
+	repo, err := configlite.New(filepath.Join(t.TempDir(), "config.db"))
+	require.NoError(t, err)
+	t.Cleanup(repo.Close)
+
+	path, err := (&SyncCmd{}).sqlitePath(repo)
+	require.NoError(t, err)
+	require.Empty(t, path)
+
+	require.NoError(t, repo.RegisterApplication(appName))
+	require.NoError(t, repo.UpsertConfig(appName, "syncer_sqlite", "/shared/tt.db"))
+	path, err = (&SyncCmd{}).sqlitePath(repo)
+	require.NoError(t, err)
+	require.Equal(t, "/shared/tt.db", path)
+
+	// Explicit flags take precedence over the configuration
+	path, err = (&SyncCmd{SQLite: "/other/tt.db"}).sqlitePath(repo)
+	require.NoError(t, err)
+	require.Equal(t, "/other/tt.db", path)
+
+	path, err = (&SyncCmd{Hostname: "db.example.com"}).sqlitePath(repo)
+	require.NoError(t, err)
+	require.Empty(t, path)
+
+	_, err = (&SyncCmd{SQLite: "/other/tt.db", Login: "me"}).sqlitePath(repo)
+	require.ErrorIs(t, err, errInvalidParameter)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dgsb/tt/internal/db"
+)
+
+// workHours is a daily window of time, like 09:00-18:00, given as offsets
+// from the local midnight. The zero value stands for the whole day.
+type workHours struct {
+	start time.Duration
+	stop  time.Duration
+}
+
+// parseWorkHours parses a window of time given as two hh:mm times separated by a dash.
+// An empty value stands for the whole day.
+func parseWorkHours(value string) (workHours, error) {
+	if value == "" {
+		return workHours{}, nil
+	}
+
+	from, to, found := strings.Cut(value, "-")
+	if !found {
+		return workHours{}, fmt.Errorf("%w: work hours %s are not in the hh:mm-hh:mm format",
+			errInvalidParameter, value)
+	}
+	start, err := clockOffset(from)
+	if err != nil {
+		return workHours{}, fmt.Errorf("%w: work hours start %s is not in the hh:mm format",
+			errInvalidParameter, from)
+	}
+	stop, err := clockOffset(to)
+	if err != nil {
+		return workHours{}, fmt.Errorf("%w: work hours stop %s is not in the hh:mm format",
+			errInvalidParameter, to)
+	}
+	if stop <= start {
+		return workHours{}, fmt.Errorf("%w: work hours %s must start before they stop",
+			errInvalidParameter, value)
+	}
+	return workHours{start: start, stop: stop}, nil
+}
+
+// clip returns the part of [start, stop) which falls within the window
+// on each of the local days it spans.
+func (w workHours) clip(start, stop time.Time) time.Duration {
+	if w == (workHours{}) {
+		return stop.Sub(start)
+	}
+
+	var clipped time.Duration
+	year, month, day := start.In(time.Local).Date()
+	for ; ; day++ {
+		// The window is computed on the wall clock so that it doesn't move
+		// across daylight saving time changes
+		windowStart := time.Date(year, month, day, 0, 0, int(w.start/time.Second), 0, time.Local)
+		if !windowStart.Before(stop) {
+			break
+		}
+		windowStop := time.Date(year, month, day, 0, 0, int(w.stop/time.Second), 0, time.Local)
+
+		from, to := start, stop
+		if windowStart.After(from) {
+			from = windowStart
+		}
+		if windowStop.Before(to) {
+			to = windowStop
+		}
+		if to.After(from) {
+			clipped += to.Sub(from)
+		}
+	}
+	return clipped
+}
+
+// intervalDuration returns the part of the duration of an interval which falls
+// within the window. The opened interval is considered as stopping now.
+func (w workHours) intervalDuration(ta db.TaggedInterval) time.Duration {
+	return w.clip(ta.Interval.StartTimestamp, ta.Interval.StartTimestamp.Add(intervalDuration(ta)))
+}
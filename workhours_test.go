@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dgsb/tt/internal/db"
+)
+
+func TestWorkHours(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2022, 3, day, hour, 0, 0, 0, time.Local)
+	}
+	hours, err := parseWorkHours("09:00-18:00")
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name     string
+		start    time.Time
+		stop     time.Time
+		expected time.Duration
+	}{
+		{name: "fully inside", start: at(1, 10), stop: at(1, 12), expected: 2 * time.Hour},
+		{name: "fully outside", start: at(1, 19), stop: at(1, 23), expected: 0},
+		{name: "overlapping the end", start: at(1, 17), stop: at(1, 20), expected: time.Hour},
+		{name: "overlapping the start", start: at(1, 7), stop: at(1, 10), expected: time.Hour},
+		{name: "covering the window", start: at(1, 6), stop: at(1, 21), expected: 9 * time.Hour},
+		{name: "overnight", start: at(1, 17), stop: at(2, 10), expected: 2 * time.Hour},
+		{name: "spanning days", start: at(1, 12), stop: at(3, 12), expected: 18 * time.Hour},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, hours.clip(tc.start, tc.stop))
+			require.Equal(t, tc.stop.Sub(tc.start), workHours{}.clip(tc.start, tc.stop))
+		})
+	}
+
+	t.Run("summary", func(t *testing.T) {
+		interval := func(id string, start, stop int, tags ...string) db.TaggedInterval {
+			return db.TaggedInterval{
+				Interval: db.Interval{
+					ID:             id,
+					StartTimestamp: at(1, start),
+					StopTimestamp:  at(1, stop),
+				},
+				Tags: tags,
+			}
+		}
+		tas := []db.TaggedInterval{
+			interval("1", 10, 12, "a"),
+			interval("2", 17, 20, "a", "b"),
+			interval("3", 20, 22, "c"),
+		}
+		summaries, total := summarize(tas, hours)
+		require.Equal(t, 3*time.Hour, total)
+		require.Equal(t, []tagSummary{
+			{Tag: "a", Count: 2, Duration: 3 * time.Hour},
+			{Tag: "b", Count: 1, Duration: time.Hour},
+			{Tag: "c", Count: 1, Duration: 0},
+		}, summaries)
+	})
+
+	t.Run("parse", func(t *testing.T) {
+		hours, err := parseWorkHours("09:00-18:30")
+		require.NoError(t, err)
+		require.Equal(t, workHours{start: 9 * time.Hour, stop: 18*time.Hour + 30*time.Minute},
+			hours)
+		hours, err = parseWorkHours("")
+		require.NoError(t, err)
+		require.Equal(t, workHours{}, hours)
+
+		for _, value := range []string{"09:00", "9am-6pm", "18:00-09:00", "09:00-09:00"} {
+			_, err := parseWorkHours(value)
+			require.ErrorIs(t, err, errInvalidParameter, value)
+		}
+	})
+}